@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS luna4_email_change;
+DROP TABLE IF EXISTS luna4_session;
+DROP TABLE IF EXISTS luna4_audit_log;
+DROP TABLE IF EXISTS luna4_user_service;
+DROP TABLE IF EXISTS luna4_email_auth;
+DROP TABLE IF EXISTS luna4_users;
@@ -0,0 +1,79 @@
+-- Luna4User table
+CREATE TABLE IF NOT EXISTS luna4_users (
+    id TEXT PRIMARY KEY,
+    email TEXT UNIQUE NOT NULL,
+    status TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL,
+    status_reason TEXT NOT NULL DEFAULT '',
+    status_changed_at BIGINT
+);
+
+-- Luna4EmailAuth table
+CREATE TABLE IF NOT EXISTS luna4_email_auth (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    token TEXT NOT NULL,
+    sent_at BIGINT NOT NULL,
+    completed BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (user_id) REFERENCES luna4_users(id) ON DELETE CASCADE
+);
+
+-- Luna4UserService table
+CREATE TABLE IF NOT EXISTS luna4_user_service (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    service TEXT NOT NULL,
+    permission TEXT NOT NULL,
+    expires_at BIGINT,
+    FOREIGN KEY (user_id) REFERENCES luna4_users(id) ON DELETE CASCADE
+);
+
+-- Luna4AuditLog table
+CREATE TABLE IF NOT EXISTS luna4_audit_log (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL
+);
+
+-- Luna4Session table
+CREATE TABLE IF NOT EXISTS luna4_session (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL,
+    revoked_at BIGINT,
+    FOREIGN KEY (user_id) REFERENCES luna4_users(id) ON DELETE CASCADE
+);
+
+-- Luna4EmailChange table
+CREATE TABLE IF NOT EXISTS luna4_email_change (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    old_email TEXT NOT NULL,
+    new_email TEXT NOT NULL,
+    confirm_token TEXT NOT NULL,
+    revert_token TEXT NOT NULL,
+    requested_by TEXT NOT NULL,
+    requested_at BIGINT NOT NULL,
+    confirmed_at BIGINT,
+    reverted_at BIGINT,
+    FOREIGN KEY (user_id) REFERENCES luna4_users(id) ON DELETE CASCADE
+);
+
+-- Indexes for performance
+CREATE INDEX IF NOT EXISTS idx_luna4_users_email ON luna4_users(email);
+CREATE INDEX IF NOT EXISTS idx_luna4_users_status ON luna4_users(status);
+CREATE INDEX IF NOT EXISTS idx_luna4_email_auth_user_id ON luna4_email_auth(user_id);
+CREATE INDEX IF NOT EXISTS idx_luna4_email_auth_token ON luna4_email_auth(token);
+CREATE INDEX IF NOT EXISTS idx_luna4_user_service_user_id ON luna4_user_service(user_id);
+CREATE INDEX IF NOT EXISTS idx_luna4_user_service_service ON luna4_user_service(service);
+CREATE INDEX IF NOT EXISTS idx_luna4_audit_log_user_id ON luna4_audit_log(user_id);
+CREATE INDEX IF NOT EXISTS idx_luna4_audit_log_action ON luna4_audit_log(action);
+CREATE INDEX IF NOT EXISTS idx_luna4_session_user_id ON luna4_session(user_id);
+CREATE INDEX IF NOT EXISTS idx_luna4_email_change_user_id ON luna4_email_change(user_id);
+CREATE INDEX IF NOT EXISTS idx_luna4_email_change_confirm_token ON luna4_email_change(confirm_token);
+CREATE INDEX IF NOT EXISTS idx_luna4_email_change_revert_token ON luna4_email_change(revert_token);
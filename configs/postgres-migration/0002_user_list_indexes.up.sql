@@ -0,0 +1,4 @@
+-- Keyset pagination of the user list orders by the sort column, then by id
+CREATE INDEX IF NOT EXISTS idx_luna4_users_created_at ON luna4_users(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_luna4_users_updated_at ON luna4_users(updated_at, id);
+CREATE INDEX IF NOT EXISTS idx_luna4_user_service_permission ON luna4_user_service(service, permission);
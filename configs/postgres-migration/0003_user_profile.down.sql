@@ -0,0 +1,8 @@
+ALTER TABLE luna4_users
+DROP COLUMN notes;
+
+ALTER TABLE luna4_users
+DROP COLUMN organization;
+
+ALTER TABLE luna4_users
+DROP COLUMN name;
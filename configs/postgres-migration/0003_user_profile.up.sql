@@ -0,0 +1,8 @@
+ALTER TABLE luna4_users
+ADD COLUMN name TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE luna4_users
+ADD COLUMN organization TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE luna4_users
+ADD COLUMN notes TEXT NOT NULL DEFAULT '';
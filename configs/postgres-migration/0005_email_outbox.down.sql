@@ -0,0 +1 @@
+DROP TABLE IF EXISTS luna4_outbox_email;
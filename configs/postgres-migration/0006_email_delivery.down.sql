@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS luna4_email_suppression;
+DROP TABLE IF EXISTS luna4_delivery_event;
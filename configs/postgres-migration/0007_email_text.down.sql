@@ -0,0 +1,5 @@
+ALTER TABLE luna4_outbox_email
+DROP COLUMN unsubscribe;
+
+ALTER TABLE luna4_outbox_email
+DROP COLUMN text;
@@ -0,0 +1,5 @@
+ALTER TABLE luna4_outbox_email
+ADD COLUMN text TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE luna4_outbox_email
+ADD COLUMN unsubscribe TEXT NOT NULL DEFAULT '';
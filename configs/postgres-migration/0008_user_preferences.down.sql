@@ -0,0 +1,2 @@
+ALTER TABLE luna4_users
+DROP COLUMN preferences;
@@ -0,0 +1,2 @@
+ALTER TABLE luna4_users
+ADD COLUMN preferences TEXT NOT NULL DEFAULT '{}';
@@ -0,0 +1,5 @@
+ALTER TABLE luna4_session
+ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE luna4_session
+ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
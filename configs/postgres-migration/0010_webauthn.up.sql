@@ -0,0 +1,29 @@
+-- Luna4WebAuthnCredential table
+CREATE TABLE IF NOT EXISTS luna4_webauthn_credential (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    public_key BYTEA NOT NULL,
+    algorithm INTEGER NOT NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    aaguid TEXT NOT NULL DEFAULT '',
+    transports TEXT NOT NULL DEFAULT '',
+    backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+    backed_up BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at BIGINT NOT NULL,
+    last_used_at BIGINT,
+    FOREIGN KEY (user_id) REFERENCES luna4_users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_luna4_webauthn_credential_user_id ON luna4_webauthn_credential(user_id);
+
+-- Luna4WebAuthnChallenge table
+CREATE TABLE IF NOT EXISTS luna4_webauthn_challenge (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL DEFAULT '',
+    ceremony TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    expires_at BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_luna4_webauthn_challenge_expires_at ON luna4_webauthn_challenge(expires_at);
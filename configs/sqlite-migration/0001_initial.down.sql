@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS luna4_user_service;
+DROP TABLE IF EXISTS luna4_email_auth;
+DROP TABLE IF EXISTS luna4_users;
@@ -36,5 +36,3 @@ CREATE INDEX IF NOT EXISTS idx_luna4_email_auth_user_id ON luna4_email_auth(user
 CREATE INDEX IF NOT EXISTS idx_luna4_email_auth_token ON luna4_email_auth(token);
 CREATE INDEX IF NOT EXISTS idx_luna4_user_service_user_id ON luna4_user_service(user_id);
 CREATE INDEX IF NOT EXISTS idx_luna4_user_service_service ON luna4_user_service(service);
-
-PRAGMA schema_version = 1;
\ No newline at end of file
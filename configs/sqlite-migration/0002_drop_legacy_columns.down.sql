@@ -0,0 +1,5 @@
+ALTER TABLE luna4_users
+ADD COLUMN last_login_at INTEGER;
+
+ALTER TABLE luna4_email_auth
+ADD COLUMN expires_at INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS luna4_audit_log;
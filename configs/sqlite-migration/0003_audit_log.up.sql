@@ -0,0 +1,12 @@
+-- Luna4AuditLog table
+CREATE TABLE IF NOT EXISTS luna4_audit_log (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_luna4_audit_log_user_id ON luna4_audit_log(user_id);
+CREATE INDEX IF NOT EXISTS idx_luna4_audit_log_action ON luna4_audit_log(action);
@@ -0,0 +1,5 @@
+ALTER TABLE luna4_users
+DROP COLUMN status_changed_at;
+
+ALTER TABLE luna4_users
+DROP COLUMN status_reason;
@@ -0,0 +1,5 @@
+ALTER TABLE luna4_users
+ADD COLUMN status_reason TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE luna4_users
+ADD COLUMN status_changed_at INTEGER;
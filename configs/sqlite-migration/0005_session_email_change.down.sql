@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS luna4_email_change;
+DROP TABLE IF EXISTS luna4_session;
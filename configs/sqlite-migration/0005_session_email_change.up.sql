@@ -0,0 +1,29 @@
+-- Luna4Session table
+CREATE TABLE IF NOT EXISTS luna4_session (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL,
+    revoked_at INTEGER,
+    FOREIGN KEY (user_id) REFERENCES luna4_users(id) ON DELETE CASCADE
+);
+
+-- Luna4EmailChange table
+CREATE TABLE IF NOT EXISTS luna4_email_change (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    old_email TEXT NOT NULL,
+    new_email TEXT NOT NULL,
+    confirm_token TEXT NOT NULL,
+    revert_token TEXT NOT NULL,
+    requested_by TEXT NOT NULL,
+    requested_at INTEGER NOT NULL,
+    confirmed_at INTEGER,
+    reverted_at INTEGER,
+    FOREIGN KEY (user_id) REFERENCES luna4_users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_luna4_session_user_id ON luna4_session(user_id);
+CREATE INDEX IF NOT EXISTS idx_luna4_email_change_user_id ON luna4_email_change(user_id);
+CREATE INDEX IF NOT EXISTS idx_luna4_email_change_confirm_token ON luna4_email_change(confirm_token);
+CREATE INDEX IF NOT EXISTS idx_luna4_email_change_revert_token ON luna4_email_change(revert_token);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_luna4_user_service_permission;
+DROP INDEX IF EXISTS idx_luna4_users_updated_at;
+DROP INDEX IF EXISTS idx_luna4_users_created_at;
@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS luna4_user_search_delete;
+DROP TRIGGER IF EXISTS luna4_user_search_update;
+DROP TRIGGER IF EXISTS luna4_user_search_insert;
+DROP TABLE IF EXISTS luna4_user_search;
+
+ALTER TABLE luna4_users
+DROP COLUMN notes;
+
+ALTER TABLE luna4_users
+DROP COLUMN organization;
+
+ALTER TABLE luna4_users
+DROP COLUMN name;
@@ -0,0 +1,38 @@
+ALTER TABLE luna4_users
+ADD COLUMN name TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE luna4_users
+ADD COLUMN organization TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE luna4_users
+ADD COLUMN notes TEXT NOT NULL DEFAULT '';
+
+-- Full-text index of the searchable user fields. The trigram tokenizer matches any
+-- fragment of three or more characters, such as part of an email address.
+CREATE VIRTUAL TABLE IF NOT EXISTS luna4_user_search USING fts5(
+    user_id UNINDEXED,
+    email,
+    name,
+    organization,
+    notes,
+    tokenize = 'trigram'
+);
+
+INSERT INTO luna4_user_search (user_id, email, name, organization, notes)
+SELECT id, email, name, organization, notes FROM luna4_users;
+
+-- Keep the index in step with luna4_users
+CREATE TRIGGER IF NOT EXISTS luna4_user_search_insert AFTER INSERT ON luna4_users BEGIN
+    INSERT INTO luna4_user_search (user_id, email, name, organization, notes)
+    VALUES (new.id, new.email, new.name, new.organization, new.notes);
+END;
+
+CREATE TRIGGER IF NOT EXISTS luna4_user_search_update AFTER UPDATE OF id, email, name, organization, notes ON luna4_users BEGIN
+    DELETE FROM luna4_user_search WHERE user_id = old.id;
+    INSERT INTO luna4_user_search (user_id, email, name, organization, notes)
+    VALUES (new.id, new.email, new.name, new.organization, new.notes);
+END;
+
+CREATE TRIGGER IF NOT EXISTS luna4_user_search_delete AFTER DELETE ON luna4_users BEGIN
+    DELETE FROM luna4_user_search WHERE user_id = old.id;
+END;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS luna4_scim_user;
+DROP TABLE IF EXISTS luna4_scim_token;
@@ -0,0 +1,20 @@
+-- Luna4ScimToken table
+CREATE TABLE IF NOT EXISTS luna4_scim_token (
+    id TEXT PRIMARY KEY,
+    tenant TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at INTEGER NOT NULL,
+    last_used_at INTEGER,
+    revoked_at INTEGER
+);
+
+-- Luna4ScimUser table
+CREATE TABLE IF NOT EXISTS luna4_scim_user (
+    user_id TEXT PRIMARY KEY,
+    tenant TEXT NOT NULL,
+    external_id TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES luna4_users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_luna4_scim_user_tenant ON luna4_scim_user(tenant);
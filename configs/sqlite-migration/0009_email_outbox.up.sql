@@ -0,0 +1,18 @@
+-- Luna4OutboxEmail table
+CREATE TABLE IF NOT EXISTS luna4_outbox_email (
+    id TEXT PRIMARY KEY,
+    idempotency_key TEXT NOT NULL UNIQUE,
+    sender TEXT NOT NULL,
+    recipient TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    html TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at INTEGER NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER,
+    sent_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_luna4_outbox_email_status ON luna4_outbox_email(status, next_attempt_at);
@@ -0,0 +1,20 @@
+-- Luna4DeliveryEvent table
+CREATE TABLE IF NOT EXISTS luna4_delivery_event (
+    id TEXT PRIMARY KEY,
+    email TEXT NOT NULL,
+    type TEXT NOT NULL,
+    sub_type TEXT NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    message_id TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_luna4_delivery_event_email ON luna4_delivery_event(email, created_at);
+
+-- Luna4EmailSuppression table
+CREATE TABLE IF NOT EXISTS luna4_email_suppression (
+    email TEXT PRIMARY KEY,
+    reason TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+);
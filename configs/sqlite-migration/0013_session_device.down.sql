@@ -0,0 +1,5 @@
+ALTER TABLE luna4_session
+DROP COLUMN ip_address;
+
+ALTER TABLE luna4_session
+DROP COLUMN user_agent;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS luna4_webauthn_challenge;
+DROP TABLE IF EXISTS luna4_webauthn_credential;
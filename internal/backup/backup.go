@@ -0,0 +1,291 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	filePrefix    = "airlock-"
+	fileExtension = ".db"
+	timeLayout    = "20060102T150405.000Z"
+)
+
+// Source writes a consistent copy of a live database to a file
+type Source interface {
+	Backup(ctx context.Context, path string) error
+}
+
+// Backup is a backup file in the backup directory
+type Backup struct {
+	Name       string `json:"name"`
+	Path       string `json:"-"`
+	Size       int64  `json:"size"`
+	CreatedAt  int64  `json:"createdAt"`
+	Compressed bool   `json:"compressed"`
+	Encrypted  bool   `json:"encrypted"`
+}
+
+// Manager takes scheduled and on-demand backups and prunes old ones
+type Manager struct {
+	source Source
+	config Config
+
+	// mu keeps scheduled and on-demand backups from running at the same time
+	mu sync.Mutex
+}
+
+func NewManager(source Source, config Config) *Manager {
+	return &Manager{
+		source: source,
+		config: config,
+	}
+}
+
+// Start takes a backup every interval until the context is cancelled
+func (m *Manager) Start(ctx context.Context) {
+	if m.config.Interval <= 0 {
+		log.Printf("BackupManager: Scheduled backups disabled")
+		return
+	}
+
+	log.Printf("BackupManager: Backing up to %s every %s", m.config.Dir, m.config.Interval)
+	go func() {
+		ticker := time.NewTicker(m.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := m.Run(ctx); err != nil {
+					log.Printf("BackupManager: Scheduled backup failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Run takes a backup now, then removes backups past the retention limits
+func (m *Manager) Run(ctx context.Context) (*Backup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.config.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory %s: %w", m.config.Dir, err)
+	}
+
+	now := time.Now().UTC()
+	name := filePrefix + now.Format(timeLayout) + fileExtension
+	snapshot := filepath.Join(m.config.Dir, "."+name+".tmp")
+	defer os.Remove(snapshot)
+
+	if err := m.source.Backup(ctx, snapshot); err != nil {
+		return nil, fmt.Errorf("failed to snapshot database: %w", err)
+	}
+
+	if m.config.Compress {
+		name += compressedExtension
+	}
+	if m.config.EncryptionKey != nil {
+		name += encryptedExtension
+	}
+
+	path := filepath.Join(m.config.Dir, name)
+	if err := m.encode(snapshot, path); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat backup: %w", err)
+	}
+
+	backup := &Backup{
+		Name:       name,
+		Path:       path,
+		Size:       info.Size(),
+		CreatedAt:  now.UnixMilli(),
+		Compressed: m.config.Compress,
+		Encrypted:  m.config.EncryptionKey != nil,
+	}
+	log.Printf("BackupManager: Wrote backup %s (%d bytes)", name, backup.Size)
+
+	if err := m.prune(); err != nil {
+		log.Printf("BackupManager: Failed to prune old backups: %v", err)
+	}
+
+	return backup, nil
+}
+
+// encode compresses and encrypts the snapshot as configured
+func (m *Manager) encode(snapshot, path string) error {
+	in, err := os.Open(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer out.Close()
+
+	var w io.WriteCloser = nopWriteCloser{out}
+	if m.config.EncryptionKey != nil {
+		w, err = newEncryptWriter(w, m.config.EncryptionKey)
+		if err != nil {
+			return err
+		}
+	}
+	if m.config.Compress {
+		w = newCompressWriter(w)
+	}
+
+	if _, err := io.Copy(w, in); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finish backup: %w", err)
+	}
+	return out.Sync()
+}
+
+// prune deletes backups beyond the newest Keep and backups older than Retention.
+// The newest backup is always kept.
+func (m *Manager) prune() error {
+	backups, err := List(m.config.Dir)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-m.config.Retention).UnixMilli()
+	for i, backup := range backups {
+		if i == 0 {
+			continue
+		}
+		tooMany := m.config.Keep > 0 && i >= m.config.Keep
+		tooOld := m.config.Retention > 0 && backup.CreatedAt < cutoff
+		if !tooMany && !tooOld {
+			continue
+		}
+
+		if err := os.Remove(backup.Path); err != nil {
+			return fmt.Errorf("failed to remove backup %s: %w", backup.Name, err)
+		}
+		log.Printf("BackupManager: Removed old backup %s", backup.Name)
+	}
+	return nil
+}
+
+// List returns the backups in the manager's directory, newest first
+func (m *Manager) List() ([]Backup, error) {
+	return List(m.config.Dir)
+}
+
+// List returns the backups in dir, newest first
+func List(dir string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read backup directory %s: %w", dir, err)
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		backup, ok := parseName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backup.Path = filepath.Join(dir, entry.Name())
+		backup.Size = info.Size()
+		backups = append(backups, backup)
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt > backups[j].CreatedAt })
+	return backups, nil
+}
+
+// FindAt returns the newest backup in dir taken at or before the given time
+func FindAt(dir string, at time.Time) (*Backup, error) {
+	backups, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, backup := range backups {
+		if backup.CreatedAt <= at.UnixMilli() {
+			return &backup, nil
+		}
+	}
+	return nil, fmt.Errorf("no backup in %s taken at or before %s", dir, at.Format(time.RFC3339))
+}
+
+// parseName reads the time and encoding of a backup from its file name
+func parseName(name string) (Backup, bool) {
+	backup := Backup{Name: name}
+	rest, ok := strings.CutPrefix(name, filePrefix)
+	if !ok {
+		return backup, false
+	}
+
+	if trimmed, ok := strings.CutSuffix(rest, encryptedExtension); ok {
+		rest, backup.Encrypted = trimmed, true
+	}
+	if trimmed, ok := strings.CutSuffix(rest, compressedExtension); ok {
+		rest, backup.Compressed = trimmed, true
+	}
+	rest, ok = strings.CutSuffix(rest, fileExtension)
+	if !ok {
+		return backup, false
+	}
+
+	createdAt, err := time.Parse(timeLayout, rest)
+	if err != nil {
+		return backup, false
+	}
+	backup.CreatedAt = createdAt.UnixMilli()
+	return backup, true
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// Inspect returns the backup stored at path
+func Inspect(path string) (*Backup, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat backup: %w", err)
+	}
+
+	backup, ok := parseName(filepath.Base(path))
+	if !ok {
+		return nil, fmt.Errorf("%s is not an airlock backup", path)
+	}
+	backup.Path = path
+	backup.Size = info.Size()
+	return &backup, nil
+}
@@ -0,0 +1,148 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fileSource stands in for a database, copying fixed content on every backup
+type fileSource struct {
+	content []byte
+}
+
+func (s fileSource) Backup(ctx context.Context, path string) error {
+	return os.WriteFile(path, s.content, 0600)
+}
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+func TestRunAndDecode(t *testing.T) {
+	content := bytes.Repeat([]byte("SQLite format 3\x00 airlock backup content "), 4096)
+	key := testKey(t)
+
+	tests := []struct {
+		name     string
+		compress bool
+		key      []byte
+	}{
+		{"plain", false, nil},
+		{"compressed", true, nil},
+		{"encrypted", false, key},
+		{"compressed and encrypted", true, key},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			manager := NewManager(fileSource{content}, Config{Dir: dir, Compress: tt.compress, EncryptionKey: tt.key})
+
+			backup, err := manager.Run(context.Background())
+			if err != nil {
+				t.Fatalf("backup failed: %v", err)
+			}
+			if backup.Compressed != tt.compress || backup.Encrypted != (tt.key != nil) {
+				t.Errorf("backup %s is compressed %v, encrypted %v", backup.Name, backup.Compressed, backup.Encrypted)
+			}
+
+			stored, err := os.ReadFile(backup.Path)
+			if err != nil {
+				t.Fatalf("failed to read backup: %v", err)
+			}
+			if (tt.compress || tt.key != nil) == bytes.Equal(stored, content) {
+				t.Errorf("backup file holds the content as is: %v", bytes.Equal(stored, content))
+			}
+
+			// Decoding the backup found by name gives the snapshot back
+			inspected, err := Inspect(backup.Path)
+			if err != nil {
+				t.Fatalf("failed to inspect backup: %v", err)
+			}
+			restored := filepath.Join(t.TempDir(), "restored.db")
+			if err := Decode(inspected, tt.key, restored); err != nil {
+				t.Fatalf("failed to decode backup: %v", err)
+			}
+			decoded, err := os.ReadFile(restored)
+			if err != nil {
+				t.Fatalf("failed to read restored file: %v", err)
+			}
+			if !bytes.Equal(decoded, content) {
+				t.Error("decoded backup differs from the snapshot")
+			}
+		})
+	}
+}
+
+func TestDecodeRejectsWrongKey(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewManager(fileSource{[]byte("database")}, Config{Dir: dir, Compress: true, EncryptionKey: testKey(t)})
+	backup, err := manager.Run(context.Background())
+	if err != nil {
+		t.Fatalf("backup failed: %v", err)
+	}
+
+	restored := filepath.Join(t.TempDir(), "restored.db")
+	if err := Decode(backup, testKey(t), restored); err == nil {
+		t.Error("decoding with another key succeeded")
+	}
+	if err := Decode(backup, nil, restored); err == nil {
+		t.Error("decoding an encrypted backup without a key succeeded")
+	}
+
+	// A flipped byte fails authentication
+	stored, err := os.ReadFile(backup.Path)
+	if err != nil {
+		t.Fatalf("failed to read backup: %v", err)
+	}
+	stored[len(stored)-1] ^= 0xff
+	if err := os.WriteFile(backup.Path, stored, 0600); err != nil {
+		t.Fatalf("failed to tamper with backup: %v", err)
+	}
+	if err := Decode(backup, manager.config.EncryptionKey, restored); err == nil {
+		t.Error("decoding a tampered backup succeeded")
+	}
+}
+
+func TestPruneAndFindAt(t *testing.T) {
+	dir := t.TempDir()
+	manager := NewManager(fileSource{[]byte("database")}, Config{Dir: dir, Keep: 2})
+
+	var taken []*Backup
+	for range 3 {
+		backup, err := manager.Run(context.Background())
+		if err != nil {
+			t.Fatalf("backup failed: %v", err)
+		}
+		taken = append(taken, backup)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	backups, err := manager.List()
+	if err != nil {
+		t.Fatalf("failed to list backups: %v", err)
+	}
+	if len(backups) != 2 || backups[0].Name != taken[2].Name || backups[1].Name != taken[1].Name {
+		t.Fatalf("kept %v, want the two newest", backups)
+	}
+
+	found, err := FindAt(dir, time.UnixMilli(taken[1].CreatedAt).Add(time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to find backup: %v", err)
+	}
+	if found.Name != taken[1].Name {
+		t.Errorf("found %s, want %s", found.Name, taken[1].Name)
+	}
+	if _, err := FindAt(dir, time.UnixMilli(taken[0].CreatedAt)); err == nil {
+		t.Error("found a backup older than every kept one")
+	}
+}
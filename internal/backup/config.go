@@ -0,0 +1,64 @@
+package backup
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config controls where backups are written, how often and how long they are kept
+type Config struct {
+	Dir           string
+	Interval      time.Duration
+	Keep          int
+	Retention     time.Duration
+	Compress      bool
+	EncryptionKey []byte
+}
+
+// GetConfig reads the backup settings from the environment
+func GetConfig() (Config, error) {
+	config := Config{
+		Dir:       os.Getenv("BACKUP_DIR"),
+		Interval:  time.Duration(getEnvInt("BACKUP_INTERVAL", 86400)) * time.Second,       // Default daily
+		Keep:      getEnvInt("BACKUP_KEEP", 7),                                            // Default 7 backups
+		Retention: time.Duration(getEnvInt("BACKUP_RETENTION_DAYS", 30)) * 24 * time.Hour, // Default 30 days
+		Compress:  os.Getenv("BACKUP_COMPRESS") != "false",                                // Default on
+	}
+	if config.Dir == "" {
+		config.Dir = "data/backups" // Default backup directory
+	}
+
+	key, err := GetEncryptionKey()
+	if err != nil {
+		return config, err
+	}
+	config.EncryptionKey = key
+
+	return config, nil
+}
+
+// GetEncryptionKey decodes BACKUP_ENCRYPTION_KEY, a base64 encoded 32 byte key.
+// Backups are not encrypted when it is unset.
+func GetEncryptionKey() ([]byte, error) {
+	encoded := os.Getenv("BACKUP_ENCRYPTION_KEY")
+	if encoded == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("BACKUP_ENCRYPTION_KEY must be 32 bytes encoded as base64")
+	}
+	return key, nil
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
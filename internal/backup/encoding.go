@@ -0,0 +1,227 @@
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	compressedExtension = ".gz"
+	encryptedExtension  = ".enc"
+
+	// Encrypted backups start with the magic and a random salt, followed by records of
+	// a flag byte, a big-endian length and an AES-256-GCM sealed chunk. The flag marks
+	// the final record so a truncated file is rejected.
+	encryptionMagic = "AIRLOCK-BACKUP-1"
+	saltSize        = 32
+	chunkSize       = 64 * 1024
+	flagMore        = 0
+	flagFinal       = 1
+)
+
+var ErrBackupCorrupt = errors.New("backup is corrupt or the encryption key is wrong")
+
+type compressWriter struct {
+	*gzip.Writer
+	next io.WriteCloser
+}
+
+func newCompressWriter(next io.WriteCloser) io.WriteCloser {
+	return &compressWriter{Writer: gzip.NewWriter(next), next: next}
+}
+
+func (w *compressWriter) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		return err
+	}
+	return w.next.Close()
+}
+
+type encryptWriter struct {
+	next    io.WriteCloser
+	aead    cipher.AEAD
+	buf     []byte
+	counter uint64
+}
+
+func newEncryptWriter(next io.WriteCloser, key []byte) (io.WriteCloser, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	aead, err := fileCipher(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := next.Write(append([]byte(encryptionMagic), salt...)); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{next: next, aead: aead, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(chunkSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+
+		if len(w.buf) == chunkSize {
+			if err := w.seal(flagMore); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (w *encryptWriter) Close() error {
+	if err := w.seal(flagFinal); err != nil {
+		return err
+	}
+	return w.next.Close()
+}
+
+func (w *encryptWriter) seal(flag byte) error {
+	sealed := w.aead.Seal(nil, chunkNonce(w.counter), w.buf, []byte{flag})
+	w.counter++
+	w.buf = w.buf[:0]
+
+	header := make([]byte, 5)
+	header[0] = flag
+	binary.BigEndian.PutUint32(header[1:], uint32(len(sealed)))
+	if _, err := w.next.Write(header); err != nil {
+		return err
+	}
+	_, err := w.next.Write(sealed)
+	return err
+}
+
+type decryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	buf     []byte
+	counter uint64
+	final   bool
+}
+
+func newDecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(encryptionMagic)+saltSize)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(encryptionMagic)]) != encryptionMagic {
+		return nil, ErrBackupCorrupt
+	}
+
+	aead, err := fileCipher(key, header[len(encryptionMagic):])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{r: br, aead: aead}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.final {
+			// Nothing may follow the final record
+			if _, err := d.r.ReadByte(); err != io.EOF {
+				return 0, ErrBackupCorrupt
+			}
+			return 0, io.EOF
+		}
+
+		header := make([]byte, 5)
+		if _, err := io.ReadFull(d.r, header); err != nil {
+			return 0, ErrBackupCorrupt
+		}
+		flag := header[0]
+		size := binary.BigEndian.Uint32(header[1:])
+		if size > chunkSize+uint32(d.aead.Overhead()) {
+			return 0, ErrBackupCorrupt
+		}
+
+		sealed := make([]byte, size)
+		if _, err := io.ReadFull(d.r, sealed); err != nil {
+			return 0, ErrBackupCorrupt
+		}
+
+		plain, err := d.aead.Open(nil, chunkNonce(d.counter), sealed, []byte{flag})
+		if err != nil {
+			return 0, ErrBackupCorrupt
+		}
+		d.counter++
+		d.buf = plain
+		d.final = flag == flagFinal
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// fileCipher derives a per-file key from the configured key and the file's salt
+func fileCipher(key, salt []byte) (cipher.AEAD, error) {
+	fileKey := sha256.Sum256(append(append([]byte{}, key...), salt...))
+	block, err := aes.NewCipher(fileKey[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(counter uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], counter)
+	return nonce
+}
+
+// Decode writes the plain SQLite database held in a backup file to dst
+func Decode(backup *Backup, key []byte, dst string) error {
+	in, err := os.Open(backup.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer in.Close()
+
+	var r io.Reader = in
+	if backup.Encrypted {
+		if key == nil {
+			return fmt.Errorf("backup %s is encrypted but BACKUP_ENCRYPTION_KEY is not set", backup.Name)
+		}
+		r, err = newDecryptReader(r, key)
+		if err != nil {
+			return err
+		}
+	}
+	if backup.Compressed {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to decompress backup: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, r); err != nil {
+		return fmt.Errorf("failed to decode backup: %w", err)
+	}
+	return out.Sync()
+}
@@ -0,0 +1,171 @@
+package brand
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultID names the built-in Luna4 profile used when a request names no app
+const DefaultID = "luna4"
+
+var (
+	profileID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+	hexColor  = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+)
+
+// Profile is how airlock presents itself in emails and on the /app pages for one
+// Luna4Service or client app
+type Profile struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	LogoURL        string `json:"logoUrl,omitempty"`
+	PrimaryColor   string `json:"primaryColor"`
+	SecondaryColor string `json:"secondaryColor"`
+	Sender         string `json:"sender"`
+	SupportURL     string `json:"supportUrl,omitempty"`
+}
+
+// Config locates the brand profiles and sets up the default profile
+type Config struct {
+	ProfilesPath string
+	Default      Profile
+}
+
+// GetConfig reads the brand settings from the environment
+func GetConfig() Config {
+	sender := os.Getenv("EMAIL_AUTH_SENDER")
+	if sender == "" {
+		sender = "noreply@luna4.me"
+	}
+
+	return Config{
+		ProfilesPath: os.Getenv("BRAND_PROFILES_PATH"), // Default only the Luna4 profile
+		Default: Profile{
+			ID:             DefaultID,
+			Name:           "Luna4",
+			PrimaryColor:   "#667eea",
+			SecondaryColor: "#764ba2",
+			Sender:         sender,
+			SupportURL:     os.Getenv("BRAND_SUPPORT_URL"),
+		},
+	}
+}
+
+// Registry holds the brand profiles by ID
+type Registry struct {
+	profiles map[string]*Profile
+	fallback *Profile
+}
+
+// NewRegistry loads the profiles from the JSON array at ProfilesPath. Fields a profile
+// leaves empty are taken from the default profile.
+func NewRegistry(config Config) (*Registry, error) {
+	fallback := config.Default
+	registry := &Registry{
+		profiles: map[string]*Profile{fallback.ID: &fallback},
+		fallback: &fallback,
+	}
+	if config.ProfilesPath == "" {
+		return registry, nil
+	}
+
+	data, err := os.ReadFile(config.ProfilesPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read brand profiles: %w", err)
+	}
+	var profiles []Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse brand profiles: %w", err)
+	}
+
+	for _, profile := range profiles {
+		profile.ID = strings.ToLower(strings.TrimSpace(profile.ID))
+		if _, ok := registry.profiles[profile.ID]; ok {
+			return nil, fmt.Errorf("duplicate brand profile: %s", profile.ID)
+		}
+		if err := profile.validate(); err != nil {
+			return nil, fmt.Errorf("invalid brand profile %s: %w", profile.ID, err)
+		}
+		profile.inherit(&fallback)
+		registry.profiles[profile.ID] = &profile
+	}
+	return registry, nil
+}
+
+// Lookup returns the profile of an app, matching the ID case-insensitively so a
+// Luna4Service such as PRUNK finds the profile prunk
+func (r *Registry) Lookup(id string) (*Profile, bool) {
+	profile, ok := r.profiles[strings.ToLower(strings.TrimSpace(id))]
+	return profile, ok
+}
+
+// Get returns the profile of an app, or the default profile when there is none
+func (r *Registry) Get(id string) *Profile {
+	if profile, ok := r.Lookup(id); ok {
+		return profile
+	}
+	return r.fallback
+}
+
+// Default returns the profile used when a request names no app
+func (r *Registry) Default() *Profile {
+	return r.fallback
+}
+
+// IsDefault reports whether the profile is the built-in one, which links in emails do not
+// need to name
+func (p *Profile) IsDefault() bool {
+	return p.ID == DefaultID
+}
+
+func (p *Profile) validate() error {
+	if !profileID.MatchString(p.ID) {
+		return fmt.Errorf("id must be lowercase letters, digits, - or _")
+	}
+	for _, color := range []string{p.PrimaryColor, p.SecondaryColor} {
+		if color != "" && !hexColor.MatchString(color) {
+			return fmt.Errorf("color %q is not a #rgb or #rrggbb hex color", color)
+		}
+	}
+	if p.LogoURL != "" && !hasScheme(p.LogoURL, "https") {
+		return fmt.Errorf("logo %q must be an https URL", p.LogoURL)
+	}
+	if p.SupportURL != "" && !hasScheme(p.SupportURL, "https", "mailto") {
+		return fmt.Errorf("support link %q must be an https or mailto URL", p.SupportURL)
+	}
+	if p.Sender != "" {
+		if _, err := mail.ParseAddress(p.Sender); err != nil {
+			return fmt.Errorf("invalid sender %q: %w", p.Sender, err)
+		}
+	}
+	return nil
+}
+
+func hasScheme(link string, schemes ...string) bool {
+	parsed, err := url.Parse(link)
+	return err == nil && slices.Contains(schemes, parsed.Scheme)
+}
+
+func (p *Profile) inherit(fallback *Profile) {
+	if p.Name == "" {
+		p.Name = fallback.Name
+	}
+	if p.PrimaryColor == "" {
+		p.PrimaryColor = fallback.PrimaryColor
+	}
+	if p.SecondaryColor == "" {
+		p.SecondaryColor = fallback.SecondaryColor
+	}
+	if p.Sender == "" {
+		p.Sender = fallback.Sender
+	}
+	if p.SupportURL == "" {
+		p.SupportURL = fallback.SupportURL
+	}
+}
@@ -0,0 +1,90 @@
+package brand
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testDefault = Profile{
+	ID:             DefaultID,
+	Name:           "Luna4",
+	PrimaryColor:   "#667eea",
+	SecondaryColor: "#764ba2",
+	Sender:         "noreply@luna4.me",
+	SupportURL:     "mailto:support@luna4.me",
+}
+
+// writeProfiles writes the JSON to a profiles file and returns its config
+func writeProfiles(t *testing.T, profiles string) Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "brands.json")
+	if err := os.WriteFile(path, []byte(profiles), 0600); err != nil {
+		t.Fatalf("failed to write profiles: %v", err)
+	}
+	return Config{ProfilesPath: path, Default: testDefault}
+}
+
+func TestNewRegistry(t *testing.T) {
+	registry, err := NewRegistry(writeProfiles(t, `[
+		{"id": " Prunk ", "name": "Prunk", "primaryColor": "#0a0", "logoUrl": "https://prunk.example.com/logo.png", "sender": "Prunk <hello@prunk.example.com>"},
+		{"id": "notes_app", "supportUrl": "https://notes.example.com/help"}
+	]`))
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+
+	prunk, ok := registry.Lookup("PRUNK")
+	if !ok {
+		t.Fatal("lookup by Luna4Service name found no profile")
+	}
+	if prunk.ID != "prunk" || prunk.Name != "Prunk" || prunk.PrimaryColor != "#0a0" || prunk.Sender != "Prunk <hello@prunk.example.com>" {
+		t.Errorf("prunk profile is %+v", prunk)
+	}
+	// Fields a profile leaves empty come from the default
+	if prunk.SecondaryColor != testDefault.SecondaryColor || prunk.SupportURL != testDefault.SupportURL {
+		t.Errorf("prunk did not inherit the default colors and support link: %+v", prunk)
+	}
+	if notes := registry.Get("notes_app"); notes.Name != "Luna4" || notes.SupportURL != "https://notes.example.com/help" || notes.IsDefault() {
+		t.Errorf("notes profile is %+v", notes)
+	}
+
+	if _, ok := registry.Lookup("unknown"); ok {
+		t.Errorf("lookup of an unknown app found a profile")
+	}
+	if got := registry.Get("unknown"); got != registry.Default() || !got.IsDefault() {
+		t.Errorf("unknown app got %+v, want the default profile", got)
+	}
+
+	registry, err = NewRegistry(Config{Default: testDefault})
+	if err != nil {
+		t.Fatalf("NewRegistry without profiles failed: %v", err)
+	}
+	if got, ok := registry.Lookup(DefaultID); !ok || *got != testDefault {
+		t.Errorf("default profile is %+v", got)
+	}
+}
+
+func TestNewRegistryRejects(t *testing.T) {
+	for profiles, want := range map[string]string{
+		`[{"id": "Has Space"}]`:                                    "id must be",
+		`[{"id": "prunk", "primaryColor": "green"}]`:               "not a #rgb",
+		`[{"id": "prunk", "secondaryColor": "#12345"}]`:            "not a #rgb",
+		`[{"id": "prunk", "logoUrl": "http://example.com/l.png"}]`: "https URL",
+		`[{"id": "prunk", "supportUrl": "javascript:alert(1)"}]`:   "https or mailto",
+		`[{"id": "prunk", "sender": "not an address"}]`:            "invalid sender",
+		`[{"id": "prunk"}, {"id": "PRUNK"}]`:                       "duplicate brand profile",
+		`[{"id": "luna4"}]`:                                        "duplicate brand profile",
+		`{"id": "prunk"}`:                                          "failed to parse",
+	} {
+		_, err := NewRegistry(writeProfiles(t, profiles))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("loading %s returned %v, want %q", profiles, err, want)
+		}
+	}
+
+	if _, err := NewRegistry(Config{ProfilesPath: filepath.Join(t.TempDir(), "missing.json"), Default: testDefault}); err == nil {
+		t.Errorf("missing profiles file was loaded")
+	}
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/service"
+	"github.com/luna4dev/airlock/internal/util"
+)
+
+type AccountHandler struct {
+	accountService *service.AccountService
+}
+
+func NewAccountHandler(accountService *service.AccountService) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+	}
+}
+
+// ChangeEmailRequest represents the request payload for changing the signed in user's email
+type ChangeEmailRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// EmailChangeTokenRequest represents the request payload for confirming or reverting an email change
+type EmailChangeTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ChangeEmailHandler starts an email change for the signed in user
+func (h *AccountHandler) ChangeEmailHandler(c *gin.Context) {
+	user := c.MustGet(contextKeyUser).(*model.Luna4User)
+
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON or missing email field"})
+		return
+	}
+
+	email := strings.TrimSpace(req.Email)
+	if !util.IsValidEmail(email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email format"})
+		return
+	}
+
+	if email == user.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New email is the same as the current one"})
+		return
+	}
+
+	ctx := context.Background()
+	latestChange, err := h.accountService.GetLatestEmailChange(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database"})
+		return
+	}
+
+	if latestChange != nil {
+		debounceMillis := int64(getEmailAuthDebounce() * 1000)
+		timeSinceLastRequest := time.Now().UnixMilli() - latestChange.RequestedAt
+
+		if timeSinceLastRequest < debounceMillis {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":               "Email change request too recent",
+				"retry_after_seconds": (debounceMillis - timeSinceLastRequest) / 1000,
+			})
+			return
+		}
+	}
+
+	_, err = h.accountService.StartEmailChange(ctx, user, email, requestLocale(c, user, ""), user.ID)
+	if errors.Is(err, service.ErrEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email address is already in use"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start email change"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"email":   email,
+		"message": "Confirmation email sent to the new address",
+	})
+}
+
+// ConfirmEmailChangeHandler applies an email change using the link sent to the new address
+func (h *AccountHandler) ConfirmEmailChangeHandler(c *gin.Context) {
+	var req EmailChangeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token is required"})
+		return
+	}
+
+	change, err := h.accountService.ConfirmEmailChange(context.Background(), req.Token)
+	if err != nil {
+		respondEmailChangeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"email":   change.NewEmail,
+		"message": "Email address changed successfully. Please sign in again.",
+	})
+}
+
+// RevertEmailChangeHandler cancels or undoes an email change using the link sent to the old address
+func (h *AccountHandler) RevertEmailChangeHandler(c *gin.Context) {
+	var req EmailChangeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token is required"})
+		return
+	}
+
+	change, err := h.accountService.RevertEmailChange(context.Background(), req.Token)
+	if err != nil {
+		respondEmailChangeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"email":   change.OldEmail,
+		"message": "Email change reverted. All sessions have been signed out.",
+	})
+}
+
+// GetPreferencesHandler returns the signed in user's preferences
+func (h *AccountHandler) GetPreferencesHandler(c *gin.Context) {
+	user := c.MustGet(contextKeyUser).(*model.Luna4User)
+	c.JSON(http.StatusOK, gin.H{"preferences": user.Preferences})
+}
+
+// UpdatePreferencesHandler replaces the signed in user's preferences
+func (h *AccountHandler) UpdatePreferencesHandler(c *gin.Context) {
+	user := c.MustGet(contextKeyUser).(*model.Luna4User)
+
+	var preferences model.UserPreferences
+	if err := c.ShouldBindJSON(&preferences); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	err := h.accountService.UpdateUserPreferences(context.Background(), user.ID, preferences, user.ID)
+	if errors.Is(err, service.ErrUnsupportedLanguage) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported language"})
+		return
+	}
+	if errors.Is(err, service.ErrInvalidNotificationOptOut) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Security notices about email changes and suspension cannot be turned off"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Preferences updated"})
+}
+
+// UnsubscribeHandler opts the user out of the notices named by the token in an unsubscribe
+// link. Mail clients POST here directly for one-click unsubscribe (RFC 8058).
+func (h *AccountHandler) UnsubscribeHandler(c *gin.Context) {
+	notification, err := h.accountService.OptOutOfNotification(context.Background(), c.Query("token"))
+	if errors.Is(err, service.ErrInvalidUnsubscribeToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid unsubscribe link"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notification": notification,
+		"message":      "Unsubscribed",
+	})
+}
+
+func respondEmailChangeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrEmailChangeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email change not found"})
+	case errors.Is(err, service.ErrEmailChangeExpired):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Email change link has expired"})
+	case errors.Is(err, service.ErrEmailChangeUsed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email change link is no longer valid"})
+	case errors.Is(err, service.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "Email address is already in use"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update email change"})
+	}
+}
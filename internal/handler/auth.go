@@ -2,14 +2,16 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
 	"os"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/brand"
+	"github.com/luna4dev/airlock/internal/locale"
 	"github.com/luna4dev/airlock/internal/model"
 	"github.com/luna4dev/airlock/internal/service"
 	"github.com/luna4dev/airlock/internal/util"
@@ -18,12 +20,14 @@ import (
 )
 
 type AuthHandler struct {
-	sqliteService *service.SQLiteService
+	accountService *service.AccountService
+	brands         *brand.Registry
 }
 
-func NewAuthHandler(sqliteService *service.SQLiteService) *AuthHandler {
+func NewAuthHandler(accountService *service.AccountService, brands *brand.Registry) *AuthHandler {
 	return &AuthHandler{
-		sqliteService: sqliteService,
+		accountService: accountService,
+		brands:         brands,
 	}
 }
 
@@ -31,6 +35,8 @@ func NewAuthHandler(sqliteService *service.SQLiteService) *AuthHandler {
 type AuthEmailRequest struct {
 	Email    string `json:"email" binding:"required"`
 	Redirect string `json:"redirect"`
+	Language string `json:"language"`
+	App      string `json:"app"` // Brand profile of the app the user is signing in to
 }
 
 // AuthEmailHandler handles the initial email authentication request
@@ -49,13 +55,22 @@ func (h *AuthHandler) AuthEmailHandler(c *gin.Context) {
 		return
 	}
 
-	if !isValidEmail(email) {
+	if !util.IsValidEmail(email) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email format"})
 		return
 	}
 
+	profile := h.brands.Default()
+	if req.App != "" {
+		var ok bool
+		if profile, ok = h.brands.Lookup(req.App); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown app"})
+			return
+		}
+	}
+
 	ctx := context.Background()
-	user, err := h.sqliteService.GetUserByEmail(ctx, email)
+	user, err := h.accountService.GetUserByEmail(ctx, email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get User"})
 		return
@@ -66,7 +81,12 @@ func (h *AuthHandler) AuthEmailHandler(c *gin.Context) {
 		return
 	}
 
-	latestEmailAuth, _ := h.sqliteService.GetLatestEmailAuth(ctx, user.ID)
+	if !user.Status.CanAuthenticate() {
+		rejectInactiveUser(c, user)
+		return
+	}
+
+	latestEmailAuth, _ := h.accountService.GetLatestEmailAuth(ctx, user.ID)
 	if latestEmailAuth != nil {
 		debounceSeconds := getEmailAuthDebounce()
 		timeSinceLastSent := time.Now().UnixMilli() - latestEmailAuth.SentAt
@@ -82,55 +102,21 @@ func (h *AuthHandler) AuthEmailHandler(c *gin.Context) {
 		}
 	}
 
-	// Generate new email token, tokenHash
-	token, tokenHash, err := util.GenerateEmailToken()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate authentication token"})
-		return
-	}
-
-	// Update user's email auth in database
-	// Create new user
-	emailAuthID := uuid.New().String()
-	emailAuth := &model.Luna4EmailAuth{
-		ID:        emailAuthID,
-		UserID:    user.ID,
-		Token:     tokenHash,
-		SentAt:    time.Now().UnixMilli(),
-		Completed: false,
-	}
-
-	err = h.sqliteService.CreateEmailAuth(ctx, emailAuth)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user authentication"})
-		return
-	}
-
-	// Send authentication email
-	emailService, err := service.NewEmailService()
+	// Record the email auth and queue the sign-in link; the outbox worker delivers it
+	lang := requestLocale(c, user, req.Language)
+	_, err = h.accountService.RequestEmailAuth(ctx, user, redirect, lang, profile, time.Duration(getEmailAuthExpiry())*time.Second)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize email service"})
-		return
-	}
-
-	err = emailService.SendAuthEmail(ctx, email, token, redirect)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send authentication email"})
+		log.Printf("AuthEmailHandler: Failed to queue authentication email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue authentication email"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"email":   email,
-		"message": "Authentication email sent successfully",
+		"message": "Authentication email queued for delivery",
 	})
 }
 
-// isValidEmail validates if the provided email string is in a valid format
-func isValidEmail(email string) bool {
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-	return emailRegex.MatchString(email)
-}
-
 // AuthEmailVerifyHandler handles email verification and token validation
 func (h *AuthHandler) AuthEmailVerifyHandler(c *gin.Context) {
 	token := c.Query("token")
@@ -147,13 +133,13 @@ func (h *AuthHandler) AuthEmailVerifyHandler(c *gin.Context) {
 	}
 
 	email = strings.TrimSpace(email)
-	if !isValidEmail(email) {
+	if !util.IsValidEmail(email) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email format"})
 		return
 	}
 
 	ctx := context.Background()
-	user, err := h.sqliteService.GetUserByEmail(ctx, email)
+	user, err := h.accountService.GetUserByEmail(ctx, email)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database"})
 		return
@@ -164,9 +150,16 @@ func (h *AuthHandler) AuthEmailVerifyHandler(c *gin.Context) {
 		return
 	}
 
-	latestEmailAuth, err := h.sqliteService.GetLatestEmailAuth(ctx, user.ID)
-	if err != nil {
+	// Links issued before a suspension or lock must not be redeemable afterwards
+	if !user.Status.CanAuthenticate() {
+		rejectInactiveUser(c, user)
+		return
+	}
 
+	latestEmailAuth, err := h.accountService.GetLatestEmailAuth(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database"})
+		return
 	}
 
 	// Check if email auth exists
@@ -197,25 +190,35 @@ func (h *AuthHandler) AuthEmailVerifyHandler(c *gin.Context) {
 		return
 	}
 
-	// Complete email authentication and update lastLoginAt
-	err = h.sqliteService.MarkEmailAuthCompleted(ctx, latestEmailAuth.ID)
+	// Complete email authentication, confirm a pending account and start a session
+	// so the token can be revoked later, remembering the device it was started on
+	session, err := h.accountService.RedeemEmailAuth(ctx, user, latestEmailAuth.ID, c.Request.UserAgent(), c.ClientIP())
+	if errors.Is(err, service.ErrEmailAuthUsed) {
+		// Another request redeemed the same link first
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Authentication token has already been used"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete authentication"})
 		return
 	}
 
-	// Generate JWT bearer token
-	bearerToken, err := util.GenerateBearerToken(user.ID)
+	respondWithSession(c, user, session, "Email verification successful")
+}
+
+// respondWithSession issues the bearer token of a session just started for the user
+func respondWithSession(c *gin.Context, user *model.Luna4User, session *model.Luna4Session, message string) {
+	bearerToken, err := util.GenerateBearerToken(user.ID, session.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate access token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":      "Email verification successful",
+		"message":      message,
 		"access_token": bearerToken,
 		"token_type":   "Bearer",
-		"expires_in":   30 * 24 * 60 * 60, // 30 days in seconds
+		"expires_in":   int(util.BearerTokenLifetime.Seconds()),
 		"user": gin.H{
 			"id":     user.ID,
 			"email":  user.Email,
@@ -224,6 +227,20 @@ func (h *AuthHandler) AuthEmailVerifyHandler(c *gin.Context) {
 	})
 }
 
+// rejectInactiveUser responds to an authentication attempt by a user whose status does not allow sign in
+func rejectInactiveUser(c *gin.Context, user *model.Luna4User) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":  "Account is " + strings.ToLower(string(user.Status)),
+		"status": user.Status,
+	})
+}
+
+// requestLocale picks the locale of emails sent in response to a request: the user's
+// preference, then the language the page asked for, then the browser's Accept-Language
+func requestLocale(c *gin.Context, user *model.Luna4User, requested string) string {
+	return locale.Resolve(user.Preferences.Language, requested, locale.FromAcceptLanguage(c.GetHeader("Accept-Language")))
+}
+
 // getEmailAuthDebounce returns the debounce time in seconds for email authentication requests
 func getEmailAuthDebounce() int {
 	debounceStr := os.Getenv("EMAIL_AUTH_DEBOUNCE")
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/brand"
+)
+
+// BrandHandler serves the brand profiles the /app pages style themselves with
+type BrandHandler struct {
+	brands *brand.Registry
+}
+
+// NewBrandHandler creates a new brand handler with injected dependencies
+func NewBrandHandler(brands *brand.Registry) *BrandHandler {
+	return &BrandHandler{
+		brands: brands,
+	}
+}
+
+// GetBrandHandler returns the public parts of an app's brand profile
+func (h *BrandHandler) GetBrandHandler(c *gin.Context) {
+	profile, ok := h.brands.Lookup(c.Param("app"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown app"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"brand": gin.H{
+			"id":             profile.ID,
+			"name":           profile.Name,
+			"logoUrl":        profile.LogoURL,
+			"primaryColor":   profile.PrimaryColor,
+			"secondaryColor": profile.SecondaryColor,
+			"supportUrl":     profile.SupportURL,
+		},
+	})
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/brand"
+)
+
+func TestGetBrandHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	path := filepath.Join(t.TempDir(), "brands.json")
+	profiles := `[{"id": "prunk", "name": "Prunk", "sender": "hello@prunk.example.com"}]`
+	if err := os.WriteFile(path, []byte(profiles), 0600); err != nil {
+		t.Fatalf("failed to write profiles: %v", err)
+	}
+	config := brand.GetConfig()
+	config.ProfilesPath = path
+	brands, err := brand.NewRegistry(config)
+	if err != nil {
+		t.Fatalf("failed to create brand registry: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/api/brand/:app", NewBrandHandler(brands).GetBrandHandler)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/brand/PRUNK", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET returned %d: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Brand map[string]string `json:"brand"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Brand["id"] != "prunk" || body.Brand["name"] != "Prunk" || body.Brand["primaryColor"] != config.Default.PrimaryColor {
+		t.Errorf("brand is %v", body.Brand)
+	}
+	// The sender is not public
+	if _, ok := body.Brand["sender"]; ok {
+		t.Errorf("brand exposes its sender: %v", body.Brand)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/brand/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown app returned %d, want 404", w.Code)
+	}
+}
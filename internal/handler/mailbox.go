@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/mailer"
+)
+
+var mailboxTemplate = template.Must(template.New("mailbox").Funcs(template.FuncMap{
+	"sentAt": func(millis int64) string {
+		return time.UnixMilli(millis).Format("2006-01-02 15:04:05")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Airlock Dev Mailbox</title>
+    <style>
+        body { font-family: Arial, sans-serif; margin: 40px; color: #333; }
+        table { border-collapse: collapse; width: 100%; }
+        th, td { text-align: left; padding: 8px 12px; border-bottom: 1px solid #ddd; }
+        th { background: #f5f5f5; }
+        .empty { color: #888; }
+    </style>
+</head>
+<body>
+    <h1>Dev Mailbox</h1>
+    <p>Messages written to the outbox by the <code>file</code> mail backend, newest first.</p>
+    {{if .}}
+    <table>
+        <tr><th>Sent</th><th>To</th><th>Subject</th></tr>
+        {{range .}}
+        <tr>
+            <td>{{sentAt .SentAt}}</td>
+            <td>{{.To}}</td>
+            <td><a href="/dev/mailbox/{{.ID}}">{{.Subject}}</a></td>
+        </tr>
+        {{end}}
+    </table>
+    {{else}}
+    <p class="empty">No messages yet.</p>
+    {{end}}
+</body>
+</html>
+`))
+
+// MailboxHandler serves the messages written by the file mail backend. It is only
+// registered in development, when MAIL_BACKEND=file.
+type MailboxHandler struct {
+	outbox *mailer.FileMailer
+}
+
+// NewMailboxHandler creates a new mailbox handler with injected dependencies
+func NewMailboxHandler(outbox *mailer.FileMailer) *MailboxHandler {
+	return &MailboxHandler{
+		outbox: outbox,
+	}
+}
+
+// GetMessages lists the outbox, newest first
+func (h *MailboxHandler) GetMessages(c *gin.Context) {
+	messages, err := h.outbox.List()
+	if err != nil {
+		log.Printf("GetMessages: Failed to list outbox: %v", err)
+		c.String(http.StatusInternalServerError, "Failed to list outbox")
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := mailboxTemplate.Execute(c.Writer, messages); err != nil {
+		log.Printf("GetMessages: Failed to render mailbox: %v", err)
+	}
+}
+
+// GetMessage shows the HTML body of a message as the recipient would see it
+func (h *MailboxHandler) GetMessage(c *gin.Context) {
+	message, err := h.outbox.Get(c.Param("id"))
+	if err != nil {
+		log.Printf("GetMessage: Failed to read message: %v", err)
+		c.String(http.StatusInternalServerError, "Failed to read message")
+		return
+	}
+	if message == nil {
+		c.String(http.StatusNotFound, "Message not found")
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(message.HTML))
+}
@@ -0,0 +1,36 @@
+package maintenance
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/util"
+)
+
+// getActor returns the ID of the admin making the request, for audit entries
+func getActor(c *gin.Context) string {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	claims, err := util.ParseBearerToken(token)
+	if err != nil {
+		return "maintenance"
+	}
+	return claims.UserID
+}
+
+// StatusChangeRequest represents the optional payload for changing a user's status
+type StatusChangeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// getStatusChangeReason reads the optional reason from a status change request body
+func getStatusChangeReason(c *gin.Context) string {
+	if c.Request.ContentLength == 0 {
+		return ""
+	}
+
+	var req StatusChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(req.Reason)
+}
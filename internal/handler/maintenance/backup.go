@@ -0,0 +1,77 @@
+package maintenance
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/backup"
+	"github.com/luna4dev/utility/l4error"
+)
+
+// BackupHandler struct holds the backup manager dependency
+type BackupHandler struct {
+	manager *backup.Manager
+}
+
+// NewBackupHandler creates a new backup handler with injected dependencies. The manager
+// is nil when the storage backend has no file to back up.
+func NewBackupHandler(manager *backup.Manager) *BackupHandler {
+	return &BackupHandler{
+		manager: manager,
+	}
+}
+
+// CreateBackup takes a backup of the database now
+func (h *BackupHandler) CreateBackup(c *gin.Context) {
+	if !h.requireManager(c) {
+		return
+	}
+
+	created, err := h.manager.Run(c)
+	if err != nil {
+		log.Printf("CreateBackup: Failed to back up database: %v", err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to back up database",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"backup": created,
+	})
+}
+
+// GetBackups lists the backups on disk, newest first
+func (h *BackupHandler) GetBackups(c *gin.Context) {
+	if !h.requireManager(c) {
+		return
+	}
+
+	backups, err := h.manager.List()
+	if err != nil {
+		log.Printf("GetBackups: Failed to list backups: %v", err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to list backups",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"backups": backups,
+		"count":   len(backups),
+	})
+}
+
+func (h *BackupHandler) requireManager(c *gin.Context) bool {
+	if h.manager == nil {
+		c.JSON(http.StatusNotImplemented, l4error.ErrorResponse{
+			Error:   "Not Implemented",
+			Message: "Backups are only available with the SQLite storage backend",
+		})
+		return false
+	}
+	return true
+}
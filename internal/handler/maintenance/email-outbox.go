@@ -0,0 +1,142 @@
+package maintenance
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/service"
+	"github.com/luna4dev/utility/l4error"
+)
+
+const maxOutboxListLimit = 500
+
+// EmailOutboxHandler struct holds dependencies for inspecting the email outbox
+type EmailOutboxHandler struct {
+	accountService *service.AccountService
+}
+
+// NewEmailOutboxHandler creates a new email outbox handler with injected dependencies
+func NewEmailOutboxHandler(accountService *service.AccountService) *EmailOutboxHandler {
+	return &EmailOutboxHandler{
+		accountService: accountService,
+	}
+}
+
+// GetOutboxEmails lists queued emails with a status, newest first. The status defaults to
+// DEAD, the emails that were given up on.
+func (h *EmailOutboxHandler) GetOutboxEmails(c *gin.Context) {
+	status := model.OutboxStatus(strings.ToUpper(c.DefaultQuery("status", string(model.OutboxStatusDead))))
+	switch status {
+	case model.OutboxStatusPending, model.OutboxStatusSent, model.OutboxStatusDead:
+	default:
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Status must be PENDING, SENT or DEAD",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > maxOutboxListLimit {
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Limit must be between 1 and 500",
+		})
+		return
+	}
+
+	emails, err := h.accountService.GetOutboxEmails(c, status, limit)
+	if err != nil {
+		log.Printf("GetOutboxEmails: Failed to retrieve outbox emails: %v", err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to retrieve outbox emails",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"emails": emails,
+		"count":  len(emails),
+	})
+}
+
+// RetryOutboxEmail queues a dead-lettered email for delivery again
+func (h *EmailOutboxHandler) RetryOutboxEmail(c *gin.Context) {
+	emailID := c.Param("id")
+
+	email, err := h.accountService.RetryOutboxEmail(c, emailID)
+	switch {
+	case errors.Is(err, service.ErrOutboxEmailNotFound):
+		c.JSON(http.StatusNotFound, l4error.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Outbox email not found",
+		})
+		return
+	case errors.Is(err, service.ErrOutboxEmailNotDead):
+		c.JSON(http.StatusConflict, l4error.ErrorResponse{
+			Error:   "Conflict",
+			Message: "Only dead-lettered emails can be retried",
+		})
+		return
+	case errors.Is(err, service.ErrOutboxEmailExpired):
+		c.JSON(http.StatusConflict, l4error.ErrorResponse{
+			Error:   "Conflict",
+			Message: "Email expired before delivery and cannot be retried",
+		})
+		return
+	case errors.Is(err, service.ErrOutboxEmailCleared):
+		c.JSON(http.StatusConflict, l4error.ErrorResponse{
+			Error:   "Conflict",
+			Message: "Email body was cleared and cannot be retried",
+		})
+		return
+	case err != nil:
+		log.Printf("RetryOutboxEmail: Failed to retry email %s: %v", emailID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to retry outbox email",
+		})
+		return
+	}
+
+	log.Printf("RetryOutboxEmail: Requeued email %s by %s", emailID, getActor(c))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email queued for delivery",
+		"email":   email,
+	})
+}
+
+// LiftEmailSuppression lets mail reach an address SES reported as hard bounced or
+// complaining, once the cause has been dealt with
+func (h *EmailOutboxHandler) LiftEmailSuppression(c *gin.Context) {
+	email := c.Param("email")
+	actor := getActor(c)
+
+	err := h.accountService.LiftEmailSuppression(c, email, actor)
+	if errors.Is(err, service.ErrEmailSuppressionNotFound) {
+		c.JSON(http.StatusNotFound, l4error.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Email address is not suppressed",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("LiftEmailSuppression: Failed to lift suppression of %s: %v", email, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to lift email suppression",
+		})
+		return
+	}
+
+	log.Printf("LiftEmailSuppression: Lifted suppression of %s by %s", email, actor)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email suppression lifted",
+	})
+}
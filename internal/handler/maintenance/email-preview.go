@@ -0,0 +1,100 @@
+package maintenance
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/brand"
+	"github.com/luna4dev/airlock/internal/locale"
+	"github.com/luna4dev/airlock/internal/service"
+	"github.com/luna4dev/utility/l4error"
+)
+
+// EmailPreviewHandler struct holds dependencies for previewing email templates
+type EmailPreviewHandler struct {
+	accountService *service.AccountService
+	brands         *brand.Registry
+}
+
+// NewEmailPreviewHandler creates a new email preview handler with injected dependencies
+func NewEmailPreviewHandler(accountService *service.AccountService, brands *brand.Registry) *EmailPreviewHandler {
+	return &EmailPreviewHandler{
+		accountService: accountService,
+		brands:         brands,
+	}
+}
+
+// PreviewEmail renders an email template with sample data, as the HTML body by default or
+// with format=text or format=json. lang and app pick the locale and brand.
+func (h *EmailPreviewHandler) PreviewEmail(c *gin.Context) {
+	name := c.Param("template")
+
+	lang := locale.Default
+	if requested := c.Query("lang"); requested != "" {
+		if lang = locale.Match(requested); lang == "" {
+			c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Language must be one of " + strings.Join(locale.Supported, ", "),
+			})
+			return
+		}
+	}
+
+	profile := h.brands.Default()
+	if app := c.Query("app"); app != "" {
+		var ok bool
+		if profile, ok = h.brands.Lookup(app); !ok {
+			c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+				Error:   "Bad Request",
+				Message: "Unknown app",
+			})
+			return
+		}
+	}
+
+	format := c.DefaultQuery("format", "html")
+	if format != "html" && format != "text" && format != "json" {
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Format must be html, text or json",
+		})
+		return
+	}
+
+	msg, err := h.accountService.PreviewEmail(name, lang, profile)
+	if errors.Is(err, service.ErrUnknownEmailTemplate) {
+		c.JSON(http.StatusNotFound, l4error.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Template must be one of " + strings.Join(service.EmailTemplateNames(), ", "),
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("PreviewEmail: Failed to render %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to render email template",
+		})
+		return
+	}
+
+	switch format {
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(msg.Text))
+	case "json":
+		c.JSON(http.StatusOK, gin.H{
+			"template": name,
+			"language": lang,
+			"app":      profile.ID,
+			"from":     msg.From,
+			"subject":  msg.Subject,
+			"html":     msg.HTML,
+			"text":     msg.Text,
+		})
+	default:
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+	}
+}
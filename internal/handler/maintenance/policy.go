@@ -0,0 +1,50 @@
+package maintenance
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/policy"
+	"github.com/luna4dev/utility/l4error"
+)
+
+// PolicyHandler struct holds the policy engine dependency
+type PolicyHandler struct {
+	engine *policy.Engine
+}
+
+// NewPolicyHandler creates a new policy handler with injected dependencies
+func NewPolicyHandler(engine *policy.Engine) *PolicyHandler {
+	return &PolicyHandler{
+		engine: engine,
+	}
+}
+
+// GetPolicyReport runs a policy in dry-run mode and returns the users it would affect
+func (h *PolicyHandler) GetPolicyReport(c *gin.Context) {
+	name := c.Param("name")
+	p := h.engine.Policy(name)
+	if p == nil {
+		log.Printf("GetPolicyReport: Policy not found: %s", name)
+		c.JSON(http.StatusNotFound, l4error.ErrorResponse{
+			Error:   "Not Found",
+			Message: "Policy not found",
+		})
+		return
+	}
+
+	report, err := p.Run(c, true)
+	if err != nil {
+		log.Printf("GetPolicyReport: Failed to run policy %s: %v", name, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to run policy",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"report": report,
+	})
+}
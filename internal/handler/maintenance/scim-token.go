@@ -0,0 +1,113 @@
+package maintenance
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/service"
+	"github.com/luna4dev/utility/l4error"
+)
+
+// ScimTokenHandler struct holds dependencies for managing SCIM bearer tokens
+type ScimTokenHandler struct {
+	accountService *service.AccountService
+}
+
+// NewScimTokenHandler creates a new SCIM token handler with injected dependencies
+func NewScimTokenHandler(accountService *service.AccountService) *ScimTokenHandler {
+	return &ScimTokenHandler{
+		accountService: accountService,
+	}
+}
+
+// CreateScimTokenRequest names the tenant a token is issued to
+type CreateScimTokenRequest struct {
+	Tenant string `json:"tenant" binding:"required"`
+}
+
+// GetScimTokens lists the SCIM tokens of every tenant
+func (h *ScimTokenHandler) GetScimTokens(c *gin.Context) {
+	tokens, err := h.accountService.GetScimTokens(c)
+	if err != nil {
+		log.Printf("GetScimTokens: Failed to retrieve SCIM tokens: %v", err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to retrieve SCIM tokens",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tokens": tokens,
+		"count":  len(tokens),
+	})
+}
+
+// CreateScimToken issues a bearer token for a tenant's directory. The token is only
+// shown in this response.
+func (h *ScimTokenHandler) CreateScimToken(c *gin.Context) {
+	var req CreateScimTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("CreateScimToken: Invalid JSON or missing tenant: %v", err)
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid JSON or missing tenant",
+		})
+		return
+	}
+
+	token, scimToken, err := h.accountService.IssueScimToken(c, strings.TrimSpace(req.Tenant))
+	if errors.Is(err, service.ErrInvalidTenant) {
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Tenant must be 1 to 64 letters, digits, dots, dashes or underscores",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("CreateScimToken: Failed to issue token for tenant %s: %v", req.Tenant, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to create SCIM token",
+		})
+		return
+	}
+
+	log.Printf("CreateScimToken: Issued token %s for tenant %s by %s", scimToken.ID, scimToken.Tenant, getActor(c))
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Store this token now, it cannot be shown again",
+		"token":   token,
+		"scim":    scimToken,
+	})
+}
+
+// RevokeScimToken stops a token from authenticating
+func (h *ScimTokenHandler) RevokeScimToken(c *gin.Context) {
+	tokenID := c.Param("id")
+
+	err := h.accountService.RevokeScimToken(c, tokenID)
+	if errors.Is(err, service.ErrScimTokenNotFound) {
+		c.JSON(http.StatusNotFound, l4error.ErrorResponse{
+			Error:   "Not Found",
+			Message: "SCIM token not found or already revoked",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("RevokeScimToken: Failed to revoke token %s: %v", tokenID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to revoke SCIM token",
+		})
+		return
+	}
+
+	log.Printf("RevokeScimToken: Revoked token %s by %s", tokenID, getActor(c))
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "SCIM token revoked",
+		"token_id": tokenID,
+	})
+}
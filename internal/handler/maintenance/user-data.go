@@ -0,0 +1,113 @@
+package maintenance
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/service"
+	"github.com/luna4dev/airlock/internal/util"
+	"github.com/luna4dev/utility/l4error"
+)
+
+// UserDataHandler struct holds dependencies for data subject requests
+type UserDataHandler struct {
+	accountService *service.AccountService
+}
+
+// NewUserDataHandler creates a new user data handler with injected dependencies
+func NewUserDataHandler(accountService *service.AccountService) *UserDataHandler {
+	return &UserDataHandler{
+		accountService: accountService,
+	}
+}
+
+// ExportUserData returns everything airlock holds about a user as a downloadable JSON archive
+func (h *UserDataHandler) ExportUserData(c *gin.Context) {
+	userID := c.Param("id")
+	if userID == "" {
+		log.Printf("ExportUserData: Missing user ID parameter")
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "User ID is required",
+		})
+		return
+	}
+
+	ctx := context.Background()
+
+	export, err := h.accountService.ExportUserData(ctx, userID)
+	if err != nil {
+		log.Printf("ExportUserData: Failed to export data for user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to export user data",
+		})
+		return
+	}
+
+	if export == nil {
+		log.Printf("ExportUserData: User not found with ID: %s", userID)
+		c.JSON(http.StatusNotFound, l4error.ErrorResponse{
+			Error:   "Not Found",
+			Message: "User not found",
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="airlock-user-`+userID+`.json"`)
+	c.IndentedJSON(http.StatusOK, export)
+}
+
+// EraseUserData removes a user's personal data across all tables; audit entries are kept
+// under a pseudonym so aggregate history stays intact
+func (h *UserDataHandler) EraseUserData(c *gin.Context) {
+	userID := c.Param("id")
+	if userID == "" {
+		log.Printf("EraseUserData: Missing user ID parameter")
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "User ID is required",
+		})
+		return
+	}
+
+	ctx := context.Background()
+
+	// First check if user exists
+	user, err := h.accountService.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("EraseUserData: Failed to retrieve user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to retrieve user",
+		})
+		return
+	}
+
+	if user == nil {
+		log.Printf("EraseUserData: User not found with ID: %s", userID)
+		c.JSON(http.StatusNotFound, l4error.ErrorResponse{
+			Error:   "Not Found",
+			Message: "User not found",
+		})
+		return
+	}
+
+	err = h.accountService.EraseUser(ctx, userID, getActor(c))
+	if err != nil {
+		log.Printf("EraseUserData: Failed to erase user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to erase user data",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "User data erased successfully",
+		"user_id":   userID,
+		"pseudonym": util.PseudonymizeID(userID),
+	})
+}
@@ -0,0 +1,85 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/service"
+)
+
+// UserPasskeyHandler struct holds dependencies for managing users' passkeys
+type UserPasskeyHandler struct {
+	accountService *service.AccountService
+}
+
+// NewUserPasskeyHandler creates a new user passkey handler with injected dependencies
+func NewUserPasskeyHandler(accountService *service.AccountService) *UserPasskeyHandler {
+	return &UserPasskeyHandler{
+		accountService: accountService,
+	}
+}
+
+// GetUserPasskeys lists the passkeys a user registered
+func (h *UserPasskeyHandler) GetUserPasskeys(c *gin.Context) {
+	userID := c.Param("id")
+	ctx := context.Background()
+
+	user, err := h.accountService.GetUserByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	passkeys, err := h.accountService.GetUserWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user passkeys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":  userID,
+		"passkeys": passkeys,
+		"count":    len(passkeys),
+	})
+}
+
+// RemoveUserPasskey deletes one of a user's passkeys, for example one on a lost device
+func (h *UserPasskeyHandler) RemoveUserPasskey(c *gin.Context) {
+	userID := c.Param("id")
+	passkeyID := c.Param("passkeyId")
+	ctx := context.Background()
+
+	user, err := h.accountService.GetUserByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err = h.accountService.RemovePasskey(ctx, userID, passkeyID, getActor(c))
+	if errors.Is(err, service.ErrPasskeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkey not found for this user"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove passkey"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Passkey removed successfully",
+		"user_id":    userID,
+		"passkey_id": passkeyID,
+	})
+}
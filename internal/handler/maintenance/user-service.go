@@ -12,13 +12,13 @@ import (
 
 // UserServiceHandler struct holds dependencies for user service operations
 type UserServiceHandler struct {
-	sqliteService *service.SQLiteService
+	accountService *service.AccountService
 }
 
 // NewUserServiceHandler creates a new user service handler with injected dependencies
-func NewUserServiceHandler(sqliteService *service.SQLiteService) *UserServiceHandler {
+func NewUserServiceHandler(accountService *service.AccountService) *UserServiceHandler {
 	return &UserServiceHandler{
-		sqliteService: sqliteService,
+		accountService: accountService,
 	}
 }
 
@@ -33,7 +33,7 @@ func (h *UserServiceHandler) GetUserServices(c *gin.Context) {
 	ctx := context.Background()
 
 	// First check if user exists
-	user, err := h.sqliteService.GetUserByID(ctx, userID)
+	user, err := h.accountService.GetUserByID(ctx, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
 		return
@@ -45,7 +45,7 @@ func (h *UserServiceHandler) GetUserServices(c *gin.Context) {
 	}
 
 	// Get user's services
-	services, err := h.sqliteService.GetUserServices(ctx, userID)
+	services, err := h.accountService.GetUserServices(ctx, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user services"})
 		return
@@ -82,7 +82,7 @@ func (h *UserServiceHandler) AddUserService(c *gin.Context) {
 	ctx := context.Background()
 
 	// Check if user exists
-	user, err := h.sqliteService.GetUserByID(ctx, userID)
+	user, err := h.accountService.GetUserByID(ctx, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
 		return
@@ -106,8 +106,8 @@ func (h *UserServiceHandler) AddUserService(c *gin.Context) {
 		ExpiresAt:  req.ExpiresAt,
 	}
 
-	// Add service to user
-	err = h.sqliteService.CreateUserService(ctx, userService)
+	// Add service to user and tell them about it
+	err = h.accountService.GrantUserService(ctx, user, userService)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add service to user"})
 		return
@@ -120,6 +120,64 @@ func (h *UserServiceHandler) AddUserService(c *gin.Context) {
 	})
 }
 
+// ReplaceUserServicesRequest represents the request payload for replacing all of a user's services
+type ReplaceUserServicesRequest struct {
+	Services []AddUserServiceRequest `json:"services" binding:"dive"`
+}
+
+// ReplaceUserServices replaces every service granted to a user with the given list
+func (h *UserServiceHandler) ReplaceUserServices(c *gin.Context) {
+	userID := c.Param("id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+		return
+	}
+
+	var req ReplaceUserServicesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON or missing required fields"})
+		return
+	}
+
+	ctx := context.Background()
+
+	// Check if user exists
+	user, err := h.accountService.GetUserByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+		return
+	}
+
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	services := []model.Luna4UserService{}
+	for _, svc := range req.Services {
+		services = append(services, model.Luna4UserService{
+			ID:         uuid.New().String(),
+			UserID:     userID,
+			Service:    model.Luna4Service(svc.Service),
+			Permission: model.UserServicePermission(svc.Permission),
+			ExpiresAt:  svc.ExpiresAt,
+		})
+	}
+
+	// Old grants are only removed if every new grant is written
+	err = h.accountService.ReplaceUserServices(ctx, userID, services)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replace user services"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "User services replaced successfully",
+		"user_id":  userID,
+		"services": services,
+	})
+}
+
 // RemoveUserService removes a service from a user
 func (h *UserServiceHandler) RemoveUserService(c *gin.Context) {
 	userID := c.Param("id")
@@ -138,7 +196,7 @@ func (h *UserServiceHandler) RemoveUserService(c *gin.Context) {
 	ctx := context.Background()
 
 	// Check if user exists
-	user, err := h.sqliteService.GetUserByID(ctx, userID)
+	user, err := h.accountService.GetUserByID(ctx, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
 		return
@@ -150,28 +208,28 @@ func (h *UserServiceHandler) RemoveUserService(c *gin.Context) {
 	}
 
 	// Check if service exists and belongs to the user
-	services, err := h.sqliteService.GetUserServices(ctx, userID)
+	services, err := h.accountService.GetUserServices(ctx, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user services"})
 		return
 	}
 
 	// Find the service to remove
-	var serviceFound bool
-	for _, service := range services {
-		if service.ID == serviceID {
-			serviceFound = true
+	var grant *model.Luna4UserService
+	for i := range services {
+		if services[i].ID == serviceID {
+			grant = &services[i]
 			break
 		}
 	}
 
-	if !serviceFound {
+	if grant == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Service not found for this user"})
 		return
 	}
 
-	// Remove the service
-	err = h.sqliteService.DeleteUserService(ctx, serviceID)
+	// Remove the service and tell the user about it
+	err = h.accountService.RemoveUserService(ctx, user, grant)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove service from user"})
 		return
@@ -0,0 +1,153 @@
+package maintenance
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/service"
+	"github.com/luna4dev/utility/l4error"
+)
+
+// maxUserImportBytes bounds the body of an import request
+const maxUserImportBytes = 32 << 20
+
+// UserTransferHandler struct holds dependencies for bulk user import and export
+type UserTransferHandler struct {
+	accountService *service.AccountService
+}
+
+// NewUserTransferHandler creates a new user transfer handler with injected dependencies
+func NewUserTransferHandler(accountService *service.AccountService) *UserTransferHandler {
+	return &UserTransferHandler{
+		accountService: accountService,
+	}
+}
+
+// transferFormat reads the format query parameter, falling back to the request's
+// Content-Type and then to the given default
+func transferFormat(c *gin.Context, defaultFormat string) string {
+	if format := c.Query("format"); format != "" {
+		return format
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return service.UserFormatCSV
+	case "application/jsonl", "application/x-ndjson":
+		return service.UserFormatJSONL
+	}
+	return defaultFormat
+}
+
+// ImportUsers creates users from a CSV or JSON Lines body. Every row is validated first
+// and nothing is written unless all rows are valid; with dryRun=true nothing is written
+// at all. CSV files need a header row with an email column and may have name,
+// organization, notes, status and services (SERVICE:PERMISSION[:EXPIRES_AT] separated by
+// semicolons). JSON Lines take the same fields as creating a single user.
+func (h *UserTransferHandler) ImportUsers(c *gin.Context) {
+	format := transferFormat(c, "")
+	if format != service.UserFormatCSV && format != service.UserFormatJSONL {
+		log.Printf("ImportUsers: Unsupported format %q", format)
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Format must be csv or jsonl, set with the format parameter or Content-Type",
+		})
+		return
+	}
+	dryRun := c.Query("dryRun") == "true"
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxUserImportBytes)
+	records, err := service.ReadUserRecords(body, format)
+	if err != nil {
+		log.Printf("ImportUsers: Failed to read import: %v", err)
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Failed to read import: " + err.Error(),
+		})
+		return
+	}
+
+	report, err := h.accountService.ImportUsers(c, records, dryRun)
+	if err != nil {
+		log.Printf("ImportUsers: Failed to import users: %v", err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to import users",
+		})
+		return
+	}
+
+	if len(report.Errors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+	if dryRun {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	c.JSON(http.StatusCreated, report)
+}
+
+// ExportUsers streams users with their service grants as CSV (the default) or JSON Lines.
+// It takes the same filters and sort as the user list.
+func (h *UserTransferHandler) ExportUsers(c *gin.Context) {
+	format := c.DefaultQuery("format", service.UserFormatCSV)
+	contentType := "text/csv; charset=utf-8"
+	switch format {
+	case service.UserFormatCSV:
+	case service.UserFormatJSONL:
+		contentType = "application/jsonl"
+	default:
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Format must be csv or jsonl",
+		})
+		return
+	}
+
+	opts, err := parseUserListOptions(c)
+	if err != nil {
+		log.Printf("ExportUsers: Invalid query: %v", err)
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	filename := "airlock-users-" + time.Now().UTC().Format("20060102T150405Z") + "." + format
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	writer, err := service.NewUserExportWriter(c.Writer, format)
+	if err != nil {
+		log.Printf("ExportUsers: Failed to start export: %v", err)
+		return
+	}
+
+	exported := 0
+	err = h.accountService.ExportUsers(c, opts, func(users []model.Luna4UserWithServices) error {
+		for i := range users {
+			if err := writer.Write(&users[i]); err != nil {
+				return err
+			}
+		}
+		exported += len(users)
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		// The status is already sent, so the export simply ends early
+		log.Printf("ExportUsers: Export failed after %d users: %v", exported, err)
+		return
+	}
+	log.Printf("ExportUsers: Exported %d users", exported)
+}
@@ -2,27 +2,34 @@ package maintenance
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/luna4dev/airlock-client/alcgin"
+	"github.com/luna4dev/airlock/internal/locale"
 	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/policy"
 	"github.com/luna4dev/airlock/internal/service"
+	"github.com/luna4dev/airlock/internal/util"
 	"github.com/luna4dev/utility/l4error"
 )
 
-// UserHandler struct holds the SQLite service dependency
+// UserHandler struct holds the account service dependency, whichever store backs it
 type UserHandler struct {
-	sqliteService *service.SQLiteService
+	accountService *service.AccountService
 }
 
 // NewUserHandler creates a new user handler with injected dependencies
-func NewUserHandler(sqliteService *service.SQLiteService) *UserHandler {
+func NewUserHandler(accountService *service.AccountService) *UserHandler {
 	return &UserHandler{
-		sqliteService: sqliteService,
+		accountService: accountService,
 	}
 }
 
@@ -34,7 +41,18 @@ func (h *UserHandler) Status(c *gin.Context) {
 	})
 }
 
-// GetUsers returns all users with their services using injected SQLite service
+// Page sizes of the user list and search
+const (
+	defaultUserListLimit   = 50
+	maxUserListLimit       = 200
+	defaultUserSearchLimit = 20
+	maxUserSearchLimit     = 100
+)
+
+// GetUsers returns a page of users with their services. Query parameters:
+// status (comma separated), service, permission, email (substring), createdAfter and
+// createdBefore (milliseconds), sort (createdAt, updatedAt or email), order (asc or desc),
+// limit and cursor (nextCursor of the previous page).
 func (h *UserHandler) GetUsers(c *gin.Context) {
 	_, err := alcgin.RequireUserFromContext(c)
 	if err != nil {
@@ -46,7 +64,25 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 		return
 	}
 
-	users, err := h.sqliteService.GetAllUsers(c)
+	opts, err := parseUserListOptions(c)
+	if err != nil {
+		log.Printf("GetUsers: Invalid query: %v", err)
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	page, err := h.accountService.ListUsers(c, opts)
+	if errors.Is(err, service.ErrInvalidCursor) {
+		log.Printf("GetUsers: Invalid cursor")
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid cursor",
+		})
+		return
+	}
 	if err != nil {
 		log.Printf("GetUsers: Failed to retrieve users: %v", err)
 		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
@@ -56,34 +92,108 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 		return
 	}
 
-	// Create response with users and their services
-	type UserWithServices struct {
-		*model.Luna4User
-		Services []model.Luna4UserService `json:"services"`
+	c.JSON(http.StatusOK, page)
+}
+
+// parseUserListOptions reads the user list query parameters
+func parseUserListOptions(c *gin.Context) (service.UserListOptions, error) {
+	opts := service.UserListOptions{
+		Service:    model.Luna4Service(strings.ToUpper(c.Query("service"))),
+		Permission: model.UserServicePermission(strings.ToUpper(c.Query("permission"))),
+		Email:      strings.TrimSpace(c.Query("email")),
+		Sort:       service.UserSort(c.DefaultQuery("sort", string(service.UserSortCreatedAt))),
+		Limit:      defaultUserListLimit,
+		Cursor:     c.Query("cursor"),
+	}
+
+	if statuses := c.Query("status"); statuses != "" {
+		for _, value := range strings.Split(statuses, ",") {
+			status := model.UserStatus(strings.ToUpper(strings.TrimSpace(value)))
+			if !status.IsValid() {
+				return opts, fmt.Errorf("Invalid status: %s", value)
+			}
+			opts.Statuses = append(opts.Statuses, status)
+		}
+	}
+
+	if !opts.Sort.IsValid() {
+		return opts, fmt.Errorf("Invalid sort: %s", opts.Sort)
+	}
+
+	switch order := c.DefaultQuery("order", "desc"); order {
+	case "asc":
+	case "desc":
+		opts.Descending = true
+	default:
+		return opts, fmt.Errorf("Invalid order: %s", order)
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxUserListLimit {
+			return opts, fmt.Errorf("Limit must be between 1 and %d", maxUserListLimit)
+		}
+		opts.Limit = limit
+	}
+
+	for name, target := range map[string]**int64{"createdAfter": &opts.CreatedAfter, "createdBefore": &opts.CreatedBefore} {
+		if value := c.Query(name); value != "" {
+			ms, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return opts, fmt.Errorf("Invalid %s: must be a timestamp in milliseconds", name)
+			}
+			*target = &ms
+		}
+	}
+
+	return opts, nil
+}
+
+// SearchUsers returns the users best matching q across email, name, organization and
+// notes, with the matches highlighted
+func (h *UserHandler) SearchUsers(c *gin.Context) {
+	searcher, ok := h.accountService.Store.(service.UserSearcher)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, l4error.ErrorResponse{
+			Error:   "Not Implemented",
+			Message: "User search is only available with the SQLite storage backend",
+		})
+		return
 	}
 
-	var usersWithServices []UserWithServices
-	for _, user := range users {
-		services, err := h.sqliteService.GetUserServices(c, user.ID)
-		if err != nil {
-			log.Printf("GetUsers: Failed to retrieve user services for user %s: %v", user.ID, err)
-			c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
-				Error:   "Internal Server Error",
-				Message: "Failed to retrieve user services",
+	limit := defaultUserSearchLimit
+	if value := c.Query("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxUserSearchLimit {
+			c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+				Error:   "Bad Request",
+				Message: fmt.Sprintf("Limit must be between 1 and %d", maxUserSearchLimit),
 			})
 			return
 		}
+	}
 
-		userWithServices := UserWithServices{
-			Luna4User: user,
-			Services:  services,
-		}
-		usersWithServices = append(usersWithServices, userWithServices)
+	results, err := searcher.SearchUsers(c, c.Query("q"), limit)
+	if errors.Is(err, service.ErrSearchQueryTooShort) {
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Query must contain a term of at least 3 characters",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("SearchUsers: Failed to search users: %v", err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to search users",
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"users": usersWithServices,
-		"count": len(usersWithServices),
+		"users": results,
+		"count": len(results),
 	})
 }
 
@@ -102,7 +212,7 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	ctx := context.Background()
 
 	// Get user by ID
-	user, err := h.sqliteService.GetUserByID(ctx, userID)
+	user, err := h.accountService.GetUserByID(ctx, userID)
 	if err != nil {
 		log.Printf("GetUser: Failed to retrieve user %s: %v", userID, err)
 		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
@@ -122,7 +232,7 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	}
 
 	// Get user's services
-	services, err := h.sqliteService.GetUserServices(ctx, userID)
+	services, err := h.accountService.GetUserServices(ctx, userID)
 	if err != nil {
 		log.Printf("GetUser: Failed to retrieve user services for user %s: %v", userID, err)
 		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
@@ -132,15 +242,28 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		return
 	}
 
-	// Create response with user and services
+	// Get what SES reported about delivering to the user's address
+	delivery, err := h.accountService.GetDeliveryStatus(ctx, user.Email)
+	if err != nil {
+		log.Printf("GetUser: Failed to retrieve delivery status for user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to retrieve delivery status",
+		})
+		return
+	}
+
+	// Create response with user, services and delivery status
 	type UserWithServices struct {
 		*model.Luna4User
-		Services []model.Luna4UserService `json:"services"`
+		Services []model.Luna4UserService   `json:"services"`
+		Delivery *model.Luna4DeliveryStatus `json:"delivery"`
 	}
 
 	userWithServices := UserWithServices{
 		Luna4User: user,
 		Services:  services,
+		Delivery:  delivery,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -150,9 +273,12 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 
 // CreateUserRequest represents the request payload for creating a user
 type CreateUserRequest struct {
-	Email    string                     `json:"email" binding:"required"`
-	Status   string                     `json:"status"`
-	Services []CreateUserServiceRequest `json:"services,omitempty"`
+	Email        string                     `json:"email" binding:"required"`
+	Name         string                     `json:"name" binding:"max=200"`
+	Organization string                     `json:"organization" binding:"max=200"`
+	Notes        string                     `json:"notes" binding:"max=4000"`
+	Status       string                     `json:"status"`
+	Services     []CreateUserServiceRequest `json:"services,omitempty"`
 }
 
 // CreateUserServiceRequest represents service permissions for user creation
@@ -178,13 +304,14 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	// Validate status or set default
 	status := model.UserStatusActive
 	if req.Status != "" {
-		if req.Status == string(model.UserStatusActive) || req.Status == string(model.UserStatusSuspended) {
+		switch model.UserStatus(req.Status) {
+		case model.UserStatusPending, model.UserStatusActive, model.UserStatusSuspended:
 			status = model.UserStatus(req.Status)
-		} else {
+		default:
 			log.Printf("CreateUser: Invalid status provided: %s", req.Status)
 			c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
 				Error:   "Bad Request",
-				Message: "Invalid status. Must be ACTIVE or SUSPENDED",
+				Message: "Invalid status. Must be PENDING, ACTIVE or SUSPENDED",
 			})
 			return
 		}
@@ -192,23 +319,17 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 
 	// Create new user
 	userID := uuid.New().String()
+	now := time.Now().UnixMilli()
 	user := &model.Luna4User{
-		ID:        userID,
-		Email:     req.Email,
-		Status:    status,
-		CreatedAt: time.Now().UnixMilli(),
-		UpdatedAt: time.Now().UnixMilli(),
-	}
-
-	ctx := context.Background()
-	err := h.sqliteService.CreateUser(ctx, user)
-	if err != nil {
-		log.Printf("CreateUser: Failed to create user: %v", err)
-		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
-			Error:   "Internal Server Error",
-			Message: "Failed to create user",
-		})
-		return
+		ID:              userID,
+		Email:           req.Email,
+		Name:            req.Name,
+		Organization:    req.Organization,
+		Notes:           req.Notes,
+		Status:          status,
+		StatusChangedAt: &now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
 	}
 
 	// Handle services - use provided services or default PRUNK-USER
@@ -237,17 +358,16 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		servicesToCreate = append(servicesToCreate, defaultService)
 	}
 
-	// Create user services
-	for _, userService := range servicesToCreate {
-		err = h.sqliteService.CreateUserService(ctx, &userService)
-		if err != nil {
-			log.Printf("CreateUser: Failed to create user service %s for user %s: %v", userService.Service, userID, err)
-			c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
-				Error:   "Internal Server Error",
-				Message: "Failed to create user service",
-			})
-			return
-		}
+	// Create the user and their services together so a failed grant leaves nothing behind
+	ctx := context.Background()
+	err := h.accountService.CreateUserWithServices(ctx, user, servicesToCreate)
+	if err != nil {
+		log.Printf("CreateUser: Failed to create user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to create user",
+		})
+		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
@@ -257,33 +377,89 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	})
 }
 
-// SuspendUser sets a user's status to suspended
-func (h *UserHandler) SuspendUser(c *gin.Context) {
+// UpdateUserProfileRequest replaces the descriptive fields of a user
+type UpdateUserProfileRequest struct {
+	Name         string `json:"name" binding:"max=200"`
+	Organization string `json:"organization" binding:"max=200"`
+	Notes        string `json:"notes" binding:"max=4000"`
+}
+
+// UpdateUserProfile replaces a user's name, organization and notes
+func (h *UserHandler) UpdateUserProfile(c *gin.Context) {
 	userID := c.Param("id")
-	if userID == "" {
-		log.Printf("SuspendUser: Missing user ID parameter")
+
+	var req UpdateUserProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("UpdateUserProfile: Invalid JSON: %v", err)
 		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
 			Error:   "Bad Request",
-			Message: "User ID is required",
+			Message: "Invalid JSON or field too long",
 		})
 		return
 	}
 
 	ctx := context.Background()
-
-	// First check if user exists
-	user, err := h.sqliteService.GetUserByID(ctx, userID)
+	user, err := h.accountService.GetUserByID(ctx, userID)
 	if err != nil {
-		log.Printf("SuspendUser: Failed to retrieve user %s: %v", userID, err)
+		log.Printf("UpdateUserProfile: Failed to retrieve user %s: %v", userID, err)
 		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
 			Error:   "Internal Server Error",
 			Message: "Failed to retrieve user",
 		})
 		return
 	}
+	if user == nil {
+		log.Printf("UpdateUserProfile: User not found with ID: %s", userID)
+		c.JSON(http.StatusNotFound, l4error.ErrorResponse{
+			Error:   "Not Found",
+			Message: "User not found",
+		})
+		return
+	}
+
+	err = h.accountService.UpdateUserProfile(ctx, userID, strings.TrimSpace(req.Name), strings.TrimSpace(req.Organization), req.Notes, getActor(c))
+	if err != nil {
+		log.Printf("UpdateUserProfile: Failed to update user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to update user profile",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User profile updated successfully",
+		"user_id": userID,
+	})
+}
+
+// UpdateUserPreferences replaces the preferences of a user, such as the language their
+// emails are sent in
+func (h *UserHandler) UpdateUserPreferences(c *gin.Context) {
+	userID := c.Param("id")
+
+	var preferences model.UserPreferences
+	if err := c.ShouldBindJSON(&preferences); err != nil {
+		log.Printf("UpdateUserPreferences: Invalid JSON: %v", err)
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid JSON",
+		})
+		return
+	}
 
+	ctx := context.Background()
+	user, err := h.accountService.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("UpdateUserPreferences: Failed to retrieve user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to retrieve user",
+		})
+		return
+	}
 	if user == nil {
-		log.Printf("SuspendUser: User not found with ID: %s", userID)
+		log.Printf("UpdateUserPreferences: User not found with ID: %s", userID)
 		c.JSON(http.StatusNotFound, l4error.ErrorResponse{
 			Error:   "Not Found",
 			Message: "User not found",
@@ -291,29 +467,56 @@ func (h *UserHandler) SuspendUser(c *gin.Context) {
 		return
 	}
 
-	// Update user status to suspended
-	err = h.sqliteService.UpdateUserStatus(ctx, userID, model.UserStatusSuspended)
+	err = h.accountService.UpdateUserPreferences(ctx, userID, preferences, getActor(c))
+	if errors.Is(err, service.ErrUnsupportedLanguage) {
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Language must be one of " + strings.Join(locale.Supported, ", "),
+		})
+		return
+	}
+	if errors.Is(err, service.ErrInvalidNotificationOptOut) {
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Notification opt-outs must be non-critical notification types",
+		})
+		return
+	}
 	if err != nil {
-		log.Printf("SuspendUser: Failed to suspend user %s: %v", userID, err)
+		log.Printf("UpdateUserPreferences: Failed to update user %s: %v", userID, err)
 		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
 			Error:   "Internal Server Error",
-			Message: "Failed to suspend user",
+			Message: "Failed to update user preferences",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User suspended successfully",
+		"message": "User preferences updated successfully",
 		"user_id": userID,
-		"status":  string(model.UserStatusSuspended),
 	})
 }
 
+// SuspendUser sets a user's status to suspended
+func (h *UserHandler) SuspendUser(c *gin.Context) {
+	h.changeUserStatus(c, "SuspendUser", model.UserStatusSuspended, "suspend", "suspended")
+}
+
 // ActivateUser sets a user's status to active
 func (h *UserHandler) ActivateUser(c *gin.Context) {
+	h.changeUserStatus(c, "ActivateUser", model.UserStatusActive, "activate", "activated")
+}
+
+// LockUser sets a user's status to locked
+func (h *UserHandler) LockUser(c *gin.Context) {
+	h.changeUserStatus(c, "LockUser", model.UserStatusLocked, "lock", "locked")
+}
+
+// changeUserStatus moves the user in the request path to the target status
+func (h *UserHandler) changeUserStatus(c *gin.Context, handlerName string, target model.UserStatus, verb, pastVerb string) {
 	userID := c.Param("id")
 	if userID == "" {
-		log.Printf("ActivateUser: Missing user ID parameter")
+		log.Printf("%s: Missing user ID parameter", handlerName)
 		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
 			Error:   "Bad Request",
 			Message: "User ID is required",
@@ -324,9 +527,9 @@ func (h *UserHandler) ActivateUser(c *gin.Context) {
 	ctx := context.Background()
 
 	// First check if user exists
-	user, err := h.sqliteService.GetUserByID(ctx, userID)
+	user, err := h.accountService.GetUserByID(ctx, userID)
 	if err != nil {
-		log.Printf("ActivateUser: Failed to retrieve user %s: %v", userID, err)
+		log.Printf("%s: Failed to retrieve user %s: %v", handlerName, userID, err)
 		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
 			Error:   "Internal Server Error",
 			Message: "Failed to retrieve user",
@@ -335,7 +538,7 @@ func (h *UserHandler) ActivateUser(c *gin.Context) {
 	}
 
 	if user == nil {
-		log.Printf("ActivateUser: User not found with ID: %s", userID)
+		log.Printf("%s: User not found with ID: %s", handlerName, userID)
 		c.JSON(http.StatusNotFound, l4error.ErrorResponse{
 			Error:   "Not Found",
 			Message: "User not found",
@@ -343,25 +546,43 @@ func (h *UserHandler) ActivateUser(c *gin.Context) {
 		return
 	}
 
-	// Update user status to active
-	err = h.sqliteService.UpdateUserStatus(ctx, userID, model.UserStatusActive)
+	// Deleted users come back only through RestoreUser, which enforces the retention window
+	if user.Status == model.UserStatusDeleted {
+		log.Printf("%s: User %s is deleted", handlerName, userID)
+		c.JSON(http.StatusConflict, l4error.ErrorResponse{
+			Error:   "Conflict",
+			Message: "Deleted users must be restored first",
+		})
+		return
+	}
+
+	// Update user status if the lifecycle allows it
+	err = h.accountService.TransitionUserStatus(ctx, userID, target, getActor(c), getStatusChangeReason(c))
+	if errors.Is(err, service.ErrInvalidStatusTransition) {
+		log.Printf("%s: User %s cannot be %s - current status: %s", handlerName, userID, pastVerb, user.Status)
+		c.JSON(http.StatusConflict, l4error.ErrorResponse{
+			Error:   "Conflict",
+			Message: "User cannot be " + pastVerb + " from status " + string(user.Status),
+		})
+		return
+	}
 	if err != nil {
-		log.Printf("ActivateUser: Failed to activate user %s: %v", userID, err)
+		log.Printf("%s: Failed to %s user %s: %v", handlerName, verb, userID, err)
 		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
 			Error:   "Internal Server Error",
-			Message: "Failed to activate user",
+			Message: "Failed to " + verb + " user",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User activated successfully",
+		"message": "User " + pastVerb + " successfully",
 		"user_id": userID,
-		"status":  string(model.UserStatusActive),
+		"status":  string(target),
 	})
 }
 
-// DeleteUser permanently deletes a user (only if suspended)
+// DeleteUser soft deletes a user (only if suspended); they are purged after the retention window
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	userID := c.Param("id")
 	if userID == "" {
@@ -376,7 +597,7 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 	ctx := context.Background()
 
 	// First check if user exists
-	user, err := h.sqliteService.GetUserByID(ctx, userID)
+	user, err := h.accountService.GetUserByID(ctx, userID)
 	if err != nil {
 		log.Printf("DeleteUser: Failed to retrieve user %s: %v", userID, err)
 		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
@@ -406,7 +627,7 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 	}
 
 	// Delete the user
-	err = h.sqliteService.DeleteUser(ctx, userID)
+	err = h.accountService.DeleteUser(ctx, userID, getActor(c), getStatusChangeReason(c))
 	if err != nil {
 		log.Printf("DeleteUser: Failed to delete user %s: %v", userID, err)
 		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
@@ -417,7 +638,208 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User deleted successfully",
+		"message":  "User deleted successfully",
+		"user_id":  userID,
+		"purge_at": time.Now().Add(policy.GetUserDeletionRetention()).UnixMilli(),
+	})
+}
+
+// RevokeUserSessions signs a user out of every device, for example after a lost laptop;
+// the user is told they were signed out
+func (h *UserHandler) RevokeUserSessions(c *gin.Context) {
+	userID := c.Param("id")
+	ctx := context.Background()
+
+	user, err := h.accountService.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("RevokeUserSessions: Failed to retrieve user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to retrieve user",
+		})
+		return
+	}
+	if user == nil {
+		log.Printf("RevokeUserSessions: User not found with ID: %s", userID)
+		c.JSON(http.StatusNotFound, l4error.ErrorResponse{
+			Error:   "Not Found",
+			Message: "User not found",
+		})
+		return
+	}
+
+	revoked, err := h.accountService.SignOutUser(ctx, user, getActor(c), getStatusChangeReason(c))
+	if err != nil {
+		log.Printf("RevokeUserSessions: Failed to revoke sessions of user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to revoke user sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User sessions revoked successfully",
+		"user_id": userID,
+		"revoked": revoked,
+	})
+}
+
+// RestoreUser brings back a soft deleted user, in the suspended status, within the retention window
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	userID := c.Param("id")
+	if userID == "" {
+		log.Printf("RestoreUser: Missing user ID parameter")
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "User ID is required",
+		})
+		return
+	}
+
+	ctx := context.Background()
+
+	// First check if user exists
+	user, err := h.accountService.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("RestoreUser: Failed to retrieve user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to retrieve user",
+		})
+		return
+	}
+
+	if user == nil {
+		log.Printf("RestoreUser: User not found with ID: %s", userID)
+		c.JSON(http.StatusNotFound, l4error.ErrorResponse{
+			Error:   "Not Found",
+			Message: "User not found",
+		})
+		return
+	}
+
+	if user.Status != model.UserStatusDeleted {
+		log.Printf("RestoreUser: User %s is not deleted - current status: %s", userID, user.Status)
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Only deleted users can be restored",
+		})
+		return
+	}
+
+	// Users past the retention window are waiting to be purged
+	deletedAt := user.UpdatedAt
+	if user.StatusChangedAt != nil {
+		deletedAt = *user.StatusChangedAt
+	}
+	if time.UnixMilli(deletedAt).Add(policy.GetUserDeletionRetention()).Before(time.Now()) {
+		log.Printf("RestoreUser: Retention window expired for user %s", userID)
+		c.JSON(http.StatusGone, l4error.ErrorResponse{
+			Error:   "Gone",
+			Message: "Retention window has expired",
+		})
+		return
+	}
+
+	err = h.accountService.RestoreUser(ctx, userID, getActor(c), getStatusChangeReason(c))
+	if err != nil {
+		log.Printf("RestoreUser: Failed to restore user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to restore user",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User restored successfully",
+		"user_id": userID,
+		"status":  string(model.UserStatusSuspended),
+	})
+}
+
+// ChangeUserEmailRequest represents the request payload for changing a user's email
+type ChangeUserEmailRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// ChangeUserEmail starts an email change on behalf of a user; it takes effect once
+// the user confirms the new address
+func (h *UserHandler) ChangeUserEmail(c *gin.Context) {
+	userID := c.Param("id")
+	if userID == "" {
+		log.Printf("ChangeUserEmail: Missing user ID parameter")
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "User ID is required",
+		})
+		return
+	}
+
+	var req ChangeUserEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("ChangeUserEmail: Invalid JSON or missing required fields: %v", err)
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid JSON or missing required fields",
+		})
+		return
+	}
+
+	email := strings.TrimSpace(req.Email)
+	if !util.IsValidEmail(email) {
+		log.Printf("ChangeUserEmail: Invalid email provided for user %s", userID)
+		c.JSON(http.StatusBadRequest, l4error.ErrorResponse{
+			Error:   "Bad Request",
+			Message: "Invalid email format",
+		})
+		return
+	}
+
+	ctx := context.Background()
+
+	// First check if user exists
+	user, err := h.accountService.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("ChangeUserEmail: Failed to retrieve user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to retrieve user",
+		})
+		return
+	}
+
+	if user == nil || user.Status == model.UserStatusDeleted {
+		log.Printf("ChangeUserEmail: User not found with ID: %s", userID)
+		c.JSON(http.StatusNotFound, l4error.ErrorResponse{
+			Error:   "Not Found",
+			Message: "User not found",
+		})
+		return
+	}
+
+	change, err := h.accountService.StartEmailChange(ctx, user, email, locale.Resolve(user.Preferences.Language), getActor(c))
+	if errors.Is(err, service.ErrEmailTaken) {
+		log.Printf("ChangeUserEmail: Email already in use for user %s", userID)
+		c.JSON(http.StatusConflict, l4error.ErrorResponse{
+			Error:   "Conflict",
+			Message: "Email address is already in use",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("ChangeUserEmail: Failed to start email change for user %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, l4error.ErrorResponse{
+			Error:   "Internal Server Error",
+			Message: "Failed to start email change",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Confirmation email sent to the new address",
 		"user_id": userID,
+		"change":  change,
 	})
 }
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/service"
+	"github.com/luna4dev/airlock/internal/webauthn"
+)
+
+// RegisterPasskeyRequest represents the request payload for saving a new passkey
+type RegisterPasskeyRequest struct {
+	Name       string                        `json:"name"`
+	Credential webauthn.RegistrationResponse `json:"credential" binding:"required"`
+}
+
+// PasskeyLoginOptionsHandler starts a passkey sign in, returning the options for
+// navigator.credentials.get
+func (h *AuthHandler) PasskeyLoginOptionsHandler(c *gin.Context) {
+	options, err := h.accountService.BeginPasskeyLogin(context.Background())
+	if err != nil {
+		log.Printf("PasskeyLoginOptionsHandler: Failed to start passkey sign in: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey sign in"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// PasskeyLoginHandler signs a user in with the assertion their passkey made, returning a
+// bearer token like email verification does
+func (h *AuthHandler) PasskeyLoginHandler(c *gin.Context) {
+	var req webauthn.AssertionResponse
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON or missing credential fields"})
+		return
+	}
+
+	user, session, err := h.accountService.FinishPasskeyLogin(context.Background(), &req, c.Request.UserAgent(), c.ClientIP())
+	switch {
+	case errors.Is(err, service.ErrAccountInactive):
+		rejectInactiveUser(c, user)
+		return
+	case errors.Is(err, service.ErrPasskeyChallenge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Passkey challenge is invalid, expired or already used"})
+		return
+	case errors.Is(err, service.ErrPasskeyNotFound), errors.Is(err, webauthn.ErrVerification):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Passkey verification failed"})
+		return
+	case err != nil:
+		log.Printf("PasskeyLoginHandler: Failed to complete passkey sign in: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete authentication"})
+		return
+	}
+
+	respondWithSession(c, user, session, "Passkey sign in successful")
+}
+
+// PasskeyRegistrationOptionsHandler starts adding a passkey to the signed in user's account,
+// returning the options for navigator.credentials.create
+func (h *AccountHandler) PasskeyRegistrationOptionsHandler(c *gin.Context) {
+	user := c.MustGet(contextKeyUser).(*model.Luna4User)
+
+	options, err := h.accountService.BeginPasskeyRegistration(context.Background(), user)
+	if err != nil {
+		log.Printf("PasskeyRegistrationOptionsHandler: Failed to start passkey registration: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey registration"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// RegisterPasskeyHandler saves the passkey the signed in user's authenticator created
+func (h *AccountHandler) RegisterPasskeyHandler(c *gin.Context) {
+	user := c.MustGet(contextKeyUser).(*model.Luna4User)
+
+	var req RegisterPasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON or missing credential fields"})
+		return
+	}
+
+	passkey, err := h.accountService.FinishPasskeyRegistration(context.Background(), user, req.Name, &req.Credential)
+	switch {
+	case errors.Is(err, service.ErrPasskeyChallenge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Passkey challenge is invalid, expired or already used"})
+		return
+	case errors.Is(err, webauthn.ErrVerification):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Passkey verification failed"})
+		return
+	case errors.Is(err, service.ErrPasskeyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Passkey is already registered"})
+		return
+	case err != nil:
+		log.Printf("RegisterPasskeyHandler: Failed to register passkey: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register passkey"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Passkey registered successfully",
+		"passkey": passkey,
+	})
+}
+
+// GetPasskeysHandler lists the signed in user's passkeys
+func (h *AccountHandler) GetPasskeysHandler(c *gin.Context) {
+	user := c.MustGet(contextKeyUser).(*model.Luna4User)
+
+	passkeys, err := h.accountService.GetUserWebAuthnCredentials(context.Background(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"passkeys": passkeys,
+		"count":    len(passkeys),
+	})
+}
+
+// RemovePasskeyHandler deletes one of the signed in user's passkeys
+func (h *AccountHandler) RemovePasskeyHandler(c *gin.Context) {
+	user := c.MustGet(contextKeyUser).(*model.Luna4User)
+	passkeyID := c.Param("id")
+
+	err := h.accountService.RemovePasskey(context.Background(), user.ID, passkeyID, user.ID)
+	if errors.Is(err, service.ErrPasskeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkey not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove passkey"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Passkey removed successfully",
+		"passkey_id": passkeyID,
+	})
+}
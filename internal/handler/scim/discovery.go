@@ -0,0 +1,121 @@
+package scim
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetServiceProviderConfig describes the SCIM features airlock supports
+func GetServiceProviderConfig(c *gin.Context) {
+	writeJSON(c, http.StatusOK, gin.H{
+		"schemas":        []string{schemaServiceProviderConfig},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": maxListCount},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "Per-tenant token issued through the airlock maintenance API",
+			"primary":     true,
+		}},
+		"meta": gin.H{"resourceType": "ServiceProviderConfig", "location": baseURL(c) + "/ServiceProviderConfig"},
+	})
+}
+
+// GetResourceTypes lists the User and Group resource types
+func GetResourceTypes(c *gin.Context) {
+	writeJSON(c, http.StatusOK, listResponse(resourceTypes(baseURL(c)), listQuery{startIndex: 1, count: maxListCount}))
+}
+
+func resourceTypes(base string) []gin.H {
+	return []gin.H{
+		{
+			"schemas":          []string{schemaResourceType},
+			"id":               "User",
+			"name":             "User",
+			"endpoint":         "/Users",
+			"schema":           schemaUser,
+			"schemaExtensions": []gin.H{{"schema": schemaEnterpriseUser, "required": false}},
+			"meta":             gin.H{"resourceType": "ResourceType", "location": base + "/ResourceTypes/User"},
+		},
+		{
+			"schemas":  []string{schemaResourceType},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   schemaGroup,
+			"meta":     gin.H{"resourceType": "ResourceType", "location": base + "/ResourceTypes/Group"},
+		},
+	}
+}
+
+// GetSchemas describes the attributes airlock keeps for each resource type
+func GetSchemas(c *gin.Context) {
+	writeJSON(c, http.StatusOK, listResponse(schemas(baseURL(c)), listQuery{startIndex: 1, count: maxListCount}))
+}
+
+func attribute(name, kind string, required bool, mutability string, extra gin.H) gin.H {
+	attr := gin.H{
+		"name":        name,
+		"type":        kind,
+		"multiValued": false,
+		"required":    required,
+		"caseExact":   false,
+		"mutability":  mutability,
+		"returned":    "default",
+		"uniqueness":  "none",
+	}
+	for key, value := range extra {
+		attr[key] = value
+	}
+	return attr
+}
+
+func schemas(base string) []gin.H {
+	reference := []gin.H{
+		attribute("value", "string", true, "readWrite", nil),
+		attribute("$ref", "reference", false, "readOnly", nil),
+		attribute("display", "string", false, "readOnly", nil),
+	}
+
+	schema := func(id, name string, attributes []gin.H) gin.H {
+		return gin.H{
+			"schemas":    []string{schemaSchema},
+			"id":         id,
+			"name":       name,
+			"attributes": attributes,
+			"meta":       gin.H{"resourceType": "Schema", "location": base + "/Schemas/" + id},
+		}
+	}
+
+	return []gin.H{
+		schema(schemaUser, "User", []gin.H{
+			attribute("userName", "string", true, "readWrite", gin.H{"uniqueness": "server"}),
+			attribute("externalId", "string", false, "readWrite", nil),
+			attribute("displayName", "string", false, "readWrite", nil),
+			attribute("name", "complex", false, "readWrite", gin.H{"subAttributes": []gin.H{
+				attribute("formatted", "string", false, "readWrite", nil),
+				attribute("givenName", "string", false, "readWrite", nil),
+				attribute("familyName", "string", false, "readWrite", nil),
+			}}),
+			attribute("emails", "complex", false, "readWrite", gin.H{"multiValued": true, "subAttributes": []gin.H{
+				attribute("value", "string", false, "readWrite", nil),
+				attribute("type", "string", false, "readWrite", nil),
+				attribute("primary", "boolean", false, "readWrite", nil),
+			}}),
+			attribute("active", "boolean", false, "readWrite", nil),
+			attribute("groups", "complex", false, "readOnly", gin.H{"multiValued": true, "subAttributes": reference}),
+		}),
+		schema(schemaEnterpriseUser, "EnterpriseUser", []gin.H{
+			attribute("organization", "string", false, "readWrite", nil),
+		}),
+		schema(schemaGroup, "Group", []gin.H{
+			attribute("displayName", "string", true, "immutable", gin.H{"uniqueness": "server"}),
+			attribute("members", "complex", false, "readWrite", gin.H{"multiValued": true, "subAttributes": reference}),
+		}),
+	}
+}
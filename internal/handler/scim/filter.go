@@ -0,0 +1,391 @@
+package scim
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// filter is a parsed SCIM filter expression (RFC 7644 section 3.4.2.2). Filters are
+// evaluated against the JSON form of a resource, so attribute names match
+// case-insensitively and multi-valued attributes match when any value does.
+type filter interface {
+	match(node map[string]any) bool
+}
+
+type logicalFilter struct {
+	and         bool
+	left, right filter
+}
+
+type notFilter struct {
+	inner filter
+}
+
+type compareFilter struct {
+	path  []string
+	op    string
+	value any
+}
+
+// valuePathFilter matches when an element of a multi-valued attribute matches the inner
+// filter, as in emails[type eq "work"]
+type valuePathFilter struct {
+	path  []string
+	inner filter
+}
+
+var compareOperators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true, "pr": true,
+}
+
+// matchResource evaluates the filter against a resource
+func matchResource(f filter, resource any) bool {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return false
+	}
+	var node map[string]any
+	if err := json.Unmarshal(data, &node); err != nil {
+		return false
+	}
+	return f.match(node)
+}
+
+func (f *logicalFilter) match(node map[string]any) bool {
+	if f.and {
+		return f.left.match(node) && f.right.match(node)
+	}
+	return f.left.match(node) || f.right.match(node)
+}
+
+func (f *notFilter) match(node map[string]any) bool {
+	return !f.inner.match(node)
+}
+
+func (f *valuePathFilter) match(node map[string]any) bool {
+	for _, value := range resolvePath(node, f.path) {
+		if element, ok := value.(map[string]any); ok && f.inner.match(element) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *compareFilter) match(node map[string]any) bool {
+	values := resolvePath(node, f.path)
+	switch f.op {
+	case "pr":
+		for _, value := range values {
+			if value != nil && value != "" {
+				return true
+			}
+		}
+		return false
+	case "ne":
+		for _, value := range values {
+			if compareValue("eq", value, f.value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if f.value == nil {
+		return f.op == "eq" && len(values) == 0
+	}
+	for _, value := range values {
+		if compareValue(f.op, value, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareValue applies a comparison operator. Strings compare case-insensitively and
+// date-times compare in their RFC 3339 form.
+func compareValue(op string, actual, expected any) bool {
+	switch expected := expected.(type) {
+	case string:
+		actual, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		a, e := strings.ToLower(actual), strings.ToLower(expected)
+		switch op {
+		case "eq":
+			return a == e
+		case "co":
+			return strings.Contains(a, e)
+		case "sw":
+			return strings.HasPrefix(a, e)
+		case "ew":
+			return strings.HasSuffix(a, e)
+		case "gt":
+			return a > e
+		case "ge":
+			return a >= e
+		case "lt":
+			return a < e
+		case "le":
+			return a <= e
+		}
+	case bool:
+		actual, ok := actual.(bool)
+		return ok && op == "eq" && actual == expected
+	case float64:
+		actual, ok := actual.(float64)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return actual == expected
+		case "gt":
+			return actual > expected
+		case "ge":
+			return actual >= expected
+		case "lt":
+			return actual < expected
+		case "le":
+			return actual <= expected
+		}
+	}
+	return false
+}
+
+// resolvePath returns every value found at the attribute path, flattening multi-valued
+// attributes along the way
+func resolvePath(node map[string]any, path []string) []any {
+	values := []any{node}
+	for _, name := range path {
+		var next []any
+		for _, value := range values {
+			object, ok := value.(map[string]any)
+			if !ok {
+				continue
+			}
+			for key, child := range object {
+				if !strings.EqualFold(key, name) {
+					continue
+				}
+				if list, ok := child.([]any); ok {
+					next = append(next, list...)
+				} else if child != nil {
+					next = append(next, child)
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// splitAttrPath splits an attribute path into the names to follow. A path may start with
+// a schema URN; the core schemas are implied and extension schemas are a top-level key.
+func splitAttrPath(attr string) []string {
+	if strings.HasPrefix(strings.ToLower(attr), "urn:") {
+		i := strings.LastIndex(attr, ":")
+		urn, rest := attr[:i], attr[i+1:]
+		if strings.EqualFold(urn, schemaUser) || strings.EqualFold(urn, schemaGroup) {
+			return strings.Split(rest, ".")
+		}
+		return append([]string{urn}, strings.Split(rest, ".")...)
+	}
+	return strings.Split(attr, ".")
+}
+
+// parseFilter parses a filter expression
+func parseFilter(expression string) (filter, *Error) {
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, badRequest("invalidFilter", "Unexpected %q in filter", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+func tokenizeFilter(expression string) ([]filterToken, *Error) {
+	var tokens []filterToken
+	for i := 0; i < len(expression); {
+		switch ch := expression[i]; {
+		case ch == ' ' || ch == '\t':
+			i++
+		case ch == '(' || ch == ')' || ch == '[' || ch == ']':
+			tokens = append(tokens, filterToken{text: string(ch)})
+			i++
+		case ch == '"':
+			// Find the closing quote, skipping escaped characters
+			end := i + 1
+			for end < len(expression) && expression[end] != '"' {
+				if expression[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expression) {
+				return nil, badRequest("invalidFilter", "Unterminated string in filter")
+			}
+			var text string
+			if err := json.Unmarshal([]byte(expression[i:end+1]), &text); err != nil {
+				return nil, badRequest("invalidFilter", "Invalid string in filter")
+			}
+			tokens = append(tokens, filterToken{text: text, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(expression) && !strings.ContainsRune(" \t()[]\"", rune(expression[end])) {
+				end++
+			}
+			tokens = append(tokens, filterToken{text: expression[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// peekWord reports whether the next token is the unquoted keyword
+func (p *filterParser) peekWord(word string) bool {
+	token, ok := p.peek()
+	return ok && !token.quoted && strings.EqualFold(token.text, word)
+}
+
+func (p *filterParser) expect(text string) *Error {
+	token, ok := p.peek()
+	if !ok || token.quoted || token.text != text {
+		return badRequest("invalidFilter", "Expected %q in filter", text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *filterParser) parseOr() (filter, *Error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekWord("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filter, *Error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekWord("and") {
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseTerm() (filter, *Error) {
+	if p.peekWord("not") {
+		p.pos++
+		inner, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return &notFilter{inner: inner}, nil
+	}
+	if token, ok := p.peek(); ok && !token.quoted && token.text == "(" {
+		return p.parseGroup()
+	}
+	return p.parseAttrExp()
+}
+
+func (p *filterParser) parseGroup() (filter, *Error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	inner, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return inner, nil
+}
+
+func (p *filterParser) parseAttrExp() (filter, *Error) {
+	token, ok := p.peek()
+	if !ok || token.quoted || strings.ContainsAny(token.text, "()[]") {
+		return nil, badRequest("invalidFilter", "Expected an attribute in filter")
+	}
+	p.pos++
+	path := splitAttrPath(token.text)
+
+	if next, ok := p.peek(); ok && !next.quoted && next.text == "[" {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return &valuePathFilter{path: path, inner: inner}, nil
+	}
+
+	opToken, ok := p.peek()
+	op := strings.ToLower(opToken.text)
+	if !ok || opToken.quoted || !compareOperators[op] {
+		return nil, badRequest("invalidFilter", "Expected an operator after %s", token.text)
+	}
+	p.pos++
+	if op == "pr" {
+		return &compareFilter{path: path, op: op}, nil
+	}
+
+	valueToken, ok := p.peek()
+	if !ok {
+		return nil, badRequest("invalidFilter", "Expected a value after %s %s", token.text, op)
+	}
+	p.pos++
+
+	var value any = valueToken.text
+	if !valueToken.quoted {
+		if err := json.Unmarshal([]byte(strings.ToLower(valueToken.text)), &value); err != nil {
+			return nil, badRequest("invalidFilter", "Invalid value %q in filter", valueToken.text)
+		}
+	}
+	return &compareFilter{path: path, op: op, value: value}, nil
+}
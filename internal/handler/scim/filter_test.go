@@ -0,0 +1,99 @@
+package scim
+
+import "testing"
+
+func testUser() User {
+	active := true
+	return User{
+		Schemas:     []string{schemaUser, schemaEnterpriseUser},
+		ID:          "2819c223",
+		ExternalID:  "ext-42",
+		UserName:    "Barbara.Jensen@example.com",
+		DisplayName: "Barbara Jensen",
+		Name:        &Name{Formatted: "Barbara Jensen", GivenName: "Barbara", FamilyName: "Jensen"},
+		Emails: []Email{
+			{Value: "barbara.jensen@example.com", Type: "work", Primary: true},
+			{Value: "babs@home.example.org", Type: "home"},
+		},
+		Active:     &active,
+		Groups:     []Reference{{Value: "PRUNK:USER"}},
+		Enterprise: &EnterpriseUser{Organization: "Luna4"},
+		Meta:       &Meta{ResourceType: "User", LastModified: "2026-03-01T10:00:00Z", Location: "/Users/2819c223"},
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`userName eq "barbara.jensen@example.com"`, true},
+		{`USERNAME EQ "BARBARA.JENSEN@EXAMPLE.COM"`, true},
+		{`userName eq "someone@example.com"`, false},
+		{`userName ne "someone@example.com"`, true},
+		{`userName co "jensen"`, true},
+		{`userName sw "barbara"`, true},
+		{`userName ew "@example.com"`, true},
+		{`userName ew "@example.org"`, false},
+		{`name.familyName eq "Jensen"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:name.givenName eq "Barbara"`, true},
+		{`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:organization eq "Luna4"`, true},
+		{`emails.value eq "babs@home.example.org"`, true},
+		{`emails[type eq "work" and value co "example.com"]`, true},
+		{`emails[type eq "home" and value co "example.com"]`, false},
+		{`emails[type eq "other"]`, false},
+		{`active eq true`, true},
+		{`active eq false`, false},
+		{`externalId pr`, true},
+		{`nickName pr`, false},
+		{`meta.lastModified gt "2026-01-01T00:00:00Z"`, true},
+		{`meta.lastModified lt "2026-01-01T00:00:00Z"`, false},
+		{`userName eq "x" or displayName eq "Barbara Jensen"`, true},
+		{`userName eq "x" or displayName eq "x" and active eq true`, false},
+		{`(userName eq "x" or displayName eq "Barbara Jensen") and active eq true`, true},
+		{`not (active eq true)`, false},
+		{`not (userName eq "x")`, true},
+		{`groups.value eq "PRUNK:USER"`, true},
+		{`displayName eq "Barbara \"Babs\" Jensen"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := parseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("failed to parse filter: %v", err)
+			}
+			if got := matchResource(f, testUser()); got != tt.want {
+				t.Errorf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterRejects(t *testing.T) {
+	tests := []string{
+		`userName`,
+		`userName eq`,
+		`userName equals "x"`,
+		`userName eq "unterminated`,
+		`userName eq "x" and`,
+		`(userName eq "x"`,
+		`userName eq "x")`,
+		`emails[type eq "work"`,
+		`not userName eq "x"`,
+		`userName eq bare`,
+		`"userName" eq "x"`,
+	}
+
+	for _, expression := range tests {
+		t.Run(expression, func(t *testing.T) {
+			f, err := parseFilter(expression)
+			if err == nil {
+				t.Fatalf("parsed into %#v, want an error", f)
+			}
+			if err.Status != 400 || err.ScimType != "invalidFilter" {
+				t.Errorf("error is %d %s, want 400 invalidFilter", err.Status, err.ScimType)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package scim
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/service"
+)
+
+// GroupHandler serves the SCIM Groups endpoint of the authenticated tenant. Groups are
+// service permissions, so membership changes grant and revoke access.
+type GroupHandler struct {
+	accountService *service.AccountService
+}
+
+// NewGroupHandler creates a new SCIM group handler with injected dependencies
+func NewGroupHandler(accountService *service.AccountService) *GroupHandler {
+	return &GroupHandler{
+		accountService: accountService,
+	}
+}
+
+// GetGroups lists the tenant's groups matching the filter
+func (h *GroupHandler) GetGroups(c *gin.Context) {
+	query, scimErr := parseListQuery(c)
+	if scimErr != nil {
+		writeError(c, scimErr)
+		return
+	}
+
+	accounts, ok := h.getAccounts(c)
+	if !ok {
+		return
+	}
+
+	groups := []Group{}
+	for _, group := range newGroups(accounts, baseURL(c)) {
+		if query.matches(group) {
+			groups = append(groups, group)
+		}
+	}
+	writeJSON(c, http.StatusOK, listResponse(groups, query))
+}
+
+// GetGroup returns a group with the tenant's members
+func (h *GroupHandler) GetGroup(c *gin.Context) {
+	group, ok := h.getGroup(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	writeJSON(c, http.StatusOK, group)
+}
+
+// CreateGroup sets the members of the group named SERVICE:PERMISSION. Every service
+// permission exists as a group, so creating one only assigns its members.
+func (h *GroupHandler) CreateGroup(c *gin.Context) {
+	var req Group
+	if err := readBody(c, &req); err != nil {
+		writeError(c, err)
+		return
+	}
+	if _, _, ok := parseGroupID(req.DisplayName); !ok {
+		writeError(c, badRequest("invalidValue", "displayName must be SERVICE:PERMISSION with permission USER or SUPER_USER"))
+		return
+	}
+	h.setMembers(c, req.DisplayName, req.Members, http.StatusCreated)
+}
+
+// ReplaceGroup sets the members of a group
+func (h *GroupHandler) ReplaceGroup(c *gin.Context) {
+	group, ok := h.getGroup(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req Group
+	if err := readBody(c, &req); err != nil {
+		writeError(c, err)
+		return
+	}
+	if req.DisplayName != "" && req.DisplayName != group.DisplayName {
+		writeError(c, badRequest("mutability", "Group names are SERVICE:PERMISSION and cannot change"))
+		return
+	}
+	h.setMembers(c, group.ID, req.Members, http.StatusOK)
+}
+
+// PatchGroup adds and removes members of a group
+func (h *GroupHandler) PatchGroup(c *gin.Context) {
+	group, ok := h.getGroup(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req PatchRequest
+	if err := readBody(c, &req); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err := applyGroupPatch(group, &req); err != nil {
+		writeError(c, err)
+		return
+	}
+	h.setMembers(c, group.ID, group.Members, http.StatusOK)
+}
+
+// DeleteGroup revokes the service permission from every user of the tenant
+func (h *GroupHandler) DeleteGroup(c *gin.Context) {
+	group, ok := h.getGroup(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if h.saveMembers(c, group.ID, nil) {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func (h *GroupHandler) setMembers(c *gin.Context, id string, members []Reference, status int) {
+	if !h.saveMembers(c, id, members) {
+		return
+	}
+	group, ok := h.getGroup(c, id)
+	if !ok {
+		return
+	}
+	c.Header("Location", group.Meta.Location)
+	writeJSON(c, status, group)
+}
+
+// saveMembers makes the members the only holders of the group's service permission
+func (h *GroupHandler) saveMembers(c *gin.Context, id string, members []Reference) bool {
+	grantService, permission, _ := parseGroupID(id)
+	tenant := getTenant(c)
+
+	err := h.accountService.SetScimGroupMembers(c, tenant, grantService, permission, memberIDs(members))
+	if errors.Is(err, service.ErrUnknownScimMember) {
+		writeError(c, badRequest("invalidValue", "Every member must be a user provisioned by this tenant"))
+		return false
+	}
+	if err != nil {
+		log.Printf("ScimGroup: Failed to set members of %s for tenant %s: %v", id, tenant, err)
+		writeInternalError(c, "Failed to update group")
+		return false
+	}
+	return true
+}
+
+func (h *GroupHandler) getAccounts(c *gin.Context) ([]model.Luna4ScimAccount, bool) {
+	accounts, err := h.accountService.GetScimAccounts(c, getTenant(c))
+	if err != nil {
+		log.Printf("ScimGroup: Failed to retrieve users of tenant %s: %v", getTenant(c), err)
+		writeInternalError(c, "Failed to retrieve groups")
+		return nil, false
+	}
+	return accounts, true
+}
+
+// getGroup loads a group, responding with 404 when the ID is not a service permission
+func (h *GroupHandler) getGroup(c *gin.Context, id string) (*Group, bool) {
+	accounts, ok := h.getAccounts(c)
+	if !ok {
+		return nil, false
+	}
+
+	group, ok := findGroup(accounts, baseURL(c), id)
+	if !ok {
+		writeNotFound(c, "Group")
+		return nil, false
+	}
+	return group, true
+}
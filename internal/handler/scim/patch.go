@@ -0,0 +1,315 @@
+package scim
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"strings"
+)
+
+// PatchRequest is a SCIM PATCH body (RFC 7644 section 3.5.2)
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// validate checks the operations before any is applied
+func (r *PatchRequest) validate() *Error {
+	if !slices.Contains(r.Schemas, schemaPatchOp) {
+		return badRequest("invalidSyntax", "PATCH requests must use the %s schema", schemaPatchOp)
+	}
+	if len(r.Operations) == 0 {
+		return badRequest("invalidSyntax", "PATCH request has no operations")
+	}
+	for i := range r.Operations {
+		op := &r.Operations[i]
+		op.Op = strings.ToLower(op.Op)
+		if op.Op != "add" && op.Op != "replace" && op.Op != "remove" {
+			return badRequest("invalidSyntax", "Unsupported PATCH operation %q", op.Op)
+		}
+		if op.Op != "remove" && len(op.Value) == 0 {
+			return badRequest("invalidValue", "PATCH operation %s needs a value", op.Op)
+		}
+	}
+	return nil
+}
+
+// normalizePath lowercases an attribute path and drops the core schema URN. Attributes of
+// the enterprise extension keep the extension URN as their prefix.
+func normalizePath(path, coreSchema string) string {
+	path = strings.TrimSpace(path)
+	lower := strings.ToLower(path)
+	if rest, ok := strings.CutPrefix(lower, strings.ToLower(coreSchema)+":"); ok {
+		return rest
+	}
+	return lower
+}
+
+// applyUserPatch applies the operations to the SCIM form of a user. Attributes airlock
+// does not keep are ignored so directories can send their full mapping.
+func applyUserPatch(user *User, request *PatchRequest) *Error {
+	return applyPatch(request, func(op, path string, value json.RawMessage) *Error {
+		return applyUserValue(user, op, path, value)
+	})
+}
+
+// applyPatch passes each operation to apply. Operations without a path carry an object
+// whose keys are the paths to set.
+func applyPatch(request *PatchRequest, apply func(op, path string, value json.RawMessage) *Error) *Error {
+	for _, op := range request.Operations {
+		if op.Path != "" {
+			if err := apply(op.Op, op.Path, op.Value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if op.Op == "remove" {
+			return badRequest("noTarget", "remove needs a path")
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			return badRequest("invalidValue", "PATCH without a path needs an object value")
+		}
+		for key, value := range values {
+			if err := apply(op.Op, key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func applyUserValue(user *User, op, path string, value json.RawMessage) *Error {
+	remove := op == "remove"
+	enterprisePrefix := strings.ToLower(schemaEnterpriseUser)
+
+	switch attr := normalizePath(path, schemaUser); {
+	case attr == "username":
+		if remove {
+			return badRequest("mutability", "userName cannot be removed")
+		}
+		return decodeValue(value, &user.UserName)
+	case attr == "displayname":
+		return setString(&user.DisplayName, remove, value)
+	case attr == "externalid":
+		return setString(&user.ExternalID, remove, value)
+	case attr == "active":
+		active := false
+		if !remove {
+			var err *Error
+			if active, err = decodeBool(value); err != nil {
+				return err
+			}
+		}
+		user.Active = &active
+	case attr == "name":
+		if remove {
+			user.Name, user.DisplayName = nil, ""
+			return nil
+		}
+		var name Name
+		if err := decodeValue(value, &name); err != nil {
+			return err
+		}
+		user.Name = &name
+		user.DisplayName = ""
+	case strings.HasPrefix(attr, "name."):
+		if user.Name == nil {
+			user.Name = &Name{}
+		}
+		switch attr {
+		case "name.formatted":
+			return setString(&user.Name.Formatted, remove, value)
+		case "name.givenname":
+			if err := setString(&user.Name.GivenName, remove, value); err != nil {
+				return err
+			}
+		case "name.familyname":
+			if err := setString(&user.Name.FamilyName, remove, value); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+		// A changed given or family name replaces the single name airlock keeps
+		user.DisplayName = ""
+		user.Name.Formatted = strings.TrimSpace(user.Name.GivenName + " " + user.Name.FamilyName)
+	case attr == "emails":
+		if remove {
+			user.Emails = nil
+			return nil
+		}
+		var emails []Email
+		if err := decodeValue(value, &emails); err != nil {
+			return err
+		}
+		if op == "add" {
+			user.Emails = append(user.Emails, emails...)
+		} else {
+			user.Emails = emails
+		}
+	case strings.HasPrefix(attr, "emails[") && strings.HasSuffix(attr, "].value"):
+		// Directories commonly address the work email as emails[type eq "work"].value
+		return applyEmailValue(user, path, remove, value)
+	case attr == enterprisePrefix:
+		if remove {
+			user.Enterprise = nil
+			return nil
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(value, &values); err != nil {
+			return badRequest("invalidValue", "%s must be an object", schemaEnterpriseUser)
+		}
+		for key, value := range values {
+			if err := applyUserValue(user, op, schemaEnterpriseUser+":"+key, value); err != nil {
+				return err
+			}
+		}
+	case attr == enterprisePrefix+":organization":
+		if user.Enterprise == nil {
+			user.Enterprise = &EnterpriseUser{}
+		}
+		return setString(&user.Enterprise.Organization, remove, value)
+	default:
+		log.Printf("applyUserPatch: Ignoring unsupported attribute %s", path)
+	}
+	return nil
+}
+
+// applyEmailValue sets the value of the emails matching the filter in the path, adding
+// an email of that type when none matches
+func applyEmailValue(user *User, path string, remove bool, value json.RawMessage) *Error {
+	f, err := parseFilter(path[strings.Index(path, "[")+1 : strings.LastIndex(path, "]")])
+	if err != nil {
+		return &Error{Status: err.Status, ScimType: "invalidPath", Detail: err.Detail}
+	}
+
+	var address string
+	if !remove {
+		if err := decodeValue(value, &address); err != nil {
+			return err
+		}
+	}
+
+	matched := false
+	emails := user.Emails[:0]
+	for _, email := range user.Emails {
+		if matchResource(f, email) {
+			matched = true
+			if remove {
+				continue
+			}
+			email.Value = address
+		}
+		emails = append(emails, email)
+	}
+	user.Emails = emails
+
+	if !matched && !remove {
+		email := Email{Value: address, Primary: len(user.Emails) == 0}
+		if compare, ok := f.(*compareFilter); ok && len(compare.path) == 1 && strings.EqualFold(compare.path[0], "type") {
+			email.Type, _ = compare.value.(string)
+		}
+		user.Emails = append(user.Emails, email)
+	}
+	return nil
+}
+
+// applyGroupPatch applies the operations to the SCIM form of a group. Only the members
+// can change; the display name is fixed by the service permission.
+func applyGroupPatch(group *Group, request *PatchRequest) *Error {
+	return applyPatch(request, func(op, path string, value json.RawMessage) *Error {
+		return applyGroupValue(group, op, path, value)
+	})
+}
+
+func applyGroupValue(group *Group, op, path string, value json.RawMessage) *Error {
+	switch attr := normalizePath(path, schemaGroup); {
+	case attr == "displayname":
+		var name string
+		if op == "remove" {
+			return badRequest("mutability", "displayName cannot be removed")
+		}
+		if err := decodeValue(value, &name); err != nil {
+			return err
+		}
+		if !strings.EqualFold(name, group.DisplayName) {
+			return badRequest("mutability", "Group names are SERVICE:PERMISSION and cannot change")
+		}
+	case attr == "members":
+		var members []Reference
+		if len(value) > 0 {
+			if err := decodeValue(value, &members); err != nil {
+				return err
+			}
+		}
+		switch {
+		case op == "replace":
+			group.Members = members
+		case op == "add":
+			for _, member := range members {
+				if !slices.ContainsFunc(group.Members, func(m Reference) bool { return m.Value == member.Value }) {
+					group.Members = append(group.Members, member)
+				}
+			}
+		case len(members) == 0:
+			group.Members = []Reference{}
+		default:
+			group.Members = slices.DeleteFunc(group.Members, func(m Reference) bool {
+				return slices.ContainsFunc(members, func(removed Reference) bool { return removed.Value == m.Value })
+			})
+		}
+	case strings.HasPrefix(attr, "members[") && strings.HasSuffix(attr, "]"):
+		if op != "remove" {
+			return badRequest("invalidPath", "Only remove can target members with a filter")
+		}
+		f, err := parseFilter(path[strings.Index(path, "[")+1 : strings.LastIndex(path, "]")])
+		if err != nil {
+			return &Error{Status: err.Status, ScimType: "invalidPath", Detail: err.Detail}
+		}
+		group.Members = slices.DeleteFunc(group.Members, func(m Reference) bool { return matchResource(f, m) })
+	default:
+		log.Printf("applyGroupPatch: Ignoring unsupported attribute %s", path)
+	}
+	return nil
+}
+
+func decodeValue(value json.RawMessage, v any) *Error {
+	if err := json.Unmarshal(value, v); err != nil {
+		return badRequest("invalidValue", "Invalid value %s", string(value))
+	}
+	return nil
+}
+
+func setString(target *string, remove bool, value json.RawMessage) *Error {
+	if remove {
+		*target = ""
+		return nil
+	}
+	return decodeValue(value, target)
+}
+
+// decodeBool reads a boolean, which some directories send as the string "True" or "False"
+func decodeBool(value json.RawMessage) (bool, *Error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		switch strings.ToLower(s) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, badRequest("invalidValue", "Invalid boolean %s", string(value))
+}
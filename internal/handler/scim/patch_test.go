@@ -0,0 +1,218 @@
+package scim
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func patchRequest(t *testing.T, operations string) *PatchRequest {
+	t.Helper()
+	var request PatchRequest
+	body := `{"schemas":["` + schemaPatchOp + `"],"Operations":` + operations + `}`
+	if err := json.Unmarshal([]byte(body), &request); err != nil {
+		t.Fatalf("invalid request %s: %v", body, err)
+	}
+	return &request
+}
+
+func TestApplyUserPatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations string
+		check      func(t *testing.T, user *User)
+	}{
+		{
+			name:       "replace active with a string boolean",
+			operations: `[{"op":"Replace","path":"active","value":"False"}]`,
+			check: func(t *testing.T, user *User) {
+				if user.Active == nil || *user.Active {
+					t.Errorf("active = %v, want false", user.Active)
+				}
+			},
+		},
+		{
+			name:       "replace without a path",
+			operations: `[{"op":"replace","value":{"displayName":"Babs","externalId":"ext-7"}}]`,
+			check: func(t *testing.T, user *User) {
+				if user.DisplayName != "Babs" || user.ExternalID != "ext-7" {
+					t.Errorf("displayName %q, externalId %q", user.DisplayName, user.ExternalID)
+				}
+			},
+		},
+		{
+			name:       "replace given name",
+			operations: `[{"op":"replace","path":"name.givenName","value":"Barb"}]`,
+			check: func(t *testing.T, user *User) {
+				if user.DisplayName != "" || user.Name.Formatted != "Barb Jensen" {
+					t.Errorf("displayName %q, formatted %q, want the name rebuilt", user.DisplayName, user.Name.Formatted)
+				}
+			},
+		},
+		{
+			name:       "replace the work email by filter",
+			operations: `[{"op":"replace","path":"emails[type eq \"work\"].value","value":"new@example.com"}]`,
+			check: func(t *testing.T, user *User) {
+				if user.Emails[0].Value != "new@example.com" || user.Emails[1].Value != "babs@home.example.org" {
+					t.Errorf("emails = %+v", user.Emails)
+				}
+			},
+		},
+		{
+			name:       "add an email of a new type",
+			operations: `[{"op":"add","path":"emails[type eq \"other\"].value","value":"other@example.com"}]`,
+			check: func(t *testing.T, user *User) {
+				if len(user.Emails) != 3 || user.Emails[2] != (Email{Value: "other@example.com", Type: "other"}) {
+					t.Errorf("emails = %+v", user.Emails)
+				}
+			},
+		},
+		{
+			name:       "remove an email by filter",
+			operations: `[{"op":"remove","path":"emails[type eq \"home\"].value"}]`,
+			check: func(t *testing.T, user *User) {
+				if len(user.Emails) != 1 || user.Emails[0].Type != "work" {
+					t.Errorf("emails = %+v", user.Emails)
+				}
+			},
+		},
+		{
+			name:       "replace organization under the extension URN",
+			operations: `[{"op":"replace","path":"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:organization","value":"Acme"}]`,
+			check: func(t *testing.T, user *User) {
+				if user.Enterprise.Organization != "Acme" {
+					t.Errorf("organization = %q", user.Enterprise.Organization)
+				}
+			},
+		},
+		{
+			name:       "replace the extension object",
+			operations: `[{"op":"replace","value":{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User":{"organization":"Acme"}}}]`,
+			check: func(t *testing.T, user *User) {
+				if user.Enterprise.Organization != "Acme" {
+					t.Errorf("organization = %q", user.Enterprise.Organization)
+				}
+			},
+		},
+		{
+			name:       "core schema URN prefix",
+			operations: `[{"op":"replace","path":"urn:ietf:params:scim:schemas:core:2.0:User:userName","value":"barbara@example.org"}]`,
+			check: func(t *testing.T, user *User) {
+				if user.UserName != "barbara@example.org" {
+					t.Errorf("userName = %q", user.UserName)
+				}
+			},
+		},
+		{
+			name:       "unsupported attributes are ignored",
+			operations: `[{"op":"replace","path":"title","value":"Engineer"}]`,
+			check: func(t *testing.T, user *User) {
+				want := testUser()
+				if !reflect.DeepEqual(*user, want) {
+					t.Errorf("user changed: %+v", user)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := patchRequest(t, tt.operations)
+			if err := request.validate(); err != nil {
+				t.Fatalf("invalid request: %v", err)
+			}
+			user := testUser()
+			if err := applyUserPatch(&user, request); err != nil {
+				t.Fatalf("patch failed: %v", err)
+			}
+			tt.check(t, &user)
+		})
+	}
+}
+
+func TestPatchRejects(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations string
+		scimType   string
+	}{
+		{"no operations", `[]`, "invalidSyntax"},
+		{"unknown operation", `[{"op":"move","path":"active","value":true}]`, "invalidSyntax"},
+		{"missing value", `[{"op":"replace","path":"active"}]`, "invalidValue"},
+		{"remove without a path", `[{"op":"remove"}]`, "noTarget"},
+		{"remove userName", `[{"op":"remove","path":"userName"}]`, "mutability"},
+		{"invalid boolean", `[{"op":"replace","path":"active","value":"maybe"}]`, "invalidValue"},
+		{"value of the wrong type", `[{"op":"replace","path":"displayName","value":42}]`, "invalidValue"},
+		{"invalid filter in path", `[{"op":"replace","path":"emails[type eq].value","value":"x@example.com"}]`, "invalidPath"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := patchRequest(t, tt.operations)
+			err := request.validate()
+			if err == nil {
+				user := testUser()
+				err = applyUserPatch(&user, request)
+			}
+			if err == nil {
+				t.Fatal("patch succeeded, want an error")
+			}
+			if err.ScimType != tt.scimType {
+				t.Errorf("scimType = %q, want %q", err.ScimType, tt.scimType)
+			}
+		})
+	}
+
+	var request PatchRequest
+	json.Unmarshal([]byte(`{"schemas":["urn:example"],"Operations":[{"op":"replace","path":"active","value":true}]}`), &request)
+	if err := request.validate(); err == nil || err.ScimType != "invalidSyntax" {
+		t.Errorf("request without the PatchOp schema returned %v", err)
+	}
+}
+
+func TestApplyGroupPatch(t *testing.T) {
+	newTestGroup := func() *Group {
+		group := newGroup("PRUNK:USER", "")
+		group.Members = []Reference{{Value: "a"}, {Value: "b"}}
+		return group
+	}
+
+	tests := []struct {
+		name       string
+		operations string
+		want       []string
+	}{
+		{"add members once", `[{"op":"add","path":"members","value":[{"value":"b"},{"value":"c"}]}]`, []string{"a", "b", "c"}},
+		{"remove listed members", `[{"op":"remove","path":"members","value":[{"value":"a"}]}]`, []string{"b"}},
+		{"remove every member", `[{"op":"remove","path":"members"}]`, []string{}},
+		{"remove members by filter", `[{"op":"remove","path":"members[value eq \"b\"]"}]`, []string{"a"}},
+		{"replace members", `[{"op":"replace","path":"members","value":[{"value":"c"}]}]`, []string{"c"}},
+		{"replace without a path", `[{"op":"replace","value":{"members":[{"value":"d"}]}}]`, []string{"d"}},
+		{"same display name", `[{"op":"replace","path":"displayName","value":"prunk:user"}]`, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := patchRequest(t, tt.operations)
+			if err := request.validate(); err != nil {
+				t.Fatalf("invalid request: %v", err)
+			}
+			group := newTestGroup()
+			if err := applyGroupPatch(group, request); err != nil {
+				t.Fatalf("patch failed: %v", err)
+			}
+			if got := memberIDs(group.Members); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("members = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, operations := range []string{
+		`[{"op":"replace","path":"displayName","value":"PRUNK:SUPER_USER"}]`,
+		`[{"op":"add","path":"members[value eq \"a\"]","value":[{"value":"c"}]}]`,
+	} {
+		if err := applyGroupPatch(newTestGroup(), patchRequest(t, operations)); err == nil {
+			t.Errorf("patch %s succeeded, want an error", operations)
+		}
+	}
+}
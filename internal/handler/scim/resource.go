@@ -0,0 +1,248 @@
+package scim
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/service"
+	"github.com/luna4dev/airlock/internal/util"
+)
+
+// User is the SCIM form of a provisioned user. Airlock keeps a single name and a single
+// address: the name comes from displayName, name.formatted or the given and family
+// names, and the address from userName or, when that is not an email address, the
+// primary email.
+type User struct {
+	Schemas     []string        `json:"schemas"`
+	ID          string          `json:"id,omitempty"`
+	ExternalID  string          `json:"externalId,omitempty"`
+	UserName    string          `json:"userName"`
+	Name        *Name           `json:"name,omitempty"`
+	DisplayName string          `json:"displayName,omitempty"`
+	Emails      []Email         `json:"emails,omitempty"`
+	Active      *bool           `json:"active,omitempty"`
+	Groups      []Reference     `json:"groups,omitempty"`
+	Enterprise  *EnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta        *Meta           `json:"meta,omitempty"`
+}
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// EnterpriseUser holds the attributes of the enterprise extension airlock maps
+type EnterpriseUser struct {
+	Organization string `json:"organization,omitempty"`
+}
+
+// Reference points at another resource, such as a group member
+type Reference struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location"`
+	Version      string `json:"version,omitempty"`
+}
+
+// Group is a service permission. Its ID and display name are SERVICE:PERMISSION and its
+// members are the tenant's users holding that grant.
+type Group struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []Reference `json:"members"`
+	Meta        *Meta       `json:"meta,omitempty"`
+}
+
+// knownServices are listed as groups even when no user of the tenant holds them
+var knownServices = []model.Luna4Service{model.Luna4ServicePrunk}
+
+var permissions = []model.UserServicePermission{model.UserServiceUser, model.UserServiceSuperUser}
+
+func formatTime(ms int64) string {
+	return time.UnixMilli(ms).UTC().Format(time.RFC3339)
+}
+
+// newUser returns the SCIM form of an account
+func newUser(account *model.Luna4ScimAccount, base string) User {
+	active := account.Status.CanAuthenticate()
+	user := User{
+		Schemas:    []string{schemaUser},
+		ID:         account.ID,
+		ExternalID: account.ExternalID,
+		UserName:   account.Email,
+		Emails:     []Email{{Value: account.Email, Type: "work", Primary: true}},
+		Active:     &active,
+		Groups:     []Reference{},
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      formatTime(account.CreatedAt),
+			LastModified: formatTime(account.UpdatedAt),
+			Location:     base + "/Users/" + account.ID,
+			Version:      `W/"` + strconv.FormatInt(account.UpdatedAt, 10) + `"`,
+		},
+	}
+
+	if account.Name != "" {
+		user.Name = &Name{Formatted: account.Name}
+		user.DisplayName = account.Name
+	}
+	if account.Organization != "" {
+		user.Schemas = append(user.Schemas, schemaEnterpriseUser)
+		user.Enterprise = &EnterpriseUser{Organization: account.Organization}
+	}
+
+	for _, grant := range account.Services {
+		id := groupID(grant.Service, grant.Permission)
+		user.Groups = append(user.Groups, Reference{Value: id, Ref: base + "/Groups/" + id, Display: id})
+	}
+	return user
+}
+
+// update reads the attributes airlock keeps from a User sent by a directory
+func (u *User) update() (service.ScimUserUpdate, *Error) {
+	email := strings.TrimSpace(u.UserName)
+	if !util.IsValidEmail(email) {
+		email = u.primaryEmail()
+	}
+	if !util.IsValidEmail(email) {
+		return service.ScimUserUpdate{}, badRequest("invalidValue", "userName or the primary email must be an email address")
+	}
+
+	name := strings.TrimSpace(u.DisplayName)
+	if name == "" && u.Name != nil {
+		name = strings.TrimSpace(u.Name.Formatted)
+		if name == "" {
+			name = strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+		}
+	}
+
+	organization := ""
+	if u.Enterprise != nil {
+		organization = strings.TrimSpace(u.Enterprise.Organization)
+	}
+
+	if len(name) > 200 || len(organization) > 200 {
+		return service.ScimUserUpdate{}, badRequest("invalidValue", "name and organization are limited to 200 characters")
+	}
+
+	return service.ScimUserUpdate{
+		Email:        email,
+		Name:         name,
+		Organization: organization,
+		ExternalID:   strings.TrimSpace(u.ExternalID),
+		Active:       u.Active == nil || *u.Active,
+	}, nil
+}
+
+func (u *User) primaryEmail() string {
+	for _, email := range u.Emails {
+		if email.Primary {
+			return strings.TrimSpace(email.Value)
+		}
+	}
+	if len(u.Emails) > 0 {
+		return strings.TrimSpace(u.Emails[0].Value)
+	}
+	return ""
+}
+
+func groupID(service model.Luna4Service, permission model.UserServicePermission) string {
+	return string(service) + ":" + string(permission)
+}
+
+// parseGroupID reads the service permission a group stands for
+func parseGroupID(id string) (model.Luna4Service, model.UserServicePermission, bool) {
+	service, permission, ok := strings.Cut(strings.ToUpper(strings.TrimSpace(id)), ":")
+	if !ok || service == "" || !slices.Contains(permissions, model.UserServicePermission(permission)) {
+		return "", "", false
+	}
+	return model.Luna4Service(service), model.UserServicePermission(permission), true
+}
+
+// newGroups returns the groups of the tenant: every permission of the known services and
+// of any service a user of the tenant holds
+func newGroups(accounts []model.Luna4ScimAccount, base string) []Group {
+	groups := map[string]*Group{}
+	var ids []string
+	add := func(id string) *Group {
+		if group, ok := groups[id]; ok {
+			return group
+		}
+		groups[id] = newGroup(id, base)
+		ids = append(ids, id)
+		return groups[id]
+	}
+
+	for _, service := range knownServices {
+		for _, permission := range permissions {
+			add(groupID(service, permission))
+		}
+	}
+	for _, account := range accounts {
+		for _, grant := range account.Services {
+			group := add(groupID(grant.Service, grant.Permission))
+			if !slices.ContainsFunc(group.Members, func(member Reference) bool { return member.Value == account.ID }) {
+				group.Members = append(group.Members, Reference{Value: account.ID, Ref: base + "/Users/" + account.ID, Display: account.Email})
+			}
+		}
+	}
+
+	slices.Sort(ids)
+	result := make([]Group, len(ids))
+	for i, id := range ids {
+		result[i] = *groups[id]
+	}
+	return result
+}
+
+// findGroup returns the group with the ID, which exists for any valid service permission
+func findGroup(accounts []model.Luna4ScimAccount, base, id string) (*Group, bool) {
+	service, permission, ok := parseGroupID(id)
+	if !ok {
+		return nil, false
+	}
+	id = groupID(service, permission)
+
+	for _, group := range newGroups(accounts, base) {
+		if group.ID == id {
+			return &group, true
+		}
+	}
+	return newGroup(id, base), true
+}
+
+func newGroup(id, base string) *Group {
+	return &Group{
+		Schemas:     []string{schemaGroup},
+		ID:          id,
+		DisplayName: id,
+		Members:     []Reference{},
+		Meta:        &Meta{ResourceType: "Group", Location: base + "/Groups/" + id},
+	}
+}
+
+func memberIDs(members []Reference) []string {
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.Value)
+	}
+	return ids
+}
@@ -0,0 +1,202 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/service"
+)
+
+// SCIM schema and message URNs
+const (
+	schemaUser           = "urn:ietf:params:scim:schemas:core:2.0:User"
+	schemaGroup          = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	schemaEnterpriseUser = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	schemaListResponse   = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	schemaPatchOp        = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	schemaError          = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	schemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	schemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	schemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+// contentType is the media type of every SCIM response
+const contentType = "application/scim+json"
+
+// Page sizes of list requests
+const (
+	defaultListCount = 100
+	maxListCount     = 500
+)
+
+// maxBodyBytes bounds the body of create, replace and patch requests
+const maxBodyBytes = 1 << 20
+
+// tenantKey is the gin context key holding the tenant of the authenticated token
+const tenantKey = "scimTenant"
+
+// Error is a SCIM error response. ScimType is one of the error types of RFC 7644
+// section 3.12 and is only set for 400 and 409 responses.
+type Error struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+func badRequest(scimType, format string, args ...any) *Error {
+	return &Error{Status: http.StatusBadRequest, ScimType: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+// writeJSON sends a SCIM response
+func writeJSON(c *gin.Context, status int, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		log.Printf("writeJSON: Failed to marshal SCIM response: %v", err)
+		status, data = http.StatusInternalServerError, []byte(`{}`)
+	}
+	c.Data(status, contentType, data)
+}
+
+// writeError sends a SCIM error response
+func writeError(c *gin.Context, err *Error) {
+	body := gin.H{
+		"schemas": []string{schemaError},
+		"status":  strconv.Itoa(err.Status),
+		"detail":  err.Detail,
+	}
+	if err.ScimType != "" {
+		body["scimType"] = err.ScimType
+	}
+	writeJSON(c, err.Status, body)
+}
+
+func writeInternalError(c *gin.Context, detail string) {
+	writeError(c, &Error{Status: http.StatusInternalServerError, Detail: detail})
+}
+
+func writeNotFound(c *gin.Context, resource string) {
+	writeError(c, &Error{Status: http.StatusNotFound, Detail: resource + " " + c.Param("id") + " not found"})
+}
+
+// readBody decodes a JSON request body
+func readBody(c *gin.Context, v any) *Error {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes))
+	if err != nil {
+		return badRequest("invalidSyntax", "Failed to read request body")
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return badRequest("invalidSyntax", "Invalid JSON: %v", err)
+	}
+	return nil
+}
+
+// NewAuthMiddleware authenticates requests with a tenant's SCIM bearer token
+func NewAuthMiddleware(accountService *service.AccountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			writeError(c, &Error{Status: http.StatusUnauthorized, Detail: "Missing bearer token"})
+			c.Abort()
+			return
+		}
+
+		scimToken, err := accountService.AuthenticateScimToken(c, strings.TrimSpace(token))
+		if err != nil {
+			log.Printf("ScimAuth: Failed to check token: %v", err)
+			writeInternalError(c, "Failed to check token")
+			c.Abort()
+			return
+		}
+		if scimToken == nil {
+			c.Header("WWW-Authenticate", `Bearer realm="scim", error="invalid_token"`)
+			writeError(c, &Error{Status: http.StatusUnauthorized, Detail: "Invalid or revoked bearer token"})
+			c.Abort()
+			return
+		}
+
+		c.Set(tenantKey, scimToken.Tenant)
+		c.Next()
+	}
+}
+
+func getTenant(c *gin.Context) string {
+	return c.GetString(tenantKey)
+}
+
+// baseURL returns the URL SCIM resources live under, as seen by the client
+func baseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host + "/scim/v2"
+}
+
+// listQuery is the filter and 1-based pagination of a list request
+type listQuery struct {
+	filter     filter
+	startIndex int
+	count      int
+}
+
+func parseListQuery(c *gin.Context) (listQuery, *Error) {
+	query := listQuery{startIndex: 1, count: defaultListCount}
+	if expression := c.Query("filter"); expression != "" {
+		parsed, err := parseFilter(expression)
+		if err != nil {
+			return query, err
+		}
+		query.filter = parsed
+	}
+
+	if value := c.Query("startIndex"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return query, badRequest("invalidValue", "startIndex must be a number")
+		}
+		query.startIndex = max(n, 1)
+	}
+	if value := c.Query("count"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return query, badRequest("invalidValue", "count must be a number")
+		}
+		query.count = min(max(n, 0), maxListCount)
+	}
+	return query, nil
+}
+
+// matches reports whether a resource passes the query's filter
+func (q listQuery) matches(resource any) bool {
+	return q.filter == nil || matchResource(q.filter, resource)
+}
+
+// listResponse pages through resources that matched the filter
+func listResponse[T any](resources []T, query listQuery) gin.H {
+	page := []T{}
+	if from := query.startIndex - 1; from < len(resources) {
+		page = resources[from:min(from+query.count, len(resources))]
+	}
+	return gin.H{
+		"schemas":      []string{schemaListResponse},
+		"totalResults": len(resources),
+		"startIndex":   query.startIndex,
+		"itemsPerPage": len(page),
+		"Resources":    page,
+	}
+}
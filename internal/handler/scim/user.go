@@ -0,0 +1,210 @@
+package scim
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/service"
+)
+
+// UserHandler serves the SCIM Users endpoint of the authenticated tenant
+type UserHandler struct {
+	accountService *service.AccountService
+}
+
+// NewUserHandler creates a new SCIM user handler with injected dependencies
+func NewUserHandler(accountService *service.AccountService) *UserHandler {
+	return &UserHandler{
+		accountService: accountService,
+	}
+}
+
+// GetUsers lists the tenant's users matching the filter
+func (h *UserHandler) GetUsers(c *gin.Context) {
+	query, scimErr := parseListQuery(c)
+	if scimErr != nil {
+		writeError(c, scimErr)
+		return
+	}
+
+	accounts, err := h.accountService.GetScimAccounts(c, getTenant(c))
+	if err != nil {
+		log.Printf("ScimGetUsers: Failed to retrieve users of tenant %s: %v", getTenant(c), err)
+		writeInternalError(c, "Failed to retrieve users")
+		return
+	}
+
+	base := baseURL(c)
+	users := []User{}
+	for i := range accounts {
+		if user := newUser(&accounts[i], base); query.matches(user) {
+			users = append(users, user)
+		}
+	}
+	writeJSON(c, http.StatusOK, listResponse(users, query))
+}
+
+// GetUser returns one of the tenant's users
+func (h *UserHandler) GetUser(c *gin.Context) {
+	account, ok := h.getAccount(c)
+	if !ok {
+		return
+	}
+	writeJSON(c, http.StatusOK, newUser(account, baseURL(c)))
+}
+
+// CreateUser provisions a user for the tenant
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var req User
+	if err := readBody(c, &req); err != nil {
+		writeError(c, err)
+		return
+	}
+	update, scimErr := req.update()
+	if scimErr != nil {
+		writeError(c, scimErr)
+		return
+	}
+
+	status := model.UserStatusActive
+	if !update.Active {
+		status = model.UserStatusSuspended
+	}
+	now := time.Now().UnixMilli()
+	user := &model.Luna4User{
+		ID:              uuid.New().String(),
+		Email:           update.Email,
+		Name:            update.Name,
+		Organization:    update.Organization,
+		Status:          status,
+		StatusChangedAt: &now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	tenant := getTenant(c)
+	err := h.accountService.ProvisionScimUser(c, tenant, user, update.ExternalID)
+	if errors.Is(err, service.ErrEmailTaken) {
+		writeError(c, &Error{Status: http.StatusConflict, ScimType: "uniqueness", Detail: "userName is already in use"})
+		return
+	}
+	if err != nil {
+		log.Printf("ScimCreateUser: Failed to provision user for tenant %s: %v", tenant, err)
+		writeInternalError(c, "Failed to create user")
+		return
+	}
+
+	h.respondWithUser(c, http.StatusCreated, user.ID)
+}
+
+// ReplaceUser sets every attribute airlock keeps from the request
+func (h *UserHandler) ReplaceUser(c *gin.Context) {
+	account, ok := h.getAccount(c)
+	if !ok {
+		return
+	}
+
+	var req User
+	if err := readBody(c, &req); err != nil {
+		writeError(c, err)
+		return
+	}
+	h.updateUser(c, account, &req)
+}
+
+// PatchUser applies PATCH operations to a user
+func (h *UserHandler) PatchUser(c *gin.Context) {
+	account, ok := h.getAccount(c)
+	if !ok {
+		return
+	}
+
+	var req PatchRequest
+	if err := readBody(c, &req); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	user := newUser(account, baseURL(c))
+	if err := applyUserPatch(&user, &req); err != nil {
+		writeError(c, err)
+		return
+	}
+	h.updateUser(c, account, &user)
+}
+
+// DeleteUser deprovisions a user. The user is soft deleted and purged after the
+// retention window like any other deleted user.
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	account, ok := h.getAccount(c)
+	if !ok {
+		return
+	}
+
+	if err := h.accountService.DeprovisionScimUser(c, getTenant(c), account); err != nil {
+		log.Printf("ScimDeleteUser: Failed to deprovision user %s: %v", account.ID, err)
+		writeInternalError(c, "Failed to delete user")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UserHandler) updateUser(c *gin.Context, account *model.Luna4ScimAccount, user *User) {
+	update, scimErr := user.update()
+	if scimErr != nil {
+		writeError(c, scimErr)
+		return
+	}
+
+	err := h.accountService.UpdateScimUser(c, getTenant(c), account, update)
+	if errors.Is(err, service.ErrEmailTaken) {
+		writeError(c, &Error{Status: http.StatusConflict, ScimType: "uniqueness", Detail: "userName is already in use"})
+		return
+	}
+	if err != nil {
+		log.Printf("ScimUpdateUser: Failed to update user %s: %v", account.ID, err)
+		writeInternalError(c, "Failed to update user")
+		return
+	}
+
+	h.respondWithUser(c, http.StatusOK, account.ID)
+}
+
+// getAccount loads the user in the request path, responding with 404 when the tenant has
+// no such user
+func (h *UserHandler) getAccount(c *gin.Context) (*model.Luna4ScimAccount, bool) {
+	account, err := h.accountService.GetScimAccount(c, getTenant(c), c.Param("id"))
+	if err != nil {
+		log.Printf("ScimGetUser: Failed to retrieve user %s: %v", c.Param("id"), err)
+		writeInternalError(c, "Failed to retrieve user")
+		return nil, false
+	}
+	if account == nil {
+		writeNotFound(c, "User")
+		return nil, false
+	}
+	return account, true
+}
+
+func (h *UserHandler) respondWithUser(c *gin.Context, status int, userID string) {
+	account, err := h.accountService.GetScimAccount(c, getTenant(c), userID)
+	if err != nil || account == nil {
+		log.Printf("ScimUser: Failed to reload user %s: %v", userID, err)
+		writeInternalError(c, "Failed to retrieve user")
+		return
+	}
+
+	user := newUser(account, baseURL(c))
+	c.Header("Location", user.Meta.Location)
+	c.Header("ETag", user.Meta.Version)
+	writeJSON(c, status, user)
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/service"
+	"github.com/luna4dev/airlock/internal/sns"
+)
+
+// SESNotificationHandler receives the bounce, complaint and delivery notifications SES
+// publishes through an SNS topic
+type SESNotificationHandler struct {
+	accountService *service.AccountService
+	verifier       *sns.Verifier
+}
+
+// NewSESNotificationHandler creates a new SES notification handler with injected dependencies
+func NewSESNotificationHandler(accountService *service.AccountService, verifier *sns.Verifier) *SESNotificationHandler {
+	return &SESNotificationHandler{
+		accountService: accountService,
+		verifier:       verifier,
+	}
+}
+
+// ReceiveNotification handles a message posted by SNS. SNS sends the JSON envelope as
+// text/plain and retries anything but a 2xx response.
+func (h *SESNotificationHandler) ReceiveNotification(c *gin.Context) {
+	var message sns.Message
+	if err := c.ShouldBindJSON(&message); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SNS message"})
+		return
+	}
+
+	if err := h.verifier.Verify(c, &message); err != nil {
+		log.Printf("ReceiveNotification: Rejected message %s from %s: %v", message.MessageID, message.TopicArn, err)
+		if errors.Is(err, sns.ErrInvalidSignature) || errors.Is(err, sns.ErrTopicNotAllowed) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Message could not be verified"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify message"})
+		return
+	}
+
+	switch message.Type {
+	case sns.TypeSubscriptionConfirmation:
+		if err := h.verifier.ConfirmSubscription(c, &message); err != nil {
+			log.Printf("ReceiveNotification: Failed to confirm subscription to %s: %v", message.TopicArn, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to confirm subscription"})
+			return
+		}
+		log.Printf("ReceiveNotification: Confirmed subscription to %s", message.TopicArn)
+		c.JSON(http.StatusOK, gin.H{"message": "Subscription confirmed"})
+		return
+	case sns.TypeNotification:
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Message ignored"})
+		return
+	}
+
+	var notification service.SESNotification
+	if err := json.Unmarshal([]byte(message.Message), &notification); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SES notification"})
+		return
+	}
+
+	recorded, err := h.accountService.RecordSESNotification(c, &notification)
+	if err != nil {
+		if errors.Is(err, service.ErrUnsupportedNotification) {
+			// Acknowledged so SNS does not retry event types airlock does not track
+			c.JSON(http.StatusOK, gin.H{"message": "Notification ignored"})
+			return
+		}
+		log.Printf("ReceiveNotification: Failed to record notification %s: %v", message.MessageID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Notification recorded",
+		"recorded": recorded,
+	})
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/util"
+)
+
+const (
+	contextKeyUser    = "airlock.user"
+	contextKeySession = "airlock.session"
+)
+
+// RequireSession is middleware that accepts only bearer tokens whose session is still active
+// and whose user may sign in
+func (h *AuthHandler) RequireSession(c *gin.Context) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	claims, err := util.ParseBearerToken(token)
+	if err != nil || claims.ID == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or missing token"})
+		return
+	}
+
+	ctx := context.Background()
+	session, err := h.accountService.GetSession(ctx, claims.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database"})
+		return
+	}
+
+	if session == nil || session.UserID != claims.UserID || !session.IsActive(time.Now().UnixMilli()) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session has expired or been revoked"})
+		return
+	}
+
+	user, err := h.accountService.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to query database"})
+		return
+	}
+
+	if user == nil || !user.Status.CanAuthenticate() {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session has expired or been revoked"})
+		return
+	}
+
+	c.Set(contextKeyUser, user)
+	c.Set(contextKeySession, session)
+	c.Next()
+}
+
+// GetSessionHandler lets clients check whether a bearer token's session is still valid
+func (h *AuthHandler) GetSessionHandler(c *gin.Context) {
+	user := c.MustGet(contextKeyUser).(*model.Luna4User)
+	session := c.MustGet(contextKeySession).(*model.Luna4Session)
+
+	c.JSON(http.StatusOK, gin.H{
+		"session": session,
+		"user": gin.H{
+			"id":     user.ID,
+			"email":  user.Email,
+			"status": user.Status,
+		},
+	})
+}
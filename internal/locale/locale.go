@@ -0,0 +1,86 @@
+package locale
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default is used when neither the user nor their browser asks for a supported locale
+const Default = "en"
+
+// Supported lists the locales airlock has email templates and web translations for
+var Supported = []string{"en", "ko"}
+
+// dateFormats formats dates in emails. Month names are English only in Go, so other
+// locales spell dates numerically.
+var dateFormats = map[string]string{
+	"en": "January 2, 2006",
+	"ko": "2006년 1월 2일",
+}
+
+// Match returns the supported locale for a language tag, trying the base language of a
+// regional tag such as ko-KR, or "" when there is none
+func Match(tag string) string {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if slices.Contains(Supported, tag) {
+		return tag
+	}
+	if base, _, ok := strings.Cut(tag, "-"); ok && slices.Contains(Supported, base) {
+		return base
+	}
+	return ""
+}
+
+// FromAcceptLanguage returns the supported locale the client prefers most according to an
+// Accept-Language header, or "" when it accepts none of them
+func FromAcceptLanguage(header string) string {
+	type weighted struct {
+		tag    string
+		weight float64
+	}
+
+	var tags []weighted
+	for _, entry := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if tag != "" && tag != "*" && weight > 0 {
+			tags = append(tags, weighted{tag, weight})
+		}
+	}
+
+	// Stable, so equally weighted tags keep the client's order
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].weight > tags[j].weight })
+	for _, t := range tags {
+		if match := Match(t.tag); match != "" {
+			return match
+		}
+	}
+	return ""
+}
+
+// Resolve returns the first candidate that matches a supported locale, falling back to Default
+func Resolve(candidates ...string) string {
+	for _, candidate := range candidates {
+		if match := Match(candidate); match != "" {
+			return match
+		}
+	}
+	return Default
+}
+
+// DateFormat returns the layout dates are written in for a locale
+func DateFormat(locale string) string {
+	if format, ok := dateFormats[locale]; ok {
+		return format
+	}
+	return dateFormats[Default]
+}
@@ -0,0 +1,48 @@
+package locale
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	for tag, want := range map[string]string{
+		"en":      "en",
+		"KO":      "ko",
+		"ko-KR":   "ko",
+		" ko_kr ": "ko",
+		"en-GB":   "en",
+		"fr":      "",
+		"fr-KO":   "",
+		"":        "",
+	} {
+		if got := Match(tag); got != want {
+			t.Errorf("Match(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestFromAcceptLanguage(t *testing.T) {
+	for header, want := range map[string]string{
+		"ko-KR,ko;q=0.9,en-US;q=0.8,en;q=0.7": "ko",
+		"fr-FR, en;q=0.5, ko;q=0.8":           "ko",
+		"en;q=0.5, ko;q=0.5":                  "en",
+		"ko;q=0, en;q=0.1":                    "en",
+		"ko;q=abc, fr":                        "",
+		"*":                                   "",
+		"":                                    "",
+	} {
+		if got := FromAcceptLanguage(header); got != want {
+			t.Errorf("FromAcceptLanguage(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	if got := Resolve("", "fr", "ko-KR", "en"); got != "ko" {
+		t.Errorf("Resolve picked %q, want the first supported candidate ko", got)
+	}
+	if got := Resolve("fr", ""); got != Default {
+		t.Errorf("Resolve without a supported candidate picked %q, want %q", got, Default)
+	}
+	if got := DateFormat("fr"); got != DateFormat(Default) {
+		t.Errorf("DateFormat of an unsupported locale is %q, want the default", got)
+	}
+}
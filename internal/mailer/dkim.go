@@ -0,0 +1,277 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dkimHeaders are the headers signed when present, in signing order
+var dkimHeaders = []string{
+	"from", "to", "subject", "date", "message-id", "mime-version", "content-type",
+	"list-unsubscribe", "list-unsubscribe-post",
+}
+
+var (
+	dkimSelector = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+	dkimSpaces   = regexp.MustCompile(`[ \t]+`)
+)
+
+// DKIMConfig locates the DKIM signing keys. Keys are PEM files named
+// <KeyDir>/<domain>/<selector>.pem, and each domain signs with the selector that sorts
+// last, so a key rotates in by adding a file with a later selector.
+type DKIMConfig struct {
+	KeyDir         string
+	ReloadInterval time.Duration
+}
+
+// DKIMSigner signs outgoing messages with the key of the sender's domain (RFC 6376, relaxed
+// canonicalization), picking up added and removed key files every reload interval
+type DKIMSigner struct {
+	config DKIMConfig
+
+	mu       sync.Mutex
+	keys     map[string]*dkimKey
+	loadedAt time.Time
+}
+
+// dkimKey is the active signing key of one domain
+type dkimKey struct {
+	domain    string
+	selector  string
+	algorithm string
+	signer    crypto.Signer
+}
+
+// NewDKIMSigner loads the keys, failing on any key file that cannot be used
+func NewDKIMSigner(config DKIMConfig) (*DKIMSigner, error) {
+	keys, err := loadDKIMKeys(config.KeyDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		key.logRecord()
+	}
+	return &DKIMSigner{
+		config:   config,
+		keys:     keys,
+		loadedAt: time.Now(),
+	}, nil
+}
+
+// Sign adds a DKIM-Signature header to a rendered message from sender. A message from a
+// domain without a key is returned unsigned.
+func (s *DKIMSigner) Sign(raw []byte, sender string) ([]byte, error) {
+	domain := strings.ToLower(sender[strings.LastIndex(sender, "@")+1:])
+	key := s.key(domain)
+	if key == nil {
+		log.Printf("DKIMSigner: No key for %s, sending unsigned", domain)
+		return raw, nil
+	}
+
+	header, body, ok := bytes.Cut(raw, []byte("\r\n\r\n"))
+	if !ok {
+		return nil, fmt.Errorf("failed to sign message: no header separator")
+	}
+	fields := splitHeaderFields(header)
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+
+	var names []string
+	hash := sha256.New()
+	for _, name := range dkimHeaders {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if headerName(fields[i]) == name {
+				hash.Write([]byte(relaxedHeader(fields[i]) + "\r\n"))
+				names = append(names, name)
+				break
+			}
+		}
+	}
+
+	value := "v=1; a=" + key.algorithm + "; c=relaxed/relaxed; d=" + key.domain + "; s=" + key.selector +
+		"; t=" + strconv.FormatInt(time.Now().Unix(), 10) + "; h=" + strings.Join(names, ":") +
+		"; bh=" + base64.StdEncoding.EncodeToString(bodyHash[:]) + "; b="
+	hash.Write([]byte(relaxedHeader("DKIM-Signature: " + value)))
+
+	var opts crypto.SignerOpts = crypto.SHA256
+	if key.algorithm == "ed25519-sha256" {
+		opts = crypto.Hash(0) // Ed25519 signs the SHA-256 digest itself (RFC 8463)
+	}
+	signature, err := key.signer.Sign(rand.Reader, hash.Sum(nil), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign message: %w", err)
+	}
+
+	signed := make([]byte, 0, len(raw)+len(value)+512)
+	signed = append(signed, "DKIM-Signature: "+value+base64.StdEncoding.EncodeToString(signature)+"\r\n"...)
+	return append(signed, raw...), nil
+}
+
+// key returns the domain's active key, reloading the key directory when it is due
+func (s *DKIMSigner) key(domain string) *dkimKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.ReloadInterval > 0 && time.Since(s.loadedAt) >= s.config.ReloadInterval {
+		s.loadedAt = time.Now()
+		keys, err := loadDKIMKeys(s.config.KeyDir)
+		if err != nil {
+			log.Printf("DKIMSigner: Failed to reload keys, keeping the current ones: %v", err)
+		} else {
+			for domain, key := range keys {
+				if current := s.keys[domain]; current == nil || current.selector != key.selector {
+					key.logRecord()
+				}
+			}
+			s.keys = keys
+		}
+	}
+	return s.keys[domain]
+}
+
+// logRecord logs the DNS record that must be published for the key to verify
+func (k *dkimKey) logRecord() {
+	var keyType, publicKey string
+	switch public := k.signer.Public().(type) {
+	case *rsa.PublicKey:
+		der, _ := x509.MarshalPKIXPublicKey(public)
+		keyType, publicKey = "rsa", base64.StdEncoding.EncodeToString(der)
+	case ed25519.PublicKey:
+		keyType, publicKey = "ed25519", base64.StdEncoding.EncodeToString(public)
+	}
+	log.Printf("DKIMSigner: Signing mail from %s with selector %s, publish %s._domainkey.%s TXT \"v=DKIM1; k=%s; p=%s\"",
+		k.domain, k.selector, k.selector, k.domain, keyType, publicKey)
+}
+
+// loadDKIMKeys reads the active key of every domain directory
+func loadDKIMKeys(dir string) (map[string]*dkimKey, error) {
+	domains, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DKIM key directory: %w", err)
+	}
+
+	keys := make(map[string]*dkimKey)
+	for _, domain := range domains {
+		if !domain.IsDir() {
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(dir, domain.Name(), "*.pem"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list DKIM keys of %s: %w", domain.Name(), err)
+		}
+		if len(files) == 0 {
+			continue
+		}
+		slices.Sort(files)
+
+		key, err := loadDKIMKey(files[len(files)-1])
+		if err != nil {
+			return nil, err
+		}
+		key.domain = strings.ToLower(domain.Name())
+		keys[key.domain] = key
+	}
+	return keys, nil
+}
+
+func loadDKIMKey(path string) (*dkimKey, error) {
+	selector := strings.TrimSuffix(filepath.Base(path), ".pem")
+	if !dkimSelector.MatchString(selector) {
+		return nil, fmt.Errorf("invalid DKIM selector %q in %s", selector, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DKIM key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM key in %s", path)
+	}
+
+	var parsed any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		err = fmt.Errorf("unsupported PEM type %s", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DKIM key %s: %w", path, err)
+	}
+
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		// Verifiers ignore signatures from shorter keys (RFC 8301)
+		if private.N.BitLen() < 1024 {
+			return nil, fmt.Errorf("DKIM key %s is shorter than 1024 bits", path)
+		}
+		return &dkimKey{selector: selector, algorithm: "rsa-sha256", signer: private}, nil
+	case ed25519.PrivateKey:
+		return &dkimKey{selector: selector, algorithm: "ed25519-sha256", signer: private}, nil
+	default:
+		return nil, fmt.Errorf("DKIM key %s is not an RSA or Ed25519 key", path)
+	}
+}
+
+// splitHeaderFields splits a header block into fields, keeping folded lines together
+func splitHeaderFields(header []byte) []string {
+	var fields []string
+	for _, line := range strings.Split(string(header), "\r\n") {
+		if len(fields) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			fields[len(fields)-1] += "\r\n" + line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return fields
+}
+
+func headerName(field string) string {
+	name, _, _ := strings.Cut(field, ":")
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// relaxedHeader canonicalizes a header field: the name lowercased, the value unfolded with
+// runs of whitespace reduced to one space, and no whitespace around the colon
+func relaxedHeader(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = strings.Trim(dkimSpaces.ReplaceAllString(value, " "), " ")
+	return strings.ToLower(strings.TrimRight(name, " \t")) + ":" + value
+}
+
+// relaxedBody canonicalizes a body: runs of whitespace in a line reduced to one space,
+// whitespace at line ends and empty lines at the end removed
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(dkimSpaces.ReplaceAllString(line, " "), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
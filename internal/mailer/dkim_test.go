@@ -0,0 +1,313 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+// dkimDNS answers TXT lookups with the records of the keys it was given, as the DNS of
+// the sending domains would
+type dkimDNS struct {
+	mu      sync.Mutex
+	records map[string]string
+}
+
+func (d *dkimDNS) publish(t *testing.T, selector, domain string, key crypto.Signer) {
+	t.Helper()
+	var record string
+	switch public := key.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			t.Fatalf("failed to marshal public key: %v", err)
+		}
+		record = "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der)
+	case ed25519.PublicKey:
+		record = "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(public)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.records == nil {
+		d.records = make(map[string]string)
+	}
+	d.records[selector+"._domainkey."+domain] = record
+}
+
+func (d *dkimDNS) lookupTXT(name string) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if record, ok := d.records[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, nil
+}
+
+// verify checks the message's one signature and returns the selector it was made with
+func (d *dkimDNS) verify(t *testing.T, signed []byte) (*dkim.Verification, string) {
+	t.Helper()
+	verifications, err := dkim.VerifyWithOptions(bytes.NewReader(signed), &dkim.VerifyOptions{LookupTXT: d.lookupTXT})
+	if err != nil {
+		t.Fatalf("failed to verify message: %v", err)
+	}
+	if len(verifications) != 1 {
+		t.Fatalf("message has %d signatures, want 1", len(verifications))
+	}
+	return verifications[0], dkimTag(t, signed, "s")
+}
+
+// dkimTag returns a tag of the message's DKIM-Signature header
+func dkimTag(t *testing.T, signed []byte, name string) string {
+	t.Helper()
+	field, _, _ := bytes.Cut(signed, []byte("\r\n"))
+	value, ok := strings.CutPrefix(string(field), "DKIM-Signature: ")
+	if !ok {
+		t.Fatalf("message does not start with a DKIM-Signature: %s", field)
+	}
+	for _, tag := range strings.Split(value, ";") {
+		if key, tagValue, _ := strings.Cut(strings.TrimSpace(tag), "="); key == name {
+			return tagValue
+		}
+	}
+	t.Fatalf("DKIM-Signature has no %s tag: %s", name, value)
+	return ""
+}
+
+// writeDKIMKey stores the key as <dir>/<domain>/<selector>.pem
+func writeDKIMKey(t *testing.T, dir, domain, selector string, key crypto.Signer) {
+	t.Helper()
+	var block *pem.Block
+	switch private := key.(type) {
+	case *rsa.PrivateKey:
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)}
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		if err != nil {
+			t.Fatalf("failed to marshal private key: %v", err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, domain), 0700); err != nil {
+		t.Fatalf("failed to create key directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, domain, selector+".pem"), pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+}
+
+func newRSAKey(t *testing.T) crypto.Signer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	return key
+}
+
+func newEd25519Key(t *testing.T) crypto.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+	return key
+}
+
+// renderTestMessage renders a multipart message from the domain with List-Unsubscribe
+func renderTestMessage(t *testing.T, domain string) []byte {
+	t.Helper()
+	msg := &Message{
+		From:        "Airlock <airlock@" + domain + ">",
+		To:          "jurgen@example.org",
+		Subject:     "Sign in to Airlock – Jürgen",
+		HTML:        testHTML,
+		Text:        testText,
+		Unsubscribe: "https://airlock.example.com/api/email/unsubscribe?token=u1",
+	}
+	raw, err := msg.Bytes()
+	if err != nil {
+		t.Fatalf("failed to render message: %v", err)
+	}
+	return raw
+}
+
+func TestDKIMSignVerifies(t *testing.T) {
+	for _, tc := range []struct {
+		algorithm string
+		key       crypto.Signer
+	}{
+		{"rsa-sha256", newRSAKey(t)},
+		{"ed25519-sha256", newEd25519Key(t)},
+	} {
+		t.Run(tc.algorithm, func(t *testing.T) {
+			dir := t.TempDir()
+			writeDKIMKey(t, dir, "luna4.me", "s202610", tc.key)
+			signer, err := NewDKIMSigner(DKIMConfig{KeyDir: dir})
+			if err != nil {
+				t.Fatalf("failed to load keys: %v", err)
+			}
+			dns := &dkimDNS{}
+			dns.publish(t, "s202610", "luna4.me", tc.key)
+
+			signed, err := signer.Sign(renderTestMessage(t, "luna4.me"), "airlock@Luna4.me")
+			if err != nil {
+				t.Fatalf("failed to sign message: %v", err)
+			}
+			if got := dkimTag(t, signed, "a"); got != tc.algorithm {
+				t.Errorf("signed with %s, want %s", got, tc.algorithm)
+			}
+			if got := dkimTag(t, signed, "c"); got != "relaxed/relaxed" {
+				t.Errorf("canonicalization is %s, want relaxed/relaxed", got)
+			}
+
+			verification, selector := dns.verify(t, signed)
+			if verification.Err != nil {
+				t.Fatalf("signature does not verify: %v", verification.Err)
+			}
+			if verification.Domain != "luna4.me" || selector != "s202610" {
+				t.Errorf("signed by %s with selector %s, want luna4.me with s202610", verification.Domain, selector)
+			}
+			for _, name := range dkimHeaders {
+				found := false
+				for _, key := range verification.HeaderKeys {
+					found = found || strings.EqualFold(key, name)
+				}
+				if !found {
+					t.Errorf("header %s is not signed", name)
+				}
+			}
+
+			// Relaxed canonicalization tolerates whitespace changes in transit
+			relaxed := bytes.Replace(signed, []byte("Subject: "), []byte("Subject:  \t"), 1)
+			relaxed = bytes.Replace(relaxed, []byte("Hello J=C3=BCrgen,\r\n"), []byte("Hello \t J=C3=BCrgen,  \r\n"), 2)
+			if verification, _ := dns.verify(t, relaxed); verification.Err != nil {
+				t.Errorf("signature does not verify after whitespace changes: %v", verification.Err)
+			}
+
+			for name, tampered := range map[string][]byte{
+				"subject": bytes.Replace(signed, []byte("Subject: =?UTF-8?q?Sign_in"), []byte("Subject: =?UTF-8?q?Log_in"), 1),
+				"body":    bytes.Replace(signed, []byte("token=3Da1b2c3"), []byte("token=3Dz9y8x7"), 1),
+				"unsubscribe": bytes.Replace(signed, []byte("unsubscribe?token=u1"),
+					[]byte("unsubscribe?token=u2"), 1),
+			} {
+				if bytes.Equal(tampered, signed) {
+					t.Fatalf("tampering with the %s changed nothing", name)
+				}
+				if verification, _ := dns.verify(t, tampered); verification.Err == nil {
+					t.Errorf("signature verifies after the %s was changed", name)
+				}
+			}
+		})
+	}
+}
+
+func TestDKIMSignWithoutKey(t *testing.T) {
+	dir := t.TempDir()
+	writeDKIMKey(t, dir, "luna4.me", "s202610", newEd25519Key(t))
+	signer, err := NewDKIMSigner(DKIMConfig{KeyDir: dir})
+	if err != nil {
+		t.Fatalf("failed to load keys: %v", err)
+	}
+
+	raw := renderTestMessage(t, "example.com")
+	signed, err := signer.Sign(raw, "airlock@example.com")
+	if err != nil {
+		t.Fatalf("failed to sign message: %v", err)
+	}
+	if !bytes.Equal(signed, raw) {
+		t.Errorf("message from a domain without a key was changed")
+	}
+}
+
+func TestDKIMSelectorRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldKey, newKey := newRSAKey(t), newEd25519Key(t)
+	writeDKIMKey(t, dir, "luna4.me", "s202609", oldKey)
+	signer, err := NewDKIMSigner(DKIMConfig{KeyDir: dir, ReloadInterval: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("failed to load keys: %v", err)
+	}
+	dns := &dkimDNS{}
+	dns.publish(t, "s202609", "luna4.me", oldKey)
+	dns.publish(t, "s202610", "luna4.me", newKey)
+
+	before, err := signer.Sign(renderTestMessage(t, "luna4.me"), "airlock@luna4.me")
+	if err != nil {
+		t.Fatalf("failed to sign message: %v", err)
+	}
+
+	// A key with a later selector rotates in on the next reload
+	writeDKIMKey(t, dir, "luna4.me", "s202610", newKey)
+	time.Sleep(time.Millisecond)
+	after, err := signer.Sign(renderTestMessage(t, "luna4.me"), "airlock@luna4.me")
+	if err != nil {
+		t.Fatalf("failed to sign message: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name, selector, algorithm string
+		signed                    []byte
+	}{
+		{"mail signed before the rotation", "s202609", "rsa-sha256", before},
+		{"mail signed after the rotation", "s202610", "ed25519-sha256", after},
+	} {
+		verification, selector := dns.verify(t, tc.signed)
+		if verification.Err != nil {
+			t.Errorf("%s does not verify: %v", tc.name, verification.Err)
+		}
+		if selector != tc.selector || dkimTag(t, tc.signed, "a") != tc.algorithm {
+			t.Errorf("%s uses selector %s, want %s", tc.name, selector, tc.selector)
+		}
+	}
+
+	// A key file that cannot be read keeps the current key in use
+	if err := os.WriteFile(filepath.Join(dir, "luna4.me", "s202611.pem"), []byte("not a key"), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	broken, err := signer.Sign(renderTestMessage(t, "luna4.me"), "airlock@luna4.me")
+	if err != nil {
+		t.Fatalf("failed to sign message: %v", err)
+	}
+	if verification, selector := dns.verify(t, broken); verification.Err != nil || selector != "s202610" {
+		t.Errorf("after a bad key file, signed with selector %s (%v), want s202610", selector, verification.Err)
+	}
+}
+
+func TestLoadDKIMKeysRejects(t *testing.T) {
+	t.Setenv("GODEBUG", "rsa1024min=0")
+	short, err := rsa.GenerateKey(rand.Reader, 512)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	for name, write := range map[string]func(dir string){
+		"short RSA key": func(dir string) { writeDKIMKey(t, dir, "luna4.me", "s1", short) },
+		"bad selector":  func(dir string) { writeDKIMKey(t, dir, "luna4.me", "s_1", newEd25519Key(t)) },
+		"not PEM": func(dir string) {
+			os.MkdirAll(filepath.Join(dir, "luna4.me"), 0700)
+			os.WriteFile(filepath.Join(dir, "luna4.me", "s1.pem"), []byte("not a key"), 0600)
+		},
+	} {
+		dir := t.TempDir()
+		write(dir)
+		if _, err := NewDKIMSigner(DKIMConfig{KeyDir: dir}); err == nil {
+			t.Errorf("loading a key directory with a %s succeeded", name)
+		}
+	}
+}
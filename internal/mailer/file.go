@@ -0,0 +1,204 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	outboxExtension  = ".eml"
+	outboxTimeLayout = "20060102T150405.000Z"
+)
+
+// OutboxMessage is a message written to the outbox directory
+type OutboxMessage struct {
+	ID      string `json:"id"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	SentAt  int64  `json:"sentAt"`
+	HTML    string `json:"-"`
+}
+
+// FileMailer writes every message to a directory as an .eml file instead of sending it.
+// It is meant for development, where the messages are read back through the dev mailbox.
+type FileMailer struct {
+	dir string
+}
+
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory %s: %w", dir, err)
+	}
+	return &FileMailer{dir: dir}, nil
+}
+
+// Send writes to a temporary file first so a partial message is never listed
+func (m *FileMailer) Send(ctx context.Context, msg *Message) error {
+	raw, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := time.Now().UTC().Format(outboxTimeLayout) + "-" + hex.EncodeToString(suffix) + outboxExtension
+
+	tmp := filepath.Join(m.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(m.dir, name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+// List returns the messages in the outbox without their bodies, newest first
+func (m *FileMailer) List() ([]OutboxMessage, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read outbox directory %s: %w", m.dir, err)
+	}
+
+	var messages []OutboxMessage
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), outboxExtension) || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		message, err := m.read(entry.Name(), false)
+		if err != nil {
+			continue
+		}
+		messages = append(messages, *message)
+	}
+
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID > messages[j].ID })
+	return messages, nil
+}
+
+// Get returns a message with its HTML body, or nil if there is none
+func (m *FileMailer) Get(id string) (*OutboxMessage, error) {
+	if id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, nil
+	}
+
+	message, err := m.read(id+outboxExtension, true)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return message, err
+}
+
+func (m *FileMailer) read(name string, withBody bool) (*OutboxMessage, error) {
+	f, err := os.Open(filepath.Join(m.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message %s: %w", name, err)
+	}
+
+	var decoder mime.WordDecoder
+	subject, err := decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+
+	message := &OutboxMessage{
+		ID:      strings.TrimSuffix(name, outboxExtension),
+		From:    msg.Header.Get("From"),
+		To:      msg.Header.Get("To"),
+		Subject: subject,
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		message.SentAt = date.UnixMilli()
+	}
+
+	if withBody {
+		body, err := messageBody(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read body of message %s: %w", name, err)
+		}
+		message.HTML = body
+	}
+	return message, nil
+}
+
+// messageBody returns the body of a message as HTML, preferring the HTML part of multipart
+// messages. Plain text bodies are escaped and wrapped in a pre element.
+func messageBody(contentType, encoding string, body io.Reader) (string, error) {
+	content, isHTML, err := readPart(contentType, encoding, body)
+	if err != nil {
+		return "", err
+	}
+	if isHTML {
+		return content, nil
+	}
+	return "<pre>" + html.EscapeString(content) + "</pre>", nil
+}
+
+// readPart decodes a body and reports whether it is HTML
+func readPart(contentType, encoding string, body io.Reader) (string, bool, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		text := ""
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return text, false, nil
+			}
+			if err != nil {
+				return "", false, err
+			}
+			content, isHTML, err := readPart(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return "", false, err
+			}
+			if isHTML {
+				return content, true, nil
+			}
+			if text == "" {
+				text = content
+			}
+		}
+	}
+
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return "", false, err
+	}
+	return string(content), mediaType == "text/html", nil
+}
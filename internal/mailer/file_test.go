@@ -0,0 +1,79 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileMailer(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "outbox")
+	m, err := NewFileMailer(dir)
+	if err != nil {
+		t.Fatalf("failed to create file mailer: %v", err)
+	}
+
+	messages := []*Message{
+		{From: "Airlock <airlock@example.com>", To: "jurgen@example.org", Subject: "Sign in to Airlock – Jürgen", HTML: testHTML, Text: testText},
+		{From: "airlock@example.com", To: "grace@example.org", Subject: "Plain", Text: "<b>not html</b>\n"},
+	}
+	for _, msg := range messages {
+		if err := m.Send(ctx, msg); err != nil {
+			t.Fatalf("failed to send message: %v", err)
+		}
+		// Messages sort by the millisecond they were written
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	// Files that are not finished messages are not listed
+	for _, name := range []string{".partial.eml.tmp", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("From: x\r\n\r\n"), 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	listed, err := m.List()
+	if err != nil {
+		t.Fatalf("failed to list outbox: %v", err)
+	}
+	if len(listed) != 2 || listed[0].To != "grace@example.org" || listed[1].To != "jurgen@example.org" {
+		t.Fatalf("listed %+v, want the two messages newest first", listed)
+	}
+	if listed[1].Subject != messages[0].Subject || listed[1].SentAt == 0 || listed[1].HTML != "" {
+		t.Errorf("listed message is %+v, want the decoded subject and no body", listed[1])
+	}
+
+	// Multipart messages show their HTML part, plain ones their escaped text
+	for id, want := range map[string]string{
+		listed[1].ID: testHTML,
+		listed[0].ID: "<pre>&lt;b&gt;not html&lt;/b&gt;\r\n</pre>",
+	} {
+		message, err := m.Get(id)
+		if err != nil || message == nil {
+			t.Fatalf("failed to get %s: %v", id, err)
+		}
+		if message.HTML != want {
+			t.Errorf("message %s shows %q, want %q", id, message.HTML, want)
+		}
+	}
+
+	for _, id := range []string{"missing", "../outbox/" + listed[0].ID, ".partial.eml"} {
+		if message, err := m.Get(id); err != nil || message != nil {
+			t.Errorf("getting %q returned %v (%v), want nothing", id, message, err)
+		}
+	}
+
+	if err := m.Send(ctx, &Message{From: "airlock@example.com", To: "not an address", Text: "x"}); !IsPermanent(err) {
+		t.Errorf("sending to an invalid address returned %v, want a PermanentError", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") && entry.Name() != ".partial.eml.tmp" {
+			t.Errorf("failed send left %s behind", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mail backends selectable with MAIL_BACKEND
+const (
+	BackendSES  = "ses"
+	BackendSMTP = "smtp"
+	BackendFile = "file"
+)
+
+// Message is a single email to one recipient, sent as multipart/alternative when it has
+// both an HTML and a plain text body
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+
+	// Unsubscribe is a URL that stops this kind of email in one click (RFC 8058), sent
+	// as List-Unsubscribe when set
+	Unsubscribe string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// PermanentError marks a delivery failure that retrying cannot fix, such as a rejected
+// recipient or a malformed address
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent reports whether err is a PermanentError
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// Config selects the mail backend and holds its settings
+type Config struct {
+	Backend   string
+	SMTP      SMTPConfig
+	OutboxDir string
+}
+
+// GetConfig reads the mail settings from the environment
+func GetConfig() Config {
+	config := Config{
+		Backend: strings.ToLower(os.Getenv("MAIL_BACKEND")),
+		SMTP: SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     getEnvInt("SMTP_PORT", 587), // Default submission port
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			TLS:      strings.ToLower(os.Getenv("SMTP_TLS")),
+			DKIM: DKIMConfig{
+				KeyDir:         os.Getenv("DKIM_KEY_DIR"),                                          // Default unsigned
+				ReloadInterval: time.Duration(getEnvInt("DKIM_RELOAD_INTERVAL", 60)) * time.Second, // Default every minute
+			},
+		},
+		OutboxDir: os.Getenv("MAIL_OUTBOX_DIR"),
+	}
+	if config.Backend == "" {
+		config.Backend = BackendSES // Default AWS SES
+	}
+	if config.SMTP.TLS == "" {
+		config.SMTP.TLS = SMTPStartTLS // Default STARTTLS
+	}
+	if config.OutboxDir == "" {
+		config.OutboxDir = "data/outbox" // Default outbox directory
+	}
+	return config
+}
+
+// New creates the mailer for the configured backend
+func New(ctx context.Context, config Config) (Mailer, error) {
+	switch config.Backend {
+	case BackendSES:
+		return NewSESMailer(ctx)
+	case BackendSMTP:
+		return NewSMTPMailer(config.SMTP)
+	case BackendFile:
+		return NewFileMailer(config.OutboxDir)
+	default:
+		return nil, fmt.Errorf("unknown mail backend: %s", config.Backend)
+	}
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,170 @@
+package mailer
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var messageIDPattern = regexp.MustCompile(`^<[0-9a-f]{32}@example\.com>$`)
+
+const (
+	testHTML = `<html><body><p>Hello Jürgen,</p><p><a href="https://airlock.example.com/auth?token=a1b2c3&app=luna4">Sign in to Airlock</a></p><p>This link expires in 15 minutes. If you did not ask to sign in, you can ignore this email.</p></body></html>`
+	testText = "Hello Jürgen,\n\nSign in to Airlock: https://airlock.example.com/auth?token=a1b2c3&app=luna4\n\nThis link expires in 15 minutes. If you did not ask to sign in, you can ignore this email.\n"
+)
+
+func TestMessageBytes(t *testing.T) {
+	for name, msg := range map[string]*Message{
+		"alternative": {
+			From:        "Airlock <airlock@example.com>",
+			To:          "jurgen@example.org",
+			Subject:     "Sign in to Airlock – Jürgen",
+			HTML:        testHTML,
+			Text:        testText,
+			Unsubscribe: "https://airlock.example.com/api/email/unsubscribe?token=u1",
+		},
+		"html": {
+			From:    "airlock@example.com",
+			To:      "Jürgen <jurgen@example.org>",
+			Subject: "Sign in to Airlock",
+			HTML:    testHTML,
+		},
+		"text": {
+			From:    "airlock@example.com",
+			To:      "jurgen@example.org",
+			Subject: "Sign in to Airlock",
+			Text:    testText,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			raw, err := msg.Bytes()
+			if err != nil {
+				t.Fatalf("failed to render message: %v", err)
+			}
+			assertMessageParts(t, raw, msg)
+			assertGolden(t, filepath.Join("testdata", name+".golden"), normalizeMessage(t, raw))
+		})
+	}
+}
+
+func TestMessageBytesRejectsInvalidAddresses(t *testing.T) {
+	for _, msg := range []*Message{
+		{From: "not an address", To: "jurgen@example.org", Text: testText},
+		{From: "airlock@example.com", To: "jurgen@", Text: testText},
+	} {
+		if _, err := msg.Bytes(); !IsPermanent(err) {
+			t.Errorf("rendering from %q to %q returned %v, want a PermanentError", msg.From, msg.To, err)
+		}
+	}
+}
+
+// assertMessageParts parses the message as a mail client would and checks that it holds
+// the text and HTML bodies, plainest first
+func assertMessageParts(t *testing.T, raw []byte, msg *Message) {
+	t.Helper()
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+	if subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject")); err != nil || subject != msg.Subject {
+		t.Errorf("subject decodes to %q (%v), want %q", subject, err, msg.Subject)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("failed to parse content type: %v", err)
+	}
+	if mediaType != "multipart/alternative" {
+		body := msg.HTML + msg.Text
+		if got := readQuotedPrintable(t, parsed.Body); got != body {
+			t.Errorf("body decodes to %q, want %q", got, body)
+		}
+		return
+	}
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		part, err := reader.NextRawPart()
+		if err != nil {
+			t.Fatalf("failed to read %s part: %v", want.contentType, err)
+		}
+		if got := part.Header.Get("Content-Type"); got != want.contentType {
+			t.Errorf("part is %s, want %s", got, want.contentType)
+		}
+		if got := readQuotedPrintable(t, part); got != want.body {
+			t.Errorf("%s part decodes to %q, want %q", want.contentType, got, want.body)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("message has more than two parts: %v", err)
+	}
+}
+
+// readQuotedPrintable decodes a body, turning the CRLF line breaks of the message back
+// into newlines
+func readQuotedPrintable(t *testing.T, r io.Reader) string {
+	t.Helper()
+	body, err := io.ReadAll(quotedprintable.NewReader(r))
+	if err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	return strings.ReplaceAll(string(body), "\r\n", "\n")
+}
+
+// normalizeMessage replaces the date, Message-ID and boundary, which change with every
+// rendering, after checking their form
+func normalizeMessage(t *testing.T, raw []byte) []byte {
+	t.Helper()
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+
+	date := parsed.Header.Get("Date")
+	if _, err := mail.ParseDate(date); err != nil {
+		t.Errorf("Date %q does not parse: %v", date, err)
+	}
+	id := parsed.Header.Get("Message-ID")
+	if !messageIDPattern.MatchString(id) {
+		t.Errorf("Message-ID %q is not unique to the sender's domain", id)
+	}
+
+	normalized := string(raw)
+	normalized = strings.Replace(normalized, "Date: "+date+"\r\n", "Date: DATE\r\n", 1)
+	normalized = strings.Replace(normalized, "Message-ID: "+id+"\r\n", "Message-ID: <MESSAGE-ID@example.com>\r\n", 1)
+	if _, params, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type")); params["boundary"] != "" {
+		normalized = strings.ReplaceAll(normalized, params["boundary"], "BOUNDARY")
+	}
+	return []byte(normalized)
+}
+
+func assertGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s (run with -update to create it): %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("message differs from %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
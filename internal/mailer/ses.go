@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ses"
+	"github.com/aws/aws-sdk-go-v2/service/ses/types"
+)
+
+// SESMailer sends through AWS SES with the default AWS credentials
+type SESMailer struct {
+	client *ses.Client
+}
+
+func NewSESMailer(ctx context.Context) (*SESMailer, error) {
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(os.Getenv("AWS_REGION")),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &SESMailer{client: ses.NewFromConfig(cfg)}, nil
+}
+
+// Send passes the rendered message to SES as raw MIME, which keeps the text part and
+// headers such as List-Unsubscribe that SendEmail cannot express
+func (m *SESMailer) Send(ctx context.Context, msg *Message) error {
+	raw, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	input := &ses.SendRawEmailInput{
+		RawMessage: &types.RawMessage{Data: raw},
+	}
+
+	if _, err := m.client.SendRawEmail(ctx, input); err != nil {
+		var rejected *types.MessageRejected
+		if errors.As(err, &rejected) {
+			return &PermanentError{err}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// SMTP transport security modes selectable with SMTP_TLS
+const (
+	SMTPStartTLS = "starttls" // Upgrade a plain connection, required
+	SMTPTLS      = "tls"      // Implicit TLS, usually port 465
+	SMTPNone     = "none"     // Plain text, only for local relays
+)
+
+const smtpTimeout = 30 * time.Second
+
+// SMTPConfig points the SMTP mailer at a relay
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	TLS      string
+	DKIM     DKIMConfig
+}
+
+// SMTPMailer sends through an SMTP relay, opening a connection per message, and signs
+// messages with DKIM when keys are configured
+type SMTPMailer struct {
+	config SMTPConfig
+	dkim   *DKIMSigner
+}
+
+func NewSMTPMailer(config SMTPConfig) (*SMTPMailer, error) {
+	if config.Host == "" {
+		return nil, fmt.Errorf("SMTP_HOST is required for the smtp mail backend")
+	}
+	switch config.TLS {
+	case SMTPStartTLS, SMTPTLS, SMTPNone:
+	default:
+		return nil, fmt.Errorf("unknown SMTP TLS mode: %s", config.TLS)
+	}
+
+	mailer := &SMTPMailer{config: config}
+	if config.DKIM.KeyDir != "" {
+		signer, err := NewDKIMSigner(config.DKIM)
+		if err != nil {
+			return nil, err
+		}
+		mailer.dkim = signer
+	}
+	return mailer, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg *Message) error {
+	raw, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	// Both addresses parsed when the message was rendered
+	from, _ := mail.ParseAddress(msg.From)
+	to, _ := mail.ParseAddress(msg.To)
+
+	if m.dkim != nil {
+		if raw, err = m.dkim.Sign(raw, from.Address); err != nil {
+			return err
+		}
+	}
+
+	client, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if m.config.Username != "" {
+		// PlainAuth refuses to send credentials over an unencrypted connection to a remote host
+		auth := smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("failed to authenticate with SMTP server: %w", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return smtpError("SMTP server rejected sender", err)
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return smtpError("SMTP server rejected recipient", err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return smtpError("SMTP server refused data", err)
+	}
+	if _, err := w.Write(raw); err != nil {
+		return fmt.Errorf("failed to write SMTP data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return smtpError("SMTP server rejected message", err)
+	}
+	return client.Quit()
+}
+
+// dial connects to the relay and secures the connection as configured
+func (m *SMTPMailer) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	tlsConfig := &tls.Config{ServerName: m.config.Host}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+
+	var conn net.Conn
+	var err error
+	if m.config.TLS == SMTPTLS {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SMTP server %s: %w", addr, err)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+
+	if m.config.TLS == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("SMTP server %s does not support STARTTLS", addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// smtpError wraps a server reply, marking 5xx replies as permanent failures
+func smtpError(message string, err error) error {
+	err = fmt.Errorf("%s: %w", message, err)
+
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return &PermanentError{err}
+	}
+	return err
+}
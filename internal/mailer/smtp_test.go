@@ -0,0 +1,234 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpServer is a plain text SMTP relay that records what it is sent. Replies maps a
+// command to the reply it gets instead of success.
+type smtpServer struct {
+	listener   net.Listener
+	extensions []string
+	replies    map[string]string
+
+	mu       sync.Mutex
+	auth     string
+	from, to string
+	data     []byte
+}
+
+func newSMTPServer(t *testing.T, extensions []string, replies map[string]string) *smtpServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &smtpServer{listener: listener, extensions: extensions, replies: replies}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) config() SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return SMTPConfig{Host: host, Port: p, TLS: SMTPNone}
+}
+
+func (s *smtpServer) serve(c net.Conn) {
+	conn := textproto.NewConn(c)
+	defer conn.Close()
+	conn.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		verb = strings.ToUpper(verb)
+		if reply, ok := s.replies[verb]; ok {
+			conn.PrintfLine("%s", reply)
+			continue
+		}
+
+		s.mu.Lock()
+		switch verb {
+		case "EHLO":
+			for _, extension := range s.extensions {
+				conn.PrintfLine("250-%s", extension)
+			}
+			conn.PrintfLine("250 localhost")
+		case "AUTH":
+			s.auth = arg
+			conn.PrintfLine("235 Authenticated")
+		case "MAIL":
+			s.from = arg
+			conn.PrintfLine("250 OK")
+		case "RCPT":
+			s.to = arg
+			conn.PrintfLine("250 OK")
+		case "DATA":
+			conn.PrintfLine("354 Go ahead")
+			s.mu.Unlock()
+			data, err := conn.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = data
+			conn.PrintfLine("250 Queued")
+		case "QUIT":
+			conn.PrintfLine("221 Bye")
+			s.mu.Unlock()
+			return
+		default:
+			conn.PrintfLine("502 Not implemented")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestSMTPMailerSends(t *testing.T) {
+	server := newSMTPServer(t, []string{"AUTH PLAIN"}, nil)
+	config := server.config()
+	config.Username, config.Password = "airlock", "secret"
+	m, err := NewSMTPMailer(config)
+	if err != nil {
+		t.Fatalf("failed to create SMTP mailer: %v", err)
+	}
+
+	msg := &Message{From: "Airlock <airlock@example.com>", To: "Jürgen <jurgen@example.org>", Subject: "Sign in to Airlock", HTML: testHTML, Text: testText}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.from != "FROM:<airlock@example.com>" || server.to != "TO:<jurgen@example.org>" {
+		t.Errorf("envelope is %s %s, want the bare addresses", server.from, server.to)
+	}
+	if want := "PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00airlock\x00secret")); server.auth != want {
+		t.Errorf("authenticated with %q, want %q", server.auth, want)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(server.data))
+	if err != nil {
+		t.Fatalf("relay received an unreadable message: %v", err)
+	}
+	if parsed.Header.Get("Subject") != msg.Subject {
+		t.Errorf("relay received subject %q", parsed.Header.Get("Subject"))
+	}
+}
+
+func TestSMTPMailerSignsWithDKIM(t *testing.T) {
+	keyDir := t.TempDir()
+	var dns dkimDNS
+	key := newEd25519Key(t)
+	writeDKIMKey(t, keyDir, "example.com", "airlock1", key)
+	dns.publish(t, "airlock1", "example.com", key)
+
+	server := newSMTPServer(t, nil, nil)
+	config := server.config()
+	config.DKIM = DKIMConfig{KeyDir: keyDir}
+	m, err := NewSMTPMailer(config)
+	if err != nil {
+		t.Fatalf("failed to create SMTP mailer: %v", err)
+	}
+
+	if err := m.Send(context.Background(), &Message{From: "airlock@example.com", To: "jurgen@example.org", Subject: "Hi", Text: testText}); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if verification, selector := dns.verify(t, server.data); verification.Err != nil || selector != "airlock1" {
+		t.Errorf("relayed message verifies as %v with selector %s", verification.Err, selector)
+	}
+}
+
+func TestSMTPMailerFailures(t *testing.T) {
+	msg := &Message{From: "airlock@example.com", To: "jurgen@example.org", Subject: "Hi", Text: testText}
+
+	for _, tc := range []struct {
+		name       string
+		extensions []string
+		replies    map[string]string
+		tls        string
+		permanent  bool
+		want       string
+	}{
+		{"rejected recipient", nil, map[string]string{"RCPT": "550 No such user"}, SMTPNone, true, "rejected recipient"},
+		{"rejected sender", nil, map[string]string{"MAIL": "553 Sender not allowed"}, SMTPNone, true, "rejected sender"},
+		{"rejected message", nil, map[string]string{"DATA": "554 Spam"}, SMTPNone, true, "refused data"},
+		{"busy recipient", nil, map[string]string{"RCPT": "451 Try again later"}, SMTPNone, false, "rejected recipient"},
+		{"no STARTTLS", nil, nil, SMTPStartTLS, false, "does not support STARTTLS"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newSMTPServer(t, tc.extensions, tc.replies)
+			config := server.config()
+			config.TLS = tc.tls
+			m, err := NewSMTPMailer(config)
+			if err != nil {
+				t.Fatalf("failed to create SMTP mailer: %v", err)
+			}
+
+			err = m.Send(context.Background(), msg)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("send returned %v, want %q", err, tc.want)
+			}
+			if IsPermanent(err) != tc.permanent {
+				t.Errorf("error %v is permanent: %t, want %t", err, IsPermanent(err), tc.permanent)
+			}
+		})
+	}
+
+	if _, err := NewSMTPMailer(SMTPConfig{Port: 25, TLS: SMTPNone}); err == nil {
+		t.Errorf("SMTP mailer without a host was created")
+	}
+	if _, err := NewSMTPMailer(SMTPConfig{Host: "localhost", Port: 25, TLS: "ssl"}); err == nil {
+		t.Errorf("SMTP mailer with an unknown TLS mode was created")
+	}
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "outbox")
+
+	if m, err := New(ctx, Config{Backend: BackendFile, OutboxDir: dir}); err != nil {
+		t.Errorf("file backend failed: %v", err)
+	} else if _, ok := m.(*FileMailer); !ok {
+		t.Errorf("file backend is %T", m)
+	}
+	if m, err := New(ctx, Config{Backend: BackendSMTP, SMTP: SMTPConfig{Host: "localhost", Port: 25, TLS: SMTPStartTLS}}); err != nil {
+		t.Errorf("smtp backend failed: %v", err)
+	} else if _, ok := m.(*SMTPMailer); !ok {
+		t.Errorf("smtp backend is %T", m)
+	}
+	if _, err := New(ctx, Config{Backend: "pigeon"}); err == nil {
+		t.Errorf("unknown backend was created")
+	}
+
+	for key, value := range map[string]string{"MAIL_BACKEND": "FILE", "SMTP_PORT": "", "SMTP_TLS": "", "MAIL_OUTBOX_DIR": ""} {
+		t.Setenv(key, value)
+	}
+	config := GetConfig()
+	if config.Backend != BackendFile || config.SMTP.Port != 587 || config.SMTP.TLS != SMTPStartTLS || config.OutboxDir != "data/outbox" {
+		t.Errorf("config from the environment is %+v", config)
+	}
+}
@@ -0,0 +1,35 @@
+package model
+
+type AuditAction string
+
+const (
+	AuditActionUserSuspended      AuditAction = "USER_SUSPENDED"
+	AuditActionUserActivated      AuditAction = "USER_ACTIVATED"
+	AuditActionUserLocked         AuditAction = "USER_LOCKED"
+	AuditActionUserDeleted        AuditAction = "USER_DELETED"
+	AuditActionUserRestored       AuditAction = "USER_RESTORED"
+	AuditActionUserPurged         AuditAction = "USER_PURGED"
+	AuditActionUserErased         AuditAction = "USER_ERASED"
+	AuditActionUserProvisioned    AuditAction = "USER_PROVISIONED"
+	AuditActionProfileUpdated     AuditAction = "PROFILE_UPDATED"
+	AuditActionPreferencesUpdated AuditAction = "PREFERENCES_UPDATED"
+	AuditActionDormancyWarning    AuditAction = "DORMANCY_WARNING"
+
+	AuditActionEmailChangeRequested AuditAction = "EMAIL_CHANGE_REQUESTED"
+	AuditActionEmailChanged         AuditAction = "EMAIL_CHANGED"
+	AuditActionEmailChangeReverted  AuditAction = "EMAIL_CHANGE_REVERTED"
+	AuditActionSessionsRevoked      AuditAction = "SESSIONS_REVOKED"
+	AuditActionEmailSuppressed      AuditAction = "EMAIL_SUPPRESSED"
+	AuditActionEmailUnsuppressed    AuditAction = "EMAIL_UNSUPPRESSED"
+	AuditActionPasskeyRegistered    AuditAction = "PASSKEY_REGISTERED"
+	AuditActionPasskeyRemoved       AuditAction = "PASSKEY_REMOVED"
+)
+
+type Luna4AuditLog struct {
+	ID        string      `json:"id" dynamodbav:"id"`
+	UserID    string      `json:"userId" dynamodbav:"userId"`
+	Actor     string      `json:"actor" dynamodbav:"actor"`
+	Action    AuditAction `json:"action" dynamodbav:"action"`
+	Detail    string      `json:"detail,omitempty" dynamodbav:"detail"`
+	CreatedAt int64       `json:"createdAt" dynamodbav:"createdAt"`
+}
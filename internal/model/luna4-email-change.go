@@ -0,0 +1,14 @@
+package model
+
+type Luna4EmailChange struct {
+	ID           string `json:"id" dynamodbav:"id"`
+	UserID       string `json:"userId" dynamodbav:"userId"`
+	OldEmail     string `json:"oldEmail" dynamodbav:"oldEmail"`
+	NewEmail     string `json:"newEmail" dynamodbav:"newEmail"`
+	ConfirmToken string `json:"-" dynamodbav:"confirmToken"`
+	RevertToken  string `json:"-" dynamodbav:"revertToken"`
+	RequestedBy  string `json:"requestedBy" dynamodbav:"requestedBy"`
+	RequestedAt  int64  `json:"requestedAt" dynamodbav:"requestedAt"`
+	ConfirmedAt  *int64 `json:"confirmedAt,omitempty" dynamodbav:"confirmedAt,omitempty"`
+	RevertedAt   *int64 `json:"revertedAt,omitempty" dynamodbav:"revertedAt,omitempty"`
+}
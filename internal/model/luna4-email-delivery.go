@@ -0,0 +1,42 @@
+package model
+
+type DeliveryEventType string
+
+const (
+	DeliveryEventDelivery  DeliveryEventType = "DELIVERY"
+	DeliveryEventBounce    DeliveryEventType = "BOUNCE"
+	DeliveryEventComplaint DeliveryEventType = "COMPLAINT"
+)
+
+type SuppressionReason string
+
+const (
+	SuppressionReasonHardBounce SuppressionReason = "HARD_BOUNCE"
+	SuppressionReasonComplaint  SuppressionReason = "COMPLAINT"
+)
+
+// Luna4DeliveryEvent is a delivery, bounce or complaint SES reported for an address
+type Luna4DeliveryEvent struct {
+	ID        string            `json:"id" dynamodbav:"id"`
+	Email     string            `json:"email" dynamodbav:"email"`
+	Type      DeliveryEventType `json:"type" dynamodbav:"type"`
+	SubType   string            `json:"subType,omitempty" dynamodbav:"subType"`
+	Detail    string            `json:"detail,omitempty" dynamodbav:"detail"`
+	MessageID string            `json:"messageId,omitempty" dynamodbav:"messageId"`
+	CreatedAt int64             `json:"createdAt" dynamodbav:"createdAt"`
+}
+
+// Luna4EmailSuppression stops all mail to an address that hard bounced or complained
+type Luna4EmailSuppression struct {
+	Email     string            `json:"email" dynamodbav:"id"`
+	Reason    SuppressionReason `json:"reason" dynamodbav:"reason"`
+	Detail    string            `json:"detail,omitempty" dynamodbav:"detail"`
+	CreatedAt int64             `json:"createdAt" dynamodbav:"createdAt"`
+}
+
+// Luna4DeliveryStatus summarizes whether mail reaches an address
+type Luna4DeliveryStatus struct {
+	Suppressed  bool                   `json:"suppressed"`
+	Suppression *Luna4EmailSuppression `json:"suppression,omitempty"`
+	Events      []Luna4DeliveryEvent   `json:"events"`
+}
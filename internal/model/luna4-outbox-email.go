@@ -0,0 +1,30 @@
+package model
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending OutboxStatus = "PENDING"
+	OutboxStatusSent    OutboxStatus = "SENT"
+	OutboxStatusDead    OutboxStatus = "DEAD"
+)
+
+// Luna4OutboxEmail is a message queued for delivery. The body is cleared once the message
+// is sent, and when a message with an expiry (one carrying a sign-in or confirmation link)
+// is dead-lettered, so the links are not kept. Until then the body is stored as rendered.
+type Luna4OutboxEmail struct {
+	ID             string       `json:"id" dynamodbav:"id"`
+	IdempotencyKey string       `json:"idempotencyKey" dynamodbav:"idempotencyKey"`
+	Sender         string       `json:"sender" dynamodbav:"sender"`
+	Recipient      string       `json:"recipient" dynamodbav:"recipient"`
+	Subject        string       `json:"subject" dynamodbav:"subject"`
+	HTML           string       `json:"-" dynamodbav:"html"`
+	Text           string       `json:"-" dynamodbav:"text"`
+	Unsubscribe    string       `json:"unsubscribe,omitempty" dynamodbav:"unsubscribe"`
+	Status         OutboxStatus `json:"status" dynamodbav:"status"`
+	Attempts       int          `json:"attempts" dynamodbav:"attempts"`
+	NextAttemptAt  int64        `json:"nextAttemptAt" dynamodbav:"nextAttemptAt"`
+	LastError      string       `json:"lastError,omitempty" dynamodbav:"lastError"`
+	CreatedAt      int64        `json:"createdAt" dynamodbav:"createdAt"`
+	ExpiresAt      *int64       `json:"expiresAt,omitempty" dynamodbav:"expiresAt,omitempty"`
+	SentAt         *int64       `json:"sentAt,omitempty" dynamodbav:"sentAt,omitempty"`
+}
@@ -0,0 +1,27 @@
+package model
+
+// Luna4ScimToken is a bearer token a tenant's directory uses to call the SCIM API
+type Luna4ScimToken struct {
+	ID         string `json:"id" dynamodbav:"id"`
+	Tenant     string `json:"tenant" dynamodbav:"tenant"`
+	TokenHash  string `json:"-" dynamodbav:"tokenHash"`
+	CreatedAt  int64  `json:"createdAt" dynamodbav:"createdAt"`
+	LastUsedAt *int64 `json:"lastUsedAt,omitempty" dynamodbav:"lastUsedAt,omitempty"`
+	RevokedAt  *int64 `json:"revokedAt,omitempty" dynamodbav:"revokedAt,omitempty"`
+}
+
+// Luna4ScimUser links a user to the SCIM tenant that provisioned them. A tenant only
+// sees and changes the users it provisioned.
+type Luna4ScimUser struct {
+	UserID     string `json:"userId" dynamodbav:"id"`
+	Tenant     string `json:"tenant" dynamodbav:"tenant"`
+	ExternalID string `json:"externalId,omitempty" dynamodbav:"externalId"`
+	CreatedAt  int64  `json:"createdAt" dynamodbav:"createdAt"`
+}
+
+// Luna4ScimAccount is a provisioned user with their service grants and the ID the
+// tenant's directory knows them by
+type Luna4ScimAccount struct {
+	Luna4UserWithServices
+	ExternalID string `json:"externalId,omitempty"`
+}
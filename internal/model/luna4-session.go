@@ -0,0 +1,16 @@
+package model
+
+type Luna4Session struct {
+	ID        string `json:"id" dynamodbav:"id"`
+	UserID    string `json:"userId" dynamodbav:"userId"`
+	UserAgent string `json:"userAgent,omitempty" dynamodbav:"userAgent,omitempty"`
+	IPAddress string `json:"ipAddress,omitempty" dynamodbav:"ipAddress,omitempty"`
+	CreatedAt int64  `json:"createdAt" dynamodbav:"createdAt"`
+	ExpiresAt int64  `json:"expiresAt" dynamodbav:"expiresAt"`
+	RevokedAt *int64 `json:"revokedAt,omitempty" dynamodbav:"revokedAt,omitempty"`
+}
+
+// IsActive reports whether the session can still be used at the given time in milliseconds
+func (s *Luna4Session) IsActive(now int64) bool {
+	return s.RevokedAt == nil && now < s.ExpiresAt
+}
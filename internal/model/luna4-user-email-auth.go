@@ -1,9 +1,9 @@
 package model
 
 type Luna4EmailAuth struct {
-	ID        string `json:"id"`
-	UserID    string `json:"userId"`
-	Token     string `json:"token"`
-	SentAt    int64  `json:"sentAt"`
-	Completed bool   `json:"completed"`
+	ID        string `json:"id" dynamodbav:"id"`
+	UserID    string `json:"userId" dynamodbav:"userId"`
+	Token     string `json:"token" dynamodbav:"token"`
+	SentAt    int64  `json:"sentAt" dynamodbav:"sentAt"`
+	Completed bool   `json:"completed" dynamodbav:"completed"`
 }
@@ -0,0 +1,28 @@
+package model
+
+// Luna4LoginRecord is a single successful sign in
+type Luna4LoginRecord struct {
+	Method string `json:"method"`
+	At     int64  `json:"at"`
+}
+
+// Luna4EmailAuthRecord is an email auth request without its token hash
+type Luna4EmailAuthRecord struct {
+	ID        string `json:"id"`
+	SentAt    int64  `json:"sentAt"`
+	Completed bool   `json:"completed"`
+}
+
+// Luna4UserExport is everything airlock holds about a single user
+type Luna4UserExport struct {
+	ExportedAt   int64                     `json:"exportedAt"`
+	User         *Luna4User                `json:"user"`
+	Services     []Luna4UserService        `json:"services"`
+	LoginHistory []Luna4LoginRecord        `json:"loginHistory"`
+	EmailAuths   []Luna4EmailAuthRecord    `json:"emailAuths"`
+	Sessions     []Luna4Session            `json:"sessions"`
+	EmailChanges []Luna4EmailChange        `json:"emailChanges"`
+	Passkeys     []Luna4WebAuthnCredential `json:"passkeys"`
+	AuditLog     []Luna4AuditLog           `json:"auditLog"`
+	Delivery     *Luna4DeliveryStatus      `json:"delivery"`
+}
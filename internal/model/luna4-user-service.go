@@ -14,9 +14,9 @@ const (
 )
 
 type Luna4UserService struct {
-	ID         string                `json:"id"`
-	UserID     string                `json:"userId"`
-	Service    Luna4Service          `json:"service"`
-	Permission UserServicePermission `json:"permission"`
-	ExpiresAt  *int64                `json:"expiresAt,omitempty"`
+	ID         string                `json:"id" dynamodbav:"id"`
+	UserID     string                `json:"userId" dynamodbav:"userId"`
+	Service    Luna4Service          `json:"service" dynamodbav:"service"`
+	Permission UserServicePermission `json:"permission" dynamodbav:"permission"`
+	ExpiresAt  *int64                `json:"expiresAt,omitempty" dynamodbav:"expiresAt,omitempty"`
 }
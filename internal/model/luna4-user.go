@@ -5,18 +5,71 @@ import "time"
 type UserStatus string
 
 const (
+	UserStatusPending   UserStatus = "PENDING"
 	UserStatusActive    UserStatus = "ACTIVE"
 	UserStatusSuspended UserStatus = "SUSPENDED"
+	UserStatusLocked    UserStatus = "LOCKED"
+	UserStatusDeleted   UserStatus = "DELETED"
 )
 
+// userStatusTransitions lists the statuses each status may move to
+var userStatusTransitions = map[UserStatus][]UserStatus{
+	UserStatusPending:   {UserStatusActive, UserStatusSuspended},
+	UserStatusActive:    {UserStatusSuspended, UserStatusLocked},
+	UserStatusSuspended: {UserStatusActive, UserStatusDeleted},
+	UserStatusLocked:    {UserStatusActive, UserStatusSuspended},
+	UserStatusDeleted:   {UserStatusSuspended},
+}
+
+// IsValid reports whether the status is one of the known lifecycle statuses
+func (s UserStatus) IsValid() bool {
+	_, ok := userStatusTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether a user in this status may be moved to the target status
+func (s UserStatus) CanTransitionTo(target UserStatus) bool {
+	for _, allowed := range userStatusTransitions[s] {
+		if allowed == target {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAuthenticate reports whether a user in this status may sign in
+func (s UserStatus) CanAuthenticate() bool {
+	return s == UserStatusPending || s == UserStatusActive
+}
+
 type Luna4User struct {
-	ID        string     `json:"id"`
-	Email     string     `json:"email"`
-	Status    UserStatus `json:"status"`
-	CreatedAt int64      `json:"createdAt"`
-	UpdatedAt int64      `json:"updatedAt"`
+	ID              string          `json:"id" dynamodbav:"id"`
+	Email           string          `json:"email" dynamodbav:"email"`
+	Name            string          `json:"name,omitempty" dynamodbav:"name"`
+	Organization    string          `json:"organization,omitempty" dynamodbav:"organization"`
+	Notes           string          `json:"notes,omitempty" dynamodbav:"notes"`
+	Status          UserStatus      `json:"status" dynamodbav:"status"`
+	StatusReason    string          `json:"statusReason,omitempty" dynamodbav:"statusReason"`
+	StatusChangedAt *int64          `json:"statusChangedAt,omitempty" dynamodbav:"statusChangedAt,omitempty"`
+	Preferences     UserPreferences `json:"preferences" dynamodbav:"preferences"`
+	CreatedAt       int64           `json:"createdAt" dynamodbav:"createdAt"`
+	UpdatedAt       int64           `json:"updatedAt" dynamodbav:"updatedAt"`
 }
 
 func (u *Luna4User) SetUpdatedAt() {
 	u.UpdatedAt = time.Now().UnixMilli()
 }
+
+// Luna4UserActivity pairs a user with the time of their last successful login
+// and the most recent dormancy warning they were sent
+type Luna4UserActivity struct {
+	Luna4User
+	LastActiveAt     int64  `json:"lastActiveAt"`
+	DormancyWarnedAt *int64 `json:"dormancyWarnedAt,omitempty"`
+}
+
+// Luna4UserWithServices is a user together with their service grants
+type Luna4UserWithServices struct {
+	Luna4User
+	Services []Luna4UserService `json:"services"`
+}
@@ -0,0 +1,71 @@
+package model
+
+import "testing"
+
+func TestUserStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to UserStatus
+		want     bool
+	}{
+		{UserStatusPending, UserStatusActive, true},
+		{UserStatusPending, UserStatusSuspended, true},
+		{UserStatusPending, UserStatusLocked, false},
+		{UserStatusPending, UserStatusDeleted, false},
+		{UserStatusActive, UserStatusSuspended, true},
+		{UserStatusActive, UserStatusLocked, true},
+		{UserStatusActive, UserStatusDeleted, false},
+		{UserStatusActive, UserStatusPending, false},
+		{UserStatusActive, UserStatusActive, false},
+		{UserStatusSuspended, UserStatusActive, true},
+		{UserStatusSuspended, UserStatusDeleted, true},
+		{UserStatusSuspended, UserStatusLocked, false},
+		{UserStatusLocked, UserStatusActive, true},
+		{UserStatusLocked, UserStatusSuspended, true},
+		{UserStatusLocked, UserStatusDeleted, false},
+		{UserStatusDeleted, UserStatusSuspended, true},
+		{UserStatusDeleted, UserStatusActive, false},
+		{UserStatusDeleted, UserStatusPending, false},
+		{UserStatus("UNKNOWN"), UserStatusActive, false},
+		{UserStatusActive, UserStatus("UNKNOWN"), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestUserStatusCanAuthenticate(t *testing.T) {
+	tests := []struct {
+		status UserStatus
+		want   bool
+	}{
+		{UserStatusPending, true},
+		{UserStatusActive, true},
+		{UserStatusSuspended, false},
+		{UserStatusLocked, false},
+		{UserStatusDeleted, false},
+		{UserStatus(""), false},
+		{UserStatus("UNKNOWN"), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.status.CanAuthenticate(); got != tt.want {
+			t.Errorf("%q.CanAuthenticate() = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestUserStatusIsValid(t *testing.T) {
+	for _, status := range []UserStatus{UserStatusPending, UserStatusActive, UserStatusSuspended, UserStatusLocked, UserStatusDeleted} {
+		if !status.IsValid() {
+			t.Errorf("%s is not valid", status)
+		}
+	}
+	for _, status := range []UserStatus{"", "active", "UNKNOWN"} {
+		if status.IsValid() {
+			t.Errorf("%q is valid", status)
+		}
+	}
+}
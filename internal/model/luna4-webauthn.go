@@ -0,0 +1,37 @@
+package model
+
+// Luna4WebAuthnCredential is a passkey a user registered to sign in without email
+type Luna4WebAuthnCredential struct {
+	// ID is the credential ID the authenticator chose, base64url encoded
+	ID             string   `json:"id" dynamodbav:"id"`
+	UserID         string   `json:"userId" dynamodbav:"userId"`
+	Name           string   `json:"name" dynamodbav:"name"`
+	PublicKey      []byte   `json:"-" dynamodbav:"publicKey"`
+	Algorithm      int      `json:"algorithm" dynamodbav:"algorithm"`
+	SignCount      uint32   `json:"signCount" dynamodbav:"signCount"`
+	AAGUID         string   `json:"aaguid" dynamodbav:"aaguid"`
+	Transports     []string `json:"transports,omitempty" dynamodbav:"transports,omitempty"`
+	BackupEligible bool     `json:"backupEligible" dynamodbav:"backupEligible"`
+	BackedUp       bool     `json:"backedUp" dynamodbav:"backedUp"`
+	CreatedAt      int64    `json:"createdAt" dynamodbav:"createdAt"`
+	LastUsedAt     *int64   `json:"lastUsedAt,omitempty" dynamodbav:"lastUsedAt,omitempty"`
+}
+
+// WebAuthnCeremony is the kind of WebAuthn ceremony a challenge was issued for
+type WebAuthnCeremony string
+
+const (
+	WebAuthnCeremonyRegistration   WebAuthnCeremony = "REGISTRATION"
+	WebAuthnCeremonyAuthentication WebAuthnCeremony = "AUTHENTICATION"
+)
+
+// Luna4WebAuthnChallenge is an outstanding challenge, used at most once. Registration
+// challenges belong to the signed-in user; authentication challenges to nobody yet.
+type Luna4WebAuthnChallenge struct {
+	// ID is the challenge itself, base64url encoded
+	ID        string           `json:"id" dynamodbav:"id"`
+	UserID    string           `json:"userId,omitempty" dynamodbav:"userId,omitempty"`
+	Ceremony  WebAuthnCeremony `json:"ceremony" dynamodbav:"ceremony"`
+	CreatedAt int64            `json:"createdAt" dynamodbav:"createdAt"`
+	ExpiresAt int64            `json:"expiresAt" dynamodbav:"expiresAt"`
+}
@@ -1,38 +1,49 @@
 package model
 
-import "time"
+import "slices"
 
-type EmailAuth struct {
-	Token     string `json:"token" dynamodbav:"token"`
-	SentAt    int64  `json:"sentAt" dynamodbav:"sentAt"`
-	Completed bool   `json:"completed" dynamodbav:"completed"`
-}
+// NotificationType is a kind of security notice airlock emails a user about
+type NotificationType string
 
-type UserPreferences struct {
-	// TODO: add preference schema
+const (
+	NotificationNewDeviceLogin       NotificationType = "NEW_DEVICE_LOGIN"
+	NotificationSessionsRevoked      NotificationType = "SESSIONS_REVOKED"
+	NotificationEmailChanged         NotificationType = "EMAIL_CHANGED"
+	NotificationServiceAccessChanged NotificationType = "SERVICE_ACCESS_CHANGED"
+	NotificationAccountSuspended     NotificationType = "ACCOUNT_SUSPENDED"
+)
+
+// NotificationTypes lists every notification type
+var NotificationTypes = []NotificationType{
+	NotificationNewDeviceLogin,
+	NotificationSessionsRevoked,
+	NotificationEmailChanged,
+	NotificationServiceAccessChanged,
+	NotificationAccountSuspended,
 }
 
-type User struct {
-	ID          string           `json:"id" dynamodbav:"id"`
-	Email       string           `json:"email" dynamodbav:"email"`
-	Status      UserStatus       `json:"status" dynamodbav:"status"`
-	Preferences *UserPreferences `json:"preferences,omitempty" dynamodbav:"preferences,omitempty"`
-	CreatedAt   int64            `json:"createdAt" dynamodbav:"createdAt"`
-	UpdatedAt   int64            `json:"updatedAt" dynamodbav:"updatedAt"`
-	LastLoginAt *int64           `json:"lastLoginAt,omitempty" dynamodbav:"lastLoginAt,omitempty"`
-	EmailAuth   *EmailAuth       `json:"emailAuth,omitempty" dynamodbav:"emailAuth,omitempty"`
+// IsValid reports whether the notification type is known
+func (t NotificationType) IsValid() bool {
+	return slices.Contains(NotificationTypes, t)
 }
 
-func (u *User) SetCreatedAt() {
-	u.CreatedAt = time.Now().UnixMilli()
-	u.UpdatedAt = u.CreatedAt
+// IsCritical reports whether the notice is always sent. A user cannot opt out of being
+// told their address changed or their account was suspended.
+func (t NotificationType) IsCritical() bool {
+	return t == NotificationEmailChanged || t == NotificationAccountSuspended
 }
 
-func (u *User) SetUpdatedAt() {
-	u.UpdatedAt = time.Now().UnixMilli()
+// UserPreferences holds the settings a user chooses for themselves
+type UserPreferences struct {
+	// Language is the locale emails and pages are shown in, such as "ko". Empty means
+	// the browser's Accept-Language decides.
+	Language string `json:"language,omitempty" dynamodbav:"language,omitempty"`
+
+	// NotificationOptOuts are the non-critical security notices the user does not want
+	NotificationOptOuts []NotificationType `json:"notificationOptOuts,omitempty" dynamodbav:"notificationOptOuts,omitempty"`
 }
 
-func (u *User) SetLastLoginAt() {
-	now := time.Now().UnixMilli()
-	u.LastLoginAt = &now
+// Notifies reports whether the user wants notices of the given type
+func (p UserPreferences) Notifies(t NotificationType) bool {
+	return t.IsCritical() || !slices.Contains(p.NotificationOptOuts, t)
 }
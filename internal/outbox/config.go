@@ -0,0 +1,38 @@
+package outbox
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config controls how many workers deliver queued email, how often they poll and how
+// failed deliveries are retried
+type Config struct {
+	Workers      int
+	PollInterval time.Duration
+	MaxAttempts  int
+	Backoff      time.Duration
+	MaxBackoff   time.Duration
+	Retention    time.Duration
+}
+
+// GetConfig reads the outbox settings from the environment
+func GetConfig() Config {
+	return Config{
+		Workers:      getEnvInt("EMAIL_OUTBOX_WORKERS", 4),                                        // Default 4 workers
+		PollInterval: time.Duration(getEnvInt("EMAIL_OUTBOX_POLL_INTERVAL", 1)) * time.Second,     // Default every second
+		MaxAttempts:  getEnvInt("EMAIL_OUTBOX_MAX_ATTEMPTS", 8),                                   // Default 8 attempts
+		Backoff:      time.Duration(getEnvInt("EMAIL_OUTBOX_BACKOFF", 30)) * time.Second,          // Default 30 seconds, doubled per attempt
+		MaxBackoff:   time.Duration(getEnvInt("EMAIL_OUTBOX_MAX_BACKOFF", 3600)) * time.Second,    // Default 1 hour
+		Retention:    time.Duration(getEnvInt("EMAIL_OUTBOX_RETENTION_DAYS", 7)) * 24 * time.Hour, // Default 7 days
+	}
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,212 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/mailer"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/service"
+)
+
+const (
+	// claimLease is how long a claimed email is hidden from other workers. An email whose
+	// worker died mid-delivery is retried once the lease runs out.
+	claimLease = 5 * time.Minute
+
+	sendTimeout   = 30 * time.Second
+	purgeInterval = time.Hour
+)
+
+// Worker delivers queued email with a pool of senders, retrying failures with exponential
+// backoff and dead-lettering emails that run out of attempts, fail permanently or expire
+type Worker struct {
+	accountService *service.AccountService
+	mailer         mailer.Mailer
+	config         Config
+}
+
+func NewWorker(accountService *service.AccountService, m mailer.Mailer, config Config) *Worker {
+	return &Worker{
+		accountService: accountService,
+		mailer:         m,
+		config:         config,
+	}
+}
+
+// Start polls the outbox every interval and hands due emails to the workers until the
+// context is cancelled
+func (w *Worker) Start(ctx context.Context) {
+	if w.config.PollInterval <= 0 || w.config.Workers <= 0 {
+		log.Printf("OutboxWorker: Delivery disabled, queued email will not be sent")
+		return
+	}
+
+	log.Printf("OutboxWorker: Delivering queued email with %d workers", w.config.Workers)
+	jobs := make(chan model.Luna4OutboxEmail)
+
+	var wg sync.WaitGroup
+	for range w.config.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for email := range jobs {
+				w.deliver(ctx, &email)
+			}
+		}()
+	}
+
+	go func() {
+		defer func() {
+			close(jobs)
+			wg.Wait()
+		}()
+
+		ticker := time.NewTicker(w.config.PollInterval)
+		defer ticker.Stop()
+
+		lastPurge := time.Time{}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.dispatch(ctx, jobs)
+				if time.Since(lastPurge) >= purgeInterval {
+					w.purge(ctx)
+					lastPurge = time.Now()
+				}
+			}
+		}
+	}()
+}
+
+// dispatch claims the due emails and queues them for the workers
+func (w *Worker) dispatch(ctx context.Context, jobs chan<- model.Luna4OutboxEmail) {
+	now := time.Now().UnixMilli()
+	emails, err := w.accountService.GetDueOutboxEmails(ctx, now, w.config.Workers*4)
+	if err != nil {
+		log.Printf("OutboxWorker: Failed to query due emails: %v", err)
+		return
+	}
+
+	for _, email := range emails {
+		claimed, err := w.accountService.ClaimOutboxEmail(ctx, email.ID, now, now+claimLease.Milliseconds())
+		if err != nil {
+			log.Printf("OutboxWorker: Failed to claim email %s: %v", email.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		select {
+		case jobs <- email:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver makes one attempt at sending the email and records the outcome
+func (w *Worker) deliver(ctx context.Context, email *model.Luna4OutboxEmail) {
+	now := time.Now()
+	if email.ExpiresAt != nil && *email.ExpiresAt <= now.UnixMilli() {
+		log.Printf("OutboxWorker: Email %s expired before delivery", email.ID)
+		deadLetter(email, "expired before delivery")
+		w.save(ctx, email)
+		return
+	}
+
+	suppression, err := w.accountService.IsEmailSuppressed(ctx, email.Recipient)
+	if err != nil {
+		log.Printf("OutboxWorker: Failed to check suppression of email %s: %v", email.ID, err)
+	}
+	if suppression != nil {
+		log.Printf("OutboxWorker: Not sending email %s, recipient is suppressed after %s", email.ID, suppression.Reason)
+		deadLetter(email, "recipient is suppressed: "+string(suppression.Reason))
+		email.HTML = ""
+		email.Text = ""
+		w.save(ctx, email)
+		return
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	err = w.mailer.Send(sendCtx, &mailer.Message{
+		From:        email.Sender,
+		To:          email.Recipient,
+		Subject:     email.Subject,
+		HTML:        email.HTML,
+		Text:        email.Text,
+		Unsubscribe: email.Unsubscribe,
+	})
+	cancel()
+	email.Attempts++
+
+	switch {
+	case err == nil:
+		sentAt := time.Now().UnixMilli()
+		email.Status = model.OutboxStatusSent
+		email.SentAt = &sentAt
+		email.LastError = ""
+		email.HTML = ""
+		email.Text = ""
+	case mailer.IsPermanent(err) || email.Attempts >= w.config.MaxAttempts:
+		log.Printf("OutboxWorker: Dead-lettering email %s after %d attempts: %v", email.ID, email.Attempts, err)
+		deadLetter(email, err.Error())
+	default:
+		delay := w.backoff(email.Attempts)
+		log.Printf("OutboxWorker: Attempt %d for email %s failed, retrying in %s: %v", email.Attempts, email.ID, delay, err)
+		email.NextAttemptAt = now.Add(delay).UnixMilli()
+		email.LastError = err.Error()
+	}
+
+	w.save(ctx, email)
+}
+
+// deadLetter stops delivery of the email. An email with an expiry carries a sign-in or
+// confirmation link, so its body is cleared rather than kept for a retry.
+func deadLetter(email *model.Luna4OutboxEmail, reason string) {
+	email.Status = model.OutboxStatusDead
+	email.LastError = reason
+	if email.ExpiresAt != nil {
+		email.HTML = ""
+		email.Text = ""
+	}
+}
+
+func (w *Worker) save(ctx context.Context, email *model.Luna4OutboxEmail) {
+	if err := w.accountService.UpdateOutboxEmail(ctx, email); err != nil {
+		log.Printf("OutboxWorker: Failed to record delivery of email %s: %v", email.ID, err)
+	}
+}
+
+// backoff doubles the delay with every failed attempt up to the maximum, with jitter so
+// emails that failed together are not retried together
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := w.config.Backoff
+	for i := 1; i < attempts && delay < w.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, w.config.MaxBackoff)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// purge removes sent emails past the retention period
+func (w *Worker) purge(ctx context.Context) {
+	if w.config.Retention <= 0 {
+		return
+	}
+
+	deleted, err := w.accountService.DeleteSentOutboxEmails(ctx, time.Now().Add(-w.config.Retention).UnixMilli())
+	if err != nil {
+		log.Printf("OutboxWorker: Failed to purge sent emails: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("OutboxWorker: Purged %d sent emails", deleted)
+	}
+}
@@ -0,0 +1,293 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/mailer"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/service"
+	"github.com/luna4dev/airlock/internal/webauthn"
+)
+
+var testConfig = Config{
+	Workers:     2,
+	MaxAttempts: 3,
+	Backoff:     time.Minute,
+	MaxBackoff:  10 * time.Minute,
+}
+
+// fakeMailer records the messages it is asked to send and fails with err when set
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []*mailer.Message
+	err  error
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg *mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return m.err
+}
+
+// newTestWorker returns a worker on a migrated SQLite database in a temporary directory
+func newTestWorker(t *testing.T, m mailer.Mailer) *Worker {
+	t.Helper()
+	config := service.GetSQLiteConfig()
+	config.Path = filepath.Join(t.TempDir(), "airlock.db")
+	store, err := service.NewSQLiteService(config, os.DirFS("../.."))
+	if err != nil {
+		t.Fatalf("failed to open SQLite store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := service.Migrate(context.Background(), store); err != nil {
+		t.Fatalf("failed to migrate SQLite store: %v", err)
+	}
+
+	return NewWorker(service.NewAccountService(store, nil, webauthn.Config{}), m, testConfig)
+}
+
+// queueTestEmail queues an email to the recipient, with a link that expires in an hour
+// when withLink is set
+func queueTestEmail(t *testing.T, w *Worker, recipient string, withLink bool) *model.Luna4OutboxEmail {
+	t.Helper()
+	var expiresAt *int64
+	if withLink {
+		expiry := time.Now().Add(time.Hour).UnixMilli()
+		expiresAt = &expiry
+	}
+
+	msg := &mailer.Message{
+		From:    "airlock@example.com",
+		To:      recipient,
+		Subject: "Test",
+		HTML:    "<p>https://example.com/auth?token=secret</p>",
+		Text:    "https://example.com/auth?token=secret",
+	}
+	email, err := w.accountService.QueueEmail(context.Background(), "test:"+uuid.New().String(), msg, expiresAt)
+	if err != nil {
+		t.Fatalf("failed to queue email: %v", err)
+	}
+	return email
+}
+
+func getTestEmail(t *testing.T, w *Worker, emailID string) *model.Luna4OutboxEmail {
+	t.Helper()
+	email, err := w.accountService.GetOutboxEmail(context.Background(), emailID)
+	if err != nil || email == nil {
+		t.Fatalf("failed to read email %s: %v", emailID, err)
+	}
+	return email
+}
+
+func TestDispatchClaimsEachEmailOnce(t *testing.T) {
+	w := newTestWorker(t, &fakeMailer{})
+	ctx := context.Background()
+	for range 5 {
+		queueTestEmail(t, w, "user@example.com", false)
+	}
+
+	// Two instances polling the same database at once
+	jobs := make(chan model.Luna4OutboxEmail, 20)
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.dispatch(ctx, jobs)
+		}()
+	}
+	wg.Wait()
+	close(jobs)
+
+	claimed := map[string]int{}
+	for email := range jobs {
+		claimed[email.ID]++
+	}
+	if len(claimed) != 5 {
+		t.Errorf("%d emails claimed, want 5", len(claimed))
+	}
+	for id, count := range claimed {
+		if count != 1 {
+			t.Errorf("email %s claimed %d times", id, count)
+		}
+
+		// The lease hides the email until it runs out
+		email := getTestEmail(t, w, id)
+		if lease := time.Until(time.UnixMilli(email.NextAttemptAt)); lease < claimLease-time.Minute || lease > claimLease {
+			t.Errorf("email %s is leased for %s, want %s", id, lease, claimLease)
+		}
+	}
+
+	again := make(chan model.Luna4OutboxEmail, 20)
+	w.dispatch(ctx, again)
+	if len(again) != 0 {
+		t.Errorf("%d leased emails claimed again", len(again))
+	}
+}
+
+func TestDeliverSendsAndClearsBody(t *testing.T) {
+	m := &fakeMailer{}
+	w := newTestWorker(t, m)
+	queued := queueTestEmail(t, w, "user@example.com", true)
+	text := queued.Text
+
+	w.deliver(context.Background(), queued)
+
+	if len(m.sent) != 1 || m.sent[0].To != "user@example.com" || m.sent[0].Text != text {
+		t.Fatalf("sent %v, want the queued message", m.sent)
+	}
+	email := getTestEmail(t, w, queued.ID)
+	if email.Status != model.OutboxStatusSent || email.SentAt == nil || email.Attempts != 1 {
+		t.Errorf("email is %s after %d attempts, want SENT after 1", email.Status, email.Attempts)
+	}
+	if email.HTML != "" || email.Text != "" {
+		t.Errorf("sent email kept its body")
+	}
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	m := &fakeMailer{err: errors.New("connection refused")}
+	w := newTestWorker(t, m)
+	queued := queueTestEmail(t, w, "user@example.com", true)
+
+	before := time.Now()
+	w.deliver(context.Background(), queued)
+
+	email := getTestEmail(t, w, queued.ID)
+	if email.Status != model.OutboxStatusPending || email.Attempts != 1 || email.LastError != "connection refused" {
+		t.Fatalf("email is %s after %d attempts (%s), want PENDING after 1", email.Status, email.Attempts, email.LastError)
+	}
+	delay := time.UnixMilli(email.NextAttemptAt).Sub(before)
+	if delay < testConfig.Backoff/2-time.Second || delay > testConfig.Backoff+time.Second {
+		t.Errorf("retry in %s, want between %s and %s", delay, testConfig.Backoff/2, testConfig.Backoff)
+	}
+	if email.HTML == "" || email.Text == "" {
+		t.Errorf("body was cleared before the email was sent")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	w := &Worker{config: testConfig}
+	for attempts, want := range map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		3:  4 * time.Minute,
+		4:  8 * time.Minute,
+		5:  10 * time.Minute,
+		20: 10 * time.Minute,
+	} {
+		for range 50 {
+			if delay := w.backoff(attempts); delay < want/2 || delay > want {
+				t.Fatalf("backoff after %d attempts is %s, want between %s and %s", attempts, delay, want/2, want)
+			}
+		}
+	}
+}
+
+func TestDeliverDeadLettersAfterMaxAttempts(t *testing.T) {
+	m := &fakeMailer{err: errors.New("connection refused")}
+	w := newTestWorker(t, m)
+	ctx := context.Background()
+	link := queueTestEmail(t, w, "user@example.com", true)
+	notice := queueTestEmail(t, w, "user@example.com", false)
+
+	for _, queued := range []*model.Luna4OutboxEmail{link, notice} {
+		for attempt := 1; attempt <= testConfig.MaxAttempts; attempt++ {
+			email := getTestEmail(t, w, queued.ID)
+			if email.Status != model.OutboxStatusPending {
+				t.Fatalf("email is %s before attempt %d", email.Status, attempt)
+			}
+			w.deliver(ctx, email)
+		}
+		if email := getTestEmail(t, w, queued.ID); email.Status != model.OutboxStatusDead || email.Attempts != testConfig.MaxAttempts {
+			t.Errorf("email is %s after %d attempts, want DEAD after %d", email.Status, email.Attempts, testConfig.MaxAttempts)
+		}
+	}
+
+	// The sign-in link does not outlive delivery, an email without a link can be retried
+	if email := getTestEmail(t, w, link.ID); email.HTML != "" || email.Text != "" {
+		t.Errorf("dead-lettered email kept its link")
+	}
+	if _, err := w.accountService.RetryOutboxEmail(ctx, link.ID); !errors.Is(err, service.ErrOutboxEmailCleared) {
+		t.Errorf("retrying the cleared email returned %v, want ErrOutboxEmailCleared", err)
+	}
+
+	retried, err := w.accountService.RetryOutboxEmail(ctx, notice.ID)
+	if err != nil {
+		t.Fatalf("retrying the email without a link failed: %v", err)
+	}
+	if retried.Status != model.OutboxStatusPending || retried.Attempts != 0 || retried.Text != notice.Text {
+		t.Errorf("retried email is %s after %d attempts, want PENDING with its body", retried.Status, retried.Attempts)
+	}
+}
+
+func TestDeliverDeadLettersPermanentFailure(t *testing.T) {
+	m := &fakeMailer{err: &mailer.PermanentError{Err: errors.New("550 no such user")}}
+	w := newTestWorker(t, m)
+	queued := queueTestEmail(t, w, "user@example.com", true)
+
+	w.deliver(context.Background(), queued)
+
+	email := getTestEmail(t, w, queued.ID)
+	if email.Status != model.OutboxStatusDead || email.Attempts != 1 || email.LastError != "550 no such user" {
+		t.Errorf("email is %s after %d attempts (%s), want DEAD after 1", email.Status, email.Attempts, email.LastError)
+	}
+	if email.HTML != "" || email.Text != "" {
+		t.Errorf("dead-lettered email kept its link")
+	}
+}
+
+func TestDeliverDeadLettersExpiredEmail(t *testing.T) {
+	m := &fakeMailer{}
+	w := newTestWorker(t, m)
+	queued := queueTestEmail(t, w, "user@example.com", true)
+	expired := time.Now().Add(-time.Second).UnixMilli()
+	queued.ExpiresAt = &expired
+
+	w.deliver(context.Background(), queued)
+
+	if len(m.sent) != 0 {
+		t.Errorf("expired email was sent")
+	}
+	email := getTestEmail(t, w, queued.ID)
+	if email.Status != model.OutboxStatusDead || email.HTML != "" || email.Text != "" {
+		t.Errorf("expired email is %s with its body kept, want DEAD and cleared", email.Status)
+	}
+}
+
+func TestDeliverSkipsSuppressedRecipient(t *testing.T) {
+	m := &fakeMailer{}
+	w := newTestWorker(t, m)
+	ctx := context.Background()
+	queued := queueTestEmail(t, w, "Bounced@Example.com", false)
+
+	err := w.accountService.CreateEmailSuppression(ctx, &model.Luna4EmailSuppression{
+		Email:     "bounced@example.com",
+		Reason:    model.SuppressionReasonHardBounce,
+		CreatedAt: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		t.Fatalf("failed to suppress address: %v", err)
+	}
+
+	w.deliver(ctx, queued)
+
+	if len(m.sent) != 0 {
+		t.Errorf("email to a suppressed address was sent")
+	}
+	email := getTestEmail(t, w, queued.ID)
+	if email.Status != model.OutboxStatusDead || email.Attempts != 0 {
+		t.Errorf("email is %s after %d attempts, want DEAD without an attempt", email.Status, email.Attempts)
+	}
+	if email.LastError != "recipient is suppressed: HARD_BOUNCE" || email.HTML != "" || email.Text != "" {
+		t.Errorf("email recorded %q and kept its body", email.LastError)
+	}
+}
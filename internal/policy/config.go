@@ -0,0 +1,44 @@
+package policy
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetEngineInterval returns how often the policy engine runs, from POLICY_ENGINE_INTERVAL in seconds
+func GetEngineInterval() time.Duration {
+	return time.Duration(getEnvInt("POLICY_ENGINE_INTERVAL", 3600)) * time.Second // Default 1 hour
+}
+
+// GetDormantAccountThreshold returns the inactivity after which accounts are suspended,
+// from DORMANT_ACCOUNT_THRESHOLD_DAYS
+func GetDormantAccountThreshold() time.Duration {
+	return time.Duration(getEnvInt("DORMANT_ACCOUNT_THRESHOLD_DAYS", 180)) * 24 * time.Hour // Default 180 days
+}
+
+// GetDormantAccountWarning returns how long before suspension users are warned,
+// from DORMANT_ACCOUNT_WARNING_DAYS
+func GetDormantAccountWarning() time.Duration {
+	return time.Duration(getEnvInt("DORMANT_ACCOUNT_WARNING_DAYS", 14)) * 24 * time.Hour // Default 14 days
+}
+
+// GetUserDeletionRetention returns how long soft deleted users can be restored before
+// they are purged, from USER_DELETION_RETENTION_DAYS
+func GetUserDeletionRetention() time.Duration {
+	return time.Duration(getEnvInt("USER_DELETION_RETENTION_DAYS", 30)) * 24 * time.Hour // Default 30 days
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
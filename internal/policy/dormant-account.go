@@ -0,0 +1,103 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/service"
+)
+
+const DormantAccountPolicyName = "dormant-account"
+
+const (
+	ActionDormancyWarn    = "WARN"
+	ActionDormancySuspend = "SUSPEND"
+)
+
+// DormantAccountPolicy warns users who have not signed in for a while and
+// suspends them once the warning period has passed without a login
+type DormantAccountPolicy struct {
+	accountService *service.AccountService
+	threshold      time.Duration
+	warning        time.Duration
+}
+
+// NewDormantAccountPolicy creates the policy; threshold is the inactivity after which
+// a user is suspended and warning is how long before that they are emailed
+func NewDormantAccountPolicy(accountService *service.AccountService, threshold, warning time.Duration) *DormantAccountPolicy {
+	if warning > threshold {
+		warning = threshold
+	}
+
+	return &DormantAccountPolicy{
+		accountService: accountService,
+		threshold:      threshold,
+		warning:        warning,
+	}
+}
+
+func (p *DormantAccountPolicy) Name() string {
+	return DormantAccountPolicyName
+}
+
+func (p *DormantAccountPolicy) Run(ctx context.Context, dryRun bool) (*Report, error) {
+	report := newReport(p.Name(), dryRun)
+	now := time.Now()
+
+	// Everyone past the warning point is a candidate for either a warning or a suspension
+	warnBefore := now.Add(-(p.threshold - p.warning)).UnixMilli()
+	users, err := p.accountService.GetDormantUsers(ctx, warnBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		// A warning only counts if it was sent after the user's last login
+		warned := user.DormancyWarnedAt != nil && *user.DormancyWarnedAt > user.LastActiveAt
+		suspendAt := time.UnixMilli(user.LastActiveAt).Add(p.threshold)
+
+		action := Action{
+			UserID:       user.ID,
+			Email:        user.Email,
+			LastActiveAt: user.LastActiveAt,
+		}
+
+		switch {
+		case !warned:
+			// Always leave the full warning period between the email and the suspension
+			if earliest := now.Add(p.warning); suspendAt.Before(earliest) {
+				suspendAt = earliest
+			}
+			action.Action = ActionDormancyWarn
+			if !dryRun {
+				inactiveDays := int((p.threshold - p.warning).Hours() / 24)
+				if err := p.accountService.WarnDormantUser(ctx, &user, inactiveDays, suspendAt, p.actor()); err != nil {
+					log.Printf("DormantAccountPolicy: Failed to warn user %s: %v", user.ID, err)
+					action.Error = err.Error()
+				}
+			}
+		case !now.Before(suspendAt) && time.UnixMilli(*user.DormancyWarnedAt).Add(p.warning).Before(now):
+			action.Action = ActionDormancySuspend
+			if !dryRun {
+				reason := fmt.Sprintf("No login for %d days", int(now.Sub(time.UnixMilli(user.LastActiveAt)).Hours()/24))
+				if err := p.accountService.SuspendUser(ctx, user.ID, p.actor(), reason); err != nil {
+					log.Printf("DormantAccountPolicy: Failed to suspend user %s: %v", user.ID, err)
+					action.Error = err.Error()
+				}
+			}
+		default:
+			// Warned and still within the warning period
+			continue
+		}
+
+		report.add(action)
+	}
+
+	return report, nil
+}
+
+func (p *DormantAccountPolicy) actor() string {
+	return "policy:" + p.Name()
+}
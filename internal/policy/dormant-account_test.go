@@ -0,0 +1,277 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/brand"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/service"
+	"github.com/luna4dev/airlock/internal/webauthn"
+)
+
+// newTestAccountService returns an account service on a migrated SQLite database in a
+// temporary directory, rendering email from the repository's templates
+func newTestAccountService(t *testing.T) *service.AccountService {
+	t.Helper()
+	repoFS := os.DirFS("../..")
+	service.TemplateFS = repoFS
+
+	config := service.GetSQLiteConfig()
+	config.Path = filepath.Join(t.TempDir(), "airlock.db")
+	store, err := service.NewSQLiteService(config, repoFS)
+	if err != nil {
+		t.Fatalf("failed to open SQLite store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := service.Migrate(context.Background(), store); err != nil {
+		t.Fatalf("failed to migrate SQLite store: %v", err)
+	}
+
+	brands, err := brand.NewRegistry(brand.GetConfig())
+	if err != nil {
+		t.Fatalf("failed to create brand registry: %v", err)
+	}
+	emails, err := service.NewEmailService(brands)
+	if err != nil {
+		t.Fatalf("failed to load email templates: %v", err)
+	}
+	return service.NewAccountService(store, emails, webauthn.Config{})
+}
+
+// createTestUser stores a user with the status, created the given number of days ago
+func createTestUser(t *testing.T, accountService *service.AccountService, email string, status model.UserStatus, daysAgo int) *model.Luna4User {
+	t.Helper()
+	createdAt := time.Now().AddDate(0, 0, -daysAgo).UnixMilli()
+	user := &model.Luna4User{
+		ID:        uuid.New().String(),
+		Email:     email,
+		Status:    status,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	if err := accountService.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("failed to create user %s: %v", email, err)
+	}
+	return user
+}
+
+// completeTestLogin records a completed email login the given number of days ago
+func completeTestLogin(t *testing.T, accountService *service.AccountService, user *model.Luna4User, daysAgo int) {
+	t.Helper()
+	ctx := context.Background()
+	emailAuth := &model.Luna4EmailAuth{
+		ID:     uuid.New().String(),
+		UserID: user.ID,
+		Token:  uuid.New().String(),
+		SentAt: time.Now().AddDate(0, 0, -daysAgo).UnixMilli(),
+	}
+	if err := accountService.CreateEmailAuth(ctx, emailAuth); err != nil {
+		t.Fatalf("failed to create email auth: %v", err)
+	}
+	if err := accountService.MarkEmailAuthCompleted(ctx, emailAuth.ID); err != nil {
+		t.Fatalf("failed to complete email auth: %v", err)
+	}
+}
+
+// auditActions returns the actions recorded for the user, each prefixed with its actor
+func auditActions(t *testing.T, accountService *service.AccountService, userID string) []string {
+	t.Helper()
+	logs, err := accountService.GetUserAuditLogs(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("failed to read audit logs: %v", err)
+	}
+	var actions []string
+	for _, log := range logs {
+		actions = append(actions, log.Actor+" "+string(log.Action))
+	}
+	return actions
+}
+
+// reportedActions maps each user in the report to its action
+func reportedActions(t *testing.T, report *Report) map[string]string {
+	t.Helper()
+	if report.Count != len(report.Actions) {
+		t.Errorf("report counts %d of %d actions", report.Count, len(report.Actions))
+	}
+	actions := make(map[string]string)
+	for _, action := range report.Actions {
+		if action.Error != "" {
+			t.Errorf("%s for %s failed: %s", action.Action, action.Email, action.Error)
+		}
+		actions[action.Email] = action.Action
+	}
+	return actions
+}
+
+func TestDormantAccountPolicyWarns(t *testing.T) {
+	ctx := context.Background()
+	accountService := newTestAccountService(t)
+	p := NewDormantAccountPolicy(accountService, 90*24*time.Hour, 14*24*time.Hour)
+
+	dormant := createTestUser(t, accountService, "dormant@example.com", model.UserStatusActive, 100)
+	nearing := createTestUser(t, accountService, "nearing@example.com", model.UserStatusActive, 120)
+	completeTestLogin(t, accountService, nearing, 80)
+	recent := createTestUser(t, accountService, "recent@example.com", model.UserStatusActive, 120)
+	completeTestLogin(t, accountService, recent, 10)
+	createTestUser(t, accountService, "suspended@example.com", model.UserStatusSuspended, 200)
+
+	want := map[string]string{dormant.Email: ActionDormancyWarn, nearing.Email: ActionDormancyWarn}
+
+	// A dry run reports who would be warned without emailing or recording anything
+	report, err := p.Run(ctx, true)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if !report.DryRun || report.Policy != DormantAccountPolicyName {
+		t.Errorf("report is for %s with dry run %v", report.Policy, report.DryRun)
+	}
+	if got := reportedActions(t, report); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("dry run actions are %v, want %v", got, want)
+	}
+	for _, user := range []*model.Luna4User{dormant, nearing} {
+		if actions := auditActions(t, accountService, user.ID); len(actions) != 0 {
+			t.Errorf("dry run recorded %v for %s", actions, user.Email)
+		}
+	}
+	if queued, err := accountService.GetOutboxEmails(ctx, model.OutboxStatusPending, 10); err != nil || len(queued) != 0 {
+		t.Errorf("dry run queued %d emails (%v)", len(queued), err)
+	}
+
+	report, err = p.Run(ctx, false)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if got := reportedActions(t, report); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("run actions are %v, want %v", got, want)
+	}
+	queued, err := accountService.GetOutboxEmails(ctx, model.OutboxStatusPending, 10)
+	if err != nil {
+		t.Fatalf("failed to read outbox: %v", err)
+	}
+	recipients := make(map[string]bool)
+	for _, email := range queued {
+		recipients[email.Recipient] = true
+	}
+	if len(queued) != 2 || !recipients[dormant.Email] || !recipients[nearing.Email] {
+		t.Errorf("warnings were queued for %v, want the two dormant users", recipients)
+	}
+	for _, user := range []*model.Luna4User{dormant, nearing} {
+		stored, err := accountService.GetUserByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("failed to read user: %v", err)
+		}
+		if stored.Status != model.UserStatusActive {
+			t.Errorf("%s is %s after the warning, want active", user.Email, stored.Status)
+		}
+		actions := auditActions(t, accountService, user.ID)
+		if len(actions) != 1 || actions[0] != "policy:dormant-account DORMANCY_WARNING" {
+			t.Errorf("%s has audit entries %v, want one warning", user.Email, actions)
+		}
+	}
+
+	// Warned users are left alone for the warning period
+	report, err = p.Run(ctx, false)
+	if err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+	if report.Count != 0 {
+		t.Errorf("second run took actions %v, want none", report.Actions)
+	}
+	if again, _ := accountService.GetOutboxEmails(ctx, model.OutboxStatusPending, 10); len(again) != 2 {
+		t.Errorf("second run left %d warnings queued, want 2", len(again))
+	}
+}
+
+func TestDormantAccountPolicySuspendsAfterWarning(t *testing.T) {
+	ctx := context.Background()
+	accountService := newTestAccountService(t)
+
+	// A warning period this short lets the suspension follow the warning within the test
+	p := NewDormantAccountPolicy(accountService, 90*24*time.Hour, time.Millisecond)
+	dormant := createTestUser(t, accountService, "dormant@example.com", model.UserStatusActive, 100)
+	recent := createTestUser(t, accountService, "recent@example.com", model.UserStatusActive, 100)
+	completeTestLogin(t, accountService, recent, 1)
+
+	// Nobody is suspended without being warned first, however long they have been away
+	report, err := p.Run(ctx, false)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if got := reportedActions(t, report); len(got) != 1 || got[dormant.Email] != ActionDormancyWarn {
+		t.Fatalf("first run actions are %v, want a warning for %s", got, dormant.Email)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	report, err = p.Run(ctx, true)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if got := reportedActions(t, report); len(got) != 1 || got[dormant.Email] != ActionDormancySuspend {
+		t.Fatalf("dry run actions are %v, want a suspension for %s", got, dormant.Email)
+	}
+	if stored, _ := accountService.GetUserByID(ctx, dormant.ID); stored.Status != model.UserStatusActive {
+		t.Errorf("dry run moved %s to %s", dormant.Email, stored.Status)
+	}
+
+	report, err = p.Run(ctx, false)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if got := reportedActions(t, report); len(got) != 1 || got[dormant.Email] != ActionDormancySuspend {
+		t.Fatalf("run actions are %v, want a suspension for %s", got, dormant.Email)
+	}
+
+	stored, err := accountService.GetUserByID(ctx, dormant.ID)
+	if err != nil {
+		t.Fatalf("failed to read user: %v", err)
+	}
+	if stored.Status != model.UserStatusSuspended || stored.StatusReason != "No login for 100 days" {
+		t.Errorf("%s is %s because %q, want suspended for no login", dormant.Email, stored.Status, stored.StatusReason)
+	}
+	actions := auditActions(t, accountService, dormant.ID)
+	wantActions := map[string]bool{
+		"policy:dormant-account DORMANCY_WARNING": true,
+		"policy:dormant-account USER_SUSPENDED":   true,
+	}
+	if len(actions) != 2 || !wantActions[actions[0]] || !wantActions[actions[1]] {
+		t.Errorf("audit entries are %v, want the warning and the suspension", actions)
+	}
+
+	if stored, _ := accountService.GetUserByID(ctx, recent.ID); stored.Status != model.UserStatusActive {
+		t.Errorf("recently active user is %s", stored.Status)
+	}
+
+	// Suspended users are no longer dormant candidates
+	report, err = p.Run(ctx, false)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if report.Count != 0 {
+		t.Errorf("run after the suspension took actions %v, want none", report.Actions)
+	}
+}
+
+func TestEngineRunsRegisteredPolicies(t *testing.T) {
+	accountService := newTestAccountService(t)
+	dormant := NewDormantAccountPolicy(accountService, 90*24*time.Hour, 14*24*time.Hour)
+	engine := NewEngine(0, dormant)
+
+	if engine.Policy(DormantAccountPolicyName) != dormant {
+		t.Errorf("engine does not return the registered policy")
+	}
+	if engine.Policy("unknown") != nil {
+		t.Errorf("engine returned a policy that was not registered")
+	}
+
+	user := createTestUser(t, accountService, "dormant@example.com", model.UserStatusActive, 100)
+	engine.RunAll(context.Background())
+	if actions := auditActions(t, accountService, user.ID); len(actions) != 1 {
+		t.Errorf("running all policies recorded %v, want the warning", actions)
+	}
+}
@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Policy is an account hygiene rule that is evaluated periodically by the Engine
+type Policy interface {
+	Name() string
+	Run(ctx context.Context, dryRun bool) (*Report, error)
+}
+
+// Report lists the actions a policy run took, or would take when dry-run
+type Report struct {
+	Policy  string   `json:"policy"`
+	DryRun  bool     `json:"dryRun"`
+	RunAt   int64    `json:"runAt"`
+	Actions []Action `json:"actions"`
+	Count   int      `json:"count"`
+}
+
+// Action is a single change a policy applied to a user
+type Action struct {
+	UserID       string `json:"userId"`
+	Email        string `json:"email"`
+	Action       string `json:"action"`
+	LastActiveAt int64  `json:"lastActiveAt,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+func newReport(name string, dryRun bool) *Report {
+	return &Report{
+		Policy:  name,
+		DryRun:  dryRun,
+		RunAt:   time.Now().UnixMilli(),
+		Actions: []Action{},
+	}
+}
+
+func (r *Report) add(action Action) {
+	r.Actions = append(r.Actions, action)
+	r.Count = len(r.Actions)
+}
+
+// Engine runs every registered policy on a fixed interval
+type Engine struct {
+	policies map[string]Policy
+	order    []string
+	interval time.Duration
+}
+
+// NewEngine creates a policy engine; an interval of zero disables scheduled runs
+func NewEngine(interval time.Duration, policies ...Policy) *Engine {
+	engine := &Engine{
+		policies: make(map[string]Policy),
+		interval: interval,
+	}
+
+	for _, p := range policies {
+		engine.policies[p.Name()] = p
+		engine.order = append(engine.order, p.Name())
+	}
+
+	return engine
+}
+
+// Policy returns the registered policy with the given name, or nil
+func (e *Engine) Policy(name string) Policy {
+	return e.policies[name]
+}
+
+// Start runs all policies every interval until the context is cancelled
+func (e *Engine) Start(ctx context.Context) {
+	if e.interval <= 0 {
+		log.Printf("PolicyEngine: Scheduled runs disabled")
+		return
+	}
+
+	log.Printf("PolicyEngine: Running %d policies every %s", len(e.order), e.interval)
+	go func() {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.RunAll(ctx)
+			}
+		}
+	}()
+}
+
+// RunAll runs every registered policy once, in registration order
+func (e *Engine) RunAll(ctx context.Context) {
+	for _, name := range e.order {
+		report, err := e.policies[name].Run(ctx, false)
+		if err != nil {
+			log.Printf("PolicyEngine: Policy %s failed: %v", name, err)
+			continue
+		}
+		log.Printf("PolicyEngine: Policy %s applied %d actions", name, report.Count)
+	}
+}
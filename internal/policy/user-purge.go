@@ -0,0 +1,60 @@
+package policy
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/service"
+)
+
+const UserPurgePolicyName = "user-purge"
+
+const ActionPurge = "PURGE"
+
+// UserPurgePolicy permanently removes soft deleted users once their retention window has passed
+type UserPurgePolicy struct {
+	accountService *service.AccountService
+	retention      time.Duration
+}
+
+// NewUserPurgePolicy creates the policy; retention is how long deleted users can still be restored
+func NewUserPurgePolicy(accountService *service.AccountService, retention time.Duration) *UserPurgePolicy {
+	return &UserPurgePolicy{
+		accountService: accountService,
+		retention:      retention,
+	}
+}
+
+func (p *UserPurgePolicy) Name() string {
+	return UserPurgePolicyName
+}
+
+func (p *UserPurgePolicy) Run(ctx context.Context, dryRun bool) (*Report, error) {
+	report := newReport(p.Name(), dryRun)
+
+	deletedBefore := time.Now().Add(-p.retention).UnixMilli()
+	users, err := p.accountService.GetDeletedUsers(ctx, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		action := Action{
+			UserID: user.ID,
+			Email:  user.Email,
+			Action: ActionPurge,
+		}
+
+		if !dryRun {
+			if err := p.accountService.PurgeUser(ctx, user.ID, "policy:"+p.Name()); err != nil {
+				log.Printf("UserPurgePolicy: Failed to purge user %s: %v", user.ID, err)
+				action.Error = err.Error()
+			}
+		}
+
+		report.add(action)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,68 @@
+package policy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+func TestUserPurgePolicy(t *testing.T) {
+	ctx := context.Background()
+	accountService := newTestAccountService(t)
+
+	deleted := createTestUser(t, accountService, "deleted@example.com", model.UserStatusSuspended, 10)
+	restored := createTestUser(t, accountService, "restored@example.com", model.UserStatusSuspended, 10)
+	suspended := createTestUser(t, accountService, "suspended@example.com", model.UserStatusSuspended, 10)
+	for _, user := range []*model.Luna4User{deleted, restored} {
+		if err := accountService.DeleteUser(ctx, user.ID, "admin", ""); err != nil {
+			t.Fatalf("failed to delete %s: %v", user.Email, err)
+		}
+	}
+	if err := accountService.RestoreUser(ctx, restored.ID, "admin", ""); err != nil {
+		t.Fatalf("failed to restore %s: %v", restored.Email, err)
+	}
+
+	// Users deleted within the retention window can still be restored
+	report, err := NewUserPurgePolicy(accountService, 30*24*time.Hour).Run(ctx, false)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if report.Count != 0 {
+		t.Errorf("run within the retention window took actions %v, want none", report.Actions)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	p := NewUserPurgePolicy(accountService, time.Millisecond)
+	report, err = p.Run(ctx, true)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if got := reportedActions(t, report); len(got) != 1 || got[deleted.Email] != ActionPurge {
+		t.Errorf("dry run actions are %v, want a purge of %s", got, deleted.Email)
+	}
+	if stored, err := accountService.GetUserByID(ctx, deleted.ID); err != nil || stored == nil {
+		t.Fatalf("dry run removed %s (%v)", deleted.Email, err)
+	}
+
+	report, err = p.Run(ctx, false)
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if got := reportedActions(t, report); len(got) != 1 || got[deleted.Email] != ActionPurge {
+		t.Errorf("run actions are %v, want a purge of %s", got, deleted.Email)
+	}
+	if stored, err := accountService.GetUserByID(ctx, deleted.ID); err != nil || stored != nil {
+		t.Errorf("purged user is %v (%v), want gone", stored, err)
+	}
+	for _, user := range []*model.Luna4User{restored, suspended} {
+		if stored, err := accountService.GetUserByID(ctx, user.ID); err != nil || stored == nil {
+			t.Errorf("%s was removed (%v)", user.Email, err)
+		}
+	}
+
+	if report, err := p.Run(ctx, false); err != nil || report.Count != 0 {
+		t.Errorf("run after the purge returned %v (%v), want no actions", report, err)
+	}
+}
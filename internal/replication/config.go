@@ -0,0 +1,51 @@
+package replication
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config controls where the database is replicated and how often
+type Config struct {
+	URL              string
+	Interval         time.Duration
+	CheckpointSize   int64
+	SnapshotInterval time.Duration
+	Retention        time.Duration
+	S3               S3Config
+}
+
+// S3Config points the S3 replica at AWS or an S3 compatible endpoint
+type S3Config struct {
+	Endpoint string
+	Region   string
+}
+
+// GetConfig reads the replication settings from the environment. Replication is
+// disabled when REPLICATION_URL is unset.
+func GetConfig() Config {
+	config := Config{
+		URL:              os.Getenv("REPLICATION_URL"),
+		Interval:         time.Duration(getEnvInt("REPLICATION_INTERVAL", 1)) * time.Second,              // Default every second
+		CheckpointSize:   int64(getEnvInt("REPLICATION_CHECKPOINT_MB", 4)) << 20,                         // Default 4 MiB
+		SnapshotInterval: time.Duration(getEnvInt("REPLICATION_SNAPSHOT_INTERVAL", 86400)) * time.Second, // Default daily
+		Retention:        time.Duration(getEnvInt("REPLICATION_RETENTION_DAYS", 3)) * 24 * time.Hour,     // Default 3 days
+		S3: S3Config{
+			Endpoint: os.Getenv("REPLICATION_S3_ENDPOINT"),
+			Region:   os.Getenv("REPLICATION_S3_REGION"),
+		},
+	}
+	if config.S3.Region == "" {
+		config.S3.Region = os.Getenv("AWS_REGION")
+	}
+	return config
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,139 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Replica stores replicated snapshots and WAL segments under slash separated keys
+type Replica interface {
+	Put(ctx context.Context, key string, body io.ReadSeeker) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns every key starting with prefix, sorted
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, key string) error
+	String() string
+}
+
+// NewReplica opens the replica at a file:// or s3:// URL. A plain path is treated as a
+// local directory.
+func NewReplica(ctx context.Context, rawURL string, s3Config S3Config) (Replica, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid replica URL %s: %w", rawURL, err)
+	}
+
+	switch u.Scheme {
+	case "", "file":
+		return NewFileReplica(u.Path)
+	case "s3":
+		return NewS3Replica(ctx, u.Host, strings.TrimPrefix(u.Path, "/"), s3Config)
+	default:
+		return nil, fmt.Errorf("unsupported replica URL scheme: %s", u.Scheme)
+	}
+}
+
+// FileReplica keeps the replica in a local directory, such as a mounted network volume
+type FileReplica struct {
+	root string
+}
+
+func NewFileReplica(root string) (*FileReplica, error) {
+	if root == "" {
+		return nil, fmt.Errorf("replica directory is empty")
+	}
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create replica directory %s: %w", root, err)
+	}
+	return &FileReplica{root: root}, nil
+}
+
+// Put writes to a temporary file first so a partial upload is never listed
+func (r *FileReplica) Put(ctx context.Context, key string, body io.ReadSeeker) error {
+	path := r.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create replica directory: %w", err)
+	}
+
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", tmp, err)
+	}
+	defer os.Remove(tmp)
+
+	if _, err := io.Copy(out, body); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to sync %s: %w", key, err)
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (r *FileReplica) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(r.path(key))
+}
+
+func (r *FileReplica) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(r.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(r.root, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list replica directory %s: %w", r.root, err)
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Delete removes the file and any directories it leaves empty
+func (r *FileReplica) Delete(ctx context.Context, key string) error {
+	path := r.path(key)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+
+	for dir := filepath.Dir(path); dir != filepath.Clean(r.root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (r *FileReplica) String() string {
+	return "file://" + r.root
+}
+
+func (r *FileReplica) path(key string) string {
+	return filepath.Join(r.root, filepath.FromSlash(key))
+}
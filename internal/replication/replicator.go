@@ -0,0 +1,379 @@
+package replication
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Replicas hold generations, each a snapshot of the database followed by the WAL
+// segments written after it:
+//
+//	generations/<time>/snapshot.db.gz
+//	generations/<time>/wal/<index>/<offset>-<time>.wal.gz
+//
+// The WAL index increases every time the replicator truncates the WAL, and the offset is
+// the segment's position in that WAL file.
+const (
+	generationsPrefix = "generations/"
+	snapshotName      = "snapshot.db.gz"
+	segmentExtension  = ".wal.gz"
+	timeLayout        = "20060102T150405.000Z"
+)
+
+// Database is a SQLite database in WAL mode that the replicator can lock against writes
+type Database interface {
+	Path() string
+	Exclusive(ctx context.Context, fn func(conn *sql.Conn) error) error
+}
+
+// Replicator continuously copies committed WAL frames to a replica. It takes over
+// checkpointing from SQLite so no frame is checkpointed before it has been copied.
+type Replicator struct {
+	db      Database
+	replica Replica
+	config  Config
+
+	generation string
+	startedAt  time.Time
+	index      int
+	pos        walPosition
+}
+
+func NewReplicator(db Database, replica Replica, config Config) *Replicator {
+	return &Replicator{
+		db:      db,
+		replica: replica,
+		config:  config,
+	}
+}
+
+// Start replicates every interval until the context is cancelled
+func (r *Replicator) Start(ctx context.Context) error {
+	if r.config.Interval <= 0 {
+		log.Printf("Replicator: Replication disabled")
+		return nil
+	}
+
+	err := r.db.Exclusive(ctx, func(conn *sql.Conn) error {
+		var journalMode string
+		if err := conn.QueryRowContext(ctx, `PRAGMA journal_mode`).Scan(&journalMode); err != nil {
+			return fmt.Errorf("failed to read journal mode: %w", err)
+		}
+		if !strings.EqualFold(journalMode, "wal") {
+			return fmt.Errorf("replication requires SQLITE_JOURNAL_MODE=WAL, database is in %s mode", journalMode)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Replicator: Replicating %s to %s every %s", r.db.Path(), r.replica, r.config.Interval)
+	go func() {
+		ticker := time.NewTicker(r.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			if err := r.sync(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Replicator: Sync failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// sync copies new WAL frames, starting a new generation when there is none yet, when the
+// snapshot interval has passed or when the WAL was reset behind the replicator's back
+func (r *Replicator) sync(ctx context.Context) error {
+	if r.generation == "" {
+		return r.newGeneration(ctx)
+	}
+	if r.config.SnapshotInterval > 0 && time.Since(r.startedAt) >= r.config.SnapshotInterval {
+		// Finish the current generation so restores up to now can still use it
+		if err := r.replicate(ctx); err != nil {
+			log.Printf("Replicator: Failed to finish generation %s: %v", r.generation, err)
+		}
+		return r.newGeneration(ctx)
+	}
+
+	err := r.replicate(ctx)
+	if errors.Is(err, errWALReset) {
+		log.Printf("Replicator: WAL was checkpointed outside the replicator, starting a new generation")
+		return r.newGeneration(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	if r.pos.offset > 0 && r.pos.offset >= r.config.CheckpointSize {
+		return r.checkpoint(ctx)
+	}
+	return nil
+}
+
+// replicate uploads the transactions committed since the last sync as one segment
+func (r *Replicator) replicate(ctx context.Context) error {
+	segment, next, err := readWAL(r.db.Path()+"-wal", r.pos)
+	if err != nil || segment == nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(segment); err != nil {
+		return fmt.Errorf("failed to compress WAL segment: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress WAL segment: %w", err)
+	}
+
+	key := segmentKey(r.generation, r.index, r.pos.offset, time.Now().UTC())
+	if err := r.replica.Put(ctx, key, bytes.NewReader(buf.Bytes())); err != nil {
+		return err
+	}
+
+	r.pos = next
+	return nil
+}
+
+// checkpoint copies the rest of the WAL and truncates it while writes are held back
+func (r *Replicator) checkpoint(ctx context.Context) error {
+	return r.db.Exclusive(ctx, func(conn *sql.Conn) error {
+		if err := r.replicate(ctx); err != nil {
+			return err
+		}
+
+		truncated, err := truncateWAL(ctx, conn)
+		if err != nil {
+			return err
+		}
+		// Readers can keep the WAL from being truncated, in which case it keeps growing
+		// until the next attempt
+		if truncated {
+			r.index++
+			r.pos = walPosition{}
+		}
+		return nil
+	})
+}
+
+// newGeneration truncates the WAL and uploads a copy of the database file
+func (r *Replicator) newGeneration(ctx context.Context) error {
+	r.generation = ""
+	now := time.Now().UTC()
+	name := now.Format(timeLayout)
+
+	snapshot := r.db.Path() + ".replica.tmp"
+	defer os.Remove(snapshot)
+
+	// WAL frames hold raw pages, so the snapshot must be a byte copy of the database file
+	// rather than a VACUUM INTO backup with a different page layout
+	err := r.db.Exclusive(ctx, func(conn *sql.Conn) error {
+		truncated, err := truncateWAL(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if !truncated {
+			return fmt.Errorf("database is busy, WAL could not be truncated")
+		}
+		return compressFile(r.db.Path(), snapshot)
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	if err := r.replica.Put(ctx, snapshotKey(name), file); err != nil {
+		return err
+	}
+
+	r.generation = name
+	r.startedAt = now
+	r.index = 0
+	r.pos = walPosition{}
+	log.Printf("Replicator: Started generation %s", name)
+
+	if err := r.prune(ctx); err != nil {
+		log.Printf("Replicator: Failed to prune old generations: %v", err)
+	}
+	return nil
+}
+
+// prune deletes generations that ended before the retention period. The current
+// generation is always kept.
+func (r *Replicator) prune(ctx context.Context) error {
+	if r.config.Retention <= 0 {
+		return nil
+	}
+
+	generations, err := listGenerations(ctx, r.replica)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-r.config.Retention)
+	for i := 0; i+1 < len(generations); i++ {
+		// A generation covers the time until the next one starts
+		if !generations[i+1].createdAt.Before(cutoff) {
+			break
+		}
+		for _, key := range generations[i].keys {
+			if err := r.replica.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+		log.Printf("Replicator: Removed generation %s", generations[i].name)
+	}
+	return nil
+}
+
+// truncateWAL checkpoints the whole WAL into the database file and truncates it. It
+// reports false when readers kept the checkpoint from completing.
+func truncateWAL(ctx context.Context, conn *sql.Conn) (bool, error) {
+	// Keep SQLite from checkpointing on its own, which would lose frames not yet copied
+	if _, err := conn.ExecContext(ctx, `PRAGMA wal_autocheckpoint = 0`); err != nil {
+		return false, fmt.Errorf("failed to disable automatic checkpoints: %w", err)
+	}
+
+	var busy, frames, checkpointed int
+	if err := conn.QueryRowContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &frames, &checkpointed); err != nil {
+		return false, fmt.Errorf("failed to checkpoint WAL: %w", err)
+	}
+	return busy == 0, nil
+}
+
+func compressFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer out.Close()
+
+	gz, _ := gzip.NewWriterLevel(out, gzip.BestSpeed)
+	if _, err := io.Copy(gz, in); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return gz.Close()
+}
+
+func snapshotKey(generation string) string {
+	return generationsPrefix + generation + "/" + snapshotName
+}
+
+func segmentKey(generation string, index int, offset int64, createdAt time.Time) string {
+	return fmt.Sprintf("%s%s/wal/%08d/%016x-%s%s", generationsPrefix, generation, index, offset, createdAt.Format(timeLayout), segmentExtension)
+}
+
+type generation struct {
+	name      string
+	createdAt time.Time
+	snapshot  bool
+	segments  []segment
+	keys      []string
+}
+
+type segment struct {
+	key       string
+	index     int
+	offset    int64
+	createdAt time.Time
+}
+
+// listGenerations reads the generations in a replica, oldest first, with their segments
+// in replay order. Keys that do not follow the layout are ignored.
+func listGenerations(ctx context.Context, replica Replica) ([]*generation, error) {
+	keys, err := replica.List(ctx, generationsPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]*generation{}
+	for _, key := range keys {
+		parts := strings.Split(strings.TrimPrefix(key, generationsPrefix), "/")
+		createdAt, err := time.Parse(timeLayout, parts[0])
+		if err != nil {
+			continue
+		}
+
+		gen := byName[parts[0]]
+		if gen == nil {
+			gen = &generation{name: parts[0], createdAt: createdAt}
+			byName[parts[0]] = gen
+		}
+		gen.keys = append(gen.keys, key)
+
+		switch {
+		case len(parts) == 2 && parts[1] == snapshotName:
+			gen.snapshot = true
+		case len(parts) == 4 && parts[1] == "wal":
+			if segment, ok := parseSegment(key, parts[2], parts[3]); ok {
+				gen.segments = append(gen.segments, segment)
+			}
+		}
+	}
+
+	var generations []*generation
+	for _, gen := range byName {
+		sort.Slice(gen.segments, func(i, j int) bool {
+			if gen.segments[i].index != gen.segments[j].index {
+				return gen.segments[i].index < gen.segments[j].index
+			}
+			return gen.segments[i].offset < gen.segments[j].offset
+		})
+		generations = append(generations, gen)
+	}
+	sort.Slice(generations, func(i, j int) bool { return generations[i].createdAt.Before(generations[j].createdAt) })
+	return generations, nil
+}
+
+func parseSegment(key, index, file string) (segment, bool) {
+	name, ok := strings.CutSuffix(file, segmentExtension)
+	if !ok {
+		return segment{}, false
+	}
+	offset, createdAt, ok := strings.Cut(name, "-")
+	if !ok {
+		return segment{}, false
+	}
+
+	s := segment{key: key}
+	var err error
+	if s.index, err = strconv.Atoi(index); err != nil {
+		return s, false
+	}
+	if s.offset, err = strconv.ParseInt(offset, 16, 64); err != nil {
+		return s, false
+	}
+	if s.createdAt, err = time.Parse(timeLayout, createdAt); err != nil {
+		return s, false
+	}
+	return s, true
+}
@@ -0,0 +1,309 @@
+package replication
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/service"
+)
+
+var testConfig = Config{
+	Interval:       time.Second,
+	CheckpointSize: 64 << 20,
+}
+
+// newTestDatabase opens a migrated SQLite database in WAL mode in a temporary directory
+func newTestDatabase(t *testing.T) *service.SQLiteService {
+	t.Helper()
+	config := service.GetSQLiteConfig()
+	config.Path = filepath.Join(t.TempDir(), "airlock.db")
+	store, err := service.NewSQLiteService(config, os.DirFS("../.."))
+	if err != nil {
+		t.Fatalf("failed to open SQLite store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := service.Migrate(context.Background(), store); err != nil {
+		t.Fatalf("failed to migrate SQLite store: %v", err)
+	}
+	return store
+}
+
+func newTestReplicator(t *testing.T, db *service.SQLiteService, config Config) (*Replicator, *FileReplica) {
+	t.Helper()
+	replica, err := NewFileReplica(filepath.Join(t.TempDir(), "replica"))
+	if err != nil {
+		t.Fatalf("failed to create replica: %v", err)
+	}
+	return NewReplicator(db, replica, config), replica
+}
+
+func createTestUser(t *testing.T, db *service.SQLiteService, email string) {
+	t.Helper()
+	now := time.Now().UnixMilli()
+	user := &model.Luna4User{ID: uuid.New().String(), Email: email, Status: model.UserStatusActive, CreatedAt: now, UpdatedAt: now}
+	if err := db.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("failed to create user %s: %v", email, err)
+	}
+}
+
+// syncReplica runs one replication pass and returns a time after everything it copied
+func syncReplica(t *testing.T, r *Replicator) time.Time {
+	t.Helper()
+	if err := r.sync(context.Background()); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	// Segment times have millisecond precision
+	time.Sleep(2 * time.Millisecond)
+	synced := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	return synced
+}
+
+// restoredEmails restores the replica as of at and returns the emails of the users in it
+func restoredEmails(t *testing.T, replica Replica, at time.Time) []string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "restored.db")
+	if _, err := Restore(context.Background(), replica, path, at); err != nil {
+		t.Fatalf("restore to %s failed: %v", at.Format(time.RFC3339Nano), err)
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		t.Fatalf("failed to open restored database: %v", err)
+	}
+	defer db.Close()
+
+	var integrity string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&integrity); err != nil || integrity != "ok" {
+		t.Fatalf("restored database is corrupt: %s (%v)", integrity, err)
+	}
+
+	rows, err := db.Query(`SELECT email FROM luna4_users ORDER BY email`)
+	if err != nil {
+		t.Fatalf("failed to read restored users: %v", err)
+	}
+	defer rows.Close()
+	var emails []string
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			t.Fatalf("failed to scan restored user: %v", err)
+		}
+		emails = append(emails, email)
+	}
+	return emails
+}
+
+func TestReplicateAndRestoreToPointInTime(t *testing.T) {
+	db := newTestDatabase(t)
+	r, replica := newTestReplicator(t, db, testConfig)
+
+	createTestUser(t, db, "a@example.com")
+	started := syncReplica(t, r)
+
+	createTestUser(t, db, "b@example.com")
+	afterB := syncReplica(t, r)
+
+	// A small checkpoint size truncates the WAL after the next pass, so the rest of the
+	// generation continues in a new WAL index
+	r.config.CheckpointSize = 1
+	createTestUser(t, db, "c@example.com")
+	afterC := syncReplica(t, r)
+	if r.index != 1 {
+		t.Fatalf("WAL index is %d after the checkpoint, want 1", r.index)
+	}
+	r.config.CheckpointSize = testConfig.CheckpointSize
+
+	createTestUser(t, db, "d@example.com")
+	createTestUser(t, db, "e@example.com")
+	afterE := syncReplica(t, r)
+
+	generations, err := listGenerations(context.Background(), replica)
+	if err != nil {
+		t.Fatalf("failed to list generations: %v", err)
+	}
+	if len(generations) != 1 || !generations[0].snapshot || len(generations[0].segments) != 3 {
+		t.Fatalf("replica holds %d generations, want one with a snapshot and 3 segments", len(generations))
+	}
+
+	for _, tc := range []struct {
+		name string
+		at   time.Time
+		want []string
+	}{
+		{"snapshot", started, []string{"a@example.com"}},
+		{"first segment", afterB, []string{"a@example.com", "b@example.com"}},
+		{"before the checkpoint", afterC, []string{"a@example.com", "b@example.com", "c@example.com"}},
+		{"after the checkpoint", afterE, []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"}},
+	} {
+		if got := restoredEmails(t, replica, tc.at); !slices.Equal(got, tc.want) {
+			t.Errorf("restoring to the %s has users %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := Restore(context.Background(), replica, filepath.Join(t.TempDir(), "early.db"), generations[0].createdAt.Add(-time.Second)); err == nil {
+		t.Errorf("restoring to before the first snapshot succeeded")
+	}
+}
+
+func TestSyncStartsNewGenerationWhenWALIsReset(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	r, replica := newTestReplicator(t, db, testConfig)
+
+	createTestUser(t, db, "a@example.com")
+	syncReplica(t, r)
+	createTestUser(t, db, "b@example.com")
+	syncReplica(t, r)
+	first := r.generation
+
+	// A checkpoint the replicator did not run may have applied frames it never copied
+	err := db.Exclusive(ctx, func(conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to checkpoint: %v", err)
+	}
+	createTestUser(t, db, "c@example.com")
+	syncReplica(t, r)
+	if r.generation == first {
+		t.Fatalf("replicator kept generation %s after the WAL was reset", first)
+	}
+
+	createTestUser(t, db, "d@example.com")
+	synced := syncReplica(t, r)
+	want := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"}
+	if got := restoredEmails(t, replica, synced); !slices.Equal(got, want) {
+		t.Errorf("restored users are %v, want %v", got, want)
+	}
+}
+
+func TestPruneKeepsGenerationsWithinRetention(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	config := testConfig
+	config.SnapshotInterval = time.Nanosecond
+	config.Retention = 50 * time.Millisecond
+	r, replica := newTestReplicator(t, db, config)
+
+	var names []string
+	for i, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if i > 0 {
+			time.Sleep(2 * config.Retention)
+		}
+		createTestUser(t, db, email)
+		syncReplica(t, r)
+		names = append(names, r.generation)
+	}
+
+	generations, err := listGenerations(ctx, replica)
+	if err != nil {
+		t.Fatalf("failed to list generations: %v", err)
+	}
+	var kept []string
+	for _, gen := range generations {
+		kept = append(kept, gen.name)
+	}
+
+	// The previous generation covers the time up to the current one, which just started
+	if !slices.Equal(kept, names[1:]) {
+		t.Errorf("replica keeps generations %v, want %v", kept, names[1:])
+	}
+	keys, err := replica.List(ctx, generationsPrefix+names[0])
+	if err != nil || len(keys) != 0 {
+		t.Errorf("pruned generation left %v (%v)", keys, err)
+	}
+}
+
+func TestRestoreRejectsMissingSegment(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t)
+	r, replica := newTestReplicator(t, db, testConfig)
+
+	syncReplica(t, r)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		createTestUser(t, db, email)
+		syncReplica(t, r)
+	}
+
+	generations, err := listGenerations(ctx, replica)
+	if err != nil {
+		t.Fatalf("failed to list generations: %v", err)
+	}
+	if err := replica.Delete(ctx, generations[0].segments[1].key); err != nil {
+		t.Fatalf("failed to delete segment: %v", err)
+	}
+
+	_, err = Restore(ctx, replica, filepath.Join(t.TempDir(), "restored.db"), time.Now())
+	if err == nil || !strings.Contains(err.Error(), "missing WAL data") {
+		t.Errorf("restoring with a missing segment returned %v, want missing WAL data", err)
+	}
+}
+
+func TestNewReplica(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s3Config := S3Config{Endpoint: "http://127.0.0.1:9000", Region: "us-east-1"}
+
+	for rawURL, want := range map[string]string{
+		filepath.Join(dir, "plain"):             "file://" + filepath.Join(dir, "plain"),
+		"file://" + filepath.Join(dir, "local"): "file://" + filepath.Join(dir, "local"),
+		"s3://backups/airlock/prod":             "s3://backups/airlock/prod",
+	} {
+		replica, err := NewReplica(ctx, rawURL, s3Config)
+		if err != nil {
+			t.Errorf("opening %s failed: %v", rawURL, err)
+			continue
+		}
+		if replica.String() != want {
+			t.Errorf("%s opened %s, want %s", rawURL, replica, want)
+		}
+	}
+
+	for _, rawURL := range []string{"ftp://example.com/replica", "s3:///airlock", "file://"} {
+		if _, err := NewReplica(ctx, rawURL, s3Config); err == nil {
+			t.Errorf("opening %s succeeded", rawURL)
+		}
+	}
+}
+
+func TestFileReplica(t *testing.T) {
+	ctx := context.Background()
+	replica, err := NewFileReplica(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create replica: %v", err)
+	}
+
+	for _, key := range []string{"generations/b/wal/1.wal.gz", "generations/a/snapshot.db.gz", "other/key"} {
+		if err := replica.Put(ctx, key, strings.NewReader(key)); err != nil {
+			t.Fatalf("failed to put %s: %v", key, err)
+		}
+	}
+
+	keys, err := replica.List(ctx, generationsPrefix)
+	if err != nil {
+		t.Fatalf("failed to list: %v", err)
+	}
+	if want := []string{"generations/a/snapshot.db.gz", "generations/b/wal/1.wal.gz"}; !slices.Equal(keys, want) {
+		t.Errorf("listed %v, want %v", keys, want)
+	}
+
+	if err := replica.Delete(ctx, "generations/b/wal/1.wal.gz"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(replica.root, "generations", "b")); !os.IsNotExist(err) {
+		t.Errorf("deleting the last key left its directory behind: %v", err)
+	}
+	if err := replica.Delete(ctx, "generations/b/wal/1.wal.gz"); err != nil {
+		t.Errorf("deleting a missing key failed: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package replication
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Restore writes the database as it was at the given time to dst. It starts from the
+// newest snapshot taken at or before that time and replays the WAL segments replicated
+// up to it. It returns the time of the last segment applied.
+func Restore(ctx context.Context, replica Replica, dst string, at time.Time) (time.Time, error) {
+	generations, err := listGenerations(ctx, replica)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var selected *generation
+	for i := len(generations) - 1; i >= 0; i-- {
+		if generations[i].snapshot && !generations[i].createdAt.After(at) {
+			selected = generations[i]
+			break
+		}
+	}
+	if selected == nil {
+		return time.Time{}, fmt.Errorf("no snapshot in %s taken at or before %s", replica, at.Format(time.RFC3339))
+	}
+
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Remove(dst + suffix); err != nil && !os.IsNotExist(err) {
+			return time.Time{}, fmt.Errorf("failed to clear %s: %w", dst+suffix, err)
+		}
+	}
+
+	if _, err := download(ctx, replica, snapshotKey(selected.name), dst, false); err != nil {
+		return time.Time{}, err
+	}
+
+	restoredAt := selected.createdAt
+	walPath := dst + "-wal"
+	index, size := -1, int64(0)
+	for _, segment := range selected.segments {
+		if segment.createdAt.After(at) {
+			break
+		}
+
+		if segment.index != index {
+			if index >= 0 {
+				if err := applyWAL(ctx, dst); err != nil {
+					return time.Time{}, err
+				}
+			}
+			index, size = segment.index, 0
+		}
+		if segment.offset != size {
+			return time.Time{}, fmt.Errorf("replica is missing WAL data before %s", segment.key)
+		}
+
+		n, err := download(ctx, replica, segment.key, walPath, true)
+		if err != nil {
+			return time.Time{}, err
+		}
+		size += n
+		restoredAt = segment.createdAt
+	}
+	if index >= 0 {
+		if err := applyWAL(ctx, dst); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	return restoredAt, nil
+}
+
+// download decompresses the object at key into path and returns the bytes written
+func download(ctx context.Context, replica Replica, key, path string, appendTo bool) (int64, error) {
+	body, err := replica.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decompress %s: %w", key, err)
+	}
+	defer gz.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendTo {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	out, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer out.Close()
+
+	n, err := io.Copy(out, gz)
+	if err != nil {
+		return n, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	return n, out.Sync()
+}
+
+// applyWAL lets SQLite recover the WAL next to the database and checkpoints it into the
+// database file, checking that every frame was accepted
+func applyWAL(ctx context.Context, path string) error {
+	wal, err := os.ReadFile(path + "-wal")
+	if err != nil {
+		return fmt.Errorf("failed to read WAL: %w", err)
+	}
+	if len(wal) < walHeaderSize {
+		return fmt.Errorf("replicated WAL is truncated")
+	}
+	header, err := parseWALHeader(wal[:walHeaderSize])
+	if err != nil {
+		return err
+	}
+	expected := (int64(len(wal)) - walHeaderSize) / (walFrameHeaderSize + header.pageSize)
+
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		return fmt.Errorf("failed to open restored database: %w", err)
+	}
+	defer db.Close()
+
+	// A passive checkpoint copies the frames SQLite accepted during recovery into the
+	// database file and reports how many there were
+	var busy, frames, checkpointed int64
+	if err := db.QueryRowContext(ctx, `PRAGMA wal_checkpoint(PASSIVE)`).Scan(&busy, &frames, &checkpointed); err != nil {
+		return fmt.Errorf("failed to replay WAL: %w", err)
+	}
+	if busy != 0 || frames != expected || checkpointed != expected {
+		return fmt.Errorf("replayed %d of %d WAL frames", checkpointed, expected)
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close restored database: %w", err)
+	}
+
+	// The next WAL index starts from an empty file
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(path + suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove replayed WAL: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Replica keeps the replica in an S3 bucket or an S3 compatible store such as MinIO
+type S3Replica struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+func NewS3Replica(ctx context.Context, bucket, prefix string, s3Config S3Config) (*S3Replica, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("replica URL has no bucket")
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx,
+		config.WithRegion(s3Config.Region),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		// MinIO and most compatible stores only serve path style requests
+		if s3Config.Endpoint != "" {
+			o.BaseEndpoint = aws.String(s3Config.Endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	return &S3Replica{
+		client: client,
+		bucket: bucket,
+		prefix: prefix,
+	}, nil
+}
+
+func (r *S3Replica) Put(ctx context.Context, key string, body io.ReadSeeker) error {
+	_, err := r.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(r.key(key)),
+		Body:   body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+func (r *S3Replica) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	output, err := r.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(r.key(key)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	return output.Body, nil
+}
+
+func (r *S3Replica) List(ctx context.Context, prefix string) ([]string, error) {
+	base := ""
+	if r.prefix != "" {
+		base = r.prefix + "/"
+	}
+
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(r.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(r.bucket),
+		Prefix: aws.String(base + prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list replica: %w", err)
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key)[len(base):])
+		}
+	}
+	// ListObjectsV2 returns keys in ascending order
+	return keys, nil
+}
+
+func (r *S3Replica) Delete(ctx context.Context, key string) error {
+	_, err := r.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(r.key(key)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+func (r *S3Replica) String() string {
+	return "s3://" + path.Join(r.bucket, r.prefix)
+}
+
+func (r *S3Replica) key(key string) string {
+	if r.prefix == "" {
+		return key
+	}
+	return r.prefix + "/" + key
+}
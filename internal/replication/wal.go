@@ -0,0 +1,152 @@
+package replication
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// SQLite WAL file layout, see https://www.sqlite.org/fileformat.html#the_write_ahead_log
+const (
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+	walMagicLE         = 0x377f0682
+	walMagicBE         = 0x377f0683
+)
+
+// errWALReset means the WAL was restarted by something other than the replicator, so
+// frames may have been checkpointed before they were replicated
+var errWALReset = errors.New("WAL was reset outside the replicator")
+
+type walHeader struct {
+	bigEndian bool
+	pageSize  int64
+	salt      [8]byte
+	checksum  [2]uint32
+}
+
+func parseWALHeader(b []byte) (walHeader, error) {
+	var header walHeader
+	switch binary.BigEndian.Uint32(b[0:4]) {
+	case walMagicLE:
+	case walMagicBE:
+		header.bigEndian = true
+	default:
+		return header, fmt.Errorf("invalid WAL header magic")
+	}
+
+	header.pageSize = int64(binary.BigEndian.Uint32(b[8:12]))
+	if header.pageSize == 1 {
+		header.pageSize = 65536
+	}
+	copy(header.salt[:], b[16:24])
+	header.checksum = [2]uint32{binary.BigEndian.Uint32(b[24:28]), binary.BigEndian.Uint32(b[28:32])}
+
+	if walChecksum(header.bigEndian, [2]uint32{}, b[:24]) != header.checksum {
+		return header, fmt.Errorf("invalid WAL header checksum")
+	}
+	return header, nil
+}
+
+// walChecksum extends the cumulative WAL checksum over b
+func walChecksum(bigEndian bool, sum [2]uint32, b []byte) [2]uint32 {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	for i := 0; i+8 <= len(b); i += 8 {
+		sum[0] += order.Uint32(b[i:]) + sum[1]
+		sum[1] += order.Uint32(b[i+4:]) + sum[0]
+	}
+	return sum
+}
+
+// walPosition is how far into the current WAL file the replica reaches. Offsets always
+// fall on the end of a committed transaction.
+type walPosition struct {
+	offset   int64
+	salt     [8]byte
+	checksum [2]uint32
+}
+
+// readWAL returns the WAL bytes after pos up to the end of the last committed transaction,
+// and the position that follows them. The segment starting a new WAL includes its header.
+// Frames are checked against the header salt and the running checksum, so frames from an
+// earlier WAL cycle or a write still in progress are never returned.
+func readWAL(path string, pos walPosition) ([]byte, walPosition, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) && pos.offset == 0 {
+			return nil, pos, nil
+		}
+		if os.IsNotExist(err) {
+			return nil, pos, errWALReset
+		}
+		return nil, pos, fmt.Errorf("failed to open WAL: %w", err)
+	}
+	defer file.Close()
+
+	headerBytes := make([]byte, walHeaderSize)
+	if _, err := io.ReadFull(file, headerBytes); err != nil {
+		// An empty WAL has nothing to replicate yet
+		if pos.offset == 0 {
+			return nil, pos, nil
+		}
+		return nil, pos, errWALReset
+	}
+
+	header, err := parseWALHeader(headerBytes)
+	if err != nil {
+		return nil, pos, err
+	}
+
+	start := pos.offset
+	checksum := pos.checksum
+	if start == 0 {
+		start = walHeaderSize
+		checksum = header.checksum
+	} else if header.salt != pos.salt {
+		return nil, pos, errWALReset
+	}
+
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return nil, pos, fmt.Errorf("failed to seek WAL: %w", err)
+	}
+	rest, err := io.ReadAll(file)
+	if err != nil {
+		return nil, pos, fmt.Errorf("failed to read WAL: %w", err)
+	}
+
+	frameSize := walFrameHeaderSize + header.pageSize
+	committed := int64(0)
+	next := walPosition{offset: pos.offset, salt: header.salt, checksum: pos.checksum}
+	for i := int64(0); i+frameSize <= int64(len(rest)); i += frameSize {
+		frame := rest[i : i+frameSize]
+		if [8]byte(frame[8:16]) != header.salt {
+			break
+		}
+
+		checksum = walChecksum(header.bigEndian, checksum, frame[:8])
+		checksum = walChecksum(header.bigEndian, checksum, frame[walFrameHeaderSize:])
+		if checksum != [2]uint32{binary.BigEndian.Uint32(frame[16:20]), binary.BigEndian.Uint32(frame[20:24])} {
+			break
+		}
+
+		// Commit frames record the database size in pages
+		if binary.BigEndian.Uint32(frame[4:8]) != 0 {
+			committed = i + frameSize
+			next.offset = start + committed
+			next.checksum = checksum
+		}
+	}
+
+	if committed == 0 {
+		return nil, pos, nil
+	}
+	if pos.offset == 0 {
+		return append(headerBytes, rest[:committed]...), next, nil
+	}
+	return rest[:committed], next, nil
+}
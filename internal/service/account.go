@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+
+	"github.com/luna4dev/airlock/internal/webauthn"
+)
+
+// AccountService implements account lifecycle operations on top of any Store
+type AccountService struct {
+	Store
+	emails   *EmailService
+	passkeys webauthn.Config
+}
+
+func NewAccountService(store Store, emails *EmailService, passkeys webauthn.Config) *AccountService {
+	return &AccountService{
+		Store:    store,
+		emails:   emails,
+		passkeys: passkeys,
+	}
+}
+
+// inTx runs fn with a copy of the service whose store is bound to a transaction
+func (s *AccountService) inTx(ctx context.Context, fn func(tx *AccountService) error) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		txService := *s
+		txService.Store = tx
+		return fn(&txService)
+	})
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+func (s *sqlStore) CreateAuditLog(ctx context.Context, userID, actor string, action model.AuditAction, detail string) error {
+	log.Printf("CreateAuditLog: Recording %s for user %s by %s", action, userID, actor)
+	query := `
+		INSERT INTO luna4_audit_log (id, user_id, actor, action, detail, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		uuid.New().String(),
+		userID,
+		actor,
+		action,
+		detail,
+		time.Now().UnixMilli(),
+	)
+	if err != nil {
+		log.Printf("CreateAuditLog: Failed to create audit log: %v", err)
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserAuditLogs returns audit entries about the user and entries for actions the user took
+func (s *sqlStore) GetUserAuditLogs(ctx context.Context, userID string) ([]model.Luna4AuditLog, error) {
+	log.Printf("GetUserAuditLogs: Fetching audit logs for user: %s", userID)
+	query := `
+		SELECT id, user_id, actor, action, detail, created_at
+		FROM luna4_audit_log
+		WHERE user_id = ? OR actor = ?
+		ORDER BY created_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, userID)
+	if err != nil {
+		log.Printf("GetUserAuditLogs: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []model.Luna4AuditLog
+	for rows.Next() {
+		var entry model.Luna4AuditLog
+
+		err := rows.Scan(
+			&entry.ID,
+			&entry.UserID,
+			&entry.Actor,
+			&entry.Action,
+			&entry.Detail,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			log.Printf("GetUserAuditLogs: Failed to scan audit log row: %v", err)
+			return nil, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+
+		logs = append(logs, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("GetUserAuditLogs: Error during row iteration: %v", err)
+		return nil, fmt.Errorf("error iterating over audit log rows: %w", err)
+	}
+
+	log.Printf("GetUserAuditLogs: Successfully retrieved %d audit logs for user %s", len(logs), userID)
+	return logs, nil
+}
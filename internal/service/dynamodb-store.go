@@ -0,0 +1,1134 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+func (s *DynamoDBService) GetAllUsers(ctx context.Context) ([]*model.Luna4User, error) {
+	users, err := scanDynamoTable[*model.Luna4User](ctx, s, dynamoUsersTable, "", nil, nil)
+	if err != nil {
+		log.Printf("GetAllUsers: Scan failed: %v", err)
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].CreatedAt > users[j].CreatedAt })
+	log.Printf("GetAllUsers: Successfully retrieved %d users", len(users))
+	return users, nil
+}
+
+// ListUsers scans the users and service grants once each and filters, sorts and pages
+// them in memory. DynamoDB has no secondary ordering to page through.
+func (s *DynamoDBService) ListUsers(ctx context.Context, opts UserListOptions) (*UserPage, error) {
+	var cursorValue any
+	var cursorID string
+	if opts.Cursor != "" {
+		var err error
+		if cursorValue, cursorID, err = decodeUserCursor(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	users, err := scanDynamoTable[model.Luna4User](ctx, s, dynamoUsersTable, "", nil, nil)
+	if err != nil {
+		log.Printf("ListUsers: Scan failed: %v", err)
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	grants, err := scanDynamoTable[model.Luna4UserService](ctx, s, dynamoUserServiceTable, "", nil, nil)
+	if err != nil {
+		log.Printf("ListUsers: Scan failed: %v", err)
+		return nil, fmt.Errorf("failed to query user services: %w", err)
+	}
+
+	servicesByUser := map[string][]model.Luna4UserService{}
+	for _, grant := range grants {
+		servicesByUser[grant.UserID] = append(servicesByUser[grant.UserID], grant)
+	}
+
+	// compare orders two users by the sort field, then by ID
+	compare := func(a *model.Luna4User, value any, id string) int {
+		var c int
+		switch v := value.(type) {
+		case string:
+			c = strings.Compare(a.Email, v)
+		case int64:
+			field := a.CreatedAt
+			if opts.Sort == UserSortUpdatedAt {
+				field = a.UpdatedAt
+			}
+			c = cmp.Compare(field, v)
+		}
+		if c == 0 {
+			c = strings.Compare(a.ID, id)
+		}
+		if opts.Descending {
+			c = -c
+		}
+		return c
+	}
+	sortValue := func(u *model.Luna4User) any {
+		switch opts.Sort {
+		case UserSortEmail:
+			return u.Email
+		case UserSortUpdatedAt:
+			return u.UpdatedAt
+		default:
+			return u.CreatedAt
+		}
+	}
+
+	email := strings.ToLower(opts.Email)
+	var matched []model.Luna4UserWithServices
+	for _, user := range users {
+		services := servicesByUser[user.ID]
+		if !matchesUserListOptions(&user, services, opts, email) {
+			continue
+		}
+		if cursorID != "" && compare(&user, cursorValue, cursorID) <= 0 {
+			continue
+		}
+
+		sort.Slice(services, func(i, j int) bool { return services[i].Service < services[j].Service })
+		if services == nil {
+			services = []model.Luna4UserService{}
+		}
+		matched = append(matched, model.Luna4UserWithServices{Luna4User: user, Services: services})
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		b := &matched[j].Luna4User
+		return compare(&matched[i].Luna4User, sortValue(b), b.ID) < 0
+	})
+	if len(matched) > opts.Limit+1 {
+		matched = matched[:opts.Limit+1]
+	}
+	if matched == nil {
+		matched = []model.Luna4UserWithServices{}
+	}
+
+	return newUserPage(opts, matched), nil
+}
+
+// matchesUserListOptions applies the list filters to a user and their grants
+func matchesUserListOptions(user *model.Luna4User, services []model.Luna4UserService, opts UserListOptions, email string) bool {
+	if len(opts.Statuses) > 0 && !slices.Contains(opts.Statuses, user.Status) {
+		return false
+	}
+	if email != "" && !strings.Contains(strings.ToLower(user.Email), email) {
+		return false
+	}
+	if opts.CreatedAfter != nil && user.CreatedAt < *opts.CreatedAfter {
+		return false
+	}
+	if opts.CreatedBefore != nil && user.CreatedAt >= *opts.CreatedBefore {
+		return false
+	}
+	if opts.Service == "" && opts.Permission == "" {
+		return true
+	}
+	return slices.ContainsFunc(services, func(grant model.Luna4UserService) bool {
+		return (opts.Service == "" || grant.Service == opts.Service) &&
+			(opts.Permission == "" || grant.Permission == opts.Permission)
+	})
+}
+
+// dynamoUserEmail claims an address for one user. DynamoDB cannot enforce a unique
+// secondary key, so every write that gives a user an address also creates its claim, in
+// the same transaction and only if no other user holds it.
+type dynamoUserEmail struct {
+	Email  string `dynamodbav:"id"`
+	UserID string `dynamodbav:"userId"`
+}
+
+// CreateUser checks the email index before writing and claims the address together with
+// the user, so of two concurrent requests for the same address only one succeeds
+func (s *DynamoDBService) CreateUser(ctx context.Context, user *model.Luna4User) error {
+	log.Printf("CreateUser: Creating user with ID: %s, Email: %s", user.ID, user.Email)
+	existing, err := s.GetUserByEmail(ctx, user.Email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("user with email %s already exists", user.Email)
+	}
+
+	return s.inTx(ctx, func(tx *DynamoDBService) error {
+		if err := tx.putItemOr(ctx, ErrEmailTaken, dynamoUserEmailTable, &dynamoUserEmail{Email: user.Email, UserID: user.ID}); err != nil {
+			return err
+		}
+		if err := tx.putItem(ctx, dynamoUsersTable, user); err != nil {
+			log.Printf("CreateUser: Failed to create user: %v", err)
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *DynamoDBService) GetUserByID(ctx context.Context, userID string) (*model.Luna4User, error) {
+	user, err := getDynamoItem[model.Luna4User](ctx, s, dynamoUsersTable, userID)
+	if err != nil {
+		log.Printf("GetUserByID: Failed to get user: %v", err)
+		return nil, fmt.Errorf("failed to get user by ID: %w", err)
+	}
+	return user, nil
+}
+
+func (s *DynamoDBService) GetUserByEmail(ctx context.Context, email string) (*model.Luna4User, error) {
+	users, err := queryDynamoIndex[model.Luna4User](ctx, s, dynamoUsersTable, "email-index", "email", email, 1)
+	if err != nil {
+		log.Printf("GetUserByEmail: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to get auth information: %w", err)
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+	return &users[0], nil
+}
+
+func (s *DynamoDBService) UpdateUserStatus(ctx context.Context, userID string, status model.UserStatus, reason string) error {
+	now := time.Now().UnixMilli()
+	found, err := s.updateItem(ctx, dynamoUsersTable, userID,
+		"SET #status = :status, statusReason = :reason, statusChangedAt = :now, updatedAt = :now",
+		map[string]string{"#status": "status"},
+		map[string]any{":status": status, ":reason": reason, ":now": now},
+	)
+	if err != nil {
+		log.Printf("UpdateUserStatus: Failed to update user status: %v", err)
+		return fmt.Errorf("failed to update user status: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("no user found with ID: %s", userID)
+	}
+	return nil
+}
+
+// UpdateUserEmail moves the user's claim from the old address to the new one together
+// with the user, and returns ErrEmailTaken when another user holds the new address
+func (s *DynamoDBService) UpdateUserEmail(ctx context.Context, userID, email string) error {
+	return s.inTx(ctx, func(tx *DynamoDBService) error {
+		user, err := tx.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return fmt.Errorf("no user found with ID: %s", userID)
+		}
+		if user.Email == email {
+			return nil
+		}
+
+		// Users created before addresses were claimed have no claim to release
+		if _, err := tx.deleteItem(ctx, dynamoUserEmailTable, user.Email); err != nil {
+			return fmt.Errorf("failed to release email: %w", err)
+		}
+		if err := tx.putItemOr(ctx, ErrEmailTaken, dynamoUserEmailTable, &dynamoUserEmail{Email: email, UserID: userID}); err != nil {
+			return err
+		}
+
+		found, err := tx.updateItem(ctx, dynamoUsersTable, userID,
+			"SET email = :email, updatedAt = :now",
+			nil,
+			map[string]any{":email": email, ":now": time.Now().UnixMilli()},
+		)
+		if err != nil {
+			log.Printf("UpdateUserEmail: Failed to update user email: %v", err)
+			return fmt.Errorf("failed to update user email: %w", err)
+		}
+		if !found {
+			return fmt.Errorf("no user found with ID: %s", userID)
+		}
+		return nil
+	})
+}
+
+func (s *DynamoDBService) UpdateUserProfile(ctx context.Context, userID, name, organization, notes string) error {
+	found, err := s.updateItem(ctx, dynamoUsersTable, userID,
+		"SET #name = :name, organization = :organization, notes = :notes, updatedAt = :now",
+		map[string]string{"#name": "name"},
+		map[string]any{":name": name, ":organization": organization, ":notes": notes, ":now": time.Now().UnixMilli()},
+	)
+	if err != nil {
+		log.Printf("UpdateUserProfile: Failed to update user profile: %v", err)
+		return fmt.Errorf("failed to update user profile: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("no user found with ID: %s", userID)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) UpdateUserPreferences(ctx context.Context, userID string, preferences model.UserPreferences) error {
+	found, err := s.updateItem(ctx, dynamoUsersTable, userID,
+		"SET preferences = :preferences, updatedAt = :now",
+		nil,
+		map[string]any{":preferences": preferences, ":now": time.Now().UnixMilli()},
+	)
+	if err != nil {
+		log.Printf("UpdateUserPreferences: Failed to update user preferences: %v", err)
+		return fmt.Errorf("failed to update user preferences: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("no user found with ID: %s", userID)
+	}
+	return nil
+}
+
+// GetDormantUsers scans active users and looks up each one's last activity and dormancy warning
+func (s *DynamoDBService) GetDormantUsers(ctx context.Context, inactiveSince int64) ([]model.Luna4UserActivity, error) {
+	users, err := scanDynamoTable[model.Luna4User](ctx, s, dynamoUsersTable,
+		"#status = :status",
+		map[string]string{"#status": "status"},
+		map[string]any{":status": model.UserStatusActive},
+	)
+	if err != nil {
+		log.Printf("GetDormantUsers: Scan failed: %v", err)
+		return nil, fmt.Errorf("failed to query dormant users: %w", err)
+	}
+
+	var dormant []model.Luna4UserActivity
+	for _, user := range users {
+		activity := model.Luna4UserActivity{Luna4User: user, LastActiveAt: user.CreatedAt}
+
+		lastActiveAt, err := s.lastActiveAt(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if lastActiveAt != nil {
+			activity.LastActiveAt = *lastActiveAt
+		}
+		if activity.LastActiveAt >= inactiveSince {
+			continue
+		}
+
+		logs, err := queryDynamoIndex[model.Luna4AuditLog](ctx, s, dynamoAuditLogTable, "userId-index", "userId", user.ID, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query audit logs: %w", err)
+		}
+		for _, entry := range logs {
+			if entry.Action == model.AuditActionDormancyWarning {
+				warnedAt := entry.CreatedAt
+				activity.DormancyWarnedAt = &warnedAt
+				break
+			}
+		}
+
+		dormant = append(dormant, activity)
+	}
+
+	sort.Slice(dormant, func(i, j int) bool { return dormant[i].LastActiveAt < dormant[j].LastActiveAt })
+	log.Printf("GetDormantUsers: Found %d dormant users", len(dormant))
+	return dormant, nil
+}
+
+// lastActiveAt returns the time of the user's latest completed email login, session or
+// passkey sign-in, or nil if there was none
+func (s *DynamoDBService) lastActiveAt(ctx context.Context, userID string) (*int64, error) {
+	var lastActiveAt *int64
+	seen := func(at int64) {
+		if lastActiveAt == nil || at > *lastActiveAt {
+			lastActiveAt = &at
+		}
+	}
+
+	emailAuths, err := s.GetUserEmailAuths(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, emailAuth := range emailAuths {
+		if emailAuth.Completed {
+			seen(emailAuth.SentAt)
+		}
+	}
+
+	sessions, err := s.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		seen(session.CreatedAt)
+	}
+
+	credentials, err := s.GetUserWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, credential := range credentials {
+		if credential.LastUsedAt != nil {
+			seen(*credential.LastUsedAt)
+		}
+	}
+	return lastActiveAt, nil
+}
+
+func (s *DynamoDBService) GetDeletedUsers(ctx context.Context, deletedBefore int64) ([]*model.Luna4User, error) {
+	users, err := scanDynamoTable[*model.Luna4User](ctx, s, dynamoUsersTable,
+		"#status = :status AND statusChangedAt < :before",
+		map[string]string{"#status": "status"},
+		map[string]any{":status": model.UserStatusDeleted, ":before": deletedBefore},
+	)
+	if err != nil {
+		log.Printf("GetDeletedUsers: Scan failed: %v", err)
+		return nil, fmt.Errorf("failed to query deleted users: %w", err)
+	}
+
+	sort.Slice(users, func(i, j int) bool { return *users[i].StatusChangedAt < *users[j].StatusChangedAt })
+	return users, nil
+}
+
+func (s *DynamoDBService) DeleteUserData(ctx context.Context, userID, pseudonym string) error {
+	log.Printf("DeleteUserData: Purging user with ID: %s", userID)
+
+	// Details are free text and may identify the user, so only the action and time survive
+	subjectLogs, err := queryDynamoIndex[model.Luna4AuditLog](ctx, s, dynamoAuditLogTable, "userId-index", "userId", userID, 0)
+	if err != nil {
+		return fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	for _, entry := range subjectLogs {
+		_, err := s.updateItem(ctx, dynamoAuditLogTable, entry.ID,
+			"SET userId = :pseudonym, detail = :empty",
+			nil,
+			map[string]any{":pseudonym": pseudonym, ":empty": ""},
+		)
+		if err != nil {
+			log.Printf("DeleteUserData: Failed to anonymize audit logs: %v", err)
+			return fmt.Errorf("failed to anonymize audit logs: %w", err)
+		}
+	}
+
+	actorLogs, err := queryDynamoIndex[model.Luna4AuditLog](ctx, s, dynamoAuditLogTable, "actor-index", "actor", userID, 0)
+	if err != nil {
+		return fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	for _, entry := range actorLogs {
+		_, err := s.updateItem(ctx, dynamoAuditLogTable, entry.ID,
+			"SET actor = :pseudonym",
+			nil,
+			map[string]any{":pseudonym": pseudonym},
+		)
+		if err != nil {
+			log.Printf("DeleteUserData: Failed to anonymize audit log actors: %v", err)
+			return fmt.Errorf("failed to anonymize audit logs: %w", err)
+		}
+	}
+
+	for _, table := range []string{dynamoEmailAuthTable, dynamoUserServiceTable, dynamoSessionTable, dynamoEmailChangeTable, dynamoWebAuthnTable} {
+		items, err := queryDynamoIndex[struct {
+			ID string `dynamodbav:"id"`
+		}](ctx, s, table, "userId-index", "userId", userID, 0)
+		if err != nil {
+			return fmt.Errorf("failed to query user data: %w", err)
+		}
+		for _, item := range items {
+			if _, err := s.deleteItem(ctx, table, item.ID); err != nil {
+				log.Printf("DeleteUserData: Failed to delete user data: %v", err)
+				return fmt.Errorf("failed to delete user data: %w", err)
+			}
+		}
+	}
+
+	// Mail and delivery events are keyed by address; suppressions are kept so the address
+	// is never mailed again
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user != nil {
+		email := normalizeEmail(user.Email)
+		events, err := queryDynamoIndex[model.Luna4DeliveryEvent](ctx, s, dynamoDeliveryTable, "email-index", "email", email, 0)
+		if err != nil {
+			return fmt.Errorf("failed to query delivery events: %w", err)
+		}
+		emails, err := scanDynamoTable[model.Luna4OutboxEmail](ctx, s, dynamoOutboxEmailTable, "recipient = :recipient", nil, map[string]any{":recipient": user.Email})
+		if err != nil {
+			return fmt.Errorf("failed to scan outbox emails: %w", err)
+		}
+
+		for _, event := range events {
+			if _, err := s.deleteItem(ctx, dynamoDeliveryTable, event.ID); err != nil {
+				log.Printf("DeleteUserData: Failed to delete user data: %v", err)
+				return fmt.Errorf("failed to delete user data: %w", err)
+			}
+		}
+		for _, email := range emails {
+			if _, err := s.deleteItem(ctx, dynamoOutboxEmailTable, email.ID); err != nil {
+				log.Printf("DeleteUserData: Failed to delete user data: %v", err)
+				return fmt.Errorf("failed to delete user data: %w", err)
+			}
+		}
+	}
+
+	// The SCIM link is keyed by the user's ID
+	if _, err := s.deleteItem(ctx, dynamoScimUserTable, userID); err != nil {
+		log.Printf("DeleteUserData: Failed to delete user data: %v", err)
+		return fmt.Errorf("failed to delete user data: %w", err)
+	}
+
+	// Purging frees the address for a new account
+	if user != nil {
+		if _, err := s.deleteItem(ctx, dynamoUserEmailTable, user.Email); err != nil {
+			log.Printf("DeleteUserData: Failed to release email: %v", err)
+			return fmt.Errorf("failed to release email: %w", err)
+		}
+	}
+
+	found, err := s.deleteItem(ctx, dynamoUsersTable, userID)
+	if err != nil {
+		log.Printf("DeleteUserData: Failed to delete user: %v", err)
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if !found {
+		log.Printf("DeleteUserData: No user found with ID: %s", userID)
+		return fmt.Errorf("no user found with ID: %s", userID)
+	}
+
+	log.Printf("DeleteUserData: Successfully purged user with ID: %s", userID)
+	return nil
+}
+
+func (s *DynamoDBService) CreateEmailAuth(ctx context.Context, emailAuth *model.Luna4EmailAuth) error {
+	if err := s.putItem(ctx, dynamoEmailAuthTable, emailAuth); err != nil {
+		log.Printf("CreateEmailAuth: Failed to create email auth: %v", err)
+		return fmt.Errorf("failed to create email auth: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) GetLatestEmailAuth(ctx context.Context, userID string) (*model.Luna4EmailAuth, error) {
+	emailAuths, err := queryDynamoIndex[model.Luna4EmailAuth](ctx, s, dynamoEmailAuthTable, "userId-index", "userId", userID, 1)
+	if err != nil {
+		log.Printf("GetLatestEmailAuth: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to get latest email auth: %w", err)
+	}
+	if len(emailAuths) == 0 {
+		return nil, nil
+	}
+	return &emailAuths[0], nil
+}
+
+func (s *DynamoDBService) MarkEmailAuthCompleted(ctx context.Context, emailAuthID string) error {
+	err := s.updateItemOr(ctx, fmt.Errorf("%w: %s", ErrEmailAuthUsed, emailAuthID), dynamoEmailAuthTable, emailAuthID,
+		"SET completed = :completed",
+		"completed = :uncompleted",
+		nil,
+		map[string]any{":completed": true, ":uncompleted": false},
+	)
+	if err != nil && !errors.Is(err, ErrEmailAuthUsed) {
+		log.Printf("MarkEmailAuthCompleted: Failed to update email auth: %v", err)
+		return fmt.Errorf("failed to mark email auth as completed: %w", err)
+	}
+	return err
+}
+
+func (s *DynamoDBService) GetUserEmailAuths(ctx context.Context, userID string) ([]model.Luna4EmailAuth, error) {
+	emailAuths, err := queryDynamoIndex[model.Luna4EmailAuth](ctx, s, dynamoEmailAuthTable, "userId-index", "userId", userID, 0)
+	if err != nil {
+		log.Printf("GetUserEmailAuths: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query email auths: %w", err)
+	}
+	return emailAuths, nil
+}
+
+func (s *DynamoDBService) CreateUserService(ctx context.Context, userService *model.Luna4UserService) error {
+	if err := s.putItem(ctx, dynamoUserServiceTable, userService); err != nil {
+		log.Printf("CreateUserService: Failed to create user service: %v", err)
+		return fmt.Errorf("failed to create user service: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) GetUserServices(ctx context.Context, userID string) ([]model.Luna4UserService, error) {
+	services, err := queryDynamoIndex[model.Luna4UserService](ctx, s, dynamoUserServiceTable, "userId-index", "userId", userID, 0)
+	if err != nil {
+		log.Printf("GetUserServices: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query user services: %w", err)
+	}
+
+	sort.Slice(services, func(i, j int) bool { return services[i].Service < services[j].Service })
+	return services, nil
+}
+
+func (s *DynamoDBService) DeleteUserService(ctx context.Context, serviceID string) error {
+	found, err := s.deleteItem(ctx, dynamoUserServiceTable, serviceID)
+	if err != nil {
+		log.Printf("DeleteUserService: Failed to delete user service: %v", err)
+		return fmt.Errorf("failed to delete user service: %w", err)
+	}
+	if !found {
+		log.Printf("DeleteUserService: No service found with ID: %s", serviceID)
+		return fmt.Errorf("no user service found with ID: %s", serviceID)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) CreateAuditLog(ctx context.Context, userID, actor string, action model.AuditAction, detail string) error {
+	entry := &model.Luna4AuditLog{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Actor:     actor,
+		Action:    action,
+		Detail:    detail,
+		CreatedAt: time.Now().UnixMilli(),
+	}
+	if err := s.putItem(ctx, dynamoAuditLogTable, entry); err != nil {
+		log.Printf("CreateAuditLog: Failed to create audit log: %v", err)
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+	return nil
+}
+
+// GetUserAuditLogs returns audit entries about the user and entries for actions the user took
+func (s *DynamoDBService) GetUserAuditLogs(ctx context.Context, userID string) ([]model.Luna4AuditLog, error) {
+	logs, err := queryDynamoIndex[model.Luna4AuditLog](ctx, s, dynamoAuditLogTable, "userId-index", "userId", userID, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit logs: %w", err)
+	}
+
+	actorLogs, err := queryDynamoIndex[model.Luna4AuditLog](ctx, s, dynamoAuditLogTable, "actor-index", "actor", userID, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	for _, entry := range actorLogs {
+		// Entries the user made about themselves are already included
+		if entry.UserID != userID {
+			logs = append(logs, entry)
+		}
+	}
+
+	sort.Slice(logs, func(i, j int) bool { return logs[i].CreatedAt > logs[j].CreatedAt })
+	return logs, nil
+}
+
+func (s *DynamoDBService) CreateSession(ctx context.Context, session *model.Luna4Session) error {
+	if err := s.putItem(ctx, dynamoSessionTable, session); err != nil {
+		log.Printf("CreateSession: Failed to create session: %v", err)
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) GetSession(ctx context.Context, sessionID string) (*model.Luna4Session, error) {
+	session, err := getDynamoItem[model.Luna4Session](ctx, s, dynamoSessionTable, sessionID)
+	if err != nil {
+		log.Printf("GetSession: Failed to get session: %v", err)
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return session, nil
+}
+
+func (s *DynamoDBService) GetUserSessions(ctx context.Context, userID string) ([]model.Luna4Session, error) {
+	sessions, err := queryDynamoIndex[model.Luna4Session](ctx, s, dynamoSessionTable, "userId-index", "userId", userID, 0)
+	if err != nil {
+		log.Printf("GetUserSessions: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeUserSessions ends every active session of the user and returns how many were revoked
+func (s *DynamoDBService) RevokeUserSessions(ctx context.Context, userID string) (int64, error) {
+	sessions, err := s.GetUserSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UnixMilli()
+	var revoked int64
+	for _, session := range sessions {
+		if !session.IsActive(now) {
+			continue
+		}
+
+		_, err := s.updateItem(ctx, dynamoSessionTable, session.ID,
+			"SET revokedAt = :now",
+			nil,
+			map[string]any{":now": now},
+		)
+		if err != nil {
+			log.Printf("RevokeUserSessions: Failed to revoke session %s: %v", session.ID, err)
+			return revoked, fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		revoked++
+	}
+
+	log.Printf("RevokeUserSessions: Revoked %d sessions for user %s", revoked, userID)
+	return revoked, nil
+}
+
+func (s *DynamoDBService) CreateEmailChange(ctx context.Context, change *model.Luna4EmailChange) error {
+	if err := s.putItem(ctx, dynamoEmailChangeTable, change); err != nil {
+		log.Printf("CreateEmailChange: Failed to create email change: %v", err)
+		return fmt.Errorf("failed to create email change: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) GetLatestEmailChange(ctx context.Context, userID string) (*model.Luna4EmailChange, error) {
+	return s.getEmailChange(ctx, "userId-index", "userId", userID)
+}
+
+func (s *DynamoDBService) GetEmailChangeByConfirmToken(ctx context.Context, tokenHash string) (*model.Luna4EmailChange, error) {
+	return s.getEmailChange(ctx, "confirmToken-index", "confirmToken", tokenHash)
+}
+
+func (s *DynamoDBService) GetEmailChangeByRevertToken(ctx context.Context, tokenHash string) (*model.Luna4EmailChange, error) {
+	return s.getEmailChange(ctx, "revertToken-index", "revertToken", tokenHash)
+}
+
+func (s *DynamoDBService) getEmailChange(ctx context.Context, index, key, value string) (*model.Luna4EmailChange, error) {
+	changes, err := queryDynamoIndex[model.Luna4EmailChange](ctx, s, dynamoEmailChangeTable, index, key, value, 1)
+	if err != nil {
+		log.Printf("getEmailChange: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to get email change: %w", err)
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return &changes[0], nil
+}
+
+func (s *DynamoDBService) GetUserEmailChanges(ctx context.Context, userID string) ([]model.Luna4EmailChange, error) {
+	changes, err := queryDynamoIndex[model.Luna4EmailChange](ctx, s, dynamoEmailChangeTable, "userId-index", "userId", userID, 0)
+	if err != nil {
+		log.Printf("GetUserEmailChanges: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query email changes: %w", err)
+	}
+	return changes, nil
+}
+
+func (s *DynamoDBService) MarkEmailChangeConfirmed(ctx context.Context, changeID string) error {
+	err := s.updateItemOr(ctx, ErrEmailChangeUsed, dynamoEmailChangeTable, changeID,
+		"SET confirmedAt = :now",
+		"attribute_exists(id) AND attribute_not_exists(confirmedAt) AND attribute_not_exists(revertedAt)",
+		nil,
+		map[string]any{":now": time.Now().UnixMilli()},
+	)
+	if err != nil && !errors.Is(err, ErrEmailChangeUsed) {
+		log.Printf("MarkEmailChangeConfirmed: Failed to update email change %s: %v", changeID, err)
+		return fmt.Errorf("failed to mark email change as confirmed: %w", err)
+	}
+	return err
+}
+
+func (s *DynamoDBService) MarkEmailChangeReverted(ctx context.Context, changeID string) error {
+	err := s.updateItemOr(ctx, ErrEmailChangeUsed, dynamoEmailChangeTable, changeID,
+		"SET revertedAt = :now",
+		"attribute_exists(id) AND attribute_not_exists(revertedAt)",
+		nil,
+		map[string]any{":now": time.Now().UnixMilli()},
+	)
+	if err != nil && !errors.Is(err, ErrEmailChangeUsed) {
+		log.Printf("MarkEmailChangeReverted: Failed to update email change %s: %v", changeID, err)
+		return fmt.Errorf("failed to mark email change as reverted: %w", err)
+	}
+	return err
+}
+
+func (s *DynamoDBService) CreateScimToken(ctx context.Context, token *model.Luna4ScimToken) error {
+	if err := s.putItem(ctx, dynamoScimTokenTable, token); err != nil {
+		log.Printf("CreateScimToken: Failed to create SCIM token: %v", err)
+		return fmt.Errorf("failed to create SCIM token: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) GetScimTokenByHash(ctx context.Context, tokenHash string) (*model.Luna4ScimToken, error) {
+	tokens, err := queryDynamoIndex[model.Luna4ScimToken](ctx, s, dynamoScimTokenTable, "tokenHash-index", "tokenHash", tokenHash, 1)
+	if err != nil {
+		log.Printf("GetScimTokenByHash: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to get SCIM token: %w", err)
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	return &tokens[0], nil
+}
+
+func (s *DynamoDBService) GetScimTokens(ctx context.Context) ([]model.Luna4ScimToken, error) {
+	tokens, err := scanDynamoTable[model.Luna4ScimToken](ctx, s, dynamoScimTokenTable, "", nil, nil)
+	if err != nil {
+		log.Printf("GetScimTokens: Scan failed: %v", err)
+		return nil, fmt.Errorf("failed to scan SCIM tokens: %w", err)
+	}
+
+	slices.SortFunc(tokens, func(a, b model.Luna4ScimToken) int {
+		return cmp.Or(cmp.Compare(a.Tenant, b.Tenant), cmp.Compare(a.CreatedAt, b.CreatedAt))
+	})
+	if tokens == nil {
+		tokens = []model.Luna4ScimToken{}
+	}
+	return tokens, nil
+}
+
+func (s *DynamoDBService) MarkScimTokenUsed(ctx context.Context, tokenID string, usedAt int64) error {
+	_, err := s.updateItem(ctx, dynamoScimTokenTable, tokenID,
+		"SET lastUsedAt = :now",
+		nil,
+		map[string]any{":now": usedAt},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark SCIM token as used: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) RevokeScimToken(ctx context.Context, tokenID string) error {
+	token, err := getDynamoItem[model.Luna4ScimToken](ctx, s, dynamoScimTokenTable, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to get SCIM token: %w", err)
+	}
+	if token == nil || token.RevokedAt != nil {
+		return ErrScimTokenNotFound
+	}
+
+	_, err = s.updateItem(ctx, dynamoScimTokenTable, tokenID,
+		"SET revokedAt = :now",
+		nil,
+		map[string]any{":now": time.Now().UnixMilli()},
+	)
+	if err != nil {
+		log.Printf("RevokeScimToken: Failed to revoke SCIM token: %v", err)
+		return fmt.Errorf("failed to revoke SCIM token: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) CreateScimUser(ctx context.Context, link *model.Luna4ScimUser) error {
+	if err := s.putItem(ctx, dynamoScimUserTable, link); err != nil {
+		log.Printf("CreateScimUser: Failed to link user %s to tenant %s: %v", link.UserID, link.Tenant, err)
+		return fmt.Errorf("failed to create SCIM user: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) GetScimUser(ctx context.Context, userID string) (*model.Luna4ScimUser, error) {
+	link, err := getDynamoItem[model.Luna4ScimUser](ctx, s, dynamoScimUserTable, userID)
+	if err != nil {
+		log.Printf("GetScimUser: Failed to get SCIM user: %v", err)
+		return nil, fmt.Errorf("failed to get SCIM user: %w", err)
+	}
+	return link, nil
+}
+
+func (s *DynamoDBService) UpdateScimUserExternalID(ctx context.Context, userID, externalID string) error {
+	_, err := s.updateItem(ctx, dynamoScimUserTable, userID,
+		"SET externalId = :externalId",
+		nil,
+		map[string]any{":externalId": externalID},
+	)
+	if err != nil {
+		log.Printf("UpdateScimUserExternalID: Failed to update SCIM user %s: %v", userID, err)
+		return fmt.Errorf("failed to update SCIM user: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) GetScimAccounts(ctx context.Context, tenant string) ([]model.Luna4ScimAccount, error) {
+	links, err := queryDynamoIndex[model.Luna4ScimUser](ctx, s, dynamoScimUserTable, "tenant-index", "tenant", tenant, 0)
+	if err != nil {
+		log.Printf("GetScimAccounts: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query SCIM users: %w", err)
+	}
+
+	// Oldest first, as the SQL stores order them, so pages stay stable across requests
+	slices.SortFunc(links, func(a, b model.Luna4ScimUser) int {
+		return cmp.Or(cmp.Compare(a.CreatedAt, b.CreatedAt), strings.Compare(a.UserID, b.UserID))
+	})
+
+	accounts := []model.Luna4ScimAccount{}
+	for _, link := range links {
+		user, err := s.GetUserByID(ctx, link.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil || user.Status == model.UserStatusDeleted {
+			continue
+		}
+
+		services, err := s.GetUserServices(ctx, link.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if services == nil {
+			services = []model.Luna4UserService{}
+		}
+
+		accounts = append(accounts, model.Luna4ScimAccount{
+			Luna4UserWithServices: model.Luna4UserWithServices{Luna4User: *user, Services: services},
+			ExternalID:            link.ExternalID,
+		})
+	}
+	return accounts, nil
+}
+
+func (s *DynamoDBService) CreateWebAuthnCredential(ctx context.Context, credential *model.Luna4WebAuthnCredential) error {
+	if err := s.putItem(ctx, dynamoWebAuthnTable, credential); err != nil {
+		log.Printf("CreateWebAuthnCredential: Failed to create passkey: %v", err)
+		return fmt.Errorf("failed to create passkey: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) GetWebAuthnCredential(ctx context.Context, credentialID string) (*model.Luna4WebAuthnCredential, error) {
+	credential, err := getDynamoItem[model.Luna4WebAuthnCredential](ctx, s, dynamoWebAuthnTable, credentialID)
+	if err != nil {
+		log.Printf("GetWebAuthnCredential: Failed to get passkey: %v", err)
+		return nil, fmt.Errorf("failed to get passkey: %w", err)
+	}
+	return credential, nil
+}
+
+func (s *DynamoDBService) GetUserWebAuthnCredentials(ctx context.Context, userID string) ([]model.Luna4WebAuthnCredential, error) {
+	credentials, err := queryDynamoIndex[model.Luna4WebAuthnCredential](ctx, s, dynamoWebAuthnTable, "userId-index", "userId", userID, 0)
+	if err != nil {
+		log.Printf("GetUserWebAuthnCredentials: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query passkeys: %w", err)
+	}
+
+	// The index returns newest first
+	slices.Reverse(credentials)
+	if credentials == nil {
+		credentials = []model.Luna4WebAuthnCredential{}
+	}
+	return credentials, nil
+}
+
+func (s *DynamoDBService) MarkWebAuthnCredentialUsed(ctx context.Context, credentialID string, signCount uint32, backedUp bool, usedAt int64) error {
+	_, err := s.updateItem(ctx, dynamoWebAuthnTable, credentialID,
+		"SET signCount = :signCount, backedUp = :backedUp, lastUsedAt = :now",
+		nil,
+		map[string]any{":signCount": signCount, ":backedUp": backedUp, ":now": usedAt},
+	)
+	if err != nil {
+		log.Printf("MarkWebAuthnCredentialUsed: Failed to update passkey %s: %v", credentialID, err)
+		return fmt.Errorf("failed to mark passkey as used: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) DeleteWebAuthnCredential(ctx context.Context, credentialID string) error {
+	found, err := s.deleteItem(ctx, dynamoWebAuthnTable, credentialID)
+	if err != nil {
+		log.Printf("DeleteWebAuthnCredential: Failed to delete passkey: %v", err)
+		return fmt.Errorf("failed to delete passkey: %w", err)
+	}
+	if !found {
+		return ErrPasskeyNotFound
+	}
+	return nil
+}
+
+// dynamoWebAuthnChallenge carries the TTL attribute, in epoch seconds, that lets DynamoDB
+// delete challenges that expired unused
+type dynamoWebAuthnChallenge struct {
+	model.Luna4WebAuthnChallenge
+	TTL int64 `dynamodbav:"ttl"`
+}
+
+func (s *DynamoDBService) CreateWebAuthnChallenge(ctx context.Context, challenge *model.Luna4WebAuthnChallenge) error {
+	item := dynamoWebAuthnChallenge{Luna4WebAuthnChallenge: *challenge, TTL: challenge.ExpiresAt/1000 + 1}
+	if err := s.putItem(ctx, dynamoChallengeTable, item); err != nil {
+		log.Printf("CreateWebAuthnChallenge: Failed to create challenge: %v", err)
+		return fmt.Errorf("failed to create passkey challenge: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) ConsumeWebAuthnChallenge(ctx context.Context, challengeID string) (*model.Luna4WebAuthnChallenge, error) {
+	item, err := getDynamoItem[dynamoWebAuthnChallenge](ctx, s, dynamoChallengeTable, challengeID)
+	if err != nil {
+		log.Printf("ConsumeWebAuthnChallenge: Failed to get challenge: %v", err)
+		return nil, fmt.Errorf("failed to get passkey challenge: %w", err)
+	}
+	if item == nil {
+		return nil, nil
+	}
+
+	// Only the request whose delete finds the item consumes it
+	found, err := s.deleteItem(ctx, dynamoChallengeTable, challengeID)
+	if err != nil {
+		log.Printf("ConsumeWebAuthnChallenge: Failed to delete challenge: %v", err)
+		return nil, fmt.Errorf("failed to consume passkey challenge: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return &item.Luna4WebAuthnChallenge, nil
+}
+
+func (s *DynamoDBService) CreateOutboxEmail(ctx context.Context, email *model.Luna4OutboxEmail) error {
+	log.Printf("CreateOutboxEmail: Queueing email %s (%s)", email.ID, email.IdempotencyKey)
+	if err := s.putItem(ctx, dynamoOutboxEmailTable, email); err != nil {
+		log.Printf("CreateOutboxEmail: Failed to queue email: %v", err)
+		return fmt.Errorf("failed to queue email: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) GetOutboxEmail(ctx context.Context, emailID string) (*model.Luna4OutboxEmail, error) {
+	email, err := getDynamoItem[model.Luna4OutboxEmail](ctx, s, dynamoOutboxEmailTable, emailID)
+	if err != nil {
+		log.Printf("GetOutboxEmail: Failed to get outbox email: %v", err)
+		return nil, fmt.Errorf("failed to get outbox email: %w", err)
+	}
+	return email, nil
+}
+
+func (s *DynamoDBService) GetOutboxEmailByKey(ctx context.Context, idempotencyKey string) (*model.Luna4OutboxEmail, error) {
+	emails, err := queryDynamoIndex[model.Luna4OutboxEmail](ctx, s, dynamoOutboxEmailTable, "idempotencyKey-index", "idempotencyKey", idempotencyKey, 1)
+	if err != nil {
+		log.Printf("GetOutboxEmailByKey: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to get outbox email: %w", err)
+	}
+	if len(emails) == 0 {
+		return nil, nil
+	}
+	return &emails[0], nil
+}
+
+func (s *DynamoDBService) GetOutboxEmails(ctx context.Context, status model.OutboxStatus, limit int) ([]model.Luna4OutboxEmail, error) {
+	emails, err := queryDynamoIndex[model.Luna4OutboxEmail](ctx, s, dynamoOutboxEmailTable, "status-index", "status", string(status), 0)
+	if err != nil {
+		log.Printf("GetOutboxEmails: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query outbox emails: %w", err)
+	}
+
+	sort.Slice(emails, func(i, j int) bool { return emails[i].CreatedAt > emails[j].CreatedAt })
+	if len(emails) > limit {
+		emails = emails[:limit]
+	}
+	if emails == nil {
+		emails = []model.Luna4OutboxEmail{}
+	}
+	return emails, nil
+}
+
+func (s *DynamoDBService) GetDueOutboxEmails(ctx context.Context, now int64, limit int) ([]model.Luna4OutboxEmail, error) {
+	emails, err := queryDynamoIndexUpTo[model.Luna4OutboxEmail](ctx, s, dynamoOutboxEmailTable, "status-index", "status", string(model.OutboxStatusPending), "nextAttemptAt", now, int32(limit))
+	if err != nil {
+		log.Printf("GetDueOutboxEmails: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query outbox emails: %w", err)
+	}
+	return emails, nil
+}
+
+func (s *DynamoDBService) ClaimOutboxEmail(ctx context.Context, emailID string, now, claimUntil int64) (bool, error) {
+	claimed, err := s.updateItemIf(ctx, dynamoOutboxEmailTable, emailID,
+		"SET nextAttemptAt = :claimUntil",
+		"#status = :pending AND nextAttemptAt <= :now",
+		map[string]string{"#status": "status"},
+		map[string]any{":claimUntil": claimUntil, ":pending": model.OutboxStatusPending, ":now": now},
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim outbox email: %w", err)
+	}
+	return claimed, nil
+}
+
+func (s *DynamoDBService) UpdateOutboxEmail(ctx context.Context, email *model.Luna4OutboxEmail) error {
+	update := "SET html = :html, #text = :text, #status = :status, attempts = :attempts, nextAttemptAt = :nextAttemptAt, lastError = :lastError"
+	values := map[string]any{
+		":html":          email.HTML,
+		":text":          email.Text,
+		":status":        email.Status,
+		":attempts":      email.Attempts,
+		":nextAttemptAt": email.NextAttemptAt,
+		":lastError":     email.LastError,
+	}
+	if email.SentAt != nil {
+		update += ", sentAt = :sentAt"
+		values[":sentAt"] = *email.SentAt
+	}
+
+	_, err := s.updateItem(ctx, dynamoOutboxEmailTable, email.ID, update, map[string]string{"#status": "status", "#text": "text"}, values)
+	if err != nil {
+		log.Printf("UpdateOutboxEmail: Failed to update outbox email %s: %v", email.ID, err)
+		return fmt.Errorf("failed to update outbox email: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) DeleteSentOutboxEmails(ctx context.Context, sentBefore int64) (int64, error) {
+	emails, err := scanDynamoTable[model.Luna4OutboxEmail](ctx, s, dynamoOutboxEmailTable,
+		"#status = :sent AND sentAt < :before",
+		map[string]string{"#status": "status"},
+		map[string]any{":sent": model.OutboxStatusSent, ":before": sentBefore},
+	)
+	if err != nil {
+		log.Printf("DeleteSentOutboxEmails: Scan failed: %v", err)
+		return 0, fmt.Errorf("failed to scan sent outbox emails: %w", err)
+	}
+
+	var deleted int64
+	for _, email := range emails {
+		if _, err := s.deleteItem(ctx, dynamoOutboxEmailTable, email.ID); err != nil {
+			return deleted, fmt.Errorf("failed to delete outbox email %s: %w", email.ID, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (s *DynamoDBService) CreateDeliveryEvent(ctx context.Context, event *model.Luna4DeliveryEvent) (bool, error) {
+	if err := s.putItem(ctx, dynamoDeliveryTable, event); err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return false, nil
+		}
+		log.Printf("CreateDeliveryEvent: Failed to record delivery event: %v", err)
+		return false, fmt.Errorf("failed to record delivery event: %w", err)
+	}
+	return true, nil
+}
+
+func (s *DynamoDBService) GetDeliveryEvents(ctx context.Context, email string, limit int) ([]model.Luna4DeliveryEvent, error) {
+	events, err := queryDynamoIndex[model.Luna4DeliveryEvent](ctx, s, dynamoDeliveryTable, "email-index", "email", email, int32(limit))
+	if err != nil {
+		log.Printf("GetDeliveryEvents: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query delivery events: %w", err)
+	}
+	return events, nil
+}
+
+func (s *DynamoDBService) CreateEmailSuppression(ctx context.Context, suppression *model.Luna4EmailSuppression) error {
+	if err := s.putItem(ctx, dynamoSuppressionTable, suppression); err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return nil
+		}
+		log.Printf("CreateEmailSuppression: Failed to suppress %s: %v", suppression.Email, err)
+		return fmt.Errorf("failed to suppress email: %w", err)
+	}
+	return nil
+}
+
+func (s *DynamoDBService) GetEmailSuppression(ctx context.Context, email string) (*model.Luna4EmailSuppression, error) {
+	suppression, err := getDynamoItem[model.Luna4EmailSuppression](ctx, s, dynamoSuppressionTable, email)
+	if err != nil {
+		log.Printf("GetEmailSuppression: Failed to get suppression: %v", err)
+		return nil, fmt.Errorf("failed to get email suppression: %w", err)
+	}
+	return suppression, nil
+}
+
+func (s *DynamoDBService) DeleteEmailSuppression(ctx context.Context, email string) error {
+	found, err := s.deleteItem(ctx, dynamoSuppressionTable, email)
+	if err != nil {
+		log.Printf("DeleteEmailSuppression: Failed to lift suppression of %s: %v", email, err)
+		return fmt.Errorf("failed to delete email suppression: %w", err)
+	}
+	if !found {
+		return ErrEmailSuppressionNotFound
+	}
+	return nil
+}
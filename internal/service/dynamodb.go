@@ -2,18 +2,257 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDB table names, prefixed with DYNAMODB_TABLE_PREFIX
+const (
+	dynamoUsersTable       = "Users"
+	dynamoUserEmailTable   = "UserEmail"
+	dynamoEmailAuthTable   = "EmailAuth"
+	dynamoUserServiceTable = "UserService"
+	dynamoAuditLogTable    = "AuditLog"
+	dynamoSessionTable     = "Session"
+	dynamoEmailChangeTable = "EmailChange"
+	dynamoScimTokenTable   = "ScimToken"
+	dynamoScimUserTable    = "ScimUser"
+	dynamoOutboxEmailTable = "OutboxEmail"
+	dynamoDeliveryTable    = "DeliveryEvent"
+	dynamoSuppressionTable = "EmailSuppression"
+	dynamoWebAuthnTable    = "WebAuthnCredential"
+	dynamoChallengeTable   = "WebAuthnChallenge"
+)
+
+type dynamoIndex struct {
+	name     string
+	hashKey  string
+	rangeKey string
+}
+
+type dynamoTable struct {
+	name    string
+	indexes []dynamoIndex
+
+	// ttlAttribute names the epoch seconds after which DynamoDB deletes an item
+	ttlAttribute string
+}
+
+// dynamoTables describes every table and global secondary index airlock queries.
+// Range keys are numeric timestamps, every other key is a string.
+var dynamoTables = []dynamoTable{
+	{name: dynamoUsersTable, indexes: []dynamoIndex{{name: "email-index", hashKey: "email"}}},
+	{name: dynamoUserEmailTable},
+	{name: dynamoEmailAuthTable, indexes: []dynamoIndex{{name: "userId-index", hashKey: "userId", rangeKey: "sentAt"}}},
+	{name: dynamoUserServiceTable, indexes: []dynamoIndex{{name: "userId-index", hashKey: "userId"}}},
+	{name: dynamoAuditLogTable, indexes: []dynamoIndex{
+		{name: "userId-index", hashKey: "userId", rangeKey: "createdAt"},
+		{name: "actor-index", hashKey: "actor", rangeKey: "createdAt"},
+	}},
+	{name: dynamoSessionTable, indexes: []dynamoIndex{{name: "userId-index", hashKey: "userId", rangeKey: "createdAt"}}},
+	{name: dynamoEmailChangeTable, indexes: []dynamoIndex{
+		{name: "userId-index", hashKey: "userId", rangeKey: "requestedAt"},
+		{name: "confirmToken-index", hashKey: "confirmToken"},
+		{name: "revertToken-index", hashKey: "revertToken"},
+	}},
+	{name: dynamoScimTokenTable, indexes: []dynamoIndex{{name: "tokenHash-index", hashKey: "tokenHash"}}},
+	{name: dynamoScimUserTable, indexes: []dynamoIndex{{name: "tenant-index", hashKey: "tenant", rangeKey: "createdAt"}}},
+	{name: dynamoOutboxEmailTable, indexes: []dynamoIndex{
+		{name: "idempotencyKey-index", hashKey: "idempotencyKey"},
+		{name: "status-index", hashKey: "status", rangeKey: "nextAttemptAt"},
+	}},
+	{name: dynamoDeliveryTable, indexes: []dynamoIndex{{name: "email-index", hashKey: "email", rangeKey: "createdAt"}}},
+	{name: dynamoSuppressionTable},
+	{name: dynamoWebAuthnTable, indexes: []dynamoIndex{{name: "userId-index", hashKey: "userId", rangeKey: "createdAt"}}},
+	{name: dynamoChallengeTable, ttlAttribute: "ttl"},
+}
+
+// maxDynamoTxWrites is the most writes one TransactWriteItems call accepts
+const maxDynamoTxWrites = 100
+
+var (
+	ErrTransactionUnsupported = errors.New("operation cannot run in a DynamoDB transaction")
+	ErrTransactionConflict    = errors.New("transaction was cancelled by a conflicting write")
 )
 
 type DynamoDBService struct {
-	client    *dynamodb.Client
-	tableName string
+	client      *dynamodb.Client
+	tablePrefix string
+
+	// tx collects the writes of the transaction the service is bound to, or is nil
+	tx *dynamoTx
+}
+
+// dynamoTx holds the writes of a transaction until they are committed together
+type dynamoTx struct {
+	writes []*dynamoWrite
+
+	// pending maps the table and ID of every item the transaction writes to its write
+	pending map[string]*dynamoWrite
+}
+
+// dynamoWrite is the one write a transaction makes to an item. Later writes to the same
+// item are merged into it, since TransactWriteItems writes each item at most once.
+type dynamoWrite struct {
+	table string
+	id    string
+
+	// put is the whole item to write, set the attributes to update; a write with
+	// neither deletes the item, or only checks the condition when check is set
+	put   map[string]types.AttributeValue
+	set   map[string]types.AttributeValue
+	check bool
+
+	// condition must hold for the item as it was before the transaction
+	condition string
+	names     map[string]string
+	values    map[string]types.AttributeValue
+
+	// conflict is returned when the condition fails at commit
+	conflict error
+}
+
+// exists reports whether the item exists once the write is applied
+func (w *dynamoWrite) exists() bool {
+	return w.put != nil || w.set != nil
+}
+
+// add queues the first write to an item. A transaction holds at most maxDynamoTxWrites.
+func (t *dynamoTx) add(w *dynamoWrite) error {
+	if len(t.writes) == maxDynamoTxWrites {
+		return fmt.Errorf("%w: more than %d writes", ErrTransactionUnsupported, maxDynamoTxWrites)
+	}
+	t.writes = append(t.writes, w)
+	t.pending[w.table+"/"+w.id] = w
+	return nil
+}
+
+// transactItem returns the TransactWriteItems entry of the write
+func (w *dynamoWrite) transactItem() types.TransactWriteItem {
+	table, condition := aws.String(w.table), aws.String(w.condition)
+
+	switch {
+	case w.put != nil:
+		return types.TransactWriteItem{Put: &types.Put{
+			TableName:                 table,
+			Item:                      w.put,
+			ConditionExpression:       condition,
+			ExpressionAttributeNames:  dynamoNames(w.names),
+			ExpressionAttributeValues: dynamoValues(w.values),
+		}}
+	case w.set != nil:
+		// The update's placeholders are rebuilt from the merged attributes
+		names, values := maps.Clone(w.names), maps.Clone(w.values)
+		if names == nil {
+			names = map[string]string{}
+		}
+		if values == nil {
+			values = map[string]types.AttributeValue{}
+		}
+		var assignments []string
+		for i, attribute := range slices.Sorted(maps.Keys(w.set)) {
+			name, value := fmt.Sprintf("#set%d", i), fmt.Sprintf(":set%d", i)
+			names[name], values[value] = attribute, w.set[attribute]
+			assignments = append(assignments, name+" = "+value)
+		}
+		return types.TransactWriteItem{Update: &types.Update{
+			TableName:                 table,
+			Key:                       dynamoKey(w.id),
+			UpdateExpression:          aws.String("SET " + strings.Join(assignments, ", ")),
+			ConditionExpression:       condition,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		}}
+	case w.check:
+		return types.TransactWriteItem{ConditionCheck: &types.ConditionCheck{
+			TableName:                 table,
+			Key:                       dynamoKey(w.id),
+			ConditionExpression:       condition,
+			ExpressionAttributeNames:  dynamoNames(w.names),
+			ExpressionAttributeValues: dynamoValues(w.values),
+		}}
+	default:
+		return types.TransactWriteItem{Delete: &types.Delete{
+			TableName:                 table,
+			Key:                       dynamoKey(w.id),
+			ConditionExpression:       condition,
+			ExpressionAttributeNames:  dynamoNames(w.names),
+			ExpressionAttributeValues: dynamoValues(w.values),
+		}}
+	}
+}
+
+// dynamoPlaceholder matches the attribute name and value placeholders of an expression
+var dynamoPlaceholder = regexp.MustCompile(`[#:][A-Za-z0-9_]+`)
+
+// conditionPlaceholders returns the names and values the condition refers to. DynamoDB
+// rejects placeholders an expression does not use.
+func conditionPlaceholders(condition string, names map[string]string, values map[string]types.AttributeValue) (map[string]string, map[string]types.AttributeValue) {
+	usedNames, usedValues := map[string]string{}, map[string]types.AttributeValue{}
+	for _, placeholder := range dynamoPlaceholder.FindAllString(condition, -1) {
+		if name, ok := names[placeholder]; ok {
+			usedNames[placeholder] = name
+		}
+		if value, ok := values[placeholder]; ok {
+			usedValues[placeholder] = value
+		}
+	}
+	return usedNames, usedValues
+}
+
+// parseDynamoSet returns the attributes an update expression of plain SET assignments
+// assigns, which is the only kind a transaction can merge
+func parseDynamoSet(update string, names map[string]string, values map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+	assignments, ok := strings.CutPrefix(update, "SET ")
+	if !ok {
+		return nil, fmt.Errorf("%w: update %q is not a SET", ErrTransactionUnsupported, update)
+	}
+
+	set := map[string]types.AttributeValue{}
+	for _, assignment := range strings.Split(assignments, ",") {
+		path, placeholder, _ := strings.Cut(assignment, "=")
+		path, placeholder = strings.TrimSpace(path), strings.TrimSpace(placeholder)
+		value, ok := values[placeholder]
+		if !ok {
+			return nil, fmt.Errorf("%w: update %q does not assign a value", ErrTransactionUnsupported, update)
+		}
+		if name, ok := names[path]; ok {
+			path = name
+		}
+		set[path] = value
+	}
+	return set, nil
+}
+
+func dynamoNames(names map[string]string) map[string]string {
+	if len(names) == 0 {
+		return nil
+	}
+	return names
+}
+
+func dynamoValues(values map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if len(values) == 0 {
+		return nil
+	}
+	return values
 }
 
-func NewDynamoDBService(tableName string) (*DynamoDBService, error) {
+func NewDynamoDBService(tablePrefix string) (*DynamoDBService, error) {
 	cfg, err := config.LoadDefaultConfig(context.TODO(),
 		config.WithRegion(os.Getenv("AWS_REGION")),
 	)
@@ -21,10 +260,499 @@ func NewDynamoDBService(tableName string) (*DynamoDBService, error) {
 		return nil, err
 	}
 
-	client := dynamodb.NewFromConfig(cfg)
+	client := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		// Point at DynamoDB Local or another compatible endpoint
+		if endpoint := os.Getenv("DYNAMODB_ENDPOINT"); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+
+	service := &DynamoDBService{
+		client:      client,
+		tablePrefix: tablePrefix,
+	}
+
+	if os.Getenv("DYNAMODB_CREATE_TABLES") == "true" {
+		if err := service.createTables(context.TODO()); err != nil {
+			return nil, fmt.Errorf("failed to create DynamoDB tables: %w", err)
+		}
+	}
+
+	return service, nil
+}
+
+// WithTx buffers the writes fn makes and commits them with one TransactWriteItems call when
+// fn returns nil. Conditions are checked again at commit, so a write a concurrent request
+// invalidated cancels the whole transaction. Reads of an item fn wrote return the pending
+// item; queries and scans see the tables as they were before the transaction. See
+// Store.WithTx for what DynamoDB cannot run in one.
+func (s *DynamoDBService) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	tx := &DynamoDBService{
+		client:      s.client,
+		tablePrefix: s.tablePrefix,
+		tx:          &dynamoTx{pending: map[string]*dynamoWrite{}},
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.commit(ctx)
+}
+
+// maxTxWrites reports how many items one transaction can write
+func (s *DynamoDBService) maxTxWrites() int {
+	return maxDynamoTxWrites
+}
+
+// inTx runs fn in a transaction on the DynamoDB store, joining the current one if any
+func (s *DynamoDBService) inTx(ctx context.Context, fn func(tx *DynamoDBService) error) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		return fn(tx.(*DynamoDBService))
+	})
+}
+
+// commit writes the buffered writes of the transaction atomically
+func (s *DynamoDBService) commit(ctx context.Context) error {
+	if len(s.tx.writes) == 0 {
+		return nil
+	}
+
+	items := make([]types.TransactWriteItem, len(s.tx.writes))
+	for i, w := range s.tx.writes {
+		items[i] = w.transactItem()
+	}
+	_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if err == nil {
+		return nil
+	}
+
+	var cancelled *types.TransactionCanceledException
+	if !errors.As(err, &cancelled) {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	for i, reason := range cancelled.CancellationReasons {
+		if aws.ToString(reason.Code) == "ConditionalCheckFailed" && i < len(s.tx.writes) && s.tx.writes[i].conflict != nil {
+			return s.tx.writes[i].conflict
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrTransactionConflict, cancelled.ErrorMessage())
+}
+
+func (s *DynamoDBService) Close() error {
+	return nil
+}
+
+func (s *DynamoDBService) table(name string) *string {
+	return aws.String(s.tablePrefix + name)
+}
+
+// createTables creates any missing table with on-demand billing
+func (s *DynamoDBService) createTables(ctx context.Context) error {
+	for _, table := range dynamoTables {
+		attributes := map[string]types.ScalarAttributeType{"id": types.ScalarAttributeTypeS}
+		var indexes []types.GlobalSecondaryIndex
+		for _, index := range table.indexes {
+			attributes[index.hashKey] = types.ScalarAttributeTypeS
+			keySchema := []types.KeySchemaElement{{AttributeName: aws.String(index.hashKey), KeyType: types.KeyTypeHash}}
+			if index.rangeKey != "" {
+				attributes[index.rangeKey] = types.ScalarAttributeTypeN
+				keySchema = append(keySchema, types.KeySchemaElement{AttributeName: aws.String(index.rangeKey), KeyType: types.KeyTypeRange})
+			}
+			indexes = append(indexes, types.GlobalSecondaryIndex{
+				IndexName:  aws.String(index.name),
+				KeySchema:  keySchema,
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			})
+		}
 
-	return &DynamoDBService{
-		client:    client,
-		tableName: tableName,
-	}, nil
+		var definitions []types.AttributeDefinition
+		for name, attributeType := range attributes {
+			definitions = append(definitions, types.AttributeDefinition{AttributeName: aws.String(name), AttributeType: attributeType})
+		}
+
+		_, err := s.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+			TableName:              s.table(table.name),
+			AttributeDefinitions:   definitions,
+			KeySchema:              []types.KeySchemaElement{{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash}},
+			GlobalSecondaryIndexes: indexes,
+			BillingMode:            types.BillingModePayPerRequest,
+		})
+		if err != nil {
+			var inUse *types.ResourceInUseException
+			if errors.As(err, &inUse) {
+				continue
+			}
+			return fmt.Errorf("failed to create table %s: %w", *s.table(table.name), err)
+		}
+		log.Printf("Created DynamoDB table %s", *s.table(table.name))
+
+		if table.ttlAttribute != "" {
+			waiter := dynamodb.NewTableExistsWaiter(s.client)
+			if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: s.table(table.name)}, 2*time.Minute); err != nil {
+				return fmt.Errorf("failed to wait for table %s: %w", *s.table(table.name), err)
+			}
+			_, err := s.client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+				TableName: s.table(table.name),
+				TimeToLiveSpecification: &types.TimeToLiveSpecification{
+					AttributeName: aws.String(table.ttlAttribute),
+					Enabled:       aws.Bool(true),
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to enable TTL on table %s: %w", *s.table(table.name), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// putItem creates an item. It fails with a ConditionalCheckFailedException when an item
+// with the same ID exists.
+func (s *DynamoDBService) putItem(ctx context.Context, table string, item any) error {
+	return s.putItemOr(ctx, nil, table, item)
+}
+
+// putItemOr creates an item and returns conflict, when it is not nil, if an item with the
+// same ID exists, including when a transaction finds one at commit
+func (s *DynamoDBService) putItemOr(ctx context.Context, conflict error, table string, item any) error {
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal item: %w", err)
+	}
+	exists := error(&types.ConditionalCheckFailedException{Message: aws.String("item already exists")})
+	if conflict != nil {
+		exists = conflict
+	}
+
+	if s.tx != nil {
+		id, _ := av["id"].(*types.AttributeValueMemberS)
+		if id == nil {
+			return fmt.Errorf("item has no string id")
+		}
+		found, err := s.itemExists(ctx, table, id.Value)
+		if err != nil {
+			return err
+		}
+		if found {
+			return exists
+		}
+		// An item the transaction deleted is written again in place of the delete
+		if w := s.tx.pending[*s.table(table)+"/"+id.Value]; w != nil {
+			w.put, w.check = av, false
+			return nil
+		}
+		return s.tx.add(&dynamoWrite{
+			table:     *s.table(table),
+			id:        id.Value,
+			put:       av,
+			condition: "attribute_not_exists(id)",
+			conflict:  conflict,
+		})
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           s.table(table),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if conflict != nil && errors.As(err, &conditionFailed) {
+		return conflict
+	}
+	return err
+}
+
+// updateItem applies the update expression to an existing item and reports
+// whether the item was found
+func (s *DynamoDBService) updateItem(ctx context.Context, table, id, update string, names map[string]string, values map[string]any) (bool, error) {
+	if s.tx != nil {
+		exists, err := s.itemExists(ctx, table, id)
+		if err != nil || !exists {
+			return false, err
+		}
+	}
+	return s.updateItemIf(ctx, table, id, update, "attribute_exists(id)", names, values)
+}
+
+// updateItemIf applies the update expression when the condition holds and reports whether
+// it did. In a transaction the condition is only checked at commit, so it reports true.
+func (s *DynamoDBService) updateItemIf(ctx context.Context, table, id, update, condition string, names map[string]string, values map[string]any) (bool, error) {
+	return s.conditionalUpdate(ctx, table, id, update, condition, names, values, nil)
+}
+
+// updateItemOr applies the update expression when the condition holds and returns conflict
+// when it does not, including when a transaction finds it no longer holds at commit
+func (s *DynamoDBService) updateItemOr(ctx context.Context, conflict error, table, id, update, condition string, names map[string]string, values map[string]any) error {
+	updated, err := s.conditionalUpdate(ctx, table, id, update, condition, names, values, conflict)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return conflict
+	}
+	return nil
+}
+
+func (s *DynamoDBService) conditionalUpdate(ctx context.Context, table, id, update, condition string, names map[string]string, values map[string]any, conflict error) (bool, error) {
+	av, err := attributevalue.MarshalMap(values)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal values: %w", err)
+	}
+	if len(names) == 0 {
+		names = nil
+	}
+
+	if s.tx != nil {
+		set, err := parseDynamoSet(update, names, av)
+		if err != nil {
+			return false, err
+		}
+
+		// A second update of an item is merged into the first. Only the existence check
+		// can be evaluated against the pending item, so other conditions cannot follow.
+		if w := s.tx.pending[*s.table(table)+"/"+id]; w != nil {
+			if condition != "attribute_exists(id)" {
+				return false, fmt.Errorf("%w: conditional update of item %s/%s after it was written", ErrTransactionUnsupported, table, id)
+			}
+			target := w.set
+			if w.put != nil {
+				target = w.put
+			}
+			maps.Copy(target, set)
+			return true, nil
+		}
+
+		conditionNames, conditionValues := conditionPlaceholders(condition, names, av)
+		return true, s.tx.add(&dynamoWrite{
+			table:     *s.table(table),
+			id:        id,
+			set:       set,
+			condition: condition,
+			names:     conditionNames,
+			values:    conditionValues,
+			conflict:  conflict,
+		})
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 s.table(table),
+		Key:                       dynamoKey(id),
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: av,
+	}
+
+	if _, err := s.client.UpdateItem(ctx, input); err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// deleteItem removes an item and reports whether it existed
+func (s *DynamoDBService) deleteItem(ctx context.Context, table, id string) (bool, error) {
+	if s.tx != nil {
+		exists, err := s.itemExists(ctx, table, id)
+		if err != nil || !exists {
+			return false, err
+		}
+
+		// Deleting an item the transaction wrote replaces that write. An item it created
+		// is only checked to still be missing.
+		if w := s.tx.pending[*s.table(table)+"/"+id]; w != nil {
+			w.check = w.put != nil && w.condition == "attribute_not_exists(id)"
+			w.put, w.set = nil, nil
+			return true, nil
+		}
+		return true, s.tx.add(&dynamoWrite{
+			table:     *s.table(table),
+			id:        id,
+			condition: "attribute_exists(id)",
+		})
+	}
+
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           s.table(table),
+		Key:                 dynamoKey(id),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// itemExists reports whether the table has an item with the ID, counting the pending
+// writes of a transaction
+func (s *DynamoDBService) itemExists(ctx context.Context, table, id string) (bool, error) {
+	if s.tx != nil {
+		if w := s.tx.pending[*s.table(table)+"/"+id]; w != nil {
+			return w.exists(), nil
+		}
+	}
+
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:            s.table(table),
+		Key:                  dynamoKey(id),
+		ConsistentRead:       aws.Bool(true),
+		ProjectionExpression: aws.String("id"),
+	})
+	if err != nil {
+		return false, err
+	}
+	return output.Item != nil, nil
+}
+
+func dynamoKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: id}}
+}
+
+// getDynamoItem returns the item with the given ID, or nil if there is none. In a
+// transaction an item it already wrote is returned as the transaction will leave it.
+func getDynamoItem[T any](ctx context.Context, s *DynamoDBService, table, id string) (*T, error) {
+	var pending *dynamoWrite
+	if s.tx != nil {
+		pending = s.tx.pending[*s.table(table)+"/"+id]
+	}
+
+	var av map[string]types.AttributeValue
+	switch {
+	case pending != nil && !pending.exists():
+		return nil, nil
+	case pending != nil && pending.put != nil:
+		av = pending.put
+	default:
+		output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      s.table(table),
+			Key:            dynamoKey(id),
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if output.Item == nil {
+			return nil, nil
+		}
+		av = output.Item
+		if pending != nil {
+			maps.Copy(av, pending.set)
+		}
+	}
+
+	var item T
+	if err := attributevalue.UnmarshalMap(av, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal item: %w", err)
+	}
+	return &item, nil
+}
+
+// queryDynamoIndex returns the items whose index hash key equals value, newest first
+// when the index has a range key. A limit of zero returns every item.
+func queryDynamoIndex[T any](ctx context.Context, s *DynamoDBService, table, index, key, value string, limit int32) ([]T, error) {
+	input := &dynamodb.QueryInput{
+		TableName:                s.table(table),
+		IndexName:                aws.String(index),
+		KeyConditionExpression:   aws.String("#key = :value"),
+		ExpressionAttributeNames: map[string]string{"#key": key},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":value": &types.AttributeValueMemberS{Value: value},
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+	if limit > 0 {
+		input.Limit = aws.Int32(limit)
+	}
+
+	var items []T
+	paginator := dynamodb.NewQueryPaginator(s.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageItems []T
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageItems); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal items: %w", err)
+		}
+		items = append(items, pageItems...)
+
+		if limit > 0 && int32(len(items)) >= limit {
+			return items[:limit], nil
+		}
+	}
+	return items, nil
+}
+
+// queryDynamoIndexUpTo returns up to limit items whose index hash key equals value and
+// whose numeric range key is at most max, oldest first
+func queryDynamoIndexUpTo[T any](ctx context.Context, s *DynamoDBService, table, index, key, value, rangeKey string, max int64, limit int32) ([]T, error) {
+	input := &dynamodb.QueryInput{
+		TableName:                s.table(table),
+		IndexName:                aws.String(index),
+		KeyConditionExpression:   aws.String("#key = :value AND #range <= :max"),
+		ExpressionAttributeNames: map[string]string{"#key": key, "#range": rangeKey},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":value": &types.AttributeValueMemberS{Value: value},
+			":max":   &types.AttributeValueMemberN{Value: strconv.FormatInt(max, 10)},
+		},
+		ScanIndexForward: aws.Bool(true),
+		Limit:            aws.Int32(limit),
+	}
+
+	output, err := s.client.Query(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []T
+	if err := attributevalue.UnmarshalListOfMaps(output.Items, &items); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal items: %w", err)
+	}
+	return items, nil
+}
+
+// scanDynamoTable returns every item matching the filter expression
+func scanDynamoTable[T any](ctx context.Context, s *DynamoDBService, table, filter string, names map[string]string, values map[string]any) ([]T, error) {
+	input := &dynamodb.ScanInput{
+		TableName:      s.table(table),
+		ConsistentRead: aws.Bool(true),
+	}
+	if filter != "" {
+		av, err := attributevalue.MarshalMap(values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal values: %w", err)
+		}
+		input.FilterExpression = aws.String(filter)
+		input.ExpressionAttributeNames = names
+		input.ExpressionAttributeValues = av
+	}
+
+	var items []T
+	paginator := dynamodb.NewScanPaginator(s.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageItems []T
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageItems); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal items: %w", err)
+		}
+		items = append(items, pageItems...)
+	}
+	return items, nil
 }
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+// newTestDynamoDB creates the tables under a new prefix on the DynamoDB Local instance at
+// DYNAMODB_TEST_ENDPOINT, e.g. http://localhost:8000, and deletes them when the test ends.
+// The test is skipped when the variable is not set.
+func newTestDynamoDB(t *testing.T) *DynamoDBService {
+	t.Helper()
+	endpoint := os.Getenv("DYNAMODB_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_TEST_ENDPOINT is not set")
+	}
+
+	t.Setenv("DYNAMODB_ENDPOINT", endpoint)
+	t.Setenv("DYNAMODB_CREATE_TABLES", "true")
+	// DynamoDB Local accepts any region and credentials
+	for key, value := range map[string]string{"AWS_REGION": "us-east-1", "AWS_ACCESS_KEY_ID": "local", "AWS_SECRET_ACCESS_KEY": "local"} {
+		if os.Getenv(key) == "" {
+			t.Setenv(key, value)
+		}
+	}
+
+	prefix := "Test" + strings.ReplaceAll(uuid.New().String(), "-", "")[:12]
+	store, err := NewDynamoDBService(prefix)
+	if err != nil {
+		t.Fatalf("failed to create DynamoDB store: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, table := range dynamoTables {
+			store.client.DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: store.table(table.name)})
+		}
+	})
+	return store
+}
+
+func TestDynamoDBTransactionCommitsTogether(t *testing.T) {
+	ctx := context.Background()
+	store := newTestDynamoDB(t)
+	now := time.Now().UnixMilli()
+	user := &model.Luna4User{ID: uuid.New().String(), Email: "tx@example.com", Status: model.UserStatusActive, CreatedAt: now, UpdatedAt: now}
+	grant := model.Luna4UserService{ID: uuid.New().String(), UserID: user.ID, Service: model.Luna4ServicePrunk, Permission: model.UserServiceUser}
+
+	err := store.WithTx(ctx, func(tx Store) error {
+		if err := tx.CreateUser(ctx, user); err != nil {
+			return err
+		}
+		if err := tx.CreateUserService(ctx, &grant); err != nil {
+			return err
+		}
+
+		// Nothing is visible before the commit, but the transaction reads its own writes
+		if outside, err := store.GetUserByID(ctx, user.ID); err != nil || outside != nil {
+			t.Errorf("user is visible before commit: %v, %v", outside, err)
+		}
+		if inside, err := tx.GetUserByID(ctx, user.ID); err != nil || inside == nil || inside.Email != user.Email {
+			t.Errorf("transaction reads its user as %v (%v)", inside, err)
+		}
+
+		return tx.CreateAuditLog(ctx, user.ID, user.ID, model.AuditActionProfileUpdated, "")
+	})
+	if err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+
+	stored, err := store.GetUserByID(ctx, user.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("user was not committed: %v", err)
+	}
+	services, err := store.GetUserServices(ctx, user.ID)
+	if err != nil || len(services) != 1 {
+		t.Errorf("user has %d services (%v), want 1", len(services), err)
+	}
+	logs, err := store.GetUserAuditLogs(ctx, user.ID)
+	if err != nil || len(logs) != 1 {
+		t.Errorf("user has %d audit entries (%v), want 1", len(logs), err)
+	}
+}
+
+func TestDynamoDBTransactionRollsBack(t *testing.T) {
+	ctx := context.Background()
+	store := newTestDynamoDB(t)
+	now := time.Now().UnixMilli()
+	user := &model.Luna4User{ID: uuid.New().String(), Email: "rollback@example.com", Status: model.UserStatusActive, CreatedAt: now, UpdatedAt: now}
+	failure := errors.New("step failed")
+
+	err := store.WithTx(ctx, func(tx Store) error {
+		if err := tx.CreateUser(ctx, user); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("transaction returned %v, want the step's error", err)
+	}
+
+	stored, err := store.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("failed to read user: %v", err)
+	}
+	if stored != nil {
+		t.Error("user of a failed transaction was written")
+	}
+}
+
+func TestDynamoDBTransactionConflict(t *testing.T) {
+	ctx := context.Background()
+	store := newTestDynamoDB(t)
+	user := createTestUser(t, store, "conflict@example.com", model.UserStatusActive)
+	emailAuth := createTestEmailAuth(t, store, user.ID)
+	session := &model.Luna4Session{ID: uuid.New().String(), UserID: user.ID, CreatedAt: time.Now().UnixMilli(), ExpiresAt: time.Now().Add(time.Hour).UnixMilli()}
+
+	err := store.WithTx(ctx, func(tx Store) error {
+		if err := tx.MarkEmailAuthCompleted(ctx, emailAuth.ID); err != nil {
+			return err
+		}
+		if err := tx.CreateSession(ctx, session); err != nil {
+			return err
+		}
+
+		// A concurrent request redeems the same link before this transaction commits
+		return store.MarkEmailAuthCompleted(ctx, emailAuth.ID)
+	})
+	if !errors.Is(err, ErrEmailAuthUsed) {
+		t.Fatalf("transaction returned %v, want ErrEmailAuthUsed", err)
+	}
+
+	stored, err := store.GetSession(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("failed to read session: %v", err)
+	}
+	if stored != nil {
+		t.Error("session of a cancelled transaction was written")
+	}
+}
+
+func TestDynamoDBTransactionMergesWrites(t *testing.T) {
+	ctx := context.Background()
+	store := newTestDynamoDB(t)
+	user := createTestUser(t, store, "merge@example.com", model.UserStatusActive)
+	grant := model.Luna4UserService{ID: uuid.New().String(), UserID: user.ID, Service: model.Luna4ServicePrunk, Permission: model.UserServiceUser}
+
+	err := store.WithTx(ctx, func(tx Store) error {
+		if err := tx.UpdateUserProfile(ctx, user.ID, "Name", "", ""); err != nil {
+			return err
+		}
+		if err := tx.UpdateUserStatus(ctx, user.ID, model.UserStatusSuspended, ""); err != nil {
+			return err
+		}
+
+		// Reads see both pending updates of the user
+		pending, err := tx.GetUserByID(ctx, user.ID)
+		if err != nil || pending == nil || pending.Name != "Name" || pending.Status != model.UserStatusSuspended {
+			t.Errorf("transaction reads its user as %+v (%v)", pending, err)
+		}
+
+		// A grant created and deleted in the same transaction is never written
+		if err := tx.CreateUserService(ctx, &grant); err != nil {
+			return err
+		}
+		return tx.DeleteUserService(ctx, grant.ID)
+	})
+	if err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+
+	stored, err := store.GetUserByID(ctx, user.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read user: %v", err)
+	}
+	if stored.Name != "Name" || stored.Status != model.UserStatusSuspended {
+		t.Errorf("user is %+v, want both updates", stored)
+	}
+	if services, err := store.GetUserServices(ctx, user.ID); err != nil || len(services) != 0 {
+		t.Errorf("user has %d services (%v), want none", len(services), err)
+	}
+}
+
+func TestDynamoDBTransactionLimits(t *testing.T) {
+	ctx := context.Background()
+	store := newTestDynamoDB(t)
+	user := createTestUser(t, store, "limits@example.com", model.UserStatusActive)
+
+	err := store.WithTx(ctx, func(tx Store) error {
+		if err := tx.UpdateUserProfile(ctx, user.ID, "Name", "", ""); err != nil {
+			return err
+		}
+		for range maxDynamoTxWrites {
+			if err := tx.CreateAuditLog(ctx, user.ID, user.ID, model.AuditActionProfileUpdated, ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !errors.Is(err, ErrTransactionUnsupported) {
+		t.Errorf("writing %d items returned %v, want ErrTransactionUnsupported", maxDynamoTxWrites+1, err)
+	}
+
+	// The transaction wrote nothing
+	stored, err := store.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("failed to read user: %v", err)
+	}
+	if stored.Name != "" {
+		t.Errorf("user changed by a rejected transaction: %+v", stored)
+	}
+	logs, err := store.GetUserAuditLogs(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("failed to read audit logs: %v", err)
+	}
+	if len(logs) != 0 {
+		t.Errorf("rejected transaction wrote %d audit entries", len(logs))
+	}
+}
+
+func TestDynamoDBEmailIsUnique(t *testing.T) {
+	ctx := context.Background()
+	store := newTestDynamoDB(t)
+
+	// Concurrent requests for one address all pass the email index check
+	const requests = 5
+	errs := make(chan error, requests)
+	for range requests {
+		go func() {
+			now := time.Now().UnixMilli()
+			errs <- store.CreateUser(ctx, &model.Luna4User{ID: uuid.New().String(), Email: "race@example.com", Status: model.UserStatusActive, CreatedAt: now, UpdatedAt: now})
+		}()
+	}
+	var created int
+	for range requests {
+		if err := <-errs; err == nil {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d users were created with the same email, want 1", created)
+	}
+
+	// Changing an email moves the claim, and purging releases it
+	user, err := store.GetUserByEmail(ctx, "race@example.com")
+	if err != nil || user == nil {
+		t.Fatalf("failed to find user: %v", err)
+	}
+	other := createTestUser(t, store, "other@example.com", model.UserStatusActive)
+	if err := store.UpdateUserEmail(ctx, other.ID, "race@example.com"); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("taking a claimed email returned %v, want ErrEmailTaken", err)
+	}
+	if err := store.UpdateUserEmail(ctx, user.ID, "moved@example.com"); err != nil {
+		t.Fatalf("UpdateUserEmail failed: %v", err)
+	}
+	if err := store.UpdateUserEmail(ctx, other.ID, "race@example.com"); err != nil {
+		t.Errorf("taking a released email failed: %v", err)
+	}
+	if err := store.DeleteUserData(ctx, user.ID, "pseudonym"); err != nil {
+		t.Fatalf("DeleteUserData failed: %v", err)
+	}
+	createTestUser(t, store, "moved@example.com", model.UserStatusActive)
+}
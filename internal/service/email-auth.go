@@ -3,13 +3,20 @@ package service
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/brand"
 	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/util"
 )
 
-func (s *SQLiteService) CreateEmailAuth(ctx context.Context, emailAuth *model.Luna4EmailAuth) error {
+var ErrEmailAuthUsed = errors.New("email auth does not exist or was already used")
+
+func (s *sqlStore) CreateEmailAuth(ctx context.Context, emailAuth *model.Luna4EmailAuth) error {
 	log.Printf("CreateEmailAuth: Creating email auth for user %s with ID: %s", emailAuth.UserID, emailAuth.ID)
 	query := `
 		INSERT INTO luna4_email_auth (id, user_id, token, sent_at, completed)
@@ -33,7 +40,7 @@ func (s *SQLiteService) CreateEmailAuth(ctx context.Context, emailAuth *model.Lu
 	return err
 }
 
-func (s *SQLiteService) GetLatestEmailAuth(ctx context.Context, userID string) (*model.Luna4EmailAuth, error) {
+func (s *sqlStore) GetLatestEmailAuth(ctx context.Context, userID string) (*model.Luna4EmailAuth, error) {
 	log.Printf("GetLatestEmailAuth: Looking for latest email auth for user: %s", userID)
 	query := `
 		SELECT
@@ -70,12 +77,14 @@ func (s *SQLiteService) GetLatestEmailAuth(ctx context.Context, userID string) (
 	return &emailAuth, nil
 }
 
-func (s *SQLiteService) MarkEmailAuthCompleted(ctx context.Context, emailAuthID string) error {
+// MarkEmailAuthCompleted completes an email auth that is not completed yet, so of two
+// requests redeeming the same link only one succeeds
+func (s *sqlStore) MarkEmailAuthCompleted(ctx context.Context, emailAuthID string) error {
 	log.Printf("MarkEmailAuthCompleted: Marking email auth as completed for ID: %s", emailAuthID)
 	query := `
 		UPDATE luna4_email_auth
 		SET completed = TRUE
-		WHERE id = ?
+		WHERE id = ? AND completed = FALSE
 	`
 
 	log.Printf("MarkEmailAuthCompleted: Executing update query")
@@ -91,10 +100,171 @@ func (s *SQLiteService) MarkEmailAuthCompleted(ctx context.Context, emailAuthID
 	}
 
 	if rowsAffected == 0 {
-		log.Printf("MarkEmailAuthCompleted: No email auth found with ID: %s", emailAuthID)
-		return fmt.Errorf("no email auth found with ID: %s", emailAuthID)
+		log.Printf("MarkEmailAuthCompleted: No uncompleted email auth found with ID: %s", emailAuthID)
+		return fmt.Errorf("%w: %s", ErrEmailAuthUsed, emailAuthID)
 	}
 
 	log.Printf("MarkEmailAuthCompleted: Successfully marked email auth as completed for ID: %s (rows affected: %d)", emailAuthID, rowsAffected)
 	return nil
 }
+
+func (s *sqlStore) GetUserEmailAuths(ctx context.Context, userID string) ([]model.Luna4EmailAuth, error) {
+	log.Printf("GetUserEmailAuths: Fetching email auths for user: %s", userID)
+	query := `
+		SELECT id, user_id, token, sent_at, completed
+		FROM luna4_email_auth
+		WHERE user_id = ?
+		ORDER BY sent_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.Printf("GetUserEmailAuths: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query email auths: %w", err)
+	}
+	defer rows.Close()
+
+	var emailAuths []model.Luna4EmailAuth
+	for rows.Next() {
+		var emailAuth model.Luna4EmailAuth
+
+		err := rows.Scan(
+			&emailAuth.ID,
+			&emailAuth.UserID,
+			&emailAuth.Token,
+			&emailAuth.SentAt,
+			&emailAuth.Completed,
+		)
+		if err != nil {
+			log.Printf("GetUserEmailAuths: Failed to scan email auth row: %v", err)
+			return nil, fmt.Errorf("failed to scan email auth: %w", err)
+		}
+
+		emailAuths = append(emailAuths, emailAuth)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("GetUserEmailAuths: Error during row iteration: %v", err)
+		return nil, fmt.Errorf("error iterating over email auth rows: %w", err)
+	}
+
+	log.Printf("GetUserEmailAuths: Successfully retrieved %d email auths for user %s", len(emailAuths), userID)
+	return emailAuths, nil
+}
+
+// RequestEmailAuth records a new email auth for the user and queues the sign-in link in lang
+// and the app's brand, which expires with the auth, all or nothing
+func (s *AccountService) RequestEmailAuth(ctx context.Context, user *model.Luna4User, redirect, lang string, profile *brand.Profile, expiry time.Duration) (*model.Luna4EmailAuth, error) {
+	token, tokenHash, err := util.GenerateEmailToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate authentication token: %w", err)
+	}
+
+	emailAuth := &model.Luna4EmailAuth{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		Token:     tokenHash,
+		SentAt:    time.Now().UnixMilli(),
+		Completed: false,
+	}
+
+	msg, err := s.emails.AuthEmail(user.Email, token, redirect, lang, profile)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.UnixMilli(emailAuth.SentAt).Add(expiry).UnixMilli()
+
+	err = s.inTx(ctx, func(tx *AccountService) error {
+		if err := tx.CreateEmailAuth(ctx, emailAuth); err != nil {
+			return err
+		}
+		_, err := tx.QueueEmail(ctx, "email-auth:"+emailAuth.ID, msg, &expiresAt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return emailAuth, nil
+}
+
+// RedeemEmailAuth completes a verified email auth, activates a pending user on their first
+// login and starts a session on the device with the given user agent, all or nothing
+func (s *AccountService) RedeemEmailAuth(ctx context.Context, user *model.Luna4User, emailAuthID, userAgent, ipAddress string) (*model.Luna4Session, error) {
+	var session *model.Luna4Session
+	err := s.inTx(ctx, func(tx *AccountService) error {
+		if err := tx.MarkEmailAuthCompleted(ctx, emailAuthID); err != nil {
+			return err
+		}
+
+		// First successful login confirms a pending account
+		if user.Status == model.UserStatusPending {
+			if err := tx.ActivateUser(ctx, user.ID, user.ID, "First login"); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		session, err = tx.startSession(ctx, user, userAgent, ipAddress)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Status == model.UserStatusPending {
+		user.Status = model.UserStatusActive
+	}
+	return session, nil
+}
+
+// startSession starts a session on the device with the given user agent. The user is told
+// about a login from a user agent none of their sessions had.
+func (s *AccountService) startSession(ctx context.Context, user *model.Luna4User, userAgent, ipAddress string) (*model.Luna4Session, error) {
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	now := time.Now()
+	session := &model.Luna4Session{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		UserAgent: userAgent,
+		IPAddress: ipAddress,
+		CreatedAt: now.UnixMilli(),
+		ExpiresAt: now.Add(util.BearerTokenLifetime).UnixMilli(),
+	}
+
+	sessions, err := s.GetUserSessions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if isNewDevice(sessions, userAgent) {
+		err := s.notify(ctx, user, user.Email, session.ID, SecurityNotice{
+			Type:      model.NotificationNewDeviceLogin,
+			Time:      now,
+			Device:    userAgent,
+			IPAddress: ipAddress,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// isNewDevice reports whether none of the user's earlier sessions came from the user agent.
+// A first login, or one before sessions recorded their user agent, is not a new device.
+func isNewDevice(sessions []model.Luna4Session, userAgent string) bool {
+	known := false
+	for _, session := range sessions {
+		if session.UserAgent == userAgent {
+			return false
+		}
+		known = known || session.UserAgent != ""
+	}
+	return known
+}
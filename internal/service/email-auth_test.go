@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+func createTestEmailAuth(t *testing.T, store Store, userID string) *model.Luna4EmailAuth {
+	t.Helper()
+	emailAuth := &model.Luna4EmailAuth{
+		ID:     uuid.New().String(),
+		UserID: userID,
+		Token:  "token-hash",
+		SentAt: time.Now().UnixMilli(),
+	}
+	if err := store.CreateEmailAuth(context.Background(), emailAuth); err != nil {
+		t.Fatalf("failed to create email auth: %v", err)
+	}
+	return emailAuth
+}
+
+func TestMarkEmailAuthCompletedOnce(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		user := createTestUser(t, store, "once@example.com", model.UserStatusActive)
+		emailAuth := createTestEmailAuth(t, store, user.ID)
+
+		if err := store.MarkEmailAuthCompleted(ctx, emailAuth.ID); err != nil {
+			t.Fatalf("first completion failed: %v", err)
+		}
+		if err := store.MarkEmailAuthCompleted(ctx, emailAuth.ID); !errors.Is(err, ErrEmailAuthUsed) {
+			t.Fatalf("second completion returned %v, want ErrEmailAuthUsed", err)
+		}
+		if err := store.MarkEmailAuthCompleted(ctx, uuid.New().String()); !errors.Is(err, ErrEmailAuthUsed) {
+			t.Fatalf("completing an unknown email auth returned %v, want ErrEmailAuthUsed", err)
+		}
+
+		latest, err := store.GetLatestEmailAuth(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("failed to read email auth: %v", err)
+		}
+		if !latest.Completed {
+			t.Fatal("email auth is not completed")
+		}
+	})
+}
+
+func TestRedeemEmailAuthConcurrently(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		user := createTestUser(t, accounts.Store, "race@example.com", model.UserStatusPending)
+		emailAuth := createTestEmailAuth(t, accounts.Store, user.ID)
+
+		const attempts = 8
+		var wg sync.WaitGroup
+		errs := make([]error, attempts)
+		for i := range attempts {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// Each request loads its own copy of the user
+				requestUser := *user
+				_, errs[i] = accounts.RedeemEmailAuth(ctx, &requestUser, emailAuth.ID, "test-agent", "127.0.0.1")
+			}()
+		}
+		wg.Wait()
+
+		redeemed := 0
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				redeemed++
+			case !errors.Is(err, ErrEmailAuthUsed):
+				t.Errorf("redeeming returned %v, want nil or ErrEmailAuthUsed", err)
+			}
+		}
+		if redeemed != 1 {
+			t.Fatalf("link was redeemed %d times, want once", redeemed)
+		}
+
+		sessions, err := accounts.GetUserSessions(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("failed to read sessions: %v", err)
+		}
+		if len(sessions) != 1 {
+			t.Errorf("user has %d sessions, want 1", len(sessions))
+		}
+
+		stored, err := accounts.GetUserByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("failed to read user: %v", err)
+		}
+		if stored.Status != model.UserStatusActive {
+			t.Errorf("user status is %s, want %s", stored.Status, model.UserStatusActive)
+		}
+	})
+}
@@ -0,0 +1,416 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/util"
+)
+
+var (
+	ErrEmailChangeNotFound = errors.New("email change not found")
+	ErrEmailChangeExpired  = errors.New("email change link has expired")
+	ErrEmailChangeUsed     = errors.New("email change link is no longer valid")
+	ErrEmailTaken          = errors.New("email address is already in use")
+)
+
+const emailChangeColumns = `id, user_id, old_email, new_email, confirm_token, revert_token, requested_by, requested_at, confirmed_at, reverted_at`
+
+func (s *sqlStore) CreateEmailChange(ctx context.Context, change *model.Luna4EmailChange) error {
+	log.Printf("CreateEmailChange: Creating email change %s for user %s", change.ID, change.UserID)
+	query := `
+		INSERT INTO luna4_email_change (` + emailChangeColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		change.ID,
+		change.UserID,
+		change.OldEmail,
+		change.NewEmail,
+		change.ConfirmToken,
+		change.RevertToken,
+		change.RequestedBy,
+		change.RequestedAt,
+		change.ConfirmedAt,
+		change.RevertedAt,
+	)
+	if err != nil {
+		log.Printf("CreateEmailChange: Failed to create email change: %v", err)
+		return fmt.Errorf("failed to create email change: %w", err)
+	}
+
+	return nil
+}
+
+func (s *sqlStore) GetLatestEmailChange(ctx context.Context, userID string) (*model.Luna4EmailChange, error) {
+	return s.getEmailChange(ctx, "user_id = ? ORDER BY requested_at DESC LIMIT 1", userID)
+}
+
+func (s *sqlStore) GetEmailChangeByConfirmToken(ctx context.Context, tokenHash string) (*model.Luna4EmailChange, error) {
+	return s.getEmailChange(ctx, "confirm_token = ?", tokenHash)
+}
+
+func (s *sqlStore) GetEmailChangeByRevertToken(ctx context.Context, tokenHash string) (*model.Luna4EmailChange, error) {
+	return s.getEmailChange(ctx, "revert_token = ?", tokenHash)
+}
+
+func (s *sqlStore) getEmailChange(ctx context.Context, where string, arg any) (*model.Luna4EmailChange, error) {
+	query := `
+		SELECT ` + emailChangeColumns + `
+		FROM luna4_email_change
+		WHERE ` + where
+
+	row := s.db.QueryRowContext(ctx, query, arg)
+	change, err := scanEmailChange(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("getEmailChange: Failed to scan email change: %v", err)
+		return nil, fmt.Errorf("failed to get email change: %w", err)
+	}
+
+	return change, nil
+}
+
+func (s *sqlStore) GetUserEmailChanges(ctx context.Context, userID string) ([]model.Luna4EmailChange, error) {
+	log.Printf("GetUserEmailChanges: Fetching email changes for user: %s", userID)
+	query := `
+		SELECT ` + emailChangeColumns + `
+		FROM luna4_email_change
+		WHERE user_id = ?
+		ORDER BY requested_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.Printf("GetUserEmailChanges: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query email changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []model.Luna4EmailChange
+	for rows.Next() {
+		change, err := scanEmailChange(rows)
+		if err != nil {
+			log.Printf("GetUserEmailChanges: Failed to scan email change row: %v", err)
+			return nil, fmt.Errorf("failed to scan email change: %w", err)
+		}
+		changes = append(changes, *change)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("GetUserEmailChanges: Error during row iteration: %v", err)
+		return nil, fmt.Errorf("error iterating over email change rows: %w", err)
+	}
+
+	return changes, nil
+}
+
+// MarkEmailChangeConfirmed confirms a change that was neither confirmed nor reverted yet,
+// returning ErrEmailChangeUsed otherwise
+func (s *sqlStore) MarkEmailChangeConfirmed(ctx context.Context, changeID string) error {
+	query := `UPDATE luna4_email_change SET confirmed_at = ? WHERE id = ? AND confirmed_at IS NULL AND reverted_at IS NULL`
+	return s.markEmailChange(ctx, "confirmed", query, changeID)
+}
+
+// MarkEmailChangeReverted reverts a change that was not reverted yet, returning
+// ErrEmailChangeUsed otherwise
+func (s *sqlStore) MarkEmailChangeReverted(ctx context.Context, changeID string) error {
+	query := `UPDATE luna4_email_change SET reverted_at = ? WHERE id = ? AND reverted_at IS NULL`
+	return s.markEmailChange(ctx, "reverted", query, changeID)
+}
+
+func (s *sqlStore) markEmailChange(ctx context.Context, state, query, changeID string) error {
+	result, err := s.db.ExecContext(ctx, query, time.Now().UnixMilli(), changeID)
+	if err != nil {
+		log.Printf("markEmailChange: Failed to update email change %s: %v", changeID, err)
+		return fmt.Errorf("failed to mark email change as %s: %w", state, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrEmailChangeUsed
+	}
+	return nil
+}
+
+func (s *sqlStore) UpdateUserEmail(ctx context.Context, userID, email string) error {
+	log.Printf("UpdateUserEmail: Updating email for user %s", userID)
+	query := `
+		UPDATE luna4_users
+		SET email = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	_, err := s.db.ExecContext(ctx, query, email, time.Now().UnixMilli(), userID)
+	if err != nil {
+		log.Printf("UpdateUserEmail: Failed to update user email: %v", err)
+		return fmt.Errorf("failed to update user email: %w", err)
+	}
+
+	return nil
+}
+
+// StartEmailChange records a pending email change and queues a confirmation link to the new
+// address and a notice with a revert link to the current one, both in lang
+func (s *AccountService) StartEmailChange(ctx context.Context, user *model.Luna4User, newEmail, lang, requestedBy string) (*model.Luna4EmailChange, error) {
+	existing, err := s.GetUserByEmail(ctx, newEmail)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrEmailTaken
+	}
+
+	confirmToken, confirmTokenHash, err := util.GenerateEmailToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate confirm token: %w", err)
+	}
+	revertToken, revertTokenHash, err := util.GenerateEmailToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate revert token: %w", err)
+	}
+
+	change := &model.Luna4EmailChange{
+		ID:           uuid.New().String(),
+		UserID:       user.ID,
+		OldEmail:     user.Email,
+		NewEmail:     newEmail,
+		ConfirmToken: confirmTokenHash,
+		RevertToken:  revertTokenHash,
+		RequestedBy:  requestedBy,
+		RequestedAt:  time.Now().UnixMilli(),
+	}
+	confirmEmail, err := s.emails.EmailChangeConfirmEmail(newEmail, confirmToken, lang)
+	if err != nil {
+		return nil, err
+	}
+	noticeEmail, err := s.emails.EmailChangeNoticeEmail(user.Email, newEmail, revertToken, lang)
+	if err != nil {
+		return nil, err
+	}
+	confirmExpiresAt := time.UnixMilli(change.RequestedAt).Add(getEmailChangeExpiry()).UnixMilli()
+	revertExpiresAt := time.UnixMilli(change.RequestedAt).Add(getEmailChangeRevertExpiry()).UnixMilli()
+
+	err = s.inTx(ctx, func(tx *AccountService) error {
+		if err := tx.CreateEmailChange(ctx, change); err != nil {
+			return err
+		}
+		if _, err := tx.QueueEmail(ctx, "email-change-confirm:"+change.ID, confirmEmail, &confirmExpiresAt); err != nil {
+			return err
+		}
+		if _, err := tx.QueueEmail(ctx, "email-change-notice:"+change.ID, noticeEmail, &revertExpiresAt); err != nil {
+			return err
+		}
+		return tx.CreateAuditLog(ctx, user.ID, requestedBy, model.AuditActionEmailChangeRequested, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// ConfirmEmailChange applies the email change identified by its confirmation token,
+// revokes the user's sessions and tells the previous address. The change is read and
+// marked confirmed in one transaction, so a link confirms at most once.
+func (s *AccountService) ConfirmEmailChange(ctx context.Context, token string) (*model.Luna4EmailChange, error) {
+	tokenHash, err := util.HashEmailToken(token)
+	if err != nil {
+		return nil, ErrEmailChangeNotFound
+	}
+
+	var change *model.Luna4EmailChange
+	err = s.inTx(ctx, func(tx *AccountService) error {
+		var err error
+		change, err = tx.GetEmailChangeByConfirmToken(ctx, tokenHash)
+		if err != nil {
+			return err
+		}
+		if change == nil {
+			return ErrEmailChangeNotFound
+		}
+
+		if change.ConfirmedAt != nil || change.RevertedAt != nil {
+			return ErrEmailChangeUsed
+		}
+
+		// Only the most recent request for a user can be confirmed
+		latest, err := tx.GetLatestEmailChange(ctx, change.UserID)
+		if err != nil {
+			return err
+		}
+		if latest == nil || latest.ID != change.ID {
+			return ErrEmailChangeUsed
+		}
+
+		if time.Since(time.UnixMilli(change.RequestedAt)) > getEmailChangeExpiry() {
+			return ErrEmailChangeExpired
+		}
+
+		user, err := tx.GetUserByID(ctx, change.UserID)
+		if err != nil {
+			return err
+		}
+		if user == nil || user.Status == model.UserStatusDeleted || user.Email != change.OldEmail {
+			return ErrEmailChangeUsed
+		}
+
+		existing, err := tx.GetUserByEmail(ctx, change.NewEmail)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return ErrEmailTaken
+		}
+
+		if err := tx.MarkEmailChangeConfirmed(ctx, change.ID); err != nil {
+			return err
+		}
+		if err := tx.UpdateUserEmail(ctx, change.UserID, change.NewEmail); err != nil {
+			return err
+		}
+		if err := tx.CreateAuditLog(ctx, change.UserID, change.UserID, model.AuditActionEmailChanged, ""); err != nil {
+			return err
+		}
+		if _, err := tx.RevokeSessions(ctx, change.UserID, change.UserID, "Email changed"); err != nil {
+			return err
+		}
+		return tx.notify(ctx, user, change.OldEmail, change.ID, SecurityNotice{
+			Type:     model.NotificationEmailChanged,
+			NewEmail: change.NewEmail,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// RevertEmailChange cancels a pending email change, or restores the previous address if it
+// was already confirmed, using the token sent to the previous address. Sessions are revoked
+// in case the change was made by someone else. The change is read and marked reverted in
+// one transaction, so a link reverts at most once.
+func (s *AccountService) RevertEmailChange(ctx context.Context, token string) (*model.Luna4EmailChange, error) {
+	tokenHash, err := util.HashEmailToken(token)
+	if err != nil {
+		return nil, ErrEmailChangeNotFound
+	}
+
+	var change *model.Luna4EmailChange
+	err = s.inTx(ctx, func(tx *AccountService) error {
+		var err error
+		change, err = tx.GetEmailChangeByRevertToken(ctx, tokenHash)
+		if err != nil {
+			return err
+		}
+		if change == nil {
+			return ErrEmailChangeNotFound
+		}
+
+		if change.RevertedAt != nil {
+			return ErrEmailChangeUsed
+		}
+
+		if time.Since(time.UnixMilli(change.RequestedAt)) > getEmailChangeRevertExpiry() {
+			return ErrEmailChangeExpired
+		}
+
+		if err := tx.MarkEmailChangeReverted(ctx, change.ID); err != nil {
+			return err
+		}
+
+		if change.ConfirmedAt != nil {
+			user, err := tx.GetUserByID(ctx, change.UserID)
+			if err != nil {
+				return err
+			}
+			if user == nil || user.Email != change.NewEmail {
+				return ErrEmailChangeUsed
+			}
+
+			existing, err := tx.GetUserByEmail(ctx, change.OldEmail)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				return ErrEmailTaken
+			}
+
+			if err := tx.UpdateUserEmail(ctx, change.UserID, change.OldEmail); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.CreateAuditLog(ctx, change.UserID, change.UserID, model.AuditActionEmailChangeReverted, ""); err != nil {
+			return err
+		}
+		_, err = tx.RevokeSessions(ctx, change.UserID, change.UserID, "Email change reverted")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+func scanEmailChange(row rowScanner) (*model.Luna4EmailChange, error) {
+	var change model.Luna4EmailChange
+	var confirmedAt, revertedAt sql.NullInt64
+
+	err := row.Scan(
+		&change.ID,
+		&change.UserID,
+		&change.OldEmail,
+		&change.NewEmail,
+		&change.ConfirmToken,
+		&change.RevertToken,
+		&change.RequestedBy,
+		&change.RequestedAt,
+		&confirmedAt,
+		&revertedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if confirmedAt.Valid {
+		change.ConfirmedAt = &confirmedAt.Int64
+	}
+	if revertedAt.Valid {
+		change.RevertedAt = &revertedAt.Int64
+	}
+	return &change, nil
+}
+
+// getEmailChangeExpiry returns how long a confirmation link stays valid, from EMAIL_CHANGE_EXPIRY in seconds
+func getEmailChangeExpiry() time.Duration {
+	return getEnvSeconds("EMAIL_CHANGE_EXPIRY", 86400) // Default 24 hours
+}
+
+// getEmailChangeRevertExpiry returns how long a revert link stays valid, from EMAIL_CHANGE_REVERT_EXPIRY in seconds
+func getEmailChangeRevertExpiry() time.Duration {
+	return getEnvSeconds("EMAIL_CHANGE_REVERT_EXPIRY", 604800) // Default 7 days
+}
+
+func getEnvSeconds(key string, defaultSeconds int) time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		seconds = defaultSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/util"
+)
+
+// createTestEmailChange stores a pending change of the user's address to newEmail and
+// returns it with its confirm and revert tokens
+func createTestEmailChange(t *testing.T, store Store, user *model.Luna4User, newEmail string) (*model.Luna4EmailChange, string, string) {
+	t.Helper()
+	confirmToken, confirmTokenHash, err := util.GenerateEmailToken()
+	if err != nil {
+		t.Fatalf("failed to generate confirm token: %v", err)
+	}
+	revertToken, revertTokenHash, err := util.GenerateEmailToken()
+	if err != nil {
+		t.Fatalf("failed to generate revert token: %v", err)
+	}
+
+	change := &model.Luna4EmailChange{
+		ID:           uuid.New().String(),
+		UserID:       user.ID,
+		OldEmail:     user.Email,
+		NewEmail:     newEmail,
+		ConfirmToken: confirmTokenHash,
+		RevertToken:  revertTokenHash,
+		RequestedBy:  user.ID,
+		RequestedAt:  time.Now().UnixMilli(),
+	}
+	if err := store.CreateEmailChange(context.Background(), change); err != nil {
+		t.Fatalf("failed to create email change: %v", err)
+	}
+	return change, confirmToken, revertToken
+}
+
+// concurrently calls fn from n goroutines at once and returns how many calls succeeded,
+// failing the test for errors other than ErrEmailChangeUsed
+func concurrently(t *testing.T, n int, fn func() error) int {
+	t.Helper()
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn()
+		}()
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrEmailChangeUsed):
+			t.Errorf("call returned %v, want nil or ErrEmailChangeUsed", err)
+		}
+	}
+	return succeeded
+}
+
+func countAuditLogs(t *testing.T, store Store, userID string, action model.AuditAction) int {
+	t.Helper()
+	logs, err := store.GetUserAuditLogs(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("failed to read audit logs: %v", err)
+	}
+	count := 0
+	for _, entry := range logs {
+		if entry.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+func TestConfirmAndRevertEmailChangeConcurrently(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		user := createTestUser(t, accounts.Store, "old@example.com", model.UserStatusActive)
+		_, confirmToken, revertToken := createTestEmailChange(t, accounts.Store, user, "new@example.com")
+
+		confirmed := concurrently(t, 8, func() error {
+			_, err := accounts.ConfirmEmailChange(ctx, confirmToken)
+			return err
+		})
+		if confirmed != 1 {
+			t.Fatalf("change was confirmed %d times, want once", confirmed)
+		}
+		if got := countAuditLogs(t, accounts.Store, user.ID, model.AuditActionEmailChanged); got != 1 {
+			t.Errorf("%d email changed audit entries, want 1", got)
+		}
+		assertUserEmail(t, accounts.Store, user.ID, "new@example.com")
+
+		reverted := concurrently(t, 8, func() error {
+			_, err := accounts.RevertEmailChange(ctx, revertToken)
+			return err
+		})
+		if reverted != 1 {
+			t.Fatalf("change was reverted %d times, want once", reverted)
+		}
+		if got := countAuditLogs(t, accounts.Store, user.ID, model.AuditActionEmailChangeReverted); got != 1 {
+			t.Errorf("%d email change reverted audit entries, want 1", got)
+		}
+		assertUserEmail(t, accounts.Store, user.ID, "old@example.com")
+
+		// A reverted change cannot be confirmed again
+		if _, err := accounts.ConfirmEmailChange(ctx, confirmToken); !errors.Is(err, ErrEmailChangeUsed) {
+			t.Errorf("confirming a reverted change returned %v, want ErrEmailChangeUsed", err)
+		}
+		assertUserEmail(t, accounts.Store, user.ID, "old@example.com")
+	})
+}
+
+func TestMarkEmailChangeConditions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		user := createTestUser(t, store, "old@example.com", model.UserStatusActive)
+
+		pending, _, _ := createTestEmailChange(t, store, user, "new@example.com")
+		if err := store.MarkEmailChangeConfirmed(ctx, pending.ID); err != nil {
+			t.Fatalf("confirming a pending change failed: %v", err)
+		}
+		if err := store.MarkEmailChangeConfirmed(ctx, pending.ID); !errors.Is(err, ErrEmailChangeUsed) {
+			t.Errorf("confirming a confirmed change returned %v, want ErrEmailChangeUsed", err)
+		}
+		if err := store.MarkEmailChangeReverted(ctx, pending.ID); err != nil {
+			t.Fatalf("reverting a confirmed change failed: %v", err)
+		}
+		if err := store.MarkEmailChangeReverted(ctx, pending.ID); !errors.Is(err, ErrEmailChangeUsed) {
+			t.Errorf("reverting a reverted change returned %v, want ErrEmailChangeUsed", err)
+		}
+
+		cancelled, _, _ := createTestEmailChange(t, store, user, "other@example.com")
+		if err := store.MarkEmailChangeReverted(ctx, cancelled.ID); err != nil {
+			t.Fatalf("reverting a pending change failed: %v", err)
+		}
+		if err := store.MarkEmailChangeConfirmed(ctx, cancelled.ID); !errors.Is(err, ErrEmailChangeUsed) {
+			t.Errorf("confirming a reverted change returned %v, want ErrEmailChangeUsed", err)
+		}
+
+		if err := store.MarkEmailChangeConfirmed(ctx, uuid.New().String()); !errors.Is(err, ErrEmailChangeUsed) {
+			t.Errorf("confirming an unknown change returned %v, want ErrEmailChangeUsed", err)
+		}
+	})
+}
+
+func assertUserEmail(t *testing.T, store Store, userID, email string) {
+	t.Helper()
+	user, err := store.GetUserByID(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("failed to read user: %v", err)
+	}
+	if user.Email != email {
+		t.Errorf("user email is %s, want %s", user.Email, email)
+	}
+}
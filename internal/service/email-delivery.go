@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+var (
+	ErrEmailSuppressionNotFound = errors.New("email address is not suppressed")
+	ErrUnsupportedNotification  = errors.New("unsupported SES notification")
+)
+
+// deliveryStatusEvents is how many recent events the delivery status of an address shows
+const deliveryStatusEvents = 10
+
+// sesActor is the actor recorded in audit entries for suppressions reported by SES
+const sesActor = "ses"
+
+const deliveryEventColumns = `id, email, type, sub_type, detail, message_id, created_at`
+
+// SESNotification is a bounce, complaint or delivery notification published by SES, either
+// as an identity notification (notificationType) or a configuration set event (eventType)
+type SESNotification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"`
+	Mail             struct {
+		MessageID string `json:"messageId"`
+	} `json:"mail"`
+	Bounce *struct {
+		BounceType        string `json:"bounceType"`
+		BounceSubType     string `json:"bounceSubType"`
+		BouncedRecipients []struct {
+			EmailAddress   string `json:"emailAddress"`
+			DiagnosticCode string `json:"diagnosticCode"`
+		} `json:"bouncedRecipients"`
+		Timestamp  string `json:"timestamp"`
+		FeedbackID string `json:"feedbackId"`
+	} `json:"bounce"`
+	Complaint *struct {
+		ComplainedRecipients []struct {
+			EmailAddress string `json:"emailAddress"`
+		} `json:"complainedRecipients"`
+		ComplaintFeedbackType string `json:"complaintFeedbackType"`
+		Timestamp             string `json:"timestamp"`
+		FeedbackID            string `json:"feedbackId"`
+	} `json:"complaint"`
+	Delivery *struct {
+		Recipients   []string `json:"recipients"`
+		SMTPResponse string   `json:"smtpResponse"`
+		Timestamp    string   `json:"timestamp"`
+	} `json:"delivery"`
+}
+
+// deliveryEvents returns one event per recipient of the notification and whether the
+// recipients should be suppressed, and why
+func (n *SESNotification) deliveryEvents() ([]model.Luna4DeliveryEvent, model.SuppressionReason, error) {
+	kind := n.NotificationType
+	if kind == "" {
+		kind = n.EventType
+	}
+
+	var events []model.Luna4DeliveryEvent
+	var reason model.SuppressionReason
+	switch {
+	case kind == "Bounce" && n.Bounce != nil:
+		if n.Bounce.BounceType == "Permanent" {
+			reason = model.SuppressionReasonHardBounce
+		}
+		for _, recipient := range n.Bounce.BouncedRecipients {
+			events = append(events, model.Luna4DeliveryEvent{
+				ID:        n.Bounce.FeedbackID,
+				Email:     recipient.EmailAddress,
+				Type:      model.DeliveryEventBounce,
+				SubType:   n.Bounce.BounceType + "/" + n.Bounce.BounceSubType,
+				Detail:    recipient.DiagnosticCode,
+				CreatedAt: parseSESTimestamp(n.Bounce.Timestamp),
+			})
+		}
+	case kind == "Complaint" && n.Complaint != nil:
+		reason = model.SuppressionReasonComplaint
+		for _, recipient := range n.Complaint.ComplainedRecipients {
+			events = append(events, model.Luna4DeliveryEvent{
+				ID:        n.Complaint.FeedbackID,
+				Email:     recipient.EmailAddress,
+				Type:      model.DeliveryEventComplaint,
+				SubType:   n.Complaint.ComplaintFeedbackType,
+				CreatedAt: parseSESTimestamp(n.Complaint.Timestamp),
+			})
+		}
+	case kind == "Delivery" && n.Delivery != nil:
+		for _, recipient := range n.Delivery.Recipients {
+			events = append(events, model.Luna4DeliveryEvent{
+				ID:        n.Mail.MessageID,
+				Email:     recipient,
+				Type:      model.DeliveryEventDelivery,
+				Detail:    n.Delivery.SMTPResponse,
+				CreatedAt: parseSESTimestamp(n.Delivery.Timestamp),
+			})
+		}
+	default:
+		return nil, "", fmt.Errorf("%w: %q", ErrUnsupportedNotification, kind)
+	}
+
+	// Events are keyed by the notification and recipient so redelivered notifications are
+	// recorded once
+	for i := range events {
+		events[i].ID += ":" + normalizeEmail(events[i].Email)
+		events[i].MessageID = n.Mail.MessageID
+	}
+	return events, reason, nil
+}
+
+func parseSESTimestamp(value string) int64 {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Now().UnixMilli()
+	}
+	return t.UnixMilli()
+}
+
+// normalizeEmail returns the form addresses are recorded and suppressed in
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// RecordSESNotification records an event per recipient and suppresses the recipients of
+// hard bounces and complaints. It returns how many new events were recorded.
+func (s *AccountService) RecordSESNotification(ctx context.Context, notification *SESNotification) (int, error) {
+	events, reason, err := notification.deliveryEvents()
+	if err != nil {
+		return 0, err
+	}
+
+	recorded := 0
+	err = s.inTx(ctx, func(tx *AccountService) error {
+		for _, event := range events {
+			// SES reports the address as it was sent, which is how the user stores it
+			address := event.Email
+			event.Email = normalizeEmail(address)
+
+			created, err := tx.CreateDeliveryEvent(ctx, &event)
+			if err != nil {
+				return err
+			}
+			if !created {
+				continue
+			}
+			recorded++
+
+			if reason == "" {
+				continue
+			}
+			if err := tx.suppressEmail(ctx, &event, address, reason); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return recorded, nil
+}
+
+// suppressEmail stops mail to the event's address, noting it in the owner's audit log
+func (s *AccountService) suppressEmail(ctx context.Context, event *model.Luna4DeliveryEvent, address string, reason model.SuppressionReason) error {
+	existing, err := s.GetEmailSuppression(ctx, event.Email)
+	if err != nil || existing != nil {
+		return err
+	}
+
+	suppression := &model.Luna4EmailSuppression{
+		Email:     event.Email,
+		Reason:    reason,
+		Detail:    event.Detail,
+		CreatedAt: time.Now().UnixMilli(),
+	}
+	if err := s.CreateEmailSuppression(ctx, suppression); err != nil {
+		return err
+	}
+	log.Printf("suppressEmail: Suppressed %s after %s", event.Email, reason)
+
+	user, err := s.GetUserByEmail(ctx, address)
+	if err != nil || user == nil {
+		return err
+	}
+	return s.CreateAuditLog(ctx, user.ID, sesActor, model.AuditActionEmailSuppressed, string(reason))
+}
+
+// IsEmailSuppressed reports whether mail to the address is suppressed, and why
+func (s *AccountService) IsEmailSuppressed(ctx context.Context, email string) (*model.Luna4EmailSuppression, error) {
+	return s.GetEmailSuppression(ctx, normalizeEmail(email))
+}
+
+// GetDeliveryStatus returns whether the address is suppressed and its recent delivery events
+func (s *AccountService) GetDeliveryStatus(ctx context.Context, email string) (*model.Luna4DeliveryStatus, error) {
+	email = normalizeEmail(email)
+	suppression, err := s.GetEmailSuppression(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := s.GetDeliveryEvents(ctx, email, deliveryStatusEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Luna4DeliveryStatus{
+		Suppressed:  suppression != nil,
+		Suppression: suppression,
+		Events:      append([]model.Luna4DeliveryEvent{}, events...),
+	}, nil
+}
+
+// LiftEmailSuppression lets mail reach the address again, for example after the owner
+// fixed their mailbox
+func (s *AccountService) LiftEmailSuppression(ctx context.Context, email, actor string) error {
+	return s.inTx(ctx, func(tx *AccountService) error {
+		if err := tx.DeleteEmailSuppression(ctx, normalizeEmail(email)); err != nil {
+			return err
+		}
+
+		user, err := tx.GetUserByEmail(ctx, email)
+		if err != nil || user == nil {
+			return err
+		}
+		return tx.CreateAuditLog(ctx, user.ID, actor, model.AuditActionEmailUnsuppressed, "")
+	})
+}
+
+func (s *sqlStore) CreateDeliveryEvent(ctx context.Context, event *model.Luna4DeliveryEvent) (bool, error) {
+	query := `
+		INSERT INTO luna4_delivery_event (` + deliveryEventColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO NOTHING
+	`
+
+	result, err := s.db.ExecContext(ctx, query,
+		event.ID,
+		event.Email,
+		event.Type,
+		event.SubType,
+		event.Detail,
+		event.MessageID,
+		event.CreatedAt,
+	)
+	if err != nil {
+		log.Printf("CreateDeliveryEvent: Failed to record delivery event: %v", err)
+		return false, fmt.Errorf("failed to record delivery event: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+func (s *sqlStore) GetDeliveryEvents(ctx context.Context, email string, limit int) ([]model.Luna4DeliveryEvent, error) {
+	query := `
+		SELECT ` + deliveryEventColumns + `
+		FROM luna4_delivery_event
+		WHERE email = ?
+		ORDER BY created_at DESC
+		LIMIT ?
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, email, limit)
+	if err != nil {
+		log.Printf("GetDeliveryEvents: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query delivery events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []model.Luna4DeliveryEvent
+	for rows.Next() {
+		var event model.Luna4DeliveryEvent
+		err := rows.Scan(&event.ID, &event.Email, &event.Type, &event.SubType, &event.Detail, &event.MessageID, &event.CreatedAt)
+		if err != nil {
+			log.Printf("GetDeliveryEvents: Failed to scan delivery event row: %v", err)
+			return nil, fmt.Errorf("failed to scan delivery event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("GetDeliveryEvents: Error during row iteration: %v", err)
+		return nil, fmt.Errorf("error iterating over delivery event rows: %w", err)
+	}
+	return events, nil
+}
+
+func (s *sqlStore) CreateEmailSuppression(ctx context.Context, suppression *model.Luna4EmailSuppression) error {
+	query := `
+		INSERT INTO luna4_email_suppression (email, reason, detail, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (email) DO NOTHING
+	`
+
+	_, err := s.db.ExecContext(ctx, query, suppression.Email, suppression.Reason, suppression.Detail, suppression.CreatedAt)
+	if err != nil {
+		log.Printf("CreateEmailSuppression: Failed to suppress %s: %v", suppression.Email, err)
+		return fmt.Errorf("failed to suppress email: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) GetEmailSuppression(ctx context.Context, email string) (*model.Luna4EmailSuppression, error) {
+	query := `
+		SELECT email, reason, detail, created_at
+		FROM luna4_email_suppression
+		WHERE email = ?
+	`
+
+	var suppression model.Luna4EmailSuppression
+	err := s.db.QueryRowContext(ctx, query, email).Scan(&suppression.Email, &suppression.Reason, &suppression.Detail, &suppression.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("GetEmailSuppression: Failed to scan suppression: %v", err)
+		return nil, fmt.Errorf("failed to get email suppression: %w", err)
+	}
+	return &suppression, nil
+}
+
+func (s *sqlStore) DeleteEmailSuppression(ctx context.Context, email string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM luna4_email_suppression WHERE email = ?`, email)
+	if err != nil {
+		log.Printf("DeleteEmailSuppression: Failed to lift suppression of %s: %v", email, err)
+		return fmt.Errorf("failed to delete email suppression: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrEmailSuppressionNotFound
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/mailer"
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+var (
+	ErrOutboxEmailNotFound = errors.New("outbox email not found")
+	ErrOutboxEmailNotDead  = errors.New("only dead-lettered emails can be retried")
+	ErrOutboxEmailExpired  = errors.New("email expired before delivery and cannot be retried")
+	ErrOutboxEmailCleared  = errors.New("email body was cleared and cannot be retried")
+)
+
+const outboxEmailColumns = `id, idempotency_key, sender, recipient, subject, html, text, unsubscribe, status, attempts, next_attempt_at, last_error, created_at, expires_at, sent_at`
+
+// QueueEmail stores a message for the outbox worker to deliver. The idempotency key
+// identifies the message: queueing a key again returns the email already queued under it.
+// A message still undelivered at expiresAt is dead-lettered instead of sent.
+func (s *AccountService) QueueEmail(ctx context.Context, idempotencyKey string, msg *mailer.Message, expiresAt *int64) (*model.Luna4OutboxEmail, error) {
+	existing, err := s.GetOutboxEmailByKey(ctx, idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		log.Printf("QueueEmail: Email %s is already queued as %s", idempotencyKey, existing.ID)
+		return existing, nil
+	}
+
+	now := time.Now().UnixMilli()
+	email := &model.Luna4OutboxEmail{
+		ID:             uuid.New().String(),
+		IdempotencyKey: idempotencyKey,
+		Sender:         msg.From,
+		Recipient:      msg.To,
+		Subject:        msg.Subject,
+		HTML:           msg.HTML,
+		Text:           msg.Text,
+		Unsubscribe:    msg.Unsubscribe,
+		Status:         model.OutboxStatusPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+		ExpiresAt:      expiresAt,
+	}
+	if err := s.CreateOutboxEmail(ctx, email); err != nil {
+		return nil, err
+	}
+	return email, nil
+}
+
+// RetryOutboxEmail puts a dead-lettered email back in the queue with a fresh set of attempts
+func (s *AccountService) RetryOutboxEmail(ctx context.Context, emailID string) (*model.Luna4OutboxEmail, error) {
+	email, err := s.GetOutboxEmail(ctx, emailID)
+	if err != nil {
+		return nil, err
+	}
+	if email == nil {
+		return nil, ErrOutboxEmailNotFound
+	}
+	if email.Status != model.OutboxStatusDead {
+		return nil, ErrOutboxEmailNotDead
+	}
+
+	now := time.Now().UnixMilli()
+	if email.ExpiresAt != nil && *email.ExpiresAt <= now {
+		return nil, ErrOutboxEmailExpired
+	}
+	if email.HTML == "" && email.Text == "" {
+		return nil, ErrOutboxEmailCleared
+	}
+
+	email.Status = model.OutboxStatusPending
+	email.Attempts = 0
+	email.NextAttemptAt = now
+	email.LastError = ""
+	if err := s.UpdateOutboxEmail(ctx, email); err != nil {
+		return nil, err
+	}
+	return email, nil
+}
+
+func (s *sqlStore) CreateOutboxEmail(ctx context.Context, email *model.Luna4OutboxEmail) error {
+	log.Printf("CreateOutboxEmail: Queueing email %s (%s)", email.ID, email.IdempotencyKey)
+	query := `
+		INSERT INTO luna4_outbox_email (` + outboxEmailColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		email.ID,
+		email.IdempotencyKey,
+		email.Sender,
+		email.Recipient,
+		email.Subject,
+		email.HTML,
+		email.Text,
+		email.Unsubscribe,
+		email.Status,
+		email.Attempts,
+		email.NextAttemptAt,
+		email.LastError,
+		email.CreatedAt,
+		email.ExpiresAt,
+		email.SentAt,
+	)
+	if err != nil {
+		log.Printf("CreateOutboxEmail: Failed to queue email: %v", err)
+		return fmt.Errorf("failed to queue email: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) GetOutboxEmail(ctx context.Context, emailID string) (*model.Luna4OutboxEmail, error) {
+	return s.getOutboxEmail(ctx, "id = ?", emailID)
+}
+
+func (s *sqlStore) GetOutboxEmailByKey(ctx context.Context, idempotencyKey string) (*model.Luna4OutboxEmail, error) {
+	return s.getOutboxEmail(ctx, "idempotency_key = ?", idempotencyKey)
+}
+
+func (s *sqlStore) getOutboxEmail(ctx context.Context, where string, arg any) (*model.Luna4OutboxEmail, error) {
+	query := `
+		SELECT ` + outboxEmailColumns + `
+		FROM luna4_outbox_email
+		WHERE ` + where
+
+	email, err := scanOutboxEmail(s.db.QueryRowContext(ctx, query, arg))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("getOutboxEmail: Failed to scan outbox email: %v", err)
+		return nil, fmt.Errorf("failed to get outbox email: %w", err)
+	}
+	return email, nil
+}
+
+func (s *sqlStore) GetOutboxEmails(ctx context.Context, status model.OutboxStatus, limit int) ([]model.Luna4OutboxEmail, error) {
+	return s.queryOutboxEmails(ctx, "status = ? ORDER BY created_at DESC LIMIT ?", status, limit)
+}
+
+func (s *sqlStore) GetDueOutboxEmails(ctx context.Context, now int64, limit int) ([]model.Luna4OutboxEmail, error) {
+	return s.queryOutboxEmails(ctx, "status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?", model.OutboxStatusPending, now, limit)
+}
+
+func (s *sqlStore) queryOutboxEmails(ctx context.Context, where string, args ...any) ([]model.Luna4OutboxEmail, error) {
+	query := `
+		SELECT ` + outboxEmailColumns + `
+		FROM luna4_outbox_email
+		WHERE ` + where
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("queryOutboxEmails: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query outbox emails: %w", err)
+	}
+	defer rows.Close()
+
+	emails := []model.Luna4OutboxEmail{}
+	for rows.Next() {
+		email, err := scanOutboxEmail(rows)
+		if err != nil {
+			log.Printf("queryOutboxEmails: Failed to scan outbox email row: %v", err)
+			return nil, fmt.Errorf("failed to scan outbox email: %w", err)
+		}
+		emails = append(emails, *email)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("queryOutboxEmails: Error during row iteration: %v", err)
+		return nil, fmt.Errorf("error iterating over outbox email rows: %w", err)
+	}
+	return emails, nil
+}
+
+func (s *sqlStore) ClaimOutboxEmail(ctx context.Context, emailID string, now, claimUntil int64) (bool, error) {
+	query := `
+		UPDATE luna4_outbox_email
+		SET next_attempt_at = ?
+		WHERE id = ? AND status = ? AND next_attempt_at <= ?
+	`
+
+	result, err := s.db.ExecContext(ctx, query, claimUntil, emailID, model.OutboxStatusPending, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim outbox email: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+func (s *sqlStore) UpdateOutboxEmail(ctx context.Context, email *model.Luna4OutboxEmail) error {
+	query := `
+		UPDATE luna4_outbox_email
+		SET html = ?, text = ?, status = ?, attempts = ?, next_attempt_at = ?, last_error = ?, sent_at = ?
+		WHERE id = ?
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		email.HTML,
+		email.Text,
+		email.Status,
+		email.Attempts,
+		email.NextAttemptAt,
+		email.LastError,
+		email.SentAt,
+		email.ID,
+	)
+	if err != nil {
+		log.Printf("UpdateOutboxEmail: Failed to update outbox email %s: %v", email.ID, err)
+		return fmt.Errorf("failed to update outbox email: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) DeleteSentOutboxEmails(ctx context.Context, sentBefore int64) (int64, error) {
+	query := `DELETE FROM luna4_outbox_email WHERE status = ? AND sent_at < ?`
+
+	result, err := s.db.ExecContext(ctx, query, model.OutboxStatusSent, sentBefore)
+	if err != nil {
+		log.Printf("DeleteSentOutboxEmails: Failed to delete sent emails: %v", err)
+		return 0, fmt.Errorf("failed to delete sent outbox emails: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func scanOutboxEmail(row rowScanner) (*model.Luna4OutboxEmail, error) {
+	var email model.Luna4OutboxEmail
+	var expiresAt, sentAt sql.NullInt64
+
+	err := row.Scan(
+		&email.ID,
+		&email.IdempotencyKey,
+		&email.Sender,
+		&email.Recipient,
+		&email.Subject,
+		&email.HTML,
+		&email.Text,
+		&email.Unsubscribe,
+		&email.Status,
+		&email.Attempts,
+		&email.NextAttemptAt,
+		&email.LastError,
+		&email.CreatedAt,
+		&expiresAt,
+		&sentAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		email.ExpiresAt = &expiresAt.Int64
+	}
+	if sentAt.Valid {
+		email.SentAt = &sentAt.Int64
+	}
+	return &email, nil
+}
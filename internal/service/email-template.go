@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/brand"
+	"github.com/luna4dev/airlock/internal/locale"
+	"github.com/luna4dev/airlock/internal/mailer"
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+var ErrUnknownEmailTemplate = errors.New("unknown email template")
+
+const (
+	sampleRecipient = "user@example.com"
+	sampleNewEmail  = "new.address@example.com"
+	sampleToken     = "0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+// emailPreview renders an email from its template with sample data
+type emailPreview struct {
+	template string
+	render   func(e *EmailService, lang string, profile *brand.Profile) (*mailer.Message, error)
+}
+
+// emailPreviews render every email airlock sends with sample data, to validate templates as
+// they load and to preview them. Only the sign-in email is branded for an app; the others
+// are always sent in the default brand. Each kind of security notice is previewed on its own.
+var emailPreviews = map[string]emailPreview{
+	"email-auth": {"email-auth", func(e *EmailService, lang string, profile *brand.Profile) (*mailer.Message, error) {
+		return e.AuthEmail(sampleRecipient, sampleToken, "", lang, profile)
+	}},
+	"email-change-confirm": {"email-change-confirm", func(e *EmailService, lang string, _ *brand.Profile) (*mailer.Message, error) {
+		return e.EmailChangeConfirmEmail(sampleNewEmail, sampleToken, lang)
+	}},
+	"email-change-notice": {"email-change-notice", func(e *EmailService, lang string, _ *brand.Profile) (*mailer.Message, error) {
+		return e.EmailChangeNoticeEmail(sampleRecipient, sampleNewEmail, sampleToken, lang)
+	}},
+	"email-dormancy-warning": {"email-dormancy-warning", func(e *EmailService, lang string, _ *brand.Profile) (*mailer.Message, error) {
+		return e.DormancyWarningEmail(sampleRecipient, 180, time.Now().AddDate(0, 0, 14), lang)
+	}},
+	"email-security-new-device-login": securityNoticePreview(SecurityNotice{
+		Type:      model.NotificationNewDeviceLogin,
+		Device:    "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 Safari/605.1.15",
+		IPAddress: "203.0.113.7",
+	}),
+	"email-security-sessions-revoked": securityNoticePreview(SecurityNotice{
+		Type: model.NotificationSessionsRevoked,
+	}),
+	"email-security-email-changed": securityNoticePreview(SecurityNotice{
+		Type:     model.NotificationEmailChanged,
+		NewEmail: sampleNewEmail,
+	}),
+	"email-security-service-access-changed": securityNoticePreview(SecurityNotice{
+		Type:    model.NotificationServiceAccessChanged,
+		Granted: []string{"PRUNK (SUPER_USER)"},
+		Removed: []string{"PRUNK (USER)"},
+	}),
+	"email-security-account-suspended": securityNoticePreview(SecurityNotice{
+		Type: model.NotificationAccountSuspended,
+	}),
+}
+
+// securityNoticePreview renders a security notice of one type, with an unsubscribe link
+// unless the type is critical
+func securityNoticePreview(notice SecurityNotice) emailPreview {
+	return emailPreview{"email-security-notice", func(e *EmailService, lang string, _ *brand.Profile) (*mailer.Message, error) {
+		notice.Time = time.Now()
+		token := ""
+		if !notice.Type.IsCritical() {
+			token = sampleToken
+		}
+		return e.SecurityNoticeEmail(sampleRecipient, notice, token, lang)
+	}}
+}
+
+// EmailTemplateNames lists the emails that can be previewed
+func EmailTemplateNames() []string {
+	names := make([]string, 0, len(emailPreviews))
+	for name := range emailPreviews {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// PreviewEmail renders an email with sample data in lang and the app's brand
+func (e *EmailService) PreviewEmail(name, lang string, profile *brand.Profile) (*mailer.Message, error) {
+	preview, ok := emailPreviews[name]
+	if !ok {
+		return nil, ErrUnknownEmailTemplate
+	}
+	return preview.render(e, lang, profile)
+}
+
+// PreviewEmail renders an email with sample data, see EmailService.PreviewEmail
+func (s *AccountService) PreviewEmail(name, lang string, profile *brand.Profile) (*mailer.Message, error) {
+	return s.emails.PreviewEmail(name, lang, profile)
+}
+
+// loadTemplates parses the embedded templates of every locale, replaced file by file with
+// those in the override directory, and checks that every email renders in every locale
+func (e *EmailService) loadTemplates() (map[string]*emailTemplates, error) {
+	var overrides fs.FS
+	if e.templateDir != "" {
+		overrides = os.DirFS(e.templateDir)
+	}
+
+	locales := make(map[string]*emailTemplates)
+	for _, lang := range locale.Supported {
+		html, err := template.ParseFS(TemplateFS, "assets/templates/"+lang+"/*.html")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s email templates: %w", lang, err)
+		}
+		text, err := texttemplate.ParseFS(TemplateFS, "assets/templates/"+lang+"/*.txt")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s email templates: %w", lang, err)
+		}
+
+		if overrides != nil {
+			// ParseFS fails on a pattern without matches, and a locale may override nothing
+			if files, _ := fs.Glob(overrides, lang+"/*.html"); len(files) > 0 {
+				if html, err = html.ParseFS(overrides, files...); err != nil {
+					return nil, fmt.Errorf("failed to parse %s email template overrides: %w", lang, err)
+				}
+			}
+			if files, _ := fs.Glob(overrides, lang+"/*.txt"); len(files) > 0 {
+				if text, err = text.ParseFS(overrides, files...); err != nil {
+					return nil, fmt.Errorf("failed to parse %s email template overrides: %w", lang, err)
+				}
+			}
+		}
+		locales[lang] = &emailTemplates{html: html, text: text}
+	}
+
+	candidate := &EmailService{brands: e.brands, locales: locales}
+	for _, lang := range locale.Supported {
+		for _, name := range EmailTemplateNames() {
+			templates, file := locales[lang], emailPreviews[name].template
+			if templates.html.Lookup(file+".html") == nil || templates.text.Lookup(file+".txt") == nil ||
+				templates.text.Lookup(file+".subject") == nil {
+				return nil, fmt.Errorf("%s email templates are missing %s.html, %s.txt or %s.subject", lang, file, file, file)
+			}
+			msg, err := candidate.PreviewEmail(name, lang, e.brands.Default())
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s email template: %w", lang, err)
+			}
+			if msg.Subject == "" {
+				return nil, fmt.Errorf("invalid %s email template: %s.subject is empty", lang, name)
+			}
+		}
+	}
+	return locales, nil
+}
+
+// WatchTemplates reloads the templates whenever a file in the override directory is
+// added, changed or removed, until the context is cancelled. Templates that fail to load
+// are logged and the current ones stay in use.
+func (e *EmailService) WatchTemplates(ctx context.Context) {
+	if e.templateDir == "" || e.reloadInterval <= 0 {
+		return
+	}
+
+	log.Printf("EmailService: Watching %s for email template changes", e.templateDir)
+	last := templateDirState(e.templateDir)
+	go func() {
+		ticker := time.NewTicker(e.reloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				state := templateDirState(e.templateDir)
+				if state == last {
+					continue
+				}
+				last = state
+
+				locales, err := e.loadTemplates()
+				if err != nil {
+					log.Printf("EmailService: Keeping the current email templates: %v", err)
+					continue
+				}
+				e.mu.Lock()
+				e.locales = locales
+				e.mu.Unlock()
+				log.Printf("EmailService: Reloaded email templates from %s", e.templateDir)
+			}
+		}
+	}()
+}
+
+// templateDirState summarizes the name, size and modification time of every template file,
+// so any change to the directory changes the summary
+func templateDirState(dir string) string {
+	var state strings.Builder
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			fmt.Fprintf(&state, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+	return state.String()
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/brand"
+	"github.com/luna4dev/airlock/internal/locale"
+)
+
+// writeTemplateOverride replaces an override template file under dir/<lang> at once, so
+// the watcher never sees it half written
+func writeTemplateOverride(t *testing.T, dir, lang, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, lang), 0700); err != nil {
+		t.Fatalf("failed to create override directory: %v", err)
+	}
+	tmp := filepath.Join(dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, lang, name)); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+}
+
+func TestPreviewEveryEmail(t *testing.T) {
+	emails := newTestAccountService(t).emails
+
+	for _, lang := range locale.Supported {
+		for _, name := range EmailTemplateNames() {
+			msg, err := emails.PreviewEmail(name, lang, emails.brands.Default())
+			if err != nil {
+				t.Errorf("previewing %s in %s failed: %v", name, lang, err)
+				continue
+			}
+			if msg.Subject == "" || msg.HTML == "" || msg.Text == "" {
+				t.Errorf("%s in %s rendered an empty part", name, lang)
+			}
+		}
+	}
+
+	if _, err := emails.PreviewEmail("email-unknown", locale.Default, emails.brands.Default()); !errors.Is(err, ErrUnknownEmailTemplate) {
+		t.Errorf("previewing an unknown email returned %v, want ErrUnknownEmailTemplate", err)
+	}
+}
+
+func TestEmailTemplateOverrides(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplateOverride(t, dir, "ko", "email-auth.txt",
+		`{{- define "email-auth.subject"}}{{.Brand.Name}} 로그인 링크{{end -}}
+로그인: {{.Link}}
+`)
+	t.Setenv("EMAIL_TEMPLATE_DIR", dir)
+	emails := newTestAccountService(t).emails
+
+	msg, err := emails.AuthEmail("user@example.com", "token", "", "ko", emails.brands.Default())
+	if err != nil {
+		t.Fatalf("rendering overridden email failed: %v", err)
+	}
+	if msg.Subject != "Luna4 로그인 링크" || !strings.HasPrefix(msg.Text, "로그인: https://") {
+		t.Errorf("override was not used: subject %q, text %q", msg.Subject, msg.Text)
+	}
+	// Files the override directory does not replace keep the embedded template
+	if !strings.Contains(msg.HTML, "Luna4") {
+		t.Errorf("embedded HTML template was not used")
+	}
+	english, err := emails.AuthEmail("user@example.com", "token", "", "en", emails.brands.Default())
+	if err != nil {
+		t.Fatalf("rendering en failed: %v", err)
+	}
+	if strings.Contains(english.Text, "로그인") {
+		t.Errorf("ko override changed the en email")
+	}
+}
+
+func TestEmailTemplateOverridesAreValidated(t *testing.T) {
+	for name, tc := range map[string]struct{ file, content string }{
+		"syntax error":  {"email-auth.txt", `{{define "email-auth.subject"}}Hi{{end}}{{.Link`},
+		"unknown field": {"email-auth.txt", `{{define "email-auth.subject"}}Hi{{end}}{{.Nope}}`},
+		"html error":    {"email-change-notice.html", `<p>{{.NewEmail.Missing}}</p>`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTemplateOverride(t, dir, "en", tc.file, tc.content)
+			t.Setenv("EMAIL_TEMPLATE_DIR", dir)
+			TemplateFS = repoFS
+			brands, err := brand.NewRegistry(brand.GetConfig())
+			if err != nil {
+				t.Fatalf("failed to create brand registry: %v", err)
+			}
+			if _, err := NewEmailService(brands); err == nil {
+				t.Errorf("invalid override %s was loaded", tc.file)
+			}
+		})
+	}
+}
+
+func TestWatchTemplatesReloads(t *testing.T) {
+	dir := t.TempDir()
+	override := func(subject string) string {
+		return `{{- define "email-auth.subject"}}` + subject + `{{end -}}` + "\n{{.Link}}\n"
+	}
+	writeTemplateOverride(t, dir, "en", "email-auth.txt", override("First"))
+	t.Setenv("EMAIL_TEMPLATE_DIR", dir)
+	emails := newTestAccountService(t).emails
+	emails.reloadInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	emails.WatchTemplates(ctx)
+
+	// waitForSubject polls until the sign-in email has the subject
+	waitForSubject := func(want string) {
+		t.Helper()
+		var subject string
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			msg, err := emails.AuthEmail("user@example.com", "token", "", "en", emails.brands.Default())
+			if err != nil {
+				t.Fatalf("rendering failed: %v", err)
+			}
+			if subject = msg.Subject; subject == want {
+				return
+			}
+		}
+		t.Fatalf("subject is %q, want %q", subject, want)
+	}
+	waitForSubject("First")
+
+	writeTemplateOverride(t, dir, "en", "email-auth.txt", override("Second"))
+	waitForSubject("Second")
+
+	// A broken template is not loaded and the current ones stay in use
+	writeTemplateOverride(t, dir, "en", "email-auth.txt", override("Broken")+"{{.Link")
+	time.Sleep(100 * time.Millisecond)
+	waitForSubject("Second")
+
+	// Removing the override goes back to the embedded template
+	if err := os.Remove(filepath.Join(dir, "en", "email-auth.txt")); err != nil {
+		t.Fatalf("failed to remove override: %v", err)
+	}
+	waitForSubject("Luna4 Authentication Request")
+}
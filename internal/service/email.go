@@ -2,97 +2,222 @@ package service
 
 import (
 	"bytes"
-	"context"
-	"embed"
+	"fmt"
 	"html/template"
+	"io/fs"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/ses"
-	"github.com/aws/aws-sdk-go-v2/service/ses/types"
+	"github.com/luna4dev/airlock/internal/brand"
+	"github.com/luna4dev/airlock/internal/locale"
+	"github.com/luna4dev/airlock/internal/mailer"
+	"github.com/luna4dev/airlock/internal/model"
 )
 
+// EmailService renders the emails airlock sends, each from an HTML and a plain text
+// template of the same name in the recipient's locale, branded for the app the user came
+// from. The text template also defines the subject as name.subject. Messages are delivered
+// through the outbox, see AccountService.QueueEmail.
 type EmailService struct {
-	client   *ses.Client
-	sender   string
-	template *template.Template
+	brands         *brand.Registry
+	templateDir    string
+	reloadInterval time.Duration
+
+	mu      sync.RWMutex
+	locales map[string]*emailTemplates
+}
+
+// emailTemplates are the templates of one locale, read from assets/templates/<locale> and
+// the override directory
+type emailTemplates struct {
+	html *template.Template
+	text *texttemplate.Template
 }
 
 type EmailData struct {
-	Link string
+	Brand *brand.Profile
+	Link  string
 }
 
-var TemplateFS embed.FS
+type DormancyWarningEmailData struct {
+	Brand        *brand.Profile
+	Link         string
+	InactiveDays int
+	SuspendAt    string
+}
 
-func NewEmailService() (*EmailService, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(os.Getenv("AWS_REGION")),
-	)
-	if err != nil {
-		return nil, err
-	}
+type EmailChangeEmailData struct {
+	Brand    *brand.Profile
+	Link     string
+	NewEmail string
+}
+
+// SecurityNotice describes an event a user is told about in a security notice email. Only
+// the fields of the notice's type are set.
+type SecurityNotice struct {
+	Type      model.NotificationType
+	Time      time.Time
+	Device    string   // NEW_DEVICE_LOGIN
+	IPAddress string   // NEW_DEVICE_LOGIN
+	NewEmail  string   // EMAIL_CHANGED
+	Granted   []string // SERVICE_ACCESS_CHANGED
+	Removed   []string // SERVICE_ACCESS_CHANGED
+}
 
-	client := ses.NewFromConfig(cfg)
-	sender := os.Getenv("EMAIL_AUTH_SENDER")
-	if sender == "" {
-		sender = "noreply@luna4.me"
+type SecurityNoticeEmailData struct {
+	Brand        *brand.Profile
+	Notification string
+	Time         string
+	Device       string
+	IPAddress    string
+	NewEmail     string
+	Granted      []string
+	Removed      []string
+	Unsubscribe  string
+}
+
+// TemplateFS holds assets/templates, embedded by main
+var TemplateFS fs.FS
+
+func NewEmailService(brands *brand.Registry) (*EmailService, error) {
+	e := &EmailService{
+		brands:         brands,
+		templateDir:    os.Getenv("EMAIL_TEMPLATE_DIR"),                                             // Default embedded templates only
+		reloadInterval: time.Duration(getEnvInt("EMAIL_TEMPLATE_RELOAD_INTERVAL", 5)) * time.Second, // Default every 5 seconds
 	}
 
-	tmpl, err := template.ParseFS(TemplateFS, "assets/templates/email-auth.html")
+	locales, err := e.loadTemplates()
 	if err != nil {
 		return nil, err
 	}
-
-	return &EmailService{
-		client:   client,
-		sender:   sender,
-		template: tmpl,
-	}, nil
+	e.locales = locales
+	return e, nil
 }
 
-func (e *EmailService) SendAuthEmail(ctx context.Context, email, token string, redirect string) error {
-	serviceURL := os.Getenv("SERVICE_URL")
-	if serviceURL == "" {
-		serviceURL = "localhost:8080"
-	}
+// AuthEmail renders the sign-in link sent to a user, in the brand of the app they are
+// signing in to
+func (e *EmailService) AuthEmail(email, token, redirect, lang string, profile *brand.Profile) (*mailer.Message, error) {
+	serviceURL := getServiceURL()
 
 	authPath := os.Getenv("EMAIL_AUTH_PATH")
 	if authPath == "" {
 		authPath = "/auth/email/verify"
 	}
 
-	link := "https://" + serviceURL + authPath + "?token=" + token + "&email=" + url.QueryEscape(email)
+	link := "https://" + serviceURL + authPath + "?token=" + token + "&email=" + url.QueryEscape(email) + "&lang=" + lang
 	if redirect != "" {
 		link += "&redirect=" + url.QueryEscape(redirect)
 	}
+	if !profile.IsDefault() {
+		link += "&app=" + profile.ID
+	}
+
+	return e.render(email, lang, "email-auth", profile, EmailData{Brand: profile, Link: link})
+}
+
+// DormancyWarningEmail tells a user their account will be suspended unless they sign in
+func (e *EmailService) DormancyWarningEmail(email string, inactiveDays int, suspendAt time.Time, lang string) (*mailer.Message, error) {
+	data := DormancyWarningEmailData{
+		Brand:        e.brands.Default(),
+		Link:         "https://" + getServiceURL() + "/app/?lang=" + lang,
+		InactiveDays: inactiveDays,
+		SuspendAt:    suspendAt.UTC().Format(locale.DateFormat(lang)),
+	}
+
+	return e.render(email, lang, "email-dormancy-warning", data.Brand, data)
+}
+
+// EmailChangeConfirmEmail carries the link that confirms a new address to that address
+func (e *EmailService) EmailChangeConfirmEmail(newEmail, token, lang string) (*mailer.Message, error) {
+	data := EmailChangeEmailData{
+		Brand:    e.brands.Default(),
+		Link:     "https://" + getServiceURL() + "/app/email-change.html?action=confirm&token=" + token + "&lang=" + lang,
+		NewEmail: newEmail,
+	}
 
-	var body bytes.Buffer
-	err := e.template.Execute(&body, EmailData{Link: link})
+	return e.render(newEmail, lang, "email-change-confirm", data.Brand, data)
+}
+
+// EmailChangeNoticeEmail tells the current address about a requested change, with a link to revert it
+func (e *EmailService) EmailChangeNoticeEmail(oldEmail, newEmail, token, lang string) (*mailer.Message, error) {
+	data := EmailChangeEmailData{
+		Brand:    e.brands.Default(),
+		Link:     "https://" + getServiceURL() + "/app/email-change.html?action=revert&token=" + token + "&lang=" + lang,
+		NewEmail: newEmail,
+	}
+
+	return e.render(oldEmail, lang, "email-change-notice", data.Brand, data)
+}
+
+// SecurityNoticeEmail tells a user about an event on their account. A notice with an
+// unsubscribe token links to a page that opts out of its type and offers one-click
+// unsubscribe through List-Unsubscribe.
+func (e *EmailService) SecurityNoticeEmail(email string, notice SecurityNotice, unsubscribeToken, lang string) (*mailer.Message, error) {
+	data := SecurityNoticeEmailData{
+		Brand:        e.brands.Default(),
+		Notification: string(notice.Type),
+		Time:         notice.Time.UTC().Format(locale.DateFormat(lang) + " 15:04 UTC"),
+		Device:       notice.Device,
+		IPAddress:    notice.IPAddress,
+		NewEmail:     notice.NewEmail,
+		Granted:      notice.Granted,
+		Removed:      notice.Removed,
+	}
+	if unsubscribeToken != "" {
+		data.Unsubscribe = "https://" + getServiceURL() + "/app/unsubscribe.html?token=" + unsubscribeToken + "&lang=" + lang
+	}
+
+	msg, err := e.render(email, lang, "email-security-notice", data.Brand, data)
 	if err != nil {
-		return err
-	}
-
-	input := &ses.SendEmailInput{
-		Source: aws.String(e.sender),
-		Destination: &types.Destination{
-			ToAddresses: []string{email},
-		},
-		Message: &types.Message{
-			Subject: &types.Content{
-				Data:    aws.String("Luna4 Authentication Request"),
-				Charset: aws.String("UTF-8"),
-			},
-			Body: &types.Body{
-				Html: &types.Content{
-					Data:    aws.String(body.String()),
-					Charset: aws.String("UTF-8"),
-				},
-			},
-		},
-	}
-
-	_, err = e.client.SendEmail(ctx, input)
-	return err
+		return nil, err
+	}
+	if unsubscribeToken != "" {
+		msg.Unsubscribe = "https://" + getServiceURL() + "/api/account/notifications/unsubscribe?token=" + unsubscribeToken
+	}
+	return msg, nil
+}
+
+// render executes the name.html, name.txt and name.subject templates of the locale into
+// a message sent from the brand's sender. An email the locale has no templates for is
+// rendered in the default locale.
+func (e *EmailService) render(email, lang, name string, profile *brand.Profile, data any) (*mailer.Message, error) {
+	e.mu.RLock()
+	locales := e.locales
+	e.mu.RUnlock()
+
+	templates, ok := locales[lang]
+	if !ok || templates.html.Lookup(name+".html") == nil || templates.text.Lookup(name+".txt") == nil {
+		templates = locales[locale.Default]
+	}
+
+	var html, text, subject bytes.Buffer
+	if err := templates.html.ExecuteTemplate(&html, name+".html", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s.html: %w", name, err)
+	}
+	if err := templates.text.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s.txt: %w", name, err)
+	}
+	if err := templates.text.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s.subject: %w", name, err)
+	}
+
+	return &mailer.Message{
+		From:    profile.Sender,
+		To:      email,
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    html.String(),
+		Text:    text.String(),
+	}, nil
+}
+
+func getServiceURL() string {
+	serviceURL := os.Getenv("SERVICE_URL")
+	if serviceURL == "" {
+		serviceURL = "localhost:8080"
+	}
+	return serviceURL
 }
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailServiceRendersLocale(t *testing.T) {
+	emails := newTestAccountService(t).emails
+	suspendAt := time.Date(2026, time.March, 5, 12, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		lang    string
+		subject string
+		date    string
+	}{
+		{"en", "Your Luna4 account will be suspended", "March 5, 2026"},
+		{"ko", "Luna4 계정이 곧 정지됩니다", "2026년 3월 5일"},
+	} {
+		msg, err := emails.DormancyWarningEmail("user@example.com", 90, suspendAt, tc.lang)
+		if err != nil {
+			t.Fatalf("rendering %s failed: %v", tc.lang, err)
+		}
+		if msg.Subject != tc.subject {
+			t.Errorf("%s subject is %q, want %q", tc.lang, msg.Subject, tc.subject)
+		}
+		for part, body := range map[string]string{"html": msg.HTML, "text": msg.Text} {
+			if !strings.Contains(body, tc.date) || !strings.Contains(body, "lang="+tc.lang) {
+				t.Errorf("%s %s body lacks the date %q or the lang parameter", tc.lang, part, tc.date)
+			}
+		}
+	}
+
+	// A locale without templates falls back to the default
+	msg, err := emails.AuthEmail("user@example.com", "token", "", "fr", emails.brands.Default())
+	if err != nil {
+		t.Fatalf("rendering an unsupported locale failed: %v", err)
+	}
+	english, err := emails.AuthEmail("user@example.com", "token", "", "en", emails.brands.Default())
+	if err != nil {
+		t.Fatalf("rendering en failed: %v", err)
+	}
+	if msg.Subject != english.Subject || msg.Subject == "" {
+		t.Errorf("fr subject is %q, want the default %q", msg.Subject, english.Subject)
+	}
+	if msg.From != emails.brands.Default().Sender {
+		t.Errorf("message is from %q, want the default sender", msg.From)
+	}
+}
+
+func TestEmailServiceRendersBrand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "brands.json")
+	profiles := `[{"id": "prunk", "name": "Prunk", "primaryColor": "#00aa00", "sender": "Prunk <hello@prunk.example.com>", "supportUrl": "https://prunk.example.com/help"}]`
+	if err := os.WriteFile(path, []byte(profiles), 0600); err != nil {
+		t.Fatalf("failed to write profiles: %v", err)
+	}
+	t.Setenv("BRAND_PROFILES_PATH", path)
+	emails := newTestAccountService(t).emails
+
+	prunk, ok := emails.brands.Lookup("prunk")
+	if !ok {
+		t.Fatal("prunk profile was not loaded")
+	}
+	msg, err := emails.AuthEmail("user@example.com", "token", "", "en", prunk)
+	if err != nil {
+		t.Fatalf("rendering branded email failed: %v", err)
+	}
+	if msg.From != prunk.Sender || !strings.Contains(msg.Subject, "Prunk") {
+		t.Errorf("branded email is from %q with subject %q", msg.From, msg.Subject)
+	}
+	for _, want := range []string{"#00aa00", "https://prunk.example.com/help", "&amp;app=prunk"} {
+		if !strings.Contains(msg.HTML, want) {
+			t.Errorf("branded HTML lacks %q", want)
+		}
+	}
+	if !strings.Contains(msg.Text, "&app=prunk") || strings.Contains(msg.Text, "Luna4") {
+		t.Errorf("branded text does not link to the app or still names Luna4:\n%s", msg.Text)
+	}
+
+	// The default brand leaves the app out of the link
+	msg, err = emails.AuthEmail("user@example.com", "token", "", "en", emails.brands.Default())
+	if err != nil {
+		t.Fatalf("rendering default email failed: %v", err)
+	}
+	if strings.Contains(msg.Text, "app=") || !strings.Contains(msg.Subject, "Luna4") {
+		t.Errorf("default email is %q:\n%s", msg.Subject, msg.Text)
+	}
+}
@@ -0,0 +1,442 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrDatabaseAhead     = errors.New("database schema is newer than this binary")
+	ErrChecksumMismatch  = errors.New("applied migration differs from the embedded migration")
+	ErrNoDownMigration   = errors.New("migration has no down migration")
+	ErrUnknownMigration  = errors.New("unknown migration version")
+	migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+)
+
+const createMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at BIGINT NOT NULL
+	)
+`
+
+// Migration is a numbered schema change read from <version>_<name>.up.sql and the
+// optional <version>_<name>.down.sql
+type Migration struct {
+	Version  int    `json:"version"`
+	Name     string `json:"name"`
+	Checksum string `json:"checksum"`
+	Up       string `json:"-"`
+	Down     string `json:"-"`
+}
+
+// MigrationStatus describes a migration known to the binary or recorded in the database
+type MigrationStatus struct {
+	Version          int    `json:"version"`
+	Name             string `json:"name"`
+	Applied          bool   `json:"applied"`
+	AppliedAt        *int64 `json:"appliedAt,omitempty"`
+	ChecksumMismatch bool   `json:"checksumMismatch,omitempty"`
+	Unknown          bool   `json:"unknown,omitempty"`
+}
+
+type appliedMigration struct {
+	version   int
+	name      string
+	checksum  string
+	appliedAt int64
+
+	// baseline is set for a legacy database's migration not yet written to schema_migrations
+	baseline bool
+}
+
+// Migrator applies and rolls back the migrations of a SQL store. Every migration runs
+// in its own transaction together with its schema_migrations row.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+
+	// bind rewrites ? placeholders for the database's dialect
+	bind func(query string) string
+
+	// hasTable reports whether the database has the table
+	hasTable func(ctx context.Context, name string) (bool, error)
+
+	// legacyVersion reports the schema version of a database created before
+	// schema_migrations existed, or 0 for an empty database
+	legacyVersion func(ctx context.Context) (int, error)
+}
+
+// Migratable is implemented by stores with a versioned SQL schema
+type Migratable interface {
+	Migrator() (*Migrator, error)
+}
+
+// Migrate brings the store's schema up to date. Stores without a versioned schema are left alone.
+func Migrate(ctx context.Context, store Store) error {
+	migratable, ok := store.(Migratable)
+	if !ok {
+		return nil
+	}
+
+	migrator, err := migratable.Migrator()
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		log.Printf("Database schema is up to date (version %d)", migrator.LatestVersion())
+	}
+	return nil
+}
+
+func newMigrator(db *sql.DB, migrationFS fs.FS, dir string, bind func(string) string, hasTable func(ctx context.Context, name string) (bool, error), legacyVersion func(ctx context.Context) (int, error)) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:            db,
+		migrations:    migrations,
+		bind:          bind,
+		hasTable:      hasTable,
+		legacyVersion: legacyVersion,
+	}, nil
+}
+
+func loadMigrations(migrationFS fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration directory %s: %w", dir, err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, migration.Name, match[2])
+		}
+
+		content, err := fs.ReadFile(migrationFS, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration file %s: %w", entry.Name(), err)
+		}
+
+		if match[3] == "up" {
+			sum := sha256.Sum256(content)
+			migration.Up = string(content)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up migration", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// LatestVersion returns the highest migration version embedded in the binary
+func (m *Migrator) LatestVersion() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status lists every known migration and any applied migration the binary does not know
+// about. It does not write to the database.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			appliedAt := record.appliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			status.ChecksumMismatch = record.checksum != migration.Checksum
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, record := range applied {
+		appliedAt := record.appliedAt
+		statuses = append(statuses, MigrationStatus{
+			Version:   record.version,
+			Name:      record.name,
+			Applied:   true,
+			AppliedAt: &appliedAt,
+			Unknown:   true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+
+	return statuses, nil
+}
+
+// Up applies every pending migration in order and returns them. With dryRun the
+// pending migrations are returned without being applied.
+func (m *Migrator) Up(ctx context.Context, dryRun bool) ([]Migration, error) {
+	applied, err := m.verify(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+
+	if dryRun {
+		return pending, nil
+	}
+	if err := m.record(ctx, applied); err != nil {
+		return nil, err
+	}
+
+	for i, migration := range pending {
+		log.Printf("Applying database migration %d (%s)", migration.Version, migration.Name)
+		err := m.inTx(ctx, migration.Up,
+			`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`,
+			migration.Version, migration.Name, migration.Checksum, time.Now().UnixMilli(),
+		)
+		if err != nil {
+			return pending[:i], fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	if len(pending) > 0 {
+		log.Printf("Database migration completed successfully to version %d", m.LatestVersion())
+	}
+	return pending, nil
+}
+
+// Down rolls back applied migrations, newest first, until the schema is at the target
+// version and returns them. With dryRun the migrations are returned without being rolled back.
+func (m *Migrator) Down(ctx context.Context, target int, dryRun bool) ([]Migration, error) {
+	applied, err := m.verify(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var rollback []Migration
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version <= target {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == "" {
+			return nil, fmt.Errorf("%w: %d (%s)", ErrNoDownMigration, migration.Version, migration.Name)
+		}
+		rollback = append(rollback, migration)
+	}
+
+	if dryRun {
+		return rollback, nil
+	}
+	if err := m.record(ctx, applied); err != nil {
+		return nil, err
+	}
+
+	for i, migration := range rollback {
+		log.Printf("Rolling back database migration %d (%s)", migration.Version, migration.Name)
+		err := m.inTx(ctx, migration.Down, `DELETE FROM schema_migrations WHERE version = ?`, migration.Version)
+		if err != nil {
+			return rollback[:i], fmt.Errorf("failed to roll back migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	return rollback, nil
+}
+
+// verify refuses to continue when the database has migrations the binary does not
+// know about or an applied migration was edited after the fact
+func (m *Migrator) verify(ctx context.Context) (map[int]appliedMigration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[int]Migration{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	for version, record := range applied {
+		migration, ok := known[version]
+		if !ok {
+			if version > m.LatestVersion() {
+				return nil, fmt.Errorf("%w: database is at version %d, binary supports up to %d", ErrDatabaseAhead, version, m.LatestVersion())
+			}
+			return nil, fmt.Errorf("%w: %d (%s)", ErrUnknownMigration, version, record.name)
+		}
+		if migration.Checksum != record.checksum {
+			return nil, fmt.Errorf("%w: %d (%s)", ErrChecksumMismatch, version, migration.Name)
+		}
+	}
+
+	return applied, nil
+}
+
+// applied reads schema_migrations without writing to the database. Databases created
+// before the table existed are baselined at their legacy version, which record persists.
+func (m *Migrator) applied(ctx context.Context) (map[int]appliedMigration, error) {
+	exists, err := m.hasTable(ctx, "schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up schema_migrations table: %w", err)
+	}
+
+	applied := map[int]appliedMigration{}
+	if exists {
+		rows, err := m.db.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var record appliedMigration
+			if err := rows.Scan(&record.version, &record.name, &record.checksum, &record.appliedAt); err != nil {
+				return nil, fmt.Errorf("failed to scan schema migration: %w", err)
+			}
+			applied[record.version] = record
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating over rows: %w", err)
+		}
+	}
+
+	if len(applied) > 0 {
+		return applied, nil
+	}
+
+	legacyVersion, err := m.legacyVersion(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read legacy schema version: %w", err)
+	}
+	return m.baseline(legacyVersion)
+}
+
+// baseline returns the migrations a legacy database already has as applied
+func (m *Migrator) baseline(version int) (map[int]appliedMigration, error) {
+	applied := map[int]appliedMigration{}
+	if version == 0 {
+		return applied, nil
+	}
+	if version > m.LatestVersion() {
+		return nil, fmt.Errorf("%w: database is at version %d, binary supports up to %d", ErrDatabaseAhead, version, m.LatestVersion())
+	}
+
+	now := time.Now().UnixMilli()
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			break
+		}
+		applied[migration.Version] = appliedMigration{
+			version:   migration.Version,
+			name:      migration.Name,
+			checksum:  migration.Checksum,
+			appliedAt: now,
+			baseline:  true,
+		}
+	}
+
+	return applied, nil
+}
+
+// record creates schema_migrations and writes the baseline of a legacy database, so
+// migrations can be applied or rolled back on top of it
+func (m *Migrator) record(ctx context.Context, applied map[int]appliedMigration) error {
+	if _, err := m.db.ExecContext(ctx, createMigrationsTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var versions []int
+	for version, record := range applied {
+		if record.baseline {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+	sort.Ints(versions)
+
+	log.Printf("Baselining existing database at schema version %d", versions[len(versions)-1])
+	for _, version := range versions {
+		record := applied[version]
+		_, err := m.db.ExecContext(ctx,
+			m.bind(`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`),
+			record.version, record.name, record.checksum, record.appliedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record baseline migration %d: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+// inTx runs the migration script and the bookkeeping statement in one transaction
+func (m *Migrator) inTx(ctx context.Context, script, query string, args ...any) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, m.bind(query), args...); err != nil {
+		return fmt.Errorf("failed to update schema_migrations: %w", err)
+	}
+
+	return tx.Commit()
+}
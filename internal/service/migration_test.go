@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// newTestMigrator opens an empty SQLite database and returns its migrator
+func newTestMigrator(t *testing.T) (*SQLiteService, *Migrator) {
+	t.Helper()
+	store, err := NewSQLiteService(testSQLiteConfig(t), repoFS)
+	if err != nil {
+		t.Fatalf("failed to open SQLite store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	migrator, err := store.Migrator()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	return store, migrator
+}
+
+func assertNoMigrationsTable(t *testing.T, store *SQLiteService) {
+	t.Helper()
+	exists, err := store.hasTable(context.Background(), "schema_migrations")
+	if err != nil {
+		t.Fatalf("failed to look up schema_migrations: %v", err)
+	}
+	if exists {
+		t.Error("schema_migrations was created")
+	}
+}
+
+func schemaVersion(t *testing.T, store *SQLiteService) int {
+	t.Helper()
+	var version int
+	if err := store.conn.QueryRow(`PRAGMA schema_version`).Scan(&version); err != nil {
+		t.Fatalf("failed to read schema version: %v", err)
+	}
+	return version
+}
+
+func TestMigratorUpDownRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, migrator := newTestMigrator(t)
+
+	applied, err := migrator.Up(ctx, false)
+	if err != nil {
+		t.Fatalf("failed to migrate up: %v", err)
+	}
+	if len(applied) != len(migrator.migrations) {
+		t.Fatalf("applied %d migrations, want %d", len(applied), len(migrator.migrations))
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("failed to read status: %v", err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.ChecksumMismatch || status.Unknown {
+			t.Errorf("migration %d has status %+v after up", status.Version, status)
+		}
+	}
+
+	rolledBack, err := migrator.Down(ctx, 0, false)
+	if err != nil {
+		t.Fatalf("failed to migrate down: %v", err)
+	}
+	if len(rolledBack) != len(migrator.migrations) {
+		t.Fatalf("rolled back %d migrations, want %d", len(rolledBack), len(migrator.migrations))
+	}
+
+	// Every down migration removes what its up migration created
+	var tables []string
+	rows, err := store.conn.Query(`SELECT name FROM sqlite_master WHERE type IN ('table', 'index', 'trigger', 'view') AND name NOT LIKE 'sqlite_%' AND name != 'schema_migrations'`)
+	if err != nil {
+		t.Fatalf("failed to list schema objects: %v", err)
+	}
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if len(tables) > 0 {
+		t.Errorf("schema objects left after rolling back every migration: %v", tables)
+	}
+
+	if _, err := migrator.Up(ctx, false); err != nil {
+		t.Fatalf("failed to migrate up again: %v", err)
+	}
+	pending, err := migrator.Up(ctx, true)
+	if err != nil {
+		t.Fatalf("failed to list pending migrations: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("%d migrations pending after up", len(pending))
+	}
+}
+
+func TestMigratorDetectsChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	migrator, err := store.Migrator()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	if _, err := store.conn.Exec(`UPDATE schema_migrations SET checksum = 'edited' WHERE version = 2`); err != nil {
+		t.Fatalf("failed to edit checksum: %v", err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("failed to read status: %v", err)
+	}
+	for _, status := range statuses {
+		if status.ChecksumMismatch != (status.Version == 2) {
+			t.Errorf("migration %d reports checksum mismatch %v", status.Version, status.ChecksumMismatch)
+		}
+	}
+
+	if _, err := migrator.Up(ctx, false); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("up returned %v, want ErrChecksumMismatch", err)
+	}
+	if _, err := migrator.Down(ctx, 0, true); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("down returned %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestMigratorRefusesNewerDatabase(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	migrator, err := store.Migrator()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	_, err = store.conn.Exec(`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, 'future', 'sum', 0)`, migrator.LatestVersion()+1)
+	if err != nil {
+		t.Fatalf("failed to record migration: %v", err)
+	}
+
+	if _, err := migrator.Up(ctx, false); !errors.Is(err, ErrDatabaseAhead) {
+		t.Errorf("up returned %v, want ErrDatabaseAhead", err)
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("failed to read status: %v", err)
+	}
+	if last := statuses[len(statuses)-1]; !last.Unknown {
+		t.Errorf("migration %d is not reported as unknown", last.Version)
+	}
+}
+
+func TestMigratorReadsWithoutWriting(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("empty", func(t *testing.T) {
+		store, migrator := newTestMigrator(t)
+		if _, err := migrator.Status(ctx); err != nil {
+			t.Fatalf("failed to read status: %v", err)
+		}
+		pending, err := migrator.Up(ctx, true)
+		if err != nil {
+			t.Fatalf("failed to list pending migrations: %v", err)
+		}
+		if len(pending) != len(migrator.migrations) {
+			t.Errorf("%d migrations pending, want %d", len(pending), len(migrator.migrations))
+		}
+		assertNoMigrationsTable(t, store)
+	})
+
+	t.Run("legacy", func(t *testing.T) {
+		store, migrator := newTestMigrator(t)
+
+		// Older releases created the initial schema and stored its version in PRAGMA schema_version
+		if _, err := store.conn.Exec(migrator.migrations[0].Up); err != nil {
+			t.Fatalf("failed to create legacy schema: %v", err)
+		}
+		if _, err := store.conn.Exec(`PRAGMA schema_version = 1`); err != nil {
+			t.Fatalf("failed to set legacy schema version: %v", err)
+		}
+
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			t.Fatalf("failed to read status: %v", err)
+		}
+		for _, status := range statuses {
+			if status.Applied != (status.Version == 1) {
+				t.Errorf("migration %d applied is %v on a version 1 database", status.Version, status.Applied)
+			}
+		}
+		pending, err := migrator.Up(ctx, true)
+		if err != nil {
+			t.Fatalf("failed to list pending migrations: %v", err)
+		}
+		if len(pending) != len(migrator.migrations)-1 {
+			t.Errorf("%d migrations pending, want %d", len(pending), len(migrator.migrations)-1)
+		}
+		rollback, err := migrator.Down(ctx, 0, true)
+		if err != nil {
+			t.Fatalf("failed to list migrations to roll back: %v", err)
+		}
+		if len(rollback) != 1 || rollback[0].Version != 1 {
+			t.Errorf("would roll back %v, want migration 1", rollback)
+		}
+
+		assertNoMigrationsTable(t, store)
+		if version := schemaVersion(t, store); version != 1 {
+			t.Errorf("schema version changed to %d", version)
+		}
+
+		// Migrating records the baseline and applies the rest
+		if _, err := migrator.Up(ctx, false); err != nil {
+			t.Fatalf("failed to migrate legacy database: %v", err)
+		}
+		statuses, err = migrator.Status(ctx)
+		if err != nil {
+			t.Fatalf("failed to read status: %v", err)
+		}
+		for _, status := range statuses {
+			if !status.Applied {
+				t.Errorf("migration %d is not applied after up", status.Version)
+			}
+		}
+	})
+}
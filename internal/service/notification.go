@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/locale"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/util"
+)
+
+var (
+	ErrInvalidNotificationOptOut = errors.New("only non-critical notifications can be opted out of")
+	ErrInvalidUnsubscribeToken   = errors.New("invalid unsubscribe token")
+)
+
+// notify queues a security notice to the recipient, normally the user's address, unless the
+// user opted out of its type. The event ID makes the notice idempotent, so it is queued once
+// per event.
+func (s *AccountService) notify(ctx context.Context, user *model.Luna4User, recipient, eventID string, notice SecurityNotice) error {
+	if !user.Preferences.Notifies(notice.Type) {
+		log.Printf("notify: User %s opted out of %s notices", user.ID, notice.Type)
+		return nil
+	}
+
+	token := ""
+	if !notice.Type.IsCritical() {
+		var err error
+		if token, err = util.GenerateUnsubscribeToken(user.ID, string(notice.Type)); err != nil {
+			return err
+		}
+	}
+	if notice.Time.IsZero() {
+		notice.Time = time.Now()
+	}
+
+	msg, err := s.emails.SecurityNoticeEmail(recipient, notice, token, locale.Resolve(user.Preferences.Language))
+	if err != nil {
+		return err
+	}
+	_, err = s.QueueEmail(ctx, "security-notice:"+string(notice.Type)+":"+eventID, msg, nil)
+	return err
+}
+
+// OptOutOfNotification stops the notices named by an unsubscribe token and returns their type
+func (s *AccountService) OptOutOfNotification(ctx context.Context, token string) (model.NotificationType, error) {
+	userID, notification, err := util.ParseUnsubscribeToken(token)
+	if err != nil {
+		return "", ErrInvalidUnsubscribeToken
+	}
+	optOut := model.NotificationType(notification)
+	if !optOut.IsValid() || optOut.IsCritical() {
+		return "", ErrInvalidUnsubscribeToken
+	}
+
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if user == nil {
+		return "", ErrInvalidUnsubscribeToken
+	}
+
+	preferences := user.Preferences
+	if slices.Contains(preferences.NotificationOptOuts, optOut) {
+		return optOut, nil
+	}
+	preferences.NotificationOptOuts = append(preferences.NotificationOptOuts, optOut)
+	return optOut, s.UpdateUserPreferences(ctx, userID, preferences, userID)
+}
+
+// SignOutUser ends every active session of the user, records who did it and why, and tells
+// the user they were signed out
+func (s *AccountService) SignOutUser(ctx context.Context, user *model.Luna4User, actor, reason string) (int64, error) {
+	var revoked int64
+	err := s.inTx(ctx, func(tx *AccountService) error {
+		var err error
+		if revoked, err = tx.RevokeSessions(ctx, user.ID, actor, reason); err != nil || revoked == 0 {
+			return err
+		}
+		return tx.notify(ctx, user, user.Email, uuid.New().String(), SecurityNotice{Type: model.NotificationSessionsRevoked})
+	})
+	return revoked, err
+}
+
+// GrantUserService gives the user a service and tells them about it
+func (s *AccountService) GrantUserService(ctx context.Context, user *model.Luna4User, grant *model.Luna4UserService) error {
+	return s.inTx(ctx, func(tx *AccountService) error {
+		if err := tx.CreateUserService(ctx, grant); err != nil {
+			return err
+		}
+		return tx.notifyServiceAccess(ctx, user, grant.ID, []string{describeGrant(*grant)}, nil)
+	})
+}
+
+// RemoveUserService takes a service away from the user and tells them about it
+func (s *AccountService) RemoveUserService(ctx context.Context, user *model.Luna4User, grant *model.Luna4UserService) error {
+	return s.inTx(ctx, func(tx *AccountService) error {
+		if err := tx.DeleteUserService(ctx, grant.ID); err != nil {
+			return err
+		}
+		return tx.notifyServiceAccess(ctx, user, grant.ID, nil, []string{describeGrant(*grant)})
+	})
+}
+
+// notifyServiceAccess tells the user which grants they received and lost, if any
+func (s *AccountService) notifyServiceAccess(ctx context.Context, user *model.Luna4User, eventID string, granted, removed []string) error {
+	if len(granted) == 0 && len(removed) == 0 {
+		return nil
+	}
+	return s.notify(ctx, user, user.Email, eventID, SecurityNotice{
+		Type:    model.NotificationServiceAccessChanged,
+		Granted: granted,
+		Removed: removed,
+	})
+}
+
+// diffGrants lists the grants in after but not before and those in before but not after,
+// so replacing a grant with an identical one is no change
+func diffGrants(before, after []model.Luna4UserService) (granted, removed []string) {
+	held := map[string]int{}
+	for _, grant := range before {
+		held[describeGrant(grant)]++
+	}
+	for _, grant := range after {
+		description := describeGrant(grant)
+		if held[description] > 0 {
+			held[description]--
+			continue
+		}
+		granted = append(granted, description)
+	}
+	for _, grant := range before {
+		description := describeGrant(grant)
+		if held[description] > 0 {
+			held[description]--
+			removed = append(removed, description)
+		}
+	}
+	return granted, removed
+}
+
+// describeGrant names a grant in notices, such as PRUNK (USER)
+func describeGrant(grant model.Luna4UserService) string {
+	return string(grant.Service) + " (" + string(grant.Permission) + ")"
+}
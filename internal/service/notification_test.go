@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/util"
+)
+
+// queuedNotices returns the security notices in the outbox, keyed by type
+func queuedNotices(t *testing.T, accounts *AccountService) map[model.NotificationType][]model.Luna4OutboxEmail {
+	t.Helper()
+	queued, err := accounts.GetOutboxEmails(context.Background(), model.OutboxStatusPending, 100)
+	if err != nil {
+		t.Fatalf("failed to read outbox: %v", err)
+	}
+	notices := make(map[model.NotificationType][]model.Luna4OutboxEmail)
+	for _, email := range queued {
+		if key, ok := strings.CutPrefix(email.IdempotencyKey, "security-notice:"); ok {
+			notification, _, _ := strings.Cut(key, ":")
+			notices[model.NotificationType(notification)] = append(notices[model.NotificationType(notification)], email)
+		}
+	}
+	return notices
+}
+
+// signIn redeems a new email auth for the user from the user agent
+func signIn(t *testing.T, accounts *AccountService, user *model.Luna4User, userAgent string) {
+	t.Helper()
+	emailAuth := createTestEmailAuth(t, accounts.Store, user.ID)
+	if _, err := accounts.RedeemEmailAuth(context.Background(), user, emailAuth.ID, userAgent, "203.0.113.7"); err != nil {
+		t.Fatalf("failed to sign in: %v", err)
+	}
+}
+
+// unsubscribeToken returns the token of the one-click unsubscribe link of a notice
+func unsubscribeToken(t *testing.T, notice model.Luna4OutboxEmail) string {
+	t.Helper()
+	link, err := url.Parse(notice.Unsubscribe)
+	if err != nil || link.Query().Get("token") == "" {
+		t.Fatalf("notice has no unsubscribe link: %q", notice.Unsubscribe)
+	}
+	return link.Query().Get("token")
+}
+
+func TestNewDeviceLoginNotice(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		accounts := newTestAccountServiceOn(t, store)
+		user := createTestUser(t, store, "device@example.com", model.UserStatusActive)
+
+		// Neither the first login nor one from a known device is news
+		signIn(t, accounts, user, "Laptop/1.0")
+		signIn(t, accounts, user, "Laptop/1.0")
+		if notices := queuedNotices(t, accounts); len(notices) != 0 {
+			t.Fatalf("known device queued notices %v", notices)
+		}
+
+		signIn(t, accounts, user, "Phone/2.0")
+		notices := queuedNotices(t, accounts)[model.NotificationNewDeviceLogin]
+		if len(notices) != 1 {
+			t.Fatalf("new device queued %d notices, want 1", len(notices))
+		}
+		notice := notices[0]
+		if notice.Recipient != user.Email || notice.Unsubscribe == "" {
+			t.Errorf("notice goes to %s with unsubscribe %q", notice.Recipient, notice.Unsubscribe)
+		}
+		for _, want := range []string{"Phone/2.0", "203.0.113.7"} {
+			if !strings.Contains(notice.Text, want) {
+				t.Errorf("notice does not mention %s:\n%s", want, notice.Text)
+			}
+		}
+	})
+}
+
+func TestOptOutOfNotification(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		user := createTestUser(t, store, "optout@example.com", model.UserStatusActive)
+
+		signIn(t, accounts, user, "Laptop/1.0")
+		if revoked, err := accounts.SignOutUser(ctx, user, "admin", "Lost laptop"); err != nil || revoked != 1 {
+			t.Fatalf("SignOutUser revoked %d sessions (%v), want 1", revoked, err)
+		}
+		notices := queuedNotices(t, accounts)[model.NotificationSessionsRevoked]
+		if len(notices) != 1 {
+			t.Fatalf("sign-out queued %d notices, want 1", len(notices))
+		}
+		token := unsubscribeToken(t, notices[0])
+
+		for range 2 {
+			optOut, err := accounts.OptOutOfNotification(ctx, token)
+			if err != nil || optOut != model.NotificationSessionsRevoked {
+				t.Fatalf("OptOutOfNotification returned %s (%v)", optOut, err)
+			}
+		}
+		stored, err := store.GetUserByID(ctx, user.ID)
+		if err != nil || stored == nil {
+			t.Fatalf("failed to read user: %v", err)
+		}
+		if !slices.Equal(stored.Preferences.NotificationOptOuts, []model.NotificationType{model.NotificationSessionsRevoked}) {
+			t.Errorf("opt-outs are %v after opting out twice", stored.Preferences.NotificationOptOuts)
+		}
+
+		// Later sign-outs respect the opt-out
+		signIn(t, accounts, stored, "Laptop/1.0")
+		if _, err := accounts.SignOutUser(ctx, stored, "admin", ""); err != nil {
+			t.Fatalf("SignOutUser failed: %v", err)
+		}
+		if notices := queuedNotices(t, accounts)[model.NotificationSessionsRevoked]; len(notices) != 1 {
+			t.Errorf("opted-out sign-out queued a notice: %d in total", len(notices))
+		}
+
+		critical, err := util.GenerateUnsubscribeToken(user.ID, string(model.NotificationAccountSuspended))
+		if err != nil {
+			t.Fatalf("failed to generate token: %v", err)
+		}
+		unknown, err := util.GenerateUnsubscribeToken(user.ID, "NEWSLETTER")
+		if err != nil {
+			t.Fatalf("failed to generate token: %v", err)
+		}
+		for name, token := range map[string]string{
+			"tampered":          "x" + token,
+			"critical":          critical,
+			"unknown type":      unknown,
+			"not a token":       "garbage",
+			"altered signature": strings.Replace(token, ".", ".a", 1),
+		} {
+			if _, err := accounts.OptOutOfNotification(ctx, token); !errors.Is(err, ErrInvalidUnsubscribeToken) {
+				t.Errorf("%s token returned %v, want ErrInvalidUnsubscribeToken", name, err)
+			}
+		}
+
+		preferences := model.UserPreferences{NotificationOptOuts: []model.NotificationType{model.NotificationEmailChanged}}
+		if err := accounts.UpdateUserPreferences(ctx, user.ID, preferences, user.ID); !errors.Is(err, ErrInvalidNotificationOptOut) {
+			t.Errorf("opting out of a critical notice returned %v, want ErrInvalidNotificationOptOut", err)
+		}
+	})
+}
+
+func TestAccountChangeNotices(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		user := createTestUser(t, store, "changes@example.com", model.UserStatusActive)
+
+		// Replacing grants tells the user what changed, and nothing when nothing did
+		if err := accounts.ReplaceUserServices(ctx, user.ID, testGrants(user.ID, false, model.UserServiceUser)); err != nil {
+			t.Fatalf("ReplaceUserServices failed: %v", err)
+		}
+		if err := accounts.ReplaceUserServices(ctx, user.ID, testGrants(user.ID, false, model.UserServiceUser)); err != nil {
+			t.Fatalf("ReplaceUserServices failed: %v", err)
+		}
+		if err := accounts.ReplaceUserServices(ctx, user.ID, testGrants(user.ID, false, model.UserServiceSuperUser)); err != nil {
+			t.Fatalf("ReplaceUserServices failed: %v", err)
+		}
+		access := queuedNotices(t, accounts)[model.NotificationServiceAccessChanged]
+		if len(access) != 2 {
+			t.Fatalf("grant changes queued %d notices, want 2", len(access))
+		}
+		var swapped bool
+		for _, notice := range access {
+			swapped = swapped || strings.Contains(notice.Text, "Granted: PRUNK (SUPER_USER)\nRemoved: PRUNK (USER)")
+		}
+		if !swapped {
+			t.Errorf("no notice lists the swapped grants")
+		}
+
+		// Suspension is critical and cannot be opted out of; a restore does not repeat it
+		if err := accounts.SuspendUser(ctx, user.ID, "admin", "Abuse"); err != nil {
+			t.Fatalf("SuspendUser failed: %v", err)
+		}
+		if err := accounts.DeleteUser(ctx, user.ID, "admin", ""); err != nil {
+			t.Fatalf("DeleteUser failed: %v", err)
+		}
+		if err := accounts.RestoreUser(ctx, user.ID, "admin", ""); err != nil {
+			t.Fatalf("RestoreUser failed: %v", err)
+		}
+		suspended := queuedNotices(t, accounts)[model.NotificationAccountSuspended]
+		if len(suspended) != 1 || suspended[0].Unsubscribe != "" {
+			t.Errorf("suspension queued %d notices, want 1 without an unsubscribe link", len(suspended))
+		}
+
+		// A confirmed email change tells the previous address
+		other := createTestUser(t, store, "old@example.com", model.UserStatusActive)
+		_, confirmToken, _ := createTestEmailChange(t, store, other, "new@example.com")
+		if _, err := accounts.ConfirmEmailChange(ctx, confirmToken); err != nil {
+			t.Fatalf("ConfirmEmailChange failed: %v", err)
+		}
+		changed := queuedNotices(t, accounts)[model.NotificationEmailChanged]
+		if len(changed) != 1 || changed[0].Recipient != "old@example.com" || !strings.Contains(changed[0].Text, "new@example.com") {
+			t.Errorf("email change queued %+v, want one notice to the old address", changed)
+		}
+	})
+}
@@ -0,0 +1,430 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/webauthn"
+)
+
+var (
+	ErrPasskeyNotFound  = errors.New("passkey not found")
+	ErrPasskeyExists    = errors.New("passkey is already registered")
+	ErrPasskeyChallenge = errors.New("passkey challenge is invalid, expired or already used")
+	ErrAccountInactive  = errors.New("account cannot sign in")
+)
+
+// maxPasskeyNameLength caps the name a user gives a passkey
+const maxPasskeyNameLength = 64
+
+// BeginPasskeyRegistration issues a challenge for the signed-in user to create a passkey with
+func (s *AccountService) BeginPasskeyRegistration(ctx context.Context, user *model.Luna4User) (*webauthn.CreationOptions, error) {
+	challenge, err := s.newWebAuthnChallenge(ctx, model.WebAuthnCeremonyRegistration, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.GetUserWebAuthnCredentials(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	exclude := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		exclude = append(exclude, webauthn.NewCredentialDescriptor(credential.ID, credential.Transports))
+	}
+
+	displayName := user.Name
+	if displayName == "" {
+		displayName = user.Email
+	}
+	return s.passkeys.CreationOptions(challenge, webauthn.User{ID: user.ID, Name: user.Email, DisplayName: displayName}, exclude), nil
+}
+
+// FinishPasskeyRegistration verifies the credential the user's authenticator created for
+// a registration challenge and saves it as a passkey with the given name
+func (s *AccountService) FinishPasskeyRegistration(ctx context.Context, user *model.Luna4User, name string, response *webauthn.RegistrationResponse) (*model.Luna4WebAuthnCredential, error) {
+	challenge, err := response.Challenge()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.consumeWebAuthnChallenge(ctx, challenge, model.WebAuthnCeremonyRegistration, user.ID); err != nil {
+		return nil, err
+	}
+
+	verified, err := s.passkeys.VerifyRegistration(response, challenge)
+	if err != nil {
+		log.Printf("FinishPasskeyRegistration: Rejected passkey for user %s: %v", user.ID, err)
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len([]rune(name)) > maxPasskeyNameLength {
+		name = string([]rune(name)[:maxPasskeyNameLength])
+	}
+
+	credential := &model.Luna4WebAuthnCredential{
+		ID:             verified.ID,
+		UserID:         user.ID,
+		Name:           name,
+		PublicKey:      verified.PublicKey,
+		Algorithm:      verified.Algorithm,
+		SignCount:      verified.SignCount,
+		AAGUID:         verified.AAGUID,
+		Transports:     verified.Transports,
+		BackupEligible: verified.BackupEligible,
+		BackedUp:       verified.BackedUp,
+		CreatedAt:      time.Now().UnixMilli(),
+	}
+
+	err = s.inTx(ctx, func(tx *AccountService) error {
+		existing, err := tx.GetWebAuthnCredential(ctx, credential.ID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return ErrPasskeyExists
+		}
+		if err := tx.CreateWebAuthnCredential(ctx, credential); err != nil {
+			return err
+		}
+		return tx.CreateAuditLog(ctx, user.ID, user.ID, model.AuditActionPasskeyRegistered, credential.Name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// BeginPasskeyLogin issues a challenge for signing in with any passkey
+func (s *AccountService) BeginPasskeyLogin(ctx context.Context) (*webauthn.RequestOptions, error) {
+	challenge, err := s.newWebAuthnChallenge(ctx, model.WebAuthnCeremonyAuthentication, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.passkeys.RequestOptions(challenge), nil
+}
+
+// FinishPasskeyLogin verifies a passkey assertion for an authentication challenge and
+// starts a session on the device with the given user agent. A user who may not sign in is
+// returned with ErrAccountInactive.
+func (s *AccountService) FinishPasskeyLogin(ctx context.Context, response *webauthn.AssertionResponse, userAgent, ipAddress string) (*model.Luna4User, *model.Luna4Session, error) {
+	challenge, err := response.Challenge()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.consumeWebAuthnChallenge(ctx, challenge, model.WebAuthnCeremonyAuthentication, ""); err != nil {
+		return nil, nil, err
+	}
+
+	credential, err := s.GetWebAuthnCredential(ctx, response.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if credential == nil {
+		return nil, nil, ErrPasskeyNotFound
+	}
+
+	// The user handle is optional in an assertion, but must name the owner when present
+	userID, err := response.UserID()
+	if err != nil {
+		return nil, nil, err
+	}
+	if userID != "" && userID != credential.UserID {
+		return nil, nil, fmt.Errorf("%w: user handle does not match the passkey", webauthn.ErrVerification)
+	}
+
+	assertion, err := s.passkeys.VerifyAssertion(response, challenge, credential.PublicKey, credential.SignCount)
+	if err != nil {
+		log.Printf("FinishPasskeyLogin: Rejected passkey %s of user %s: %v", credential.ID, credential.UserID, err)
+		return nil, nil, err
+	}
+
+	user, err := s.GetUserByID(ctx, credential.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, ErrPasskeyNotFound
+	}
+	if !user.Status.CanAuthenticate() {
+		return user, nil, ErrAccountInactive
+	}
+
+	var session *model.Luna4Session
+	err = s.inTx(ctx, func(tx *AccountService) error {
+		err := tx.MarkWebAuthnCredentialUsed(ctx, credential.ID, assertion.SignCount, assertion.BackedUp, time.Now().UnixMilli())
+		if err != nil {
+			return err
+		}
+		session, err = tx.startSession(ctx, user, userAgent, ipAddress)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, session, nil
+}
+
+// RemovePasskey deletes one of the user's passkeys and records who did it
+func (s *AccountService) RemovePasskey(ctx context.Context, userID, credentialID, actor string) error {
+	credential, err := s.GetWebAuthnCredential(ctx, credentialID)
+	if err != nil {
+		return err
+	}
+	if credential == nil || credential.UserID != userID {
+		return ErrPasskeyNotFound
+	}
+
+	return s.inTx(ctx, func(tx *AccountService) error {
+		if err := tx.DeleteWebAuthnCredential(ctx, credentialID); err != nil {
+			return err
+		}
+		return tx.CreateAuditLog(ctx, userID, actor, model.AuditActionPasskeyRemoved, credential.Name)
+	})
+}
+
+// newWebAuthnChallenge stores a fresh challenge that expires with the ceremony timeout
+func (s *AccountService) newWebAuthnChallenge(ctx context.Context, ceremony model.WebAuthnCeremony, userID string) (string, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = s.CreateWebAuthnChallenge(ctx, &model.Luna4WebAuthnChallenge{
+		ID:        challenge,
+		UserID:    userID,
+		Ceremony:  ceremony,
+		CreatedAt: now.UnixMilli(),
+		ExpiresAt: now.Add(s.passkeys.Timeout).UnixMilli(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// consumeWebAuthnChallenge uses up a challenge, failing unless it was issued for the
+// ceremony and user and has not expired
+func (s *AccountService) consumeWebAuthnChallenge(ctx context.Context, challenge string, ceremony model.WebAuthnCeremony, userID string) error {
+	stored, err := s.ConsumeWebAuthnChallenge(ctx, challenge)
+	if err != nil {
+		return err
+	}
+	if stored == nil || stored.Ceremony != ceremony || stored.UserID != userID || time.Now().UnixMilli() >= stored.ExpiresAt {
+		return ErrPasskeyChallenge
+	}
+	return nil
+}
+
+func (s *sqlStore) CreateWebAuthnCredential(ctx context.Context, credential *model.Luna4WebAuthnCredential) error {
+	log.Printf("CreateWebAuthnCredential: Creating passkey %s for user %s", credential.ID, credential.UserID)
+	query := `
+		INSERT INTO luna4_webauthn_credential (id, user_id, name, public_key, algorithm, sign_count, aaguid,
+			transports, backup_eligible, backed_up, created_at, last_used_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		credential.ID,
+		credential.UserID,
+		credential.Name,
+		credential.PublicKey,
+		credential.Algorithm,
+		int64(credential.SignCount),
+		credential.AAGUID,
+		strings.Join(credential.Transports, ","),
+		credential.BackupEligible,
+		credential.BackedUp,
+		credential.CreatedAt,
+		credential.LastUsedAt,
+	)
+	if err != nil {
+		log.Printf("CreateWebAuthnCredential: Failed to create passkey: %v", err)
+		return fmt.Errorf("failed to create passkey: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) GetWebAuthnCredential(ctx context.Context, credentialID string) (*model.Luna4WebAuthnCredential, error) {
+	query := `
+		SELECT ` + webAuthnCredentialColumns + `
+		FROM luna4_webauthn_credential
+		WHERE id = ?
+	`
+
+	credential, err := scanWebAuthnCredential(s.db.QueryRowContext(ctx, query, credentialID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log.Printf("GetWebAuthnCredential: No passkey found with ID: %s", credentialID)
+			return nil, nil
+		}
+		log.Printf("GetWebAuthnCredential: Failed to scan passkey: %v", err)
+		return nil, fmt.Errorf("failed to get passkey: %w", err)
+	}
+	return credential, nil
+}
+
+func (s *sqlStore) GetUserWebAuthnCredentials(ctx context.Context, userID string) ([]model.Luna4WebAuthnCredential, error) {
+	query := `
+		SELECT ` + webAuthnCredentialColumns + `
+		FROM luna4_webauthn_credential
+		WHERE user_id = ?
+		ORDER BY created_at
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.Printf("GetUserWebAuthnCredentials: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query passkeys: %w", err)
+	}
+	defer rows.Close()
+
+	credentials := []model.Luna4WebAuthnCredential{}
+	for rows.Next() {
+		credential, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			log.Printf("GetUserWebAuthnCredentials: Failed to scan passkey row: %v", err)
+			return nil, fmt.Errorf("failed to scan passkey: %w", err)
+		}
+		credentials = append(credentials, *credential)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("GetUserWebAuthnCredentials: Error during row iteration: %v", err)
+		return nil, fmt.Errorf("error iterating over passkey rows: %w", err)
+	}
+	return credentials, nil
+}
+
+func (s *sqlStore) MarkWebAuthnCredentialUsed(ctx context.Context, credentialID string, signCount uint32, backedUp bool, usedAt int64) error {
+	query := `UPDATE luna4_webauthn_credential SET sign_count = ?, backed_up = ?, last_used_at = ? WHERE id = ?`
+	if _, err := s.db.ExecContext(ctx, query, int64(signCount), backedUp, usedAt, credentialID); err != nil {
+		log.Printf("MarkWebAuthnCredentialUsed: Failed to update passkey %s: %v", credentialID, err)
+		return fmt.Errorf("failed to mark passkey as used: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) DeleteWebAuthnCredential(ctx context.Context, credentialID string) error {
+	log.Printf("DeleteWebAuthnCredential: Deleting passkey %s", credentialID)
+	result, err := s.db.ExecContext(ctx, `DELETE FROM luna4_webauthn_credential WHERE id = ?`, credentialID)
+	if err != nil {
+		log.Printf("DeleteWebAuthnCredential: Failed to delete passkey: %v", err)
+		return fmt.Errorf("failed to delete passkey: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrPasskeyNotFound
+	}
+	return nil
+}
+
+// CreateWebAuthnChallenge stores a challenge, clearing out challenges that expired unused
+func (s *sqlStore) CreateWebAuthnChallenge(ctx context.Context, challenge *model.Luna4WebAuthnChallenge) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM luna4_webauthn_challenge WHERE expires_at <= ?`, challenge.CreatedAt); err != nil {
+		log.Printf("CreateWebAuthnChallenge: Failed to delete expired challenges: %v", err)
+		return fmt.Errorf("failed to delete expired passkey challenges: %w", err)
+	}
+
+	query := `
+		INSERT INTO luna4_webauthn_challenge (id, user_id, ceremony, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err := s.db.ExecContext(ctx, query, challenge.ID, challenge.UserID, challenge.Ceremony, challenge.CreatedAt, challenge.ExpiresAt)
+	if err != nil {
+		log.Printf("CreateWebAuthnChallenge: Failed to create challenge: %v", err)
+		return fmt.Errorf("failed to create passkey challenge: %w", err)
+	}
+	return nil
+}
+
+// ConsumeWebAuthnChallenge removes and returns a challenge, or returns nil if it does not
+// exist or another request already consumed it
+func (s *sqlStore) ConsumeWebAuthnChallenge(ctx context.Context, challengeID string) (*model.Luna4WebAuthnChallenge, error) {
+	query := `
+		SELECT id, user_id, ceremony, created_at, expires_at
+		FROM luna4_webauthn_challenge
+		WHERE id = ?
+	`
+
+	var challenge model.Luna4WebAuthnChallenge
+	err := s.db.QueryRowContext(ctx, query, challengeID).Scan(
+		&challenge.ID,
+		&challenge.UserID,
+		&challenge.Ceremony,
+		&challenge.CreatedAt,
+		&challenge.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("ConsumeWebAuthnChallenge: Failed to scan challenge: %v", err)
+		return nil, fmt.Errorf("failed to get passkey challenge: %w", err)
+	}
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM luna4_webauthn_challenge WHERE id = ?`, challengeID)
+	if err != nil {
+		log.Printf("ConsumeWebAuthnChallenge: Failed to delete challenge: %v", err)
+		return nil, fmt.Errorf("failed to consume passkey challenge: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, nil
+	}
+	return &challenge, nil
+}
+
+const webAuthnCredentialColumns = `id, user_id, name, public_key, algorithm, sign_count, aaguid, transports,
+		backup_eligible, backed_up, created_at, last_used_at`
+
+func scanWebAuthnCredential(row rowScanner) (*model.Luna4WebAuthnCredential, error) {
+	var credential model.Luna4WebAuthnCredential
+	var signCount int64
+	var transports string
+	var lastUsedAt sql.NullInt64
+
+	err := row.Scan(
+		&credential.ID,
+		&credential.UserID,
+		&credential.Name,
+		&credential.PublicKey,
+		&credential.Algorithm,
+		&signCount,
+		&credential.AAGUID,
+		&transports,
+		&credential.BackupEligible,
+		&credential.BackedUp,
+		&credential.CreatedAt,
+		&lastUsedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	credential.SignCount = uint32(signCount)
+	if transports != "" {
+		credential.Transports = strings.Split(transports, ",")
+	}
+	if lastUsedAt.Valid {
+		credential.LastUsedAt = &lastUsedAt.Int64
+	}
+	return &credential, nil
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/webauthn"
+	"github.com/luna4dev/airlock/internal/webauthn/webauthntest"
+)
+
+// registerTestPasskey creates an authenticator for the user and registers its passkey
+func registerTestPasskey(t *testing.T, accounts *AccountService, user *model.Luna4User) (*webauthntest.Authenticator, *model.Luna4WebAuthnCredential) {
+	t.Helper()
+	ctx := context.Background()
+	authenticator, err := webauthntest.New(accounts.passkeys.RPID, accounts.passkeys.Origins[0], webauthntest.AlgES256)
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+	authenticator.UserHandle = user.ID
+
+	options, err := accounts.BeginPasskeyRegistration(ctx, user)
+	if err != nil {
+		t.Fatalf("failed to begin registration: %v", err)
+	}
+	body, err := authenticator.Register(options.Challenge)
+	if err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	var response webauthn.RegistrationResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatalf("failed to decode registration: %v", err)
+	}
+
+	credential, err := accounts.FinishPasskeyRegistration(ctx, user, "Laptop", &response)
+	if err != nil {
+		t.Fatalf("failed to finish registration: %v", err)
+	}
+	return authenticator, credential
+}
+
+// beginTestPasskeyLogin asks for a login challenge and answers it with the authenticator
+func beginTestPasskeyLogin(t *testing.T, accounts *AccountService, authenticator *webauthntest.Authenticator) *webauthn.AssertionResponse {
+	t.Helper()
+	options, err := accounts.BeginPasskeyLogin(context.Background())
+	if err != nil {
+		t.Fatalf("failed to begin login: %v", err)
+	}
+	body, err := authenticator.Assert(options.Challenge)
+	if err != nil {
+		t.Fatalf("failed to assert: %v", err)
+	}
+	var response webauthn.AssertionResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatalf("failed to decode assertion: %v", err)
+	}
+	return &response
+}
+
+func TestPasskeyLogin(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		user := createTestUser(t, store, "passkey@example.com", model.UserStatusActive)
+		authenticator, registered := registerTestPasskey(t, accounts, user)
+		if registered.UserID != user.ID || registered.ID != authenticator.ID() || registered.Name != "Laptop" {
+			t.Errorf("registered passkey %s named %s for %s", registered.ID, registered.Name, registered.UserID)
+		}
+
+		loggedIn, session, err := accounts.FinishPasskeyLogin(ctx, beginTestPasskeyLogin(t, accounts, authenticator), "test", "127.0.0.1")
+		if err != nil {
+			t.Fatalf("login failed: %v", err)
+		}
+		if loggedIn.ID != user.ID || session == nil || session.UserID != user.ID {
+			t.Errorf("login returned user %s with session %v, want %s", loggedIn.ID, session, user.ID)
+		}
+
+		credential, err := store.GetWebAuthnCredential(ctx, registered.ID)
+		if err != nil {
+			t.Fatalf("failed to read passkey: %v", err)
+		}
+		if credential.SignCount != 1 || credential.LastUsedAt == nil {
+			t.Errorf("passkey counted %d, last used %v, want 1 and set", credential.SignCount, credential.LastUsedAt)
+		}
+
+		// A cloned authenticator replaying an old counter is rejected
+		authenticator.SignCount = 0
+		if _, _, err := accounts.FinishPasskeyLogin(ctx, beginTestPasskeyLogin(t, accounts, authenticator), "test", "127.0.0.1"); !errors.Is(err, webauthn.ErrVerification) {
+			t.Errorf("login with a regressed counter returned %v, want ErrVerification", err)
+		}
+	})
+}
+
+func TestPasskeyChallengeCannotBeReplayed(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		user := createTestUser(t, store, "replay@example.com", model.UserStatusActive)
+		authenticator, _ := registerTestPasskey(t, accounts, user)
+
+		response := beginTestPasskeyLogin(t, accounts, authenticator)
+		if _, _, err := accounts.FinishPasskeyLogin(ctx, response, "test", "127.0.0.1"); err != nil {
+			t.Fatalf("login failed: %v", err)
+		}
+		if _, _, err := accounts.FinishPasskeyLogin(ctx, response, "test", "127.0.0.1"); !errors.Is(err, ErrPasskeyChallenge) {
+			t.Errorf("replayed login returned %v, want ErrPasskeyChallenge", err)
+		}
+
+		// A registration challenge cannot answer a login, nor belong to another user
+		options, err := accounts.BeginPasskeyRegistration(ctx, user)
+		if err != nil {
+			t.Fatalf("failed to begin registration: %v", err)
+		}
+		body, err := authenticator.Assert(options.Challenge)
+		if err != nil {
+			t.Fatalf("failed to assert: %v", err)
+		}
+		var assertion webauthn.AssertionResponse
+		json.Unmarshal(body, &assertion)
+		if _, _, err := accounts.FinishPasskeyLogin(ctx, &assertion, "test", "127.0.0.1"); !errors.Is(err, ErrPasskeyChallenge) {
+			t.Errorf("login with a registration challenge returned %v, want ErrPasskeyChallenge", err)
+		}
+
+		other := createTestUser(t, store, "other@example.com", model.UserStatusActive)
+		options, err = accounts.BeginPasskeyRegistration(ctx, user)
+		if err != nil {
+			t.Fatalf("failed to begin registration: %v", err)
+		}
+		second, err := webauthntest.New(accounts.passkeys.RPID, accounts.passkeys.Origins[0], webauthntest.AlgEdDSA)
+		if err != nil {
+			t.Fatalf("failed to create authenticator: %v", err)
+		}
+		body, err = second.Register(options.Challenge)
+		if err != nil {
+			t.Fatalf("failed to register: %v", err)
+		}
+		var registration webauthn.RegistrationResponse
+		json.Unmarshal(body, &registration)
+		if _, err := accounts.FinishPasskeyRegistration(ctx, other, "Phone", &registration); !errors.Is(err, ErrPasskeyChallenge) {
+			t.Errorf("registering with another user's challenge returned %v, want ErrPasskeyChallenge", err)
+		}
+	})
+}
+
+func TestPasskeyLoginRejectsMismatchedUserHandle(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		user := createTestUser(t, store, "owner@example.com", model.UserStatusActive)
+		other := createTestUser(t, store, "other@example.com", model.UserStatusActive)
+		authenticator, registered := registerTestPasskey(t, accounts, user)
+
+		authenticator.UserHandle = other.ID
+		if _, _, err := accounts.FinishPasskeyLogin(ctx, beginTestPasskeyLogin(t, accounts, authenticator), "test", "127.0.0.1"); !errors.Is(err, webauthn.ErrVerification) {
+			t.Errorf("login with another user's handle returned %v, want ErrVerification", err)
+		}
+
+		// The user handle is optional
+		authenticator.UserHandle = ""
+		loggedIn, _, err := accounts.FinishPasskeyLogin(ctx, beginTestPasskeyLogin(t, accounts, authenticator), "test", "127.0.0.1")
+		if err != nil {
+			t.Fatalf("login without a user handle failed: %v", err)
+		}
+		if loggedIn.ID != registered.UserID {
+			t.Errorf("logged in as %s, want the passkey's owner %s", loggedIn.ID, registered.UserID)
+		}
+	})
+}
+
+func TestConsumeWebAuthnChallengeOnce(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			t.Fatalf("failed to create challenge: %v", err)
+		}
+		now := time.Now()
+		err = store.CreateWebAuthnChallenge(ctx, &model.Luna4WebAuthnChallenge{
+			ID:        challenge,
+			Ceremony:  model.WebAuthnCeremonyAuthentication,
+			CreatedAt: now.UnixMilli(),
+			ExpiresAt: now.Add(time.Minute).UnixMilli(),
+		})
+		if err != nil {
+			t.Fatalf("failed to store challenge: %v", err)
+		}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		consumed := 0
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				stored, err := store.ConsumeWebAuthnChallenge(ctx, challenge)
+				if err != nil {
+					t.Errorf("consuming the challenge failed: %v", err)
+					return
+				}
+				if stored != nil {
+					mu.Lock()
+					consumed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if consumed != 1 {
+			t.Errorf("challenge was consumed %d times, want once", consumed)
+		}
+		if stored, err := store.ConsumeWebAuthnChallenge(ctx, challenge); err != nil || stored != nil {
+			t.Errorf("consuming a used challenge returned %v, %v, want nothing", stored, err)
+		}
+	})
+}
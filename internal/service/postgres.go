@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+type PostgresService struct {
+	*sqlStore
+	postgresMigrationFS *embed.FS
+}
+
+func NewPostgresService(dsn string, postgresMigrationFS *embed.FS) (*PostgresService, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PostgreSQL database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping PostgreSQL database: %w", err)
+	}
+
+	service := &PostgresService{
+		sqlStore: &sqlStore{
+			db:     rebindDB{db},
+			conn:   db,
+			wrapTx: func(tx *sql.Tx) dbExecutor { return rebindDB{tx} },
+		},
+		postgresMigrationFS: postgresMigrationFS,
+	}
+
+	return service, nil
+}
+
+// Migrator returns the migrator for configs/postgres-migration
+func (s *PostgresService) Migrator() (*Migrator, error) {
+	return newMigrator(s.conn, s.postgresMigrationFS, "configs/postgres-migration", rebind, s.hasTable, s.legacyVersion)
+}
+
+// legacyVersion treats databases created from the original schema file as version 1
+func (s *PostgresService) legacyVersion(ctx context.Context) (int, error) {
+	exists, err := s.hasTable(ctx, "luna4_users")
+	if err != nil || !exists {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (s *PostgresService) hasTable(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	err := s.conn.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists)
+	return exists, err
+}
+
+func (s *PostgresService) Close() error {
+	return s.conn.Close()
+}
+
+// rebindDB rewrites the ? placeholders used by sqlStore into PostgreSQL's $1, $2, ... form
+type rebindDB struct {
+	db dbExecutor
+}
+
+func (r rebindDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return r.db.ExecContext(ctx, rebind(query), args...)
+}
+
+func (r rebindDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return r.db.QueryContext(ctx, rebind(query), args...)
+}
+
+func (r rebindDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return r.db.QueryRowContext(ctx, rebind(query), args...)
+}
+
+func rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, ch := range query {
+		if ch == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
@@ -0,0 +1,497 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/util"
+)
+
+var (
+	ErrScimTokenNotFound = errors.New("SCIM token not found")
+	ErrInvalidTenant     = errors.New("tenant must be 1 to 64 letters, digits, dots, dashes or underscores")
+	ErrUnknownScimMember = errors.New("group member is not a user of this tenant")
+)
+
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// scimTokenTouchInterval limits how often the last use of a token is written
+const scimTokenTouchInterval = time.Minute
+
+const scimTokenColumns = `id, tenant, token_hash, created_at, last_used_at, revoked_at`
+
+// ScimActor is the actor recorded in audit entries for changes made by a tenant's directory
+func ScimActor(tenant string) string {
+	return "scim:" + tenant
+}
+
+// IssueScimToken creates a bearer token for the tenant. The token is only returned here;
+// airlock keeps its hash.
+func (s *AccountService) IssueScimToken(ctx context.Context, tenant string) (string, *model.Luna4ScimToken, error) {
+	if !tenantPattern.MatchString(tenant) {
+		return "", nil, ErrInvalidTenant
+	}
+
+	token, tokenHash, err := util.GenerateEmailToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	scimToken := &model.Luna4ScimToken{
+		ID:        uuid.New().String(),
+		Tenant:    tenant,
+		TokenHash: tokenHash,
+		CreatedAt: time.Now().UnixMilli(),
+	}
+	if err := s.CreateScimToken(ctx, scimToken); err != nil {
+		return "", nil, err
+	}
+	return token, scimToken, nil
+}
+
+// AuthenticateScimToken returns the token matching a bearer token, or nil if it is unknown
+// or revoked
+func (s *AccountService) AuthenticateScimToken(ctx context.Context, token string) (*model.Luna4ScimToken, error) {
+	tokenHash, err := util.HashEmailToken(token)
+	if err != nil {
+		return nil, nil
+	}
+
+	scimToken, err := s.GetScimTokenByHash(ctx, tokenHash)
+	if err != nil || scimToken == nil || scimToken.RevokedAt != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixMilli()
+	if scimToken.LastUsedAt == nil || now-*scimToken.LastUsedAt > scimTokenTouchInterval.Milliseconds() {
+		if err := s.MarkScimTokenUsed(ctx, scimToken.ID, now); err != nil {
+			log.Printf("AuthenticateScimToken: Failed to record use of token %s: %v", scimToken.ID, err)
+		}
+	}
+	return scimToken, nil
+}
+
+// GetScimAccount returns a user the tenant provisioned, or nil if the tenant has no such
+// user or the user is deleted
+func (s *AccountService) GetScimAccount(ctx context.Context, tenant, userID string) (*model.Luna4ScimAccount, error) {
+	link, err := s.GetScimUser(ctx, userID)
+	if err != nil || link == nil || link.Tenant != tenant {
+		return nil, err
+	}
+
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil || user == nil || user.Status == model.UserStatusDeleted {
+		return nil, err
+	}
+
+	services, err := s.GetUserServices(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if services == nil {
+		services = []model.Luna4UserService{}
+	}
+
+	return &model.Luna4ScimAccount{
+		Luna4UserWithServices: model.Luna4UserWithServices{Luna4User: *user, Services: services},
+		ExternalID:            link.ExternalID,
+	}, nil
+}
+
+// ProvisionScimUser creates a user for the tenant with the default PRUNK user grant, like
+// users created through the maintenance API
+func (s *AccountService) ProvisionScimUser(ctx context.Context, tenant string, user *model.Luna4User, externalID string) error {
+	return s.inTx(ctx, func(tx *AccountService) error {
+		existing, err := tx.GetUserByEmail(ctx, user.Email)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return ErrEmailTaken
+		}
+
+		services := []model.Luna4UserService{{
+			ID:         uuid.New().String(),
+			UserID:     user.ID,
+			Service:    model.Luna4ServicePrunk,
+			Permission: model.UserServiceUser,
+		}}
+		if err := tx.CreateUserWithServices(ctx, user, services); err != nil {
+			return err
+		}
+
+		link := &model.Luna4ScimUser{
+			UserID:     user.ID,
+			Tenant:     tenant,
+			ExternalID: externalID,
+			CreatedAt:  user.CreatedAt,
+		}
+		if err := tx.CreateScimUser(ctx, link); err != nil {
+			return err
+		}
+
+		return tx.CreateAuditLog(ctx, user.ID, ScimActor(tenant), model.AuditActionUserProvisioned, "")
+	})
+}
+
+// ScimUserUpdate holds the attributes a tenant's directory manages
+type ScimUserUpdate struct {
+	Email        string
+	Name         string
+	Organization string
+	ExternalID   string
+	Active       bool
+}
+
+// UpdateScimUser applies the directory's view of a user. The directory owns the address,
+// so email changes apply at once and end the user's sessions. Deactivating suspends the
+// user and activating brings a suspended or locked user back.
+func (s *AccountService) UpdateScimUser(ctx context.Context, tenant string, account *model.Luna4ScimAccount, update ScimUserUpdate) error {
+	actor := ScimActor(tenant)
+	userID := account.ID
+
+	return s.inTx(ctx, func(tx *AccountService) error {
+		if update.Email != account.Email {
+			existing, err := tx.GetUserByEmail(ctx, update.Email)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				return ErrEmailTaken
+			}
+			if err := tx.UpdateUserEmail(ctx, userID, update.Email); err != nil {
+				return err
+			}
+			if err := tx.CreateAuditLog(ctx, userID, actor, model.AuditActionEmailChanged, ""); err != nil {
+				return err
+			}
+			if _, err := tx.RevokeSessions(ctx, userID, actor, "Email changed by directory"); err != nil {
+				return err
+			}
+			err = tx.notify(ctx, &account.Luna4User, account.Email, uuid.New().String(), SecurityNotice{
+				Type:     model.NotificationEmailChanged,
+				NewEmail: update.Email,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if update.Name != account.Name || update.Organization != account.Organization {
+			if err := tx.UpdateUserProfile(ctx, userID, update.Name, update.Organization, account.Notes, actor); err != nil {
+				return err
+			}
+		}
+
+		if update.ExternalID != account.ExternalID {
+			if err := tx.UpdateScimUserExternalID(ctx, userID, update.ExternalID); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case update.Active && (account.Status == model.UserStatusSuspended || account.Status == model.UserStatusLocked):
+			return tx.TransitionUserStatus(ctx, userID, model.UserStatusActive, actor, "Activated by directory")
+		case !update.Active && account.Status != model.UserStatusSuspended:
+			return tx.TransitionUserStatus(ctx, userID, model.UserStatusSuspended, actor, "Deactivated by directory")
+		}
+		return nil
+	})
+}
+
+// DeprovisionScimUser suspends the user if needed and soft deletes them, so they are
+// purged after the usual retention window
+func (s *AccountService) DeprovisionScimUser(ctx context.Context, tenant string, account *model.Luna4ScimAccount) error {
+	actor := ScimActor(tenant)
+	return s.inTx(ctx, func(tx *AccountService) error {
+		if account.Status != model.UserStatusSuspended {
+			if err := tx.SuspendUser(ctx, account.ID, actor, "Deprovisioned by directory"); err != nil {
+				return err
+			}
+		}
+		return tx.DeleteUser(ctx, account.ID, actor, "Deprovisioned by directory")
+	})
+}
+
+// SetScimGroupMembers makes the given users the tenant's only holders of the service
+// permission: members without the grant receive it and other users of the tenant lose it
+func (s *AccountService) SetScimGroupMembers(ctx context.Context, tenant string, service model.Luna4Service, permission model.UserServicePermission, userIDs []string) error {
+	return s.inTx(ctx, func(tx *AccountService) error {
+		accounts, err := tx.GetScimAccounts(ctx, tenant)
+		if err != nil {
+			return err
+		}
+
+		members := map[string]bool{}
+		for _, userID := range userIDs {
+			members[userID] = true
+		}
+
+		for _, account := range accounts {
+			wanted := members[account.ID]
+			delete(members, account.ID)
+
+			held := false
+			var removed []string
+			for _, grant := range account.Services {
+				if grant.Service != service || grant.Permission != permission {
+					continue
+				}
+				held = true
+				if !wanted {
+					if err := tx.DeleteUserService(ctx, grant.ID); err != nil {
+						return err
+					}
+					removed = append(removed, describeGrant(grant))
+				}
+			}
+
+			var granted []string
+			if wanted && !held {
+				grant := &model.Luna4UserService{
+					ID:         uuid.New().String(),
+					UserID:     account.ID,
+					Service:    service,
+					Permission: permission,
+				}
+				if err := tx.CreateUserService(ctx, grant); err != nil {
+					return fmt.Errorf("failed to create user service %s: %w", service, err)
+				}
+				granted = append(granted, describeGrant(*grant))
+			}
+
+			if err := tx.notifyServiceAccess(ctx, &account.Luna4User, uuid.New().String(), granted, removed); err != nil {
+				return err
+			}
+		}
+
+		if len(members) > 0 {
+			return ErrUnknownScimMember
+		}
+		return nil
+	})
+}
+
+func (s *sqlStore) CreateScimToken(ctx context.Context, token *model.Luna4ScimToken) error {
+	log.Printf("CreateScimToken: Creating SCIM token %s for tenant %s", token.ID, token.Tenant)
+	query := `
+		INSERT INTO luna4_scim_token (` + scimTokenColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		token.ID,
+		token.Tenant,
+		token.TokenHash,
+		token.CreatedAt,
+		token.LastUsedAt,
+		token.RevokedAt,
+	)
+	if err != nil {
+		log.Printf("CreateScimToken: Failed to create SCIM token: %v", err)
+		return fmt.Errorf("failed to create SCIM token: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) GetScimTokenByHash(ctx context.Context, tokenHash string) (*model.Luna4ScimToken, error) {
+	query := `
+		SELECT ` + scimTokenColumns + `
+		FROM luna4_scim_token
+		WHERE token_hash = ?
+	`
+
+	token, err := scanScimToken(s.db.QueryRowContext(ctx, query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("GetScimTokenByHash: Failed to scan SCIM token: %v", err)
+		return nil, fmt.Errorf("failed to get SCIM token: %w", err)
+	}
+	return token, nil
+}
+
+func (s *sqlStore) GetScimTokens(ctx context.Context) ([]model.Luna4ScimToken, error) {
+	query := `
+		SELECT ` + scimTokenColumns + `
+		FROM luna4_scim_token
+		ORDER BY tenant, created_at
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("GetScimTokens: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query SCIM tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []model.Luna4ScimToken{}
+	for rows.Next() {
+		token, err := scanScimToken(rows)
+		if err != nil {
+			log.Printf("GetScimTokens: Failed to scan SCIM token row: %v", err)
+			return nil, fmt.Errorf("failed to scan SCIM token: %w", err)
+		}
+		tokens = append(tokens, *token)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("GetScimTokens: Error during row iteration: %v", err)
+		return nil, fmt.Errorf("error iterating over SCIM token rows: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *sqlStore) MarkScimTokenUsed(ctx context.Context, tokenID string, usedAt int64) error {
+	query := `UPDATE luna4_scim_token SET last_used_at = ? WHERE id = ?`
+	if _, err := s.db.ExecContext(ctx, query, usedAt, tokenID); err != nil {
+		return fmt.Errorf("failed to mark SCIM token as used: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) RevokeScimToken(ctx context.Context, tokenID string) error {
+	log.Printf("RevokeScimToken: Revoking SCIM token %s", tokenID)
+	query := `UPDATE luna4_scim_token SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`
+
+	result, err := s.db.ExecContext(ctx, query, time.Now().UnixMilli(), tokenID)
+	if err != nil {
+		log.Printf("RevokeScimToken: Failed to revoke SCIM token: %v", err)
+		return fmt.Errorf("failed to revoke SCIM token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrScimTokenNotFound
+	}
+	return nil
+}
+
+func (s *sqlStore) CreateScimUser(ctx context.Context, link *model.Luna4ScimUser) error {
+	query := `
+		INSERT INTO luna4_scim_user (user_id, tenant, external_id, created_at)
+		VALUES (?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query, link.UserID, link.Tenant, link.ExternalID, link.CreatedAt)
+	if err != nil {
+		log.Printf("CreateScimUser: Failed to link user %s to tenant %s: %v", link.UserID, link.Tenant, err)
+		return fmt.Errorf("failed to create SCIM user: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) GetScimUser(ctx context.Context, userID string) (*model.Luna4ScimUser, error) {
+	query := `
+		SELECT user_id, tenant, external_id, created_at
+		FROM luna4_scim_user
+		WHERE user_id = ?
+	`
+
+	var link model.Luna4ScimUser
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&link.UserID, &link.Tenant, &link.ExternalID, &link.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("GetScimUser: Failed to scan SCIM user: %v", err)
+		return nil, fmt.Errorf("failed to get SCIM user: %w", err)
+	}
+	return &link, nil
+}
+
+func (s *sqlStore) UpdateScimUserExternalID(ctx context.Context, userID, externalID string) error {
+	query := `UPDATE luna4_scim_user SET external_id = ? WHERE user_id = ?`
+	if _, err := s.db.ExecContext(ctx, query, externalID, userID); err != nil {
+		log.Printf("UpdateScimUserExternalID: Failed to update SCIM user %s: %v", userID, err)
+		return fmt.Errorf("failed to update SCIM user: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) GetScimAccounts(ctx context.Context, tenant string) ([]model.Luna4ScimAccount, error) {
+	query := `
+		SELECT ` + qualifiedUserColumns("u") + `, l.external_id, s.id, s.service, s.permission, s.expires_at
+		FROM luna4_users u
+		JOIN luna4_scim_user l ON l.user_id = u.id
+		LEFT JOIN luna4_user_service s ON s.user_id = u.id
+		WHERE l.tenant = ? AND u.status != ?
+		ORDER BY u.created_at, u.id, s.service
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, tenant, model.UserStatusDeleted)
+	if err != nil {
+		log.Printf("GetScimAccounts: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query SCIM users: %w", err)
+	}
+	defer rows.Close()
+
+	accounts := []model.Luna4ScimAccount{}
+	for rows.Next() {
+		var user model.Luna4User
+		var externalID string
+		var serviceID, service, permission sql.NullString
+		var expiresAt sql.NullInt64
+
+		if err := scanUser(rows, &user, &externalID, &serviceID, &service, &permission, &expiresAt); err != nil {
+			log.Printf("GetScimAccounts: Failed to scan user row: %v", err)
+			return nil, fmt.Errorf("failed to scan SCIM user: %w", err)
+		}
+
+		// Rows of the same user are adjacent
+		if len(accounts) == 0 || accounts[len(accounts)-1].ID != user.ID {
+			accounts = append(accounts, model.Luna4ScimAccount{
+				Luna4UserWithServices: model.Luna4UserWithServices{Luna4User: user, Services: []model.Luna4UserService{}},
+				ExternalID:            externalID,
+			})
+		}
+		if serviceID.Valid {
+			grant := model.Luna4UserService{
+				ID:         serviceID.String,
+				UserID:     user.ID,
+				Service:    model.Luna4Service(service.String),
+				Permission: model.UserServicePermission(permission.String),
+			}
+			if expiresAt.Valid {
+				grant.ExpiresAt = &expiresAt.Int64
+			}
+			last := &accounts[len(accounts)-1]
+			last.Services = append(last.Services, grant)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("GetScimAccounts: Error during row iteration: %v", err)
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+	return accounts, nil
+}
+
+func scanScimToken(row rowScanner) (*model.Luna4ScimToken, error) {
+	var token model.Luna4ScimToken
+	var lastUsedAt, revokedAt sql.NullInt64
+
+	err := row.Scan(&token.ID, &token.Tenant, &token.TokenHash, &token.CreatedAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Int64
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Int64
+	}
+	return &token, nil
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+func TestGetScimAccountsOrder(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		link := func(user *model.Luna4User, tenant string) {
+			t.Helper()
+			err := store.CreateScimUser(ctx, &model.Luna4ScimUser{UserID: user.ID, Tenant: tenant, CreatedAt: user.CreatedAt})
+			if err != nil {
+				t.Fatalf("failed to link user: %v", err)
+			}
+		}
+
+		// Users created in the same millisecond tie on creation time and are ordered by ID
+		var users []*model.Luna4User
+		for i := range 6 {
+			user := createTestUser(t, store, fmt.Sprintf("user%d@acme.example", i), model.UserStatusActive)
+			link(user, "acme")
+			users = append(users, user)
+		}
+		deleted := createTestUser(t, store, "deleted@acme.example", model.UserStatusDeleted)
+		link(deleted, "acme")
+		link(createTestUser(t, store, "bob@globex.example", model.UserStatusActive), "globex")
+
+		slices.SortFunc(users, func(a, b *model.Luna4User) int {
+			return cmp.Or(cmp.Compare(a.CreatedAt, b.CreatedAt), strings.Compare(a.ID, b.ID))
+		})
+		var want []string
+		for _, user := range users {
+			want = append(want, user.ID)
+		}
+
+		for range 3 {
+			accounts, err := store.GetScimAccounts(ctx, "acme")
+			if err != nil {
+				t.Fatalf("GetScimAccounts failed: %v", err)
+			}
+			var got []string
+			for _, account := range accounts {
+				got = append(got, account.ID)
+			}
+			if !slices.Equal(got, want) {
+				t.Fatalf("accounts are %v, want %v", got, want)
+			}
+		}
+	})
+}
+
+// provisionTestScimUser provisions an active user for the tenant and returns their account
+func provisionTestScimUser(t *testing.T, accounts *AccountService, tenant, email string) *model.Luna4ScimAccount {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now().UnixMilli()
+	user := &model.Luna4User{ID: uuid.New().String(), Email: email, Status: model.UserStatusActive, CreatedAt: now, UpdatedAt: now}
+	if err := accounts.ProvisionScimUser(ctx, tenant, user, "ext-"+email); err != nil {
+		t.Fatalf("ProvisionScimUser failed: %v", err)
+	}
+	account, err := accounts.GetScimAccount(ctx, tenant, user.ID)
+	if err != nil || account == nil {
+		t.Fatalf("provisioned account is missing (%v)", err)
+	}
+	return account
+}
+
+func TestUpdateScimUserEmailAndActive(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		account := provisionTestScimUser(t, accounts, "acme", "old@acme.example")
+		signIn(t, accounts, &account.Luna4User, "Laptop/1.0")
+
+		// The directory renames and deactivates the user in one request
+		update := ScimUserUpdate{Email: "new@acme.example", Name: "Renamed", ExternalID: account.ExternalID, Active: false}
+		if err := accounts.UpdateScimUser(ctx, "acme", account, update); err != nil {
+			t.Fatalf("UpdateScimUser failed: %v", err)
+		}
+		stored, err := store.GetUserByID(ctx, account.ID)
+		if err != nil || stored == nil {
+			t.Fatalf("failed to read user: %v", err)
+		}
+		if stored.Email != "new@acme.example" || stored.Name != "Renamed" || stored.Status != model.UserStatusSuspended {
+			t.Errorf("user is %s %q %s, want the new email and name, suspended", stored.Email, stored.Name, stored.Status)
+		}
+		if byEmail, err := store.GetUserByEmail(ctx, "new@acme.example"); err != nil || byEmail == nil || byEmail.ID != account.ID {
+			t.Errorf("new email finds %v (%v)", byEmail, err)
+		}
+		if n := countAuditLogs(t, store, account.ID, model.AuditActionEmailChanged); n != 1 {
+			t.Errorf("email change wrote %d audit entries, want 1", n)
+		}
+
+		// The old address is free again and the new one is taken
+		other := provisionTestScimUser(t, accounts, "acme", "old@acme.example")
+		update = ScimUserUpdate{Email: "new@acme.example", ExternalID: other.ExternalID, Active: true}
+		if err := accounts.UpdateScimUser(ctx, "acme", other, update); !errors.Is(err, ErrEmailTaken) {
+			t.Errorf("taking another user's email returned %v, want ErrEmailTaken", err)
+		}
+
+		// Reactivating and renaming back also commit together
+		account, err = accounts.GetScimAccount(ctx, "acme", account.ID)
+		if err != nil || account == nil {
+			t.Fatalf("failed to read account: %v", err)
+		}
+		update = ScimUserUpdate{Email: "back@acme.example", Name: "Renamed", ExternalID: account.ExternalID, Active: true}
+		if err := accounts.UpdateScimUser(ctx, "acme", account, update); err != nil {
+			t.Fatalf("UpdateScimUser failed: %v", err)
+		}
+		if stored, _ := store.GetUserByID(ctx, account.ID); stored == nil || stored.Email != "back@acme.example" || stored.Status != model.UserStatusActive {
+			t.Errorf("user is %+v, want back@acme.example and active", stored)
+		}
+	})
+}
+
+func TestDeprovisionScimUser(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+
+		// An active user is suspended and deleted in one transaction
+		account := provisionTestScimUser(t, accounts, "acme", "leaver@acme.example")
+		signIn(t, accounts, &account.Luna4User, "Laptop/1.0")
+		if err := accounts.DeprovisionScimUser(ctx, "acme", account); err != nil {
+			t.Fatalf("DeprovisionScimUser failed: %v", err)
+		}
+		stored, err := store.GetUserByID(ctx, account.ID)
+		if err != nil || stored == nil || stored.Status != model.UserStatusDeleted {
+			t.Fatalf("deprovisioned user is %+v (%v), want deleted", stored, err)
+		}
+		for _, action := range []model.AuditAction{model.AuditActionUserSuspended, model.AuditActionUserDeleted} {
+			if n := countAuditLogs(t, store, account.ID, action); n != 1 {
+				t.Errorf("deprovisioning wrote %d %s audit entries, want 1", n, action)
+			}
+		}
+		if gone, err := accounts.GetScimAccount(ctx, "acme", account.ID); err != nil || gone != nil {
+			t.Errorf("deprovisioned account is still listed: %v (%v)", gone, err)
+		}
+
+		// A suspended user is only deleted
+		suspended := provisionTestScimUser(t, accounts, "acme", "suspended@acme.example")
+		if err := accounts.SuspendUser(ctx, suspended.ID, "admin", ""); err != nil {
+			t.Fatalf("SuspendUser failed: %v", err)
+		}
+		suspended, err = accounts.GetScimAccount(ctx, "acme", suspended.ID)
+		if err != nil || suspended == nil {
+			t.Fatalf("failed to read account: %v", err)
+		}
+		if err := accounts.DeprovisionScimUser(ctx, "acme", suspended); err != nil {
+			t.Fatalf("DeprovisionScimUser failed: %v", err)
+		}
+		if stored, _ := store.GetUserByID(ctx, suspended.ID); stored == nil || stored.Status != model.UserStatusDeleted {
+			t.Errorf("deprovisioned user is %+v, want deleted", stored)
+		}
+	})
+}
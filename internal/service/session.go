@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+// maxUserAgentLength caps the user agent stored with a session
+const maxUserAgentLength = 512
+
+func (s *sqlStore) CreateSession(ctx context.Context, session *model.Luna4Session) error {
+	log.Printf("CreateSession: Creating session %s for user %s", session.ID, session.UserID)
+	query := `
+		INSERT INTO luna4_session (id, user_id, user_agent, ip_address, created_at, expires_at, revoked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		session.ID,
+		session.UserID,
+		session.UserAgent,
+		session.IPAddress,
+		session.CreatedAt,
+		session.ExpiresAt,
+		session.RevokedAt,
+	)
+	if err != nil {
+		log.Printf("CreateSession: Failed to create session: %v", err)
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return nil
+}
+
+func (s *sqlStore) GetSession(ctx context.Context, sessionID string) (*model.Luna4Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, ip_address, created_at, expires_at, revoked_at
+		FROM luna4_session
+		WHERE id = ?
+	`
+
+	row := s.db.QueryRowContext(ctx, query, sessionID)
+	session, err := scanSession(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log.Printf("GetSession: No session found with ID: %s", sessionID)
+			return nil, nil
+		}
+		log.Printf("GetSession: Failed to scan session: %v", err)
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return session, nil
+}
+
+func (s *sqlStore) GetUserSessions(ctx context.Context, userID string) ([]model.Luna4Session, error) {
+	log.Printf("GetUserSessions: Fetching sessions for user: %s", userID)
+	query := `
+		SELECT id, user_id, user_agent, ip_address, created_at, expires_at, revoked_at
+		FROM luna4_session
+		WHERE user_id = ?
+		ORDER BY created_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		log.Printf("GetUserSessions: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []model.Luna4Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			log.Printf("GetUserSessions: Failed to scan session row: %v", err)
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, *session)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("GetUserSessions: Error during row iteration: %v", err)
+		return nil, fmt.Errorf("error iterating over session rows: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// RevokeUserSessions ends every active session of the user and returns how many were revoked
+func (s *sqlStore) RevokeUserSessions(ctx context.Context, userID string) (int64, error) {
+	log.Printf("RevokeUserSessions: Revoking sessions for user: %s", userID)
+	query := `
+		UPDATE luna4_session
+		SET revoked_at = ?
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+	`
+
+	now := time.Now().UnixMilli()
+	result, err := s.db.ExecContext(ctx, query, now, userID, now)
+	if err != nil {
+		log.Printf("RevokeUserSessions: Failed to revoke sessions: %v", err)
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	log.Printf("RevokeUserSessions: Revoked %d sessions for user %s", rowsAffected, userID)
+	return rowsAffected, nil
+}
+
+// RevokeSessions ends every active session of the user and records who did it and why
+func (s *AccountService) RevokeSessions(ctx context.Context, userID, actor, reason string) (int64, error) {
+	revoked, err := s.RevokeUserSessions(ctx, userID)
+	if err != nil || revoked == 0 {
+		return revoked, err
+	}
+
+	return revoked, s.CreateAuditLog(ctx, userID, actor, model.AuditActionSessionsRevoked, reason)
+}
+
+func scanSession(row rowScanner) (*model.Luna4Session, error) {
+	var session model.Luna4Session
+	var revokedAt sql.NullInt64
+
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+		&revokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Int64
+	}
+	return &session, nil
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// dbExecutor is the part of *sql.DB and *sql.Tx the SQL stores run their queries through
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// sqlStore implements the Store queries in SQL shared by the SQLite and PostgreSQL backends.
+// Queries use ? placeholders; the PostgreSQL executor rewrites them.
+type sqlStore struct {
+	db dbExecutor
+
+	// conn is nil when the store is bound to a transaction
+	conn *sql.DB
+
+	// wrapTx adapts a transaction to the backend's dialect
+	wrapTx func(tx *sql.Tx) dbExecutor
+}
+
+// txStore is a sqlStore bound to a transaction. Closing it is left to the owning store.
+type txStore struct {
+	*sqlStore
+}
+
+func (s *txStore) Close() error {
+	return nil
+}
+
+func (s *sqlStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.conn == nil {
+		return fn(&txStore{s})
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var db dbExecutor = tx
+	if s.wrapTx != nil {
+		db = s.wrapTx(tx)
+	}
+
+	if err := fn(&txStore{&sqlStore{db: db}}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+)
+
+// Backup writes a consistent snapshot of the database to path with VACUUM INTO. It runs
+// on the read pool, so writers are not blocked while the snapshot is taken.
+func (s *SQLiteService) Backup(ctx context.Context, path string) error {
+	if _, err := s.reader.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// ValidateSQLiteBackup checks that the database at path passes SQLite's integrity and
+// foreign key checks and that its migrations match this binary. It returns the
+// backup's schema version. The file is opened read-only and left exactly as it was.
+func ValidateSQLiteBackup(ctx context.Context, path string, sqliteMigrationFS fs.FS) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, fmt.Errorf("failed to open backup: %w", err)
+	}
+
+	// immutable also keeps SQLite from creating journal or WAL files next to the backup
+	params := url.Values{}
+	params.Set("mode", "ro")
+	params.Set("immutable", "1")
+	db, err := sql.Open("sqlite3", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return 0, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	backup := &SQLiteService{
+		sqlStore:          &sqlStore{db: db, conn: db},
+		reader:            db,
+		path:              path,
+		sqliteMigrationFS: sqliteMigrationFS,
+	}
+
+	var integrity string
+	if err := backup.reader.QueryRowContext(ctx, `PRAGMA integrity_check`).Scan(&integrity); err != nil {
+		return 0, fmt.Errorf("failed to run integrity check: %w", err)
+	}
+	if integrity != "ok" {
+		return 0, fmt.Errorf("integrity check failed: %s", integrity)
+	}
+
+	rows, err := backup.reader.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to run foreign key check: %w", err)
+	}
+	violations := rows.Next()
+	rows.Close()
+	if violations {
+		return 0, fmt.Errorf("foreign key check failed")
+	}
+
+	migrator, err := backup.Migrator()
+	if err != nil {
+		return 0, err
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for _, status := range statuses {
+		if status.Unknown {
+			return 0, fmt.Errorf("%w: backup has migration %d (%s)", ErrDatabaseAhead, status.Version, status.Name)
+		}
+		if status.ChecksumMismatch {
+			return 0, fmt.Errorf("%w: %d (%s)", ErrChecksumMismatch, status.Version, status.Name)
+		}
+		if status.Applied {
+			version = status.Version
+		}
+	}
+	if version == 0 {
+		return 0, fmt.Errorf("backup has no airlock schema")
+	}
+
+	return version, nil
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+func TestBackupValidates(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	user := createTestUser(t, store, "backup@example.com", model.UserStatusActive)
+
+	path := filepath.Join(t.TempDir(), "backup.db")
+	if err := store.Backup(ctx, path); err != nil {
+		t.Fatalf("backup failed: %v", err)
+	}
+
+	migrator, err := store.Migrator()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read backup: %v", err)
+	}
+	version, err := ValidateSQLiteBackup(ctx, path, repoFS)
+	if err != nil {
+		t.Fatalf("backup failed validation: %v", err)
+	}
+	if version != migrator.LatestVersion() {
+		t.Errorf("backup is at version %d, want %d", version, migrator.LatestVersion())
+	}
+	assertUnchanged(t, path, contents)
+
+	backup := openTestStore(t, SQLiteConfig{Path: path, JournalMode: "DELETE", Synchronous: "FULL", ForeignKeys: true, MaxReadConns: 1})
+	stored, err := backup.GetUserByID(ctx, user.ID)
+	if err != nil || stored == nil || stored.Email != user.Email {
+		t.Errorf("backup has user %+v (%v), want %s", stored, err, user.Email)
+	}
+}
+
+func TestValidateSQLiteBackupRejects(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("edited migration", func(t *testing.T) {
+		store := newTestStore(t)
+		if _, err := store.conn.Exec(`UPDATE schema_migrations SET checksum = 'edited' WHERE version = 3`); err != nil {
+			t.Fatalf("failed to edit checksum: %v", err)
+		}
+		path := filepath.Join(t.TempDir(), "backup.db")
+		if err := store.Backup(ctx, path); err != nil {
+			t.Fatalf("backup failed: %v", err)
+		}
+		if _, err := ValidateSQLiteBackup(ctx, path, repoFS); !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("validation returned %v, want ErrChecksumMismatch", err)
+		}
+	})
+
+	t.Run("newer schema", func(t *testing.T) {
+		store := newTestStore(t)
+		if _, err := store.conn.Exec(`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (9999, 'future', 'sum', 0)`); err != nil {
+			t.Fatalf("failed to record migration: %v", err)
+		}
+		path := filepath.Join(t.TempDir(), "backup.db")
+		if err := store.Backup(ctx, path); err != nil {
+			t.Fatalf("backup failed: %v", err)
+		}
+		if _, err := ValidateSQLiteBackup(ctx, path, repoFS); !errors.Is(err, ErrDatabaseAhead) {
+			t.Errorf("validation returned %v, want ErrDatabaseAhead", err)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing.db")
+		if _, err := ValidateSQLiteBackup(ctx, path, repoFS); err == nil {
+			t.Error("a missing file passed validation")
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("validation created %s (%v)", path, err)
+		}
+	})
+
+	t.Run("empty database", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "empty.db")
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		if _, err := ValidateSQLiteBackup(ctx, path, repoFS); err == nil {
+			t.Error("an empty database passed validation")
+		}
+		assertUnchanged(t, path, nil)
+	})
+
+	t.Run("not a database", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "garbage.db")
+		if err := os.WriteFile(path, []byte("this is not a SQLite database, just some text long enough to fill a header"), 0600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		if _, err := ValidateSQLiteBackup(ctx, path, repoFS); err == nil {
+			t.Error("a file that is not a database passed validation")
+		}
+	})
+}
+
+// assertUnchanged checks that validation left the file at path with its contents and
+// created nothing beside it
+func assertUnchanged(t *testing.T, path string, contents []byte) {
+	t.Helper()
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	if !bytes.Equal(after, contents) {
+		t.Errorf("validation changed %s", path)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("failed to list %s: %v", filepath.Dir(path), err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("validation left %d files beside %s", len(entries)-1, filepath.Base(path))
+	}
+}
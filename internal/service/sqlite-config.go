@@ -0,0 +1,72 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLiteConfig holds the connection settings for the SQLite backend
+type SQLiteConfig struct {
+	Path         string
+	JournalMode  string
+	Synchronous  string
+	BusyTimeout  time.Duration
+	ForeignKeys  bool
+	MaxReadConns int
+}
+
+// GetSQLiteConfig reads the SQLite connection settings from the environment
+func GetSQLiteConfig() SQLiteConfig {
+	return SQLiteConfig{
+		Path:         getEnvString("SQLITE_PATH", "data/airlock.db"),                           // Default data/airlock.db
+		JournalMode:  strings.ToUpper(getEnvString("SQLITE_JOURNAL_MODE", "WAL")),              // Default WAL
+		Synchronous:  strings.ToUpper(getEnvString("SQLITE_SYNCHRONOUS", "NORMAL")),            // Default NORMAL, safe with WAL
+		BusyTimeout:  time.Duration(getEnvInt("SQLITE_BUSY_TIMEOUT", 5000)) * time.Millisecond, // Default 5 seconds
+		ForeignKeys:  os.Getenv("SQLITE_FOREIGN_KEYS") != "false",                              // Default on
+		MaxReadConns: getEnvInt("SQLITE_MAX_READ_CONNS", runtime.NumCPU()),                     // Default one per CPU
+	}
+}
+
+// writeDSN returns the DSN of the single writer connection. Transactions take the write
+// lock up front so concurrent writers wait for the busy timeout instead of failing.
+func (c SQLiteConfig) writeDSN() string {
+	params := c.params()
+	params.Set("_journal_mode", c.JournalMode)
+	params.Set("_synchronous", c.Synchronous)
+	params.Set("_txlock", "immediate")
+	return "file:" + c.Path + "?" + params.Encode()
+}
+
+// readDSN returns the DSN of the read-only connection pool
+func (c SQLiteConfig) readDSN() string {
+	params := c.params()
+	params.Set("mode", "ro")
+	return "file:" + c.Path + "?" + params.Encode()
+}
+
+func (c SQLiteConfig) params() url.Values {
+	params := url.Values{}
+	params.Set("_busy_timeout", strconv.FormatInt(c.BusyTimeout.Milliseconds(), 10))
+	params.Set("_foreign_keys", fmt.Sprint(c.ForeignKeys))
+	return params
+}
+
+func getEnvString(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
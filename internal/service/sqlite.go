@@ -1,27 +1,27 @@
 package service
 
 import (
+	"context"
 	"database/sql"
-	"embed"
+	"errors"
 	"fmt"
-	"log"
+	"io/fs"
 	"os"
 	"path/filepath"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-const CURRENT_SCHEMA_VERSION = 2
-
 type SQLiteService struct {
-	db                *sql.DB
-	sqliteSchemaFS    *embed.FS
-	sqliteMigrationFS *embed.FS
+	*sqlStore
+	reader            *sql.DB
+	path              string
+	sqliteMigrationFS fs.FS
 }
 
-func NewSQLiteService(dbPath string, sqliteSchemaFS *embed.FS, sqliteMigrationFS *embed.FS) (*SQLiteService, error) {
+func NewSQLiteService(config SQLiteConfig, sqliteMigrationFS fs.FS) (*SQLiteService, error) {
 	// Create directory if it doesn't exist
-	dir := filepath.Dir(dbPath)
+	dir := filepath.Dir(config.Path)
 	if dir != "." && dir != "/" {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return nil, fmt.Errorf("failed to create database directory %s: %w", dir, err)
@@ -29,105 +29,104 @@ func NewSQLiteService(dbPath string, sqliteSchemaFS *embed.FS, sqliteMigrationFS
 	}
 
 	// Create database file if it doesn't exist
-	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-		file, err := os.Create(dbPath)
+	if _, err := os.Stat(config.Path); os.IsNotExist(err) {
+		file, err := os.Create(config.Path)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create database file %s: %w", dbPath, err)
+			return nil, fmt.Errorf("failed to create database file %s: %w", config.Path, err)
 		}
 		file.Close()
 	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	// SQLite allows one writer at a time, so writes share a single connection
+	writer, err := sql.Open("sqlite3", config.writeDSN())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
 	}
+	writer.SetMaxOpenConns(1)
 
-	if err := db.Ping(); err != nil {
+	if err := writer.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping SQLite database: %w", err)
 	}
 
-	service := &SQLiteService{db: db, sqliteSchemaFS: sqliteSchemaFS, sqliteMigrationFS: sqliteMigrationFS}
+	reader, err := sql.Open("sqlite3", config.readDSN())
+	if err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("failed to open SQLite database for reading: %w", err)
+	}
+	reader.SetMaxOpenConns(max(config.MaxReadConns, 1))
 
-	// Initialize the database schema
-	if err := service.initSchema(); err != nil {
-		return nil, fmt.Errorf("failed to initialize database schema: %w", err)
+	if err := reader.Ping(); err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("failed to ping SQLite database for reading: %w", err)
+	}
+
+	service := &SQLiteService{
+		sqlStore:          &sqlStore{db: splitDB{reader: reader, writer: writer}, conn: writer},
+		reader:            reader,
+		path:              config.Path,
+		sqliteMigrationFS: sqliteMigrationFS,
 	}
 
 	return service, nil
 }
 
-func (s *SQLiteService) getSchemaVersion() int {
-	var version int
-	row := s.db.QueryRow("PRAGMA schema_version")
-	row.Scan(&version)
-	return version
+// Migrator returns the migrator for configs/sqlite-migration
+func (s *SQLiteService) Migrator() (*Migrator, error) {
+	return newMigrator(s.conn, s.sqliteMigrationFS, "configs/sqlite-migration", func(query string) string { return query }, s.hasTable, s.legacyVersion)
 }
 
-func (s *SQLiteService) initSchema() error {
-	// Note that schema version is set at the end of the schema-v#.sql file
-	currentVersion := s.getSchemaVersion()
-
-	if currentVersion < CURRENT_SCHEMA_VERSION {
-		log.Printf("Database migration required: current version %d, target version %d", currentVersion, CURRENT_SCHEMA_VERSION)
-		// Recursively migrate up to current version
-		if err := s.migrateToVersion(currentVersion + 1); err != nil {
-			return fmt.Errorf("failed to migrate database: %w", err)
-		}
-		log.Printf("Database migration completed successfully to version %d", CURRENT_SCHEMA_VERSION)
-	} else {
-		log.Printf("Database schema is up to date (version %d)", currentVersion)
-	}
-
-	// read schema and sync
-	schemaFileName := fmt.Sprintf("configs/sqlite-schema/schema-v%d.sql", CURRENT_SCHEMA_VERSION)
-	schemaBytes, err := s.sqliteSchemaFS.ReadFile(schemaFileName)
-	if err != nil {
-		return fmt.Errorf("failed to read schema file %s: %w", schemaFileName, err)
-	}
-
-	// apply schema
-	schema := string(schemaBytes)
-	_, err = s.db.Exec(schema)
-	if err != nil {
-		// TODO: if the flow fails here because of the SQL syntax error
-		//		 the schema_version bump up (should not happen). Prevent the version bump
-		return fmt.Errorf("failed to execute schema: %w", err)
+// legacyVersion reads the version older releases stored in PRAGMA schema_version
+func (s *SQLiteService) legacyVersion(ctx context.Context) (int, error) {
+	exists, err := s.hasTable(ctx, "luna4_users")
+	if err != nil || !exists {
+		return 0, err
 	}
 
-	return nil
+	var version int
+	err = s.conn.QueryRowContext(ctx, "PRAGMA schema_version").Scan(&version)
+	return version, err
 }
 
-func (s *SQLiteService) migrateToVersion(targetVersion int) error {
-	if targetVersion > CURRENT_SCHEMA_VERSION {
-		return nil
-	}
+func (s *SQLiteService) hasTable(ctx context.Context, name string) (bool, error) {
+	var tables int
+	err := s.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&tables)
+	return tables > 0, err
+}
 
-	log.Printf("Applying database migration to version %d", targetVersion)
+// Path returns the database file path
+func (s *SQLiteService) Path() string {
+	return s.path
+}
 
-	// Apply migration for target version
-	migrationFileName := fmt.Sprintf("configs/sqlite-migration/migration-v%d.sql", targetVersion)
-	migrationBytes, err := s.sqliteMigrationFS.ReadFile(migrationFileName)
+// Exclusive runs fn on the writer connection. Other writes wait until fn returns.
+func (s *SQLiteService) Exclusive(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := s.conn.Conn(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to read migration file %s: %w", migrationFileName, err)
+		return fmt.Errorf("failed to acquire writer connection: %w", err)
 	}
+	defer conn.Close()
+	return fn(conn)
+}
 
-	// Execute migration
-	migration := string(migrationBytes)
-	_, err = s.db.Exec(migration)
-	if err != nil {
-		return fmt.Errorf("failed to execute migration v%d: %w", targetVersion, err)
-	}
+func (s *SQLiteService) Close() error {
+	return errors.Join(s.reader.Close(), s.conn.Close())
+}
 
-	log.Printf("Successfully applied migration to version %d", targetVersion)
+// splitDB sends queries to the read pool and statements to the writer. Transactions
+// always run on the writer.
+type splitDB struct {
+	reader *sql.DB
+	writer *sql.DB
+}
 
-	// Recursively migrate to next version if needed
-	if targetVersion < CURRENT_SCHEMA_VERSION {
-		return s.migrateToVersion(targetVersion + 1)
-	}
+func (d splitDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return d.writer.ExecContext(ctx, query, args...)
+}
 
-	return nil
+func (d splitDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.reader.QueryContext(ctx, query, args...)
 }
 
-func (s *SQLiteService) Close() error {
-	return s.db.Close()
+func (d splitDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return d.reader.QueryRowContext(ctx, query, args...)
 }
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+func countRows(t *testing.T, store *SQLiteService, table, userID string) int {
+	t.Helper()
+	var count int
+	if err := store.conn.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE user_id = ?`, userID).Scan(&count); err != nil {
+		t.Fatalf("failed to count %s rows: %v", table, err)
+	}
+	return count
+}
+
+func TestSQLiteDeleteUserCascades(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	user := createTestUser(t, store, "cascade@example.com", model.UserStatusActive)
+	other := createTestUser(t, store, "other@example.com", model.UserStatusActive)
+
+	now := time.Now().UnixMilli()
+	for _, owner := range []*model.Luna4User{user, other} {
+		createTestEmailAuth(t, store, owner.ID)
+		session := &model.Luna4Session{ID: uuid.New().String(), UserID: owner.ID, CreatedAt: now, ExpiresAt: now + time.Hour.Milliseconds()}
+		if err := store.CreateSession(ctx, session); err != nil {
+			t.Fatalf("failed to create session: %v", err)
+		}
+		grant := &model.Luna4UserService{ID: uuid.New().String(), UserID: owner.ID, Service: model.Luna4ServicePrunk, Permission: model.UserServiceUser}
+		if err := store.CreateUserService(ctx, grant); err != nil {
+			t.Fatalf("failed to create user service: %v", err)
+		}
+	}
+
+	if _, err := store.conn.Exec(`DELETE FROM luna4_users WHERE id = ?`, user.ID); err != nil {
+		t.Fatalf("failed to delete user: %v", err)
+	}
+
+	for _, table := range []string{"luna4_session", "luna4_email_auth", "luna4_user_service"} {
+		if count := countRows(t, store, table, user.ID); count != 0 {
+			t.Errorf("%d %s rows remain for the deleted user", count, table)
+		}
+		if count := countRows(t, store, table, other.ID); count != 1 {
+			t.Errorf("%d %s rows for the other user, want 1", count, table)
+		}
+	}
+}
+
+func TestSQLiteConcurrentWritersDoNotFailBusy(t *testing.T) {
+	ctx := context.Background()
+	config := testSQLiteConfig(t)
+
+	// Two stores on one file behave like two processes, each with its own writer connection
+	stores := []*SQLiteService{openTestStore(t, config), openTestStore(t, config)}
+
+	const writers = 8
+	const writes = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*writes)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store := stores[i%len(stores)]
+			for j := range writes {
+				email := fmt.Sprintf("writer%d-%d@example.com", i, j)
+
+				// Read before writing, so a deferred transaction would have to upgrade its lock
+				err := store.WithTx(ctx, func(tx Store) error {
+					existing, err := tx.GetUserByEmail(ctx, email)
+					if err != nil || existing != nil {
+						return fmt.Errorf("user %s exists (%v)", email, err)
+					}
+					now := time.Now().UnixMilli()
+					return tx.CreateUser(ctx, &model.Luna4User{ID: uuid.New().String(), Email: email, Status: model.UserStatusActive, CreatedAt: now, UpdatedAt: now})
+				})
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("concurrent write failed: %v", err)
+	}
+
+	users, err := stores[0].GetAllUsers(ctx)
+	if err != nil {
+		t.Fatalf("failed to read users: %v", err)
+	}
+	if len(users) != writers*writes {
+		t.Errorf("%d users written, want %d", len(users), writers*writes)
+	}
+}
+
+func TestSQLiteTransactionRollsBack(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	now := time.Now().UnixMilli()
+	user := &model.Luna4User{ID: uuid.New().String(), Email: "rollback@example.com", Status: model.UserStatusActive, CreatedAt: now, UpdatedAt: now}
+	failure := errors.New("step failed")
+
+	err := store.WithTx(ctx, func(tx Store) error {
+		if err := tx.CreateUser(ctx, user); err != nil {
+			return err
+		}
+		// Reads in the transaction see its own writes
+		if stored, err := tx.GetUserByID(ctx, user.ID); err != nil || stored == nil {
+			t.Errorf("user is not visible in its transaction (%v)", err)
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("transaction returned %v, want the step's error", err)
+	}
+
+	stored, err := store.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("failed to read user: %v", err)
+	}
+	if stored != nil {
+		t.Error("user of a failed transaction was written")
+	}
+
+	if err := store.WithTx(ctx, func(tx Store) error { return tx.CreateUser(ctx, user) }); err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+	if stored, err := store.GetUserByID(ctx, user.ID); err != nil || stored == nil {
+		t.Errorf("user of a committed transaction is %v (%v)", stored, err)
+	}
+}
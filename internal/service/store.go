@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+// UserStore persists Luna4 users
+type UserStore interface {
+	GetAllUsers(ctx context.Context) ([]*model.Luna4User, error)
+	ListUsers(ctx context.Context, opts UserListOptions) (*UserPage, error)
+	CreateUser(ctx context.Context, user *model.Luna4User) error
+	GetUserByID(ctx context.Context, userID string) (*model.Luna4User, error)
+	GetUserByEmail(ctx context.Context, email string) (*model.Luna4User, error)
+	UpdateUserStatus(ctx context.Context, userID string, status model.UserStatus, reason string) error
+	UpdateUserEmail(ctx context.Context, userID, email string) error
+	UpdateUserProfile(ctx context.Context, userID, name, organization, notes string) error
+	UpdateUserPreferences(ctx context.Context, userID string, preferences model.UserPreferences) error
+	GetDormantUsers(ctx context.Context, inactiveSince int64) ([]model.Luna4UserActivity, error)
+	GetDeletedUsers(ctx context.Context, deletedBefore int64) ([]*model.Luna4User, error)
+
+	// DeleteUserData permanently removes the user and every record that belongs to them.
+	// Audit entries are kept, with the user's ID replaced by the pseudonym and details cleared.
+	DeleteUserData(ctx context.Context, userID, pseudonym string) error
+}
+
+// EmailAuthStore persists email authentication requests
+type EmailAuthStore interface {
+	CreateEmailAuth(ctx context.Context, emailAuth *model.Luna4EmailAuth) error
+	GetLatestEmailAuth(ctx context.Context, userID string) (*model.Luna4EmailAuth, error)
+	MarkEmailAuthCompleted(ctx context.Context, emailAuthID string) error
+	GetUserEmailAuths(ctx context.Context, userID string) ([]model.Luna4EmailAuth, error)
+}
+
+// UserServiceStore persists the services granted to users
+type UserServiceStore interface {
+	CreateUserService(ctx context.Context, userService *model.Luna4UserService) error
+	GetUserServices(ctx context.Context, userID string) ([]model.Luna4UserService, error)
+	DeleteUserService(ctx context.Context, serviceID string) error
+}
+
+// AuditLogStore persists audit entries
+type AuditLogStore interface {
+	CreateAuditLog(ctx context.Context, userID, actor string, action model.AuditAction, detail string) error
+	GetUserAuditLogs(ctx context.Context, userID string) ([]model.Luna4AuditLog, error)
+}
+
+// SessionStore persists sessions started by bearer tokens
+type SessionStore interface {
+	CreateSession(ctx context.Context, session *model.Luna4Session) error
+	GetSession(ctx context.Context, sessionID string) (*model.Luna4Session, error)
+	GetUserSessions(ctx context.Context, userID string) ([]model.Luna4Session, error)
+	RevokeUserSessions(ctx context.Context, userID string) (int64, error)
+}
+
+// EmailChangeStore persists email change requests
+type EmailChangeStore interface {
+	CreateEmailChange(ctx context.Context, change *model.Luna4EmailChange) error
+	GetLatestEmailChange(ctx context.Context, userID string) (*model.Luna4EmailChange, error)
+	GetEmailChangeByConfirmToken(ctx context.Context, tokenHash string) (*model.Luna4EmailChange, error)
+	GetEmailChangeByRevertToken(ctx context.Context, tokenHash string) (*model.Luna4EmailChange, error)
+	GetUserEmailChanges(ctx context.Context, userID string) ([]model.Luna4EmailChange, error)
+	MarkEmailChangeConfirmed(ctx context.Context, changeID string) error
+	MarkEmailChangeReverted(ctx context.Context, changeID string) error
+}
+
+// ScimStore persists SCIM bearer tokens and the users each tenant provisioned
+type ScimStore interface {
+	CreateScimToken(ctx context.Context, token *model.Luna4ScimToken) error
+	GetScimTokenByHash(ctx context.Context, tokenHash string) (*model.Luna4ScimToken, error)
+	GetScimTokens(ctx context.Context) ([]model.Luna4ScimToken, error)
+	MarkScimTokenUsed(ctx context.Context, tokenID string, usedAt int64) error
+	RevokeScimToken(ctx context.Context, tokenID string) error
+	CreateScimUser(ctx context.Context, link *model.Luna4ScimUser) error
+	GetScimUser(ctx context.Context, userID string) (*model.Luna4ScimUser, error)
+	UpdateScimUserExternalID(ctx context.Context, userID, externalID string) error
+
+	// GetScimAccounts returns the tenant's users that are not deleted, oldest first
+	GetScimAccounts(ctx context.Context, tenant string) ([]model.Luna4ScimAccount, error)
+}
+
+// WebAuthnStore persists passkeys and the challenges of ceremonies in progress
+type WebAuthnStore interface {
+	CreateWebAuthnCredential(ctx context.Context, credential *model.Luna4WebAuthnCredential) error
+	GetWebAuthnCredential(ctx context.Context, credentialID string) (*model.Luna4WebAuthnCredential, error)
+	GetUserWebAuthnCredentials(ctx context.Context, userID string) ([]model.Luna4WebAuthnCredential, error)
+	MarkWebAuthnCredentialUsed(ctx context.Context, credentialID string, signCount uint32, backedUp bool, usedAt int64) error
+	DeleteWebAuthnCredential(ctx context.Context, credentialID string) error
+	CreateWebAuthnChallenge(ctx context.Context, challenge *model.Luna4WebAuthnChallenge) error
+
+	// ConsumeWebAuthnChallenge removes and returns a challenge, or returns nil if it does
+	// not exist or was already consumed, so each challenge is answered once
+	ConsumeWebAuthnChallenge(ctx context.Context, challengeID string) (*model.Luna4WebAuthnChallenge, error)
+}
+
+// OutboxStore persists queued emails until they are delivered
+type OutboxStore interface {
+	CreateOutboxEmail(ctx context.Context, email *model.Luna4OutboxEmail) error
+	GetOutboxEmail(ctx context.Context, emailID string) (*model.Luna4OutboxEmail, error)
+	GetOutboxEmailByKey(ctx context.Context, idempotencyKey string) (*model.Luna4OutboxEmail, error)
+
+	// GetOutboxEmails returns up to limit emails with the status, newest first
+	GetOutboxEmails(ctx context.Context, status model.OutboxStatus, limit int) ([]model.Luna4OutboxEmail, error)
+
+	// GetDueOutboxEmails returns up to limit pending emails whose next attempt is due, oldest first
+	GetDueOutboxEmails(ctx context.Context, now int64, limit int) ([]model.Luna4OutboxEmail, error)
+
+	// ClaimOutboxEmail moves the next attempt of a due pending email to claimUntil and reports
+	// whether it was still due, so only one worker delivers it
+	ClaimOutboxEmail(ctx context.Context, emailID string, now, claimUntil int64) (bool, error)
+
+	// UpdateOutboxEmail saves the outcome of a delivery attempt
+	UpdateOutboxEmail(ctx context.Context, email *model.Luna4OutboxEmail) error
+
+	// DeleteSentOutboxEmails removes emails sent before the given time
+	DeleteSentOutboxEmails(ctx context.Context, sentBefore int64) (int64, error)
+}
+
+// DeliveryStore persists what SES reported about delivering to each address
+type DeliveryStore interface {
+	// CreateDeliveryEvent records an event and reports whether it was new; SNS may deliver
+	// the same notification more than once
+	CreateDeliveryEvent(ctx context.Context, event *model.Luna4DeliveryEvent) (bool, error)
+
+	// GetDeliveryEvents returns up to limit events for the address, newest first
+	GetDeliveryEvents(ctx context.Context, email string, limit int) ([]model.Luna4DeliveryEvent, error)
+
+	// CreateEmailSuppression suppresses an address unless it already is
+	CreateEmailSuppression(ctx context.Context, suppression *model.Luna4EmailSuppression) error
+	GetEmailSuppression(ctx context.Context, email string) (*model.Luna4EmailSuppression, error)
+	DeleteEmailSuppression(ctx context.Context, email string) error
+}
+
+// Store is the storage backend airlock runs on. SQLiteService, PostgresService and
+// DynamoDBService implement it.
+type Store interface {
+	UserStore
+	EmailAuthStore
+	UserServiceStore
+	AuditLogStore
+	SessionStore
+	EmailChangeStore
+	ScimStore
+	WebAuthnStore
+	OutboxStore
+	DeliveryStore
+
+	// WithTx runs fn against a Store whose writes are committed together when fn
+	// returns nil and rolled back otherwise. Calls inside fn join the same transaction.
+	//
+	// On DynamoDB the writes are buffered and committed with one TransactWriteItems call.
+	// Repeated writes to an item are merged into one and reading the item returns it as the
+	// transaction leaves it, but queries and scans see the state before the transaction. A
+	// transaction can write at most 100 items, and a conditional update cannot follow another
+	// write to its item; fn's calls return ErrTransactionUnsupported otherwise. A conditional
+	// write that fails at commit returns the sentinel of the operation, e.g. ErrEmailAuthUsed,
+	// or ErrTransactionConflict.
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+
+	Close() error
+}
+
+var (
+	_ Store = (*SQLiteService)(nil)
+	_ Store = (*PostgresService)(nil)
+	_ Store = (*DynamoDBService)(nil)
+)
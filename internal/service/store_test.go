@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/brand"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/webauthn"
+)
+
+// repoFS holds the migrations and email templates main embeds
+var repoFS = os.DirFS("../..")
+
+// testSQLiteConfig returns the production SQLite settings for a database file in a
+// temporary directory
+func testSQLiteConfig(t *testing.T) SQLiteConfig {
+	t.Helper()
+	return SQLiteConfig{
+		Path:         filepath.Join(t.TempDir(), "airlock.db"),
+		JournalMode:  "WAL",
+		Synchronous:  "NORMAL",
+		BusyTimeout:  5 * time.Second,
+		ForeignKeys:  true,
+		MaxReadConns: 4,
+	}
+}
+
+// newTestStore opens a migrated SQLite database that is closed when the test ends
+func newTestStore(t *testing.T) *SQLiteService {
+	t.Helper()
+	return openTestStore(t, testSQLiteConfig(t))
+}
+
+func openTestStore(t *testing.T, config SQLiteConfig) *SQLiteService {
+	t.Helper()
+	store, err := NewSQLiteService(config, repoFS)
+	if err != nil {
+		t.Fatalf("failed to open SQLite store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	if err := Migrate(context.Background(), store); err != nil {
+		t.Fatalf("failed to migrate SQLite store: %v", err)
+	}
+	return store
+}
+
+// forEachStore runs fn against a new SQLite store and, when DYNAMODB_TEST_ENDPOINT is set,
+// a new DynamoDB store
+func forEachStore(t *testing.T, fn func(t *testing.T, store Store)) {
+	t.Run("sqlite", func(t *testing.T) { fn(t, newTestStore(t)) })
+	t.Run("dynamodb", func(t *testing.T) { fn(t, newTestDynamoDB(t)) })
+}
+
+// newTestAccountService returns an account service on a new SQLite database, rendering
+// email from the repository's templates
+func newTestAccountService(t *testing.T) *AccountService {
+	t.Helper()
+	return newTestAccountServiceOn(t, newTestStore(t))
+}
+
+// newTestAccountServiceOn returns an account service on the store
+func newTestAccountServiceOn(t *testing.T, store Store) *AccountService {
+	t.Helper()
+	TemplateFS = repoFS
+	// Notices carry signed unsubscribe tokens
+	t.Setenv("JWT_ISSUER", "airlock-test")
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	brands, err := brand.NewRegistry(brand.GetConfig())
+	if err != nil {
+		t.Fatalf("failed to create brand registry: %v", err)
+	}
+	emails, err := NewEmailService(brands)
+	if err != nil {
+		t.Fatalf("failed to load email templates: %v", err)
+	}
+
+	passkeys := webauthn.Config{
+		RPID:             "localhost",
+		RPName:           "Airlock",
+		Origins:          []string{"http://localhost:8080"},
+		Timeout:          time.Minute,
+		UserVerification: "preferred",
+	}
+	return NewAccountService(store, emails, passkeys)
+}
+
+// createTestUser stores a user with the email and status
+func createTestUser(t *testing.T, store Store, email string, status model.UserStatus) *model.Luna4User {
+	t.Helper()
+	now := time.Now().UnixMilli()
+	user := &model.Luna4User{
+		ID:        uuid.New().String(),
+		Email:     email,
+		Status:    status,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := store.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("failed to create user %s: %v", email, err)
+	}
+	return user
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+// ExportUserData collects everything stored about a user, or returns nil if they don't exist
+func (s *AccountService) ExportUserData(ctx context.Context, userID string) (*model.Luna4UserExport, error) {
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	services, err := s.GetUserServices(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	emailAuths, err := s.GetUserEmailAuths(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	emailChanges, err := s.GetUserEmailChanges(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	passkeys, err := s.GetUserWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	auditLogs, err := s.GetUserAuditLogs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.GetDeliveryStatus(ctx, user.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &model.Luna4UserExport{
+		ExportedAt:   time.Now().UnixMilli(),
+		User:         user,
+		Services:     append([]model.Luna4UserService{}, services...),
+		LoginHistory: []model.Luna4LoginRecord{},
+		EmailAuths:   []model.Luna4EmailAuthRecord{},
+		Sessions:     append([]model.Luna4Session{}, sessions...),
+		EmailChanges: append([]model.Luna4EmailChange{}, emailChanges...),
+		Passkeys:     append([]model.Luna4WebAuthnCredential{}, passkeys...),
+		AuditLog:     append([]model.Luna4AuditLog{}, auditLogs...),
+		Delivery:     delivery,
+	}
+
+	for _, emailAuth := range emailAuths {
+		export.EmailAuths = append(export.EmailAuths, model.Luna4EmailAuthRecord{
+			ID:        emailAuth.ID,
+			SentAt:    emailAuth.SentAt,
+			Completed: emailAuth.Completed,
+		})
+		if emailAuth.Completed {
+			export.LoginHistory = append(export.LoginHistory, model.Luna4LoginRecord{
+				Method: "EMAIL",
+				At:     emailAuth.SentAt,
+			})
+		}
+	}
+
+	return export, nil
+}
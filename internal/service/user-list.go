@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+// ErrInvalidCursor is returned when a user list cursor is malformed or was issued for a different sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// UserSort names a field the user list can be ordered by
+type UserSort string
+
+const (
+	UserSortCreatedAt UserSort = "createdAt"
+	UserSortUpdatedAt UserSort = "updatedAt"
+	UserSortEmail     UserSort = "email"
+)
+
+// userSortColumns maps each sort to its luna4_users column
+var userSortColumns = map[UserSort]string{
+	UserSortCreatedAt: "created_at",
+	UserSortUpdatedAt: "updated_at",
+	UserSortEmail:     "email",
+}
+
+// IsValid reports whether the user list can be ordered by the sort
+func (s UserSort) IsValid() bool {
+	_, ok := userSortColumns[s]
+	return ok
+}
+
+// UserListOptions filters, orders and pages the user list. Zero values do not filter.
+type UserListOptions struct {
+	Statuses      []model.UserStatus
+	Service       model.Luna4Service
+	Permission    model.UserServicePermission
+	Email         string // Case-insensitive substring
+	CreatedAfter  *int64 // Inclusive, in milliseconds
+	CreatedBefore *int64 // Exclusive, in milliseconds
+	Sort          UserSort
+	Descending    bool
+	Limit         int
+	Cursor        string
+}
+
+// UserPage is one page of the user list. NextCursor is empty on the last page.
+type UserPage struct {
+	Users      []model.Luna4UserWithServices `json:"users"`
+	Count      int                           `json:"count"`
+	NextCursor string                        `json:"nextCursor,omitempty"`
+}
+
+// userCursor is the position after the last user of a page. It carries the sort so a
+// cursor cannot be replayed against a different ordering.
+type userCursor struct {
+	Sort       UserSort `json:"s"`
+	Descending bool     `json:"d"`
+	Value      string   `json:"v"`
+	ID         string   `json:"id"`
+}
+
+func encodeUserCursor(opts UserListOptions, user *model.Luna4User) string {
+	cursor := userCursor{Sort: opts.Sort, Descending: opts.Descending, ID: user.ID}
+	switch opts.Sort {
+	case UserSortEmail:
+		cursor.Value = user.Email
+	case UserSortUpdatedAt:
+		cursor.Value = strconv.FormatInt(user.UpdatedAt, 10)
+	default:
+		cursor.Value = strconv.FormatInt(user.CreatedAt, 10)
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeUserCursor returns the cursor's sort value, typed for the column, and user ID
+func decodeUserCursor(opts UserListOptions) (any, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+	if err != nil {
+		return nil, "", ErrInvalidCursor
+	}
+
+	var cursor userCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, "", ErrInvalidCursor
+	}
+	if cursor.Sort != opts.Sort || cursor.Descending != opts.Descending {
+		return nil, "", ErrInvalidCursor
+	}
+
+	if opts.Sort == UserSortEmail {
+		return cursor.Value, cursor.ID, nil
+	}
+	value, err := strconv.ParseInt(cursor.Value, 10, 64)
+	if err != nil {
+		return nil, "", ErrInvalidCursor
+	}
+	return value, cursor.ID, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s for use with ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// ListUsers returns one page of users with their services. The page is selected in a
+// subquery and joined to the service grants, so the whole page is read in one query.
+func (s *sqlStore) ListUsers(ctx context.Context, opts UserListOptions) (*UserPage, error) {
+	column := userSortColumns[opts.Sort]
+	direction, after := "ASC", ">"
+	if opts.Descending {
+		direction, after = "DESC", "<"
+	}
+
+	var conditions []string
+	var args []any
+	if len(opts.Statuses) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(opts.Statuses)), ", ")
+		conditions = append(conditions, "status IN ("+placeholders+")")
+		for _, status := range opts.Statuses {
+			args = append(args, status)
+		}
+	}
+	if opts.Service != "" || opts.Permission != "" {
+		grant := "SELECT 1 FROM luna4_user_service s WHERE s.user_id = luna4_users.id"
+		if opts.Service != "" {
+			grant += " AND s.service = ?"
+			args = append(args, opts.Service)
+		}
+		if opts.Permission != "" {
+			grant += " AND s.permission = ?"
+			args = append(args, opts.Permission)
+		}
+		conditions = append(conditions, "EXISTS ("+grant+")")
+	}
+	if opts.Email != "" {
+		conditions = append(conditions, `LOWER(email) LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(strings.ToLower(opts.Email))+"%")
+	}
+	if opts.CreatedAfter != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *opts.CreatedAfter)
+	}
+	if opts.CreatedBefore != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, *opts.CreatedBefore)
+	}
+	if opts.Cursor != "" {
+		value, id, err := decodeUserCursor(opts)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, "("+column+" "+after+" ? OR ("+column+" = ? AND id "+after+" ?))")
+		args = append(args, value, value, id)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	// One extra user tells whether there is another page
+	args = append(args, opts.Limit+1)
+	query := `
+		SELECT u.*, s.id, s.service, s.permission, s.expires_at
+		FROM (
+			SELECT ` + userColumns + `
+			FROM luna4_users
+			` + where + `
+			ORDER BY ` + column + ` ` + direction + `, id ` + direction + `
+			LIMIT ?
+		) u
+		LEFT JOIN luna4_user_service s ON s.user_id = u.id
+		ORDER BY u.` + column + ` ` + direction + `, u.id ` + direction + `, s.service
+	`
+
+	log.Printf("ListUsers: Executing query with %d conditions", len(conditions))
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("ListUsers: Query failed with error: %v", err)
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []model.Luna4UserWithServices{}
+	for rows.Next() {
+		var user model.Luna4User
+		var serviceID, service, permission sql.NullString
+		var expiresAt sql.NullInt64
+
+		if err := scanUser(rows, &user, &serviceID, &service, &permission, &expiresAt); err != nil {
+			log.Printf("ListUsers: Failed to scan user row: %v", err)
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+
+		// Rows of the same user are adjacent
+		if len(users) == 0 || users[len(users)-1].ID != user.ID {
+			users = append(users, model.Luna4UserWithServices{Luna4User: user, Services: []model.Luna4UserService{}})
+		}
+		if serviceID.Valid {
+			grant := model.Luna4UserService{
+				ID:         serviceID.String,
+				UserID:     user.ID,
+				Service:    model.Luna4Service(service.String),
+				Permission: model.UserServicePermission(permission.String),
+			}
+			if expiresAt.Valid {
+				grant.ExpiresAt = &expiresAt.Int64
+			}
+			last := &users[len(users)-1]
+			last.Services = append(last.Services, grant)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("ListUsers: Error during row iteration: %v", err)
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	return newUserPage(opts, users), nil
+}
+
+// newUserPage trims the extra user fetched to detect another page and sets the cursor
+func newUserPage(opts UserListOptions, users []model.Luna4UserWithServices) *UserPage {
+	page := &UserPage{Users: users}
+	if len(users) > opts.Limit {
+		page.Users = users[:opts.Limit]
+		page.NextCursor = encodeUserCursor(opts, &page.Users[opts.Limit-1].Luna4User)
+	}
+	page.Count = len(page.Users)
+	return page
+}
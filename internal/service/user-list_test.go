@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+// createListTestUsers stores users created a second apart, in order, with the grants given
+// by permission. Two of them share a creation time to exercise the ID tie-break.
+func createListTestUsers(t *testing.T, store Store) []*model.Luna4User {
+	t.Helper()
+	ctx := context.Background()
+	const base = int64(1_790_000_000_000)
+
+	users := []struct {
+		email       string
+		status      model.UserStatus
+		createdAt   int64
+		permissions []model.UserServicePermission
+	}{
+		{"carol@example.com", model.UserStatusActive, base, []model.UserServicePermission{model.UserServiceUser}},
+		{"alice@example.com", model.UserStatusSuspended, base + 1000, nil},
+		{"Bob.Admin@example.com", model.UserStatusActive, base + 2000, []model.UserServicePermission{model.UserServiceUser, model.UserServiceSuperUser}},
+		{"dave_ops@example.org", model.UserStatusLocked, base + 3000, []model.UserServicePermission{model.UserServiceSuperUser}},
+		{"erin@example.org", model.UserStatusActive, base + 3000, nil},
+		{"frank@example.com", model.UserStatusPending, base + 4000, []model.UserServicePermission{model.UserServiceUser}},
+		{"daveXops@example.org", model.UserStatusActive, base + 5000, nil},
+	}
+
+	var created []*model.Luna4User
+	for _, u := range users {
+		user := &model.Luna4User{ID: uuid.New().String(), Email: u.email, Status: u.status, CreatedAt: u.createdAt, UpdatedAt: u.createdAt}
+		if err := store.CreateUser(ctx, user); err != nil {
+			t.Fatalf("failed to create user %s: %v", u.email, err)
+		}
+		for _, permission := range u.permissions {
+			grant := &model.Luna4UserService{ID: uuid.New().String(), UserID: user.ID, Service: model.Luna4ServicePrunk, Permission: permission}
+			if err := store.CreateUserService(ctx, grant); err != nil {
+				t.Fatalf("failed to create user service: %v", err)
+			}
+		}
+		created = append(created, user)
+	}
+	return created
+}
+
+// listAllUsers follows the cursors through every page and returns the emails in order
+func listAllUsers(t *testing.T, store Store, opts UserListOptions) ([]string, map[string]int) {
+	t.Helper()
+	var emails []string
+	grants := make(map[string]int)
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatalf("listing did not end after %d pages", pages)
+		}
+		page, err := store.ListUsers(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("ListUsers failed: %v", err)
+		}
+		if page.Count != len(page.Users) || page.Count > opts.Limit {
+			t.Errorf("page counts %d of %d users with limit %d", page.Count, len(page.Users), opts.Limit)
+		}
+		for _, user := range page.Users {
+			emails = append(emails, user.Email)
+			grants[user.Email] = len(user.Services)
+		}
+		if page.NextCursor == "" {
+			return emails, grants
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+func TestListUsersPagesInOrder(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		users := createListTestUsers(t, store)
+
+		// Users created at the same time are ordered by ID
+		byCreation := slices.Clone(users)
+		slices.SortStableFunc(byCreation, func(a, b *model.Luna4User) int {
+			if a.CreatedAt != b.CreatedAt {
+				return int(a.CreatedAt - b.CreatedAt)
+			}
+			if a.ID < b.ID {
+				return -1
+			}
+			return 1
+		})
+		var ascending []string
+		for _, user := range byCreation {
+			ascending = append(ascending, user.Email)
+		}
+		descending := slices.Clone(ascending)
+		slices.Reverse(descending)
+
+		byEmail := []string{"Bob.Admin@example.com", "alice@example.com", "carol@example.com", "daveXops@example.org", "dave_ops@example.org", "erin@example.org", "frank@example.com"}
+
+		for _, tc := range []struct {
+			name string
+			opts UserListOptions
+			want []string
+		}{
+			{"created ascending", UserListOptions{Sort: UserSortCreatedAt, Limit: 2}, ascending},
+			{"created descending", UserListOptions{Sort: UserSortCreatedAt, Descending: true, Limit: 3}, descending},
+			{"updated", UserListOptions{Sort: UserSortUpdatedAt, Limit: 4}, ascending},
+			{"email", UserListOptions{Sort: UserSortEmail, Limit: 3}, byEmail},
+			{"one page", UserListOptions{Sort: UserSortCreatedAt, Limit: 100}, ascending},
+			{"exact pages", UserListOptions{Sort: UserSortCreatedAt, Limit: 7}, ascending},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				got, grants := listAllUsers(t, store, tc.opts)
+				if !slices.Equal(got, tc.want) {
+					t.Errorf("listed %v, want %v", got, tc.want)
+				}
+				if grants["Bob.Admin@example.com"] != 2 || grants["carol@example.com"] != 1 || grants["alice@example.com"] != 0 {
+					t.Errorf("listed grants %v", grants)
+				}
+			})
+		}
+	})
+}
+
+func TestListUsersFilters(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		users := createListTestUsers(t, store)
+		after, before := users[2].CreatedAt, users[5].CreatedAt
+
+		for _, tc := range []struct {
+			name string
+			opts UserListOptions
+			want []string
+		}{
+			{"status", UserListOptions{Statuses: []model.UserStatus{model.UserStatusSuspended, model.UserStatusLocked}}, []string{"alice@example.com", "dave_ops@example.org"}},
+			{"service", UserListOptions{Service: model.Luna4ServicePrunk}, []string{"Bob.Admin@example.com", "carol@example.com", "dave_ops@example.org", "frank@example.com"}},
+			{"permission", UserListOptions{Permission: model.UserServiceSuperUser}, []string{"Bob.Admin@example.com", "dave_ops@example.org"}},
+			{"service and permission", UserListOptions{Service: model.Luna4ServicePrunk, Permission: model.UserServiceUser, Statuses: []model.UserStatus{model.UserStatusActive}}, []string{"Bob.Admin@example.com", "carol@example.com"}},
+			{"email ignores case", UserListOptions{Email: "bob.ADMIN"}, []string{"Bob.Admin@example.com"}},
+			{"email underscore is literal", UserListOptions{Email: "dave_"}, []string{"dave_ops@example.org"}},
+			{"email percent is literal", UserListOptions{Email: "%"}, nil},
+			{"email domain", UserListOptions{Email: "example.org"}, []string{"daveXops@example.org", "dave_ops@example.org", "erin@example.org"}},
+			{"created range", UserListOptions{CreatedAfter: &after, CreatedBefore: &before}, []string{"Bob.Admin@example.com", "dave_ops@example.org", "erin@example.org"}},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				tc.opts.Sort = UserSortEmail
+				tc.opts.Limit = 2
+				got, _ := listAllUsers(t, store, tc.opts)
+				if !slices.Equal(got, tc.want) {
+					t.Errorf("listed %v, want %v", got, tc.want)
+				}
+			})
+		}
+	})
+}
+
+func TestListUsersRejectsInvalidCursor(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		createListTestUsers(t, store)
+
+		opts := UserListOptions{Sort: UserSortCreatedAt, Limit: 2}
+		page, err := store.ListUsers(ctx, opts)
+		if err != nil {
+			t.Fatalf("ListUsers failed: %v", err)
+		}
+
+		for name, changed := range map[string]UserListOptions{
+			"another sort":      {Sort: UserSortEmail, Limit: 2, Cursor: page.NextCursor},
+			"another direction": {Sort: UserSortCreatedAt, Descending: true, Limit: 2, Cursor: page.NextCursor},
+			"not base64":        {Sort: UserSortCreatedAt, Limit: 2, Cursor: "not a cursor!"},
+			"not JSON":          {Sort: UserSortCreatedAt, Limit: 2, Cursor: "bm90IGpzb24"},
+		} {
+			if _, err := store.ListUsers(ctx, changed); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("cursor for %s returned %v, want ErrInvalidCursor", name, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+// ErrSearchQueryTooShort is returned when no search term is long enough for the trigram index
+var ErrSearchQueryTooShort = errors.New("search query needs a term of at least 3 characters")
+
+// UserSearcher is implemented by stores with a full-text index of users
+type UserSearcher interface {
+	SearchUsers(ctx context.Context, query string, limit int) ([]UserSearchResult, error)
+}
+
+var _ UserSearcher = (*SQLiteService)(nil)
+
+// UserSearchResult is a user matching a search. Highlights holds the matching fields
+// as HTML with the matches wrapped in <mark>.
+type UserSearchResult struct {
+	model.Luna4User
+	Highlights map[string]string `json:"highlights"`
+	Score      float64           `json:"score"`
+}
+
+// SQLite marks matches with control characters, which cannot appear in escaped HTML,
+// so the surrounding text can be escaped before the marks become <mark> tags
+const (
+	matchStart = "\x02"
+	matchEnd   = "\x03"
+)
+
+// userSearchMatch turns free text into an FTS5 query matching users containing every
+// term. Terms are quoted so operators in the input are searched for literally.
+func userSearchMatch(query string) (string, error) {
+	var terms []string
+	for _, term := range strings.Fields(query) {
+		if utf8.RuneCountInString(term) < 3 {
+			continue
+		}
+		terms = append(terms, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+	}
+	if len(terms) == 0 {
+		return "", ErrSearchQueryTooShort
+	}
+	return strings.Join(terms, " "), nil
+}
+
+// SearchUsers returns the users best matching the query. Email matches weigh the most,
+// then name, organization and notes.
+func (s *SQLiteService) SearchUsers(ctx context.Context, query string, limit int) ([]UserSearchResult, error) {
+	match, err := userSearchMatch(query)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("SearchUsers: Searching users for %q", match)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+qualifiedUserColumns("u")+`,
+			highlight(luna4_user_search, 1, ?, ?),
+			highlight(luna4_user_search, 2, ?, ?),
+			highlight(luna4_user_search, 3, ?, ?),
+			snippet(luna4_user_search, 4, ?, ?, '…', 64),
+			bm25(luna4_user_search, 0.0, 10.0, 5.0, 3.0, 1.0) AS rank
+		FROM luna4_user_search
+		JOIN luna4_users u ON u.id = luna4_user_search.user_id
+		WHERE luna4_user_search MATCH ?
+		ORDER BY rank
+		LIMIT ?
+	`, matchStart, matchEnd, matchStart, matchEnd, matchStart, matchEnd, matchStart, matchEnd, match, limit)
+	if err != nil {
+		log.Printf("SearchUsers: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	results := []UserSearchResult{}
+	for rows.Next() {
+		var result UserSearchResult
+		var email, name, organization, notes string
+		var rank float64
+		if err := scanUser(rows, &result.Luna4User, &email, &name, &organization, &notes, &rank); err != nil {
+			log.Printf("SearchUsers: Failed to scan user row: %v", err)
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+
+		result.Highlights = map[string]string{}
+		for field, value := range map[string]string{"email": email, "name": name, "organization": organization, "notes": notes} {
+			if strings.Contains(value, matchStart) {
+				result.Highlights[field] = highlightHTML(value)
+			}
+		}
+		// bm25 scores are negative, with the best match lowest
+		result.Score = -rank
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("SearchUsers: Error during row iteration: %v", err)
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	log.Printf("SearchUsers: Found %d users", len(results))
+	return results, nil
+}
+
+// highlightHTML escapes the marked text and turns the match marks into <mark> tags
+func highlightHTML(marked string) string {
+	escaped := html.EscapeString(marked)
+	return strings.NewReplacer(matchStart, "<mark>", matchEnd, "</mark>").Replace(escaped)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+// searchEmails returns the emails of the users matching the query, best match first
+func searchEmails(t *testing.T, store *SQLiteService, query string) []string {
+	t.Helper()
+	results, err := store.SearchUsers(context.Background(), query, 10)
+	if err != nil {
+		t.Fatalf("searching for %q failed: %v", query, err)
+	}
+	var emails []string
+	for _, result := range results {
+		emails = append(emails, result.Email)
+	}
+	return emails
+}
+
+func TestSearchUsers(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	profiles := []struct {
+		email, name, organization, notes string
+	}{
+		{"acme-admin@example.com", "Ada Admin", "Example Corp", ""},
+		{"grace@example.com", "Grace Hopper", "Acme Industries", ""},
+		{"linus@example.org", "Linus", "Kernel", "Met at the acme conference"},
+		{"other@example.net", "<script>Acme</script>", "", ""},
+	}
+	users := make(map[string]*model.Luna4User)
+	for _, p := range profiles {
+		user := createTestUser(t, store, p.email, model.UserStatusActive)
+		if err := store.UpdateUserProfile(ctx, user.ID, p.name, p.organization, p.notes); err != nil {
+			t.Fatalf("failed to update profile of %s: %v", p.email, err)
+		}
+		users[p.email] = user
+	}
+
+	// Email matches rank above name, organization and notes
+	results, err := store.SearchUsers(ctx, "ACME", 10)
+	if err != nil {
+		t.Fatalf("SearchUsers failed: %v", err)
+	}
+	if len(results) != 4 || results[0].Email != "acme-admin@example.com" || results[3].Email != "linus@example.org" {
+		t.Fatalf("search ranked %v, want the email match first and the notes match last", searchEmails(t, store, "ACME"))
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Score > results[i-1].Score {
+			t.Errorf("result %d scores %f above %f", i, results[i].Score, results[i-1].Score)
+		}
+	}
+
+	highlights := make(map[string]map[string]string)
+	for _, result := range results {
+		highlights[result.Email] = result.Highlights
+	}
+	for email, want := range map[string]map[string]string{
+		"acme-admin@example.com": {"email": "<mark>acme</mark>-admin@example.com"},
+		"grace@example.com":      {"organization": "<mark>Acme</mark> Industries"},
+		"linus@example.org":      {"notes": "Met at the <mark>acme</mark> conference"},
+		"other@example.net":      {"name": "&lt;script&gt;<mark>Acme</mark>&lt;/script&gt;"},
+	} {
+		got := highlights[email]
+		if len(got) != len(want) {
+			t.Errorf("%s highlights %v, want %v", email, got, want)
+			continue
+		}
+		for field, value := range want {
+			if got[field] != value {
+				t.Errorf("%s highlights %s as %q, want %q", email, field, got[field], value)
+			}
+		}
+	}
+
+	// Every term must match, and terms are fragments of words
+	if got := searchEmails(t, store, "acme dmi"); len(got) != 1 || got[0] != "acme-admin@example.com" {
+		t.Errorf("searching two terms found %v, want the admin", got)
+	}
+	if results, err := store.SearchUsers(ctx, "acme", 2); err != nil || len(results) != 2 {
+		t.Errorf("search with a limit of 2 returned %d results (%v)", len(results), err)
+	}
+
+	// Operators and quotes in the query are searched for literally
+	for _, query := range []string{`acme OR linus`, `"acme`, `acme*`, `NEAR(acme grace)`, `email:grace`} {
+		if _, err := store.SearchUsers(ctx, query, 10); err != nil {
+			t.Errorf("searching for %q failed: %v", query, err)
+		}
+	}
+	if got := searchEmails(t, store, "acme OR linus"); len(got) != 1 || got[0] != "linus@example.org" {
+		t.Errorf("OR was treated as an operator: found %v", got)
+	}
+
+	for _, query := range []string{"", "ab", "a b cd"} {
+		if _, err := store.SearchUsers(ctx, query, 10); !errors.Is(err, ErrSearchQueryTooShort) {
+			t.Errorf("searching for %q returned %v, want ErrSearchQueryTooShort", query, err)
+		}
+	}
+}
+
+func TestSearchIndexFollowsUsers(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	user := createTestUser(t, store, "before@example.com", model.UserStatusActive)
+
+	if err := store.UpdateUserEmail(ctx, user.ID, "after@example.com"); err != nil {
+		t.Fatalf("failed to change email: %v", err)
+	}
+	if got := searchEmails(t, store, "before"); len(got) != 0 {
+		t.Errorf("old email still finds %v", got)
+	}
+	if got := searchEmails(t, store, "after"); len(got) != 1 {
+		t.Errorf("new email finds %v, want the user", got)
+	}
+
+	if err := store.UpdateUserProfile(ctx, user.ID, "Renamed", "", "vip customer"); err != nil {
+		t.Fatalf("failed to update profile: %v", err)
+	}
+	if got := searchEmails(t, store, "renamed customer"); len(got) != 1 {
+		t.Errorf("updated profile finds %v, want the user", got)
+	}
+
+	if err := store.DeleteUserData(ctx, user.ID, "anon"); err != nil {
+		t.Fatalf("failed to purge user: %v", err)
+	}
+	if got := searchEmails(t, store, "after"); len(got) != 0 {
+		t.Errorf("purged user is still found: %v", got)
+	}
+}
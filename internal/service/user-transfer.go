@@ -0,0 +1,424 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/util"
+)
+
+// Bulk import and export formats
+const (
+	UserFormatCSV   = "csv"
+	UserFormatJSONL = "jsonl"
+)
+
+// MaxUserImportRows caps a single import so it fits comfortably in one transaction
+const MaxUserImportRows = 10000
+
+// userExportPageSize is how many users are read per query while exporting
+const userExportPageSize = 500
+
+// userCSVColumns are the columns written by the CSV export. Imports read email, name,
+// organization, notes, status and services and ignore the rest.
+var userCSVColumns = []string{"id", "email", "name", "organization", "notes", "status", "status_reason", "created_at", "updated_at", "services"}
+
+// UserRecord is one user of a bulk import
+type UserRecord struct {
+	Line         int                      `json:"-"`
+	Email        string                   `json:"email"`
+	Name         string                   `json:"name"`
+	Organization string                   `json:"organization"`
+	Notes        string                   `json:"notes"`
+	Status       model.UserStatus         `json:"status"`
+	Services     []model.Luna4UserService `json:"services"`
+	problems     []string
+	unreadable   bool
+}
+
+// UserImportError lists the problems found in one line of an import
+type UserImportError struct {
+	Line   int      `json:"line"`
+	Email  string   `json:"email,omitempty"`
+	Errors []string `json:"errors"`
+}
+
+// UserImportReport is the outcome of an import. Nothing is imported when Errors is not empty.
+type UserImportReport struct {
+	DryRun   bool              `json:"dryRun"`
+	Total    int               `json:"total"`
+	Imported int               `json:"imported"`
+	Errors   []UserImportError `json:"errors"`
+}
+
+// ReadUserRecords parses a CSV file with a header row, or JSON Lines with one object per
+// line. Lines that cannot be parsed are returned with their problems so every error is
+// reported together.
+func ReadUserRecords(r io.Reader, format string) ([]UserRecord, error) {
+	switch format {
+	case UserFormatCSV:
+		return readUserCSV(r)
+	case UserFormatJSONL:
+		return readUserJSONL(r)
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+func readUserCSV(r io.Reader) ([]UserRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, fmt.Errorf("CSV header has no email column")
+	}
+
+	var records []UserRecord
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(records) >= MaxUserImportRows {
+			return nil, fmt.Errorf("import has more than %d rows", MaxUserImportRows)
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("failed to read CSV: %w", err)
+			}
+			records = append(records, UserRecord{Line: parseErr.StartLine, problems: []string{parseErr.Err.Error()}, unreadable: true})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		record := UserRecord{
+			Line:         line,
+			Email:        field("email"),
+			Name:         field("name"),
+			Organization: field("organization"),
+			Notes:        field("notes"),
+			Status:       model.UserStatus(strings.ToUpper(field("status"))),
+		}
+		record.Services, record.problems = parseServiceList(field("services"))
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func readUserJSONL(r io.Reader) ([]UserRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var records []UserRecord
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if len(records) >= MaxUserImportRows {
+			return nil, fmt.Errorf("import has more than %d rows", MaxUserImportRows)
+		}
+
+		var record UserRecord
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			record = UserRecord{problems: []string{"invalid JSON: " + err.Error()}, unreadable: true}
+		}
+		record.Line = line
+		record.Email = strings.TrimSpace(record.Email)
+		record.Status = model.UserStatus(strings.ToUpper(string(record.Status)))
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read JSON Lines: %w", err)
+	}
+	return records, nil
+}
+
+// parseServiceList reads grants written as SERVICE:PERMISSION[:EXPIRES_AT] separated by semicolons
+func parseServiceList(value string) ([]model.Luna4UserService, []string) {
+	var services []model.Luna4UserService
+	var problems []string
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			problems = append(problems, fmt.Sprintf("invalid service grant %q, expected SERVICE:PERMISSION[:EXPIRES_AT]", entry))
+			continue
+		}
+		service := model.Luna4UserService{
+			Service:    model.Luna4Service(strings.ToUpper(parts[0])),
+			Permission: model.UserServicePermission(strings.ToUpper(parts[1])),
+		}
+		if len(parts) == 3 {
+			expiresAt, err := strconv.ParseInt(parts[2], 10, 64)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("invalid expiry in service grant %q", entry))
+				continue
+			}
+			service.ExpiresAt = &expiresAt
+		}
+		services = append(services, service)
+	}
+	return services, problems
+}
+
+func formatServiceList(services []model.Luna4UserService) string {
+	entries := make([]string, len(services))
+	for i, service := range services {
+		entries[i] = string(service.Service) + ":" + string(service.Permission)
+		if service.ExpiresAt != nil {
+			entries[i] += ":" + strconv.FormatInt(*service.ExpiresAt, 10)
+		}
+	}
+	return strings.Join(entries, ";")
+}
+
+// validate checks a record the same way CreateUser checks a single request
+func (r *UserRecord) validate() {
+	if !util.IsValidEmail(r.Email) {
+		r.problems = append(r.problems, "invalid email")
+	}
+	if len(r.Name) > 200 || len(r.Organization) > 200 || len(r.Notes) > 4000 {
+		r.problems = append(r.problems, "name and organization are limited to 200 characters and notes to 4000")
+	}
+
+	switch r.Status {
+	case "":
+		r.Status = model.UserStatusActive
+	case model.UserStatusPending, model.UserStatusActive, model.UserStatusSuspended:
+	default:
+		r.problems = append(r.problems, "invalid status "+string(r.Status)+", must be PENDING, ACTIVE or SUSPENDED")
+	}
+
+	for _, service := range r.Services {
+		if service.Service == "" {
+			r.problems = append(r.problems, "service grant without a service")
+		}
+		if service.Permission != model.UserServiceUser && service.Permission != model.UserServiceSuperUser {
+			r.problems = append(r.problems, "invalid permission "+string(service.Permission)+" for service "+string(service.Service))
+		}
+	}
+}
+
+// ImportUsers validates every record, including that no email is repeated or already
+// taken, and creates all of the users in one transaction. Stores that limit the size of a
+// transaction get as many as it takes, so a failing batch leaves the earlier ones
+// imported. When any record is invalid, or for a dry run, nothing is written.
+func (s *AccountService) ImportUsers(ctx context.Context, records []UserRecord, dryRun bool) (*UserImportReport, error) {
+	report := &UserImportReport{DryRun: dryRun, Total: len(records), Errors: []UserImportError{}}
+
+	seen := map[string]int{}
+	for i := range records {
+		record := &records[i]
+		if record.unreadable {
+			report.Errors = append(report.Errors, UserImportError{Line: record.Line, Errors: record.problems})
+			continue
+		}
+		// Addresses differing only in case or surrounding spaces are the same mailbox
+		record.Email = normalizeEmail(record.Email)
+		record.validate()
+
+		if first, ok := seen[record.Email]; ok && record.Email != "" {
+			record.problems = append(record.problems, fmt.Sprintf("email repeats line %d", first))
+		} else {
+			seen[record.Email] = record.Line
+		}
+
+		if util.IsValidEmail(record.Email) {
+			existing, err := s.GetUserByEmail(ctx, record.Email)
+			if err != nil {
+				return nil, err
+			}
+			if existing != nil {
+				record.problems = append(record.problems, "email is already registered")
+			}
+		}
+
+		if len(record.problems) > 0 {
+			report.Errors = append(report.Errors, UserImportError{Line: record.Line, Email: record.Email, Errors: record.problems})
+		}
+	}
+
+	if len(report.Errors) > 0 || dryRun {
+		log.Printf("ImportUsers: Validated %d users, %d with errors, dry run: %v", len(records), len(report.Errors), dryRun)
+		return report, nil
+	}
+
+	batches := [][]UserRecord{records}
+	if limiter, ok := s.Store.(txWriteLimiter); ok {
+		batches = batchUserRecords(records, limiter.maxTxWrites())
+	}
+	for _, batch := range batches {
+		err := s.inTx(ctx, func(tx *AccountService) error {
+			for _, record := range batch {
+				user, services := record.newUser()
+				if err := tx.CreateUserWithServices(ctx, user, services); err != nil {
+					return fmt.Errorf("failed to import line %d: %w", record.Line, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("imported %d of %d users: %w", report.Imported, len(records), err)
+		}
+		report.Imported += len(batch)
+	}
+
+	log.Printf("ImportUsers: Imported %d users", report.Imported)
+	return report, nil
+}
+
+// txWriteLimiter is implemented by stores that limit how many items one transaction
+// writes, such as DynamoDB
+type txWriteLimiter interface {
+	maxTxWrites() int
+}
+
+// batchUserRecords splits the records into batches that each fit in one transaction. A
+// user takes one write for the user, one for the claim of their address and one per
+// grant, with the default grant when there is none.
+func batchUserRecords(records []UserRecord, maxWrites int) [][]UserRecord {
+	var batches [][]UserRecord
+	start, writes := 0, 0
+	for i, record := range records {
+		cost := 2 + max(len(record.Services), 1)
+		if writes+cost > maxWrites && i > start {
+			batches = append(batches, records[start:i])
+			start, writes = i, 0
+		}
+		writes += cost
+	}
+	return append(batches, records[start:])
+}
+
+// newUser builds the user and grants to create. Like CreateUser, a user without grants
+// gets the default PRUNK user grant.
+func (r *UserRecord) newUser() (*model.Luna4User, []model.Luna4UserService) {
+	now := time.Now().UnixMilli()
+	user := &model.Luna4User{
+		ID:              uuid.New().String(),
+		Email:           r.Email,
+		Name:            r.Name,
+		Organization:    r.Organization,
+		Notes:           r.Notes,
+		Status:          r.Status,
+		StatusChangedAt: &now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	services := r.Services
+	if len(services) == 0 {
+		services = []model.Luna4UserService{{Service: model.Luna4ServicePrunk, Permission: model.UserServiceUser}}
+	}
+
+	grants := make([]model.Luna4UserService, len(services))
+	for i, service := range services {
+		grants[i] = model.Luna4UserService{
+			ID:         uuid.New().String(),
+			UserID:     user.ID,
+			Service:    service.Service,
+			Permission: service.Permission,
+			ExpiresAt:  service.ExpiresAt,
+		}
+	}
+	return user, grants
+}
+
+// ExportUsers reads the users matching the list filters page by page and passes each
+// page to fn, so an export never holds every user in memory
+func (s *AccountService) ExportUsers(ctx context.Context, opts UserListOptions, fn func(users []model.Luna4UserWithServices) error) error {
+	opts.Limit = userExportPageSize
+	opts.Cursor = ""
+	for {
+		page, err := s.ListUsers(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if err := fn(page.Users); err != nil {
+			return err
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+// UserExportWriter writes users with their grants as CSV or JSON Lines
+type UserExportWriter struct {
+	csv  *csv.Writer
+	json *json.Encoder
+}
+
+func NewUserExportWriter(w io.Writer, format string) (*UserExportWriter, error) {
+	switch format {
+	case UserFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(userCSVColumns); err != nil {
+			return nil, err
+		}
+		return &UserExportWriter{csv: writer}, nil
+	case UserFormatJSONL:
+		return &UserExportWriter{json: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+func (w *UserExportWriter) Write(user *model.Luna4UserWithServices) error {
+	if w.json != nil {
+		return w.json.Encode(user)
+	}
+	return w.csv.Write([]string{
+		user.ID,
+		user.Email,
+		user.Name,
+		user.Organization,
+		user.Notes,
+		string(user.Status),
+		user.StatusReason,
+		strconv.FormatInt(user.CreatedAt, 10),
+		strconv.FormatInt(user.UpdatedAt, 10),
+		formatServiceList(user.Services),
+	})
+}
+
+// Flush writes any buffered CSV rows
+func (w *UserExportWriter) Flush() error {
+	if w.csv == nil {
+		return nil
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/luna4dev/airlock/internal/model"
+)
+
+// importErrors maps each line of the report to its first problem
+func importErrors(report *UserImportReport) map[int]string {
+	errs := make(map[int]string)
+	for _, e := range report.Errors {
+		errs[e.Line] = strings.Join(e.Errors, "; ")
+	}
+	return errs
+}
+
+func TestReadUserRecordsCSV(t *testing.T) {
+	input := strings.Join([]string{
+		"Email, Status ,Name,Organization,Notes,Services,Ignored",
+		`ada@example.com,active,"Lovelace, Ada",Analytical,,prunk:super_user;PRUNK:USER:1800000000000,x`,
+		`grace@example.com,,Grace`,
+		`"broken@example.com,ACTIVE`,
+	}, "\n")
+
+	records, err := ReadUserRecords(strings.NewReader(input), UserFormatCSV)
+	if err != nil {
+		t.Fatalf("ReadUserRecords failed: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("read %d records, want 3", len(records))
+	}
+
+	ada := records[0]
+	if ada.Line != 2 || ada.Email != "ada@example.com" || ada.Status != model.UserStatusActive || ada.Name != "Lovelace, Ada" || ada.Organization != "Analytical" {
+		t.Errorf("first record is %+v", ada)
+	}
+	if len(ada.Services) != 2 || ada.Services[0].Permission != model.UserServiceSuperUser || ada.Services[1].ExpiresAt == nil || *ada.Services[1].ExpiresAt != 1800000000000 {
+		t.Errorf("first record grants %+v", ada.Services)
+	}
+	if grace := records[1]; grace.Line != 3 || grace.Status != "" || len(grace.Services) != 0 || grace.unreadable {
+		t.Errorf("short row is %+v, want the missing columns empty", grace)
+	}
+	if broken := records[2]; !broken.unreadable || broken.Line != 4 || len(broken.problems) != 1 {
+		t.Errorf("unterminated quote is %+v, want an unreadable line 4", broken)
+	}
+
+	if _, err := ReadUserRecords(strings.NewReader("name,status\nAda,ACTIVE\n"), UserFormatCSV); err == nil {
+		t.Errorf("CSV without an email column was read")
+	}
+	if _, err := ReadUserRecords(strings.NewReader(""), "xml"); err == nil {
+		t.Errorf("unknown format was read")
+	}
+}
+
+func TestReadUserRecordsJSONL(t *testing.T) {
+	input := strings.Join([]string{
+		`{"email":" ada@example.com ","status":"suspended","services":[{"service":"PRUNK","permission":"USER"}]}`,
+		``,
+		`{"email": "grace@example.com", "name": 42}`,
+		`{"email":"linus@example.org"}`,
+	}, "\n")
+
+	records, err := ReadUserRecords(strings.NewReader(input), UserFormatJSONL)
+	if err != nil {
+		t.Fatalf("ReadUserRecords failed: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("read %d records, want 3 without the blank line", len(records))
+	}
+	if ada := records[0]; ada.Line != 1 || ada.Email != "ada@example.com" || ada.Status != model.UserStatusSuspended || len(ada.Services) != 1 {
+		t.Errorf("first record is %+v", ada)
+	}
+	if grace := records[1]; !grace.unreadable || grace.Line != 3 {
+		t.Errorf("invalid line is %+v, want an unreadable line 3", grace)
+	}
+	if linus := records[2]; linus.Line != 4 || linus.unreadable {
+		t.Errorf("last record is %+v, want line 4", linus)
+	}
+}
+
+func TestImportUsers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		createTestUser(t, store, "taken@example.com", model.UserStatusActive)
+
+		invalid := strings.Join([]string{
+			"email,status,services",
+			"valid@example.com,ACTIVE,PRUNK:USER",
+			"not-an-email,ACTIVE,",
+			"locked@example.com,LOCKED,",
+			"grant@example.com,,PRUNK:OWNER",
+			"expiry@example.com,,PRUNK:USER:tomorrow",
+			"valid@example.com,,",
+			"taken@example.com,,",
+			"VALID@Example.com,,",
+			" Taken@Example.COM ,,",
+		}, "\n")
+		records, err := ReadUserRecords(strings.NewReader(invalid), UserFormatCSV)
+		if err != nil {
+			t.Fatalf("ReadUserRecords failed: %v", err)
+		}
+		report, err := accounts.ImportUsers(ctx, records, false)
+		if err != nil {
+			t.Fatalf("ImportUsers failed: %v", err)
+		}
+
+		errs := importErrors(report)
+		for line, want := range map[int]string{
+			3:  "invalid email",
+			4:  "invalid status LOCKED",
+			5:  "invalid permission OWNER",
+			6:  "invalid expiry",
+			7:  "email repeats line 2",
+			8:  "email is already registered",
+			9:  "email repeats line 2",
+			10: "email is already registered",
+		} {
+			if !strings.Contains(errs[line], want) {
+				t.Errorf("line %d errors are %q, want %q", line, errs[line], want)
+			}
+		}
+		if len(errs) != 8 || report.Total != 9 || report.Imported != 0 {
+			t.Errorf("report has %d errors for %d records and imported %d", len(errs), report.Total, report.Imported)
+		}
+
+		// One invalid line keeps the valid ones from being imported
+		if user, err := store.GetUserByEmail(ctx, "valid@example.com"); err != nil || user != nil {
+			t.Errorf("valid line of a failed import was written: %v (%v)", user, err)
+		}
+
+		valid := strings.Join([]string{
+			`{"email":"ada@example.com","name":"Ada","status":"SUSPENDED","services":[{"service":"PRUNK","permission":"SUPER_USER"}]}`,
+			`{"email":"Grace@Example.com"}`,
+		}, "\n")
+		records, err = ReadUserRecords(strings.NewReader(valid), UserFormatJSONL)
+		if err != nil {
+			t.Fatalf("ReadUserRecords failed: %v", err)
+		}
+		report, err = accounts.ImportUsers(ctx, records, true)
+		if err != nil {
+			t.Fatalf("dry run failed: %v", err)
+		}
+		if !report.DryRun || len(report.Errors) != 0 || report.Imported != 0 {
+			t.Errorf("dry run report is %+v", report)
+		}
+		if user, _ := store.GetUserByEmail(ctx, "ada@example.com"); user != nil {
+			t.Errorf("dry run created a user")
+		}
+
+		report, err = accounts.ImportUsers(ctx, records, false)
+		if err != nil {
+			t.Fatalf("ImportUsers failed: %v", err)
+		}
+		if len(report.Errors) != 0 || report.Imported != 2 {
+			t.Fatalf("import report is %+v, want 2 users imported", report)
+		}
+
+		for email, want := range map[string]struct {
+			status     model.UserStatus
+			permission model.UserServicePermission
+		}{
+			"ada@example.com":   {model.UserStatusSuspended, model.UserServiceSuperUser},
+			"grace@example.com": {model.UserStatusActive, model.UserServiceUser},
+		} {
+			user, err := store.GetUserByEmail(ctx, email)
+			if err != nil || user == nil {
+				t.Fatalf("imported user %s is missing (%v)", email, err)
+			}
+			services, err := store.GetUserServices(ctx, user.ID)
+			if err != nil {
+				t.Fatalf("failed to read services: %v", err)
+			}
+			if user.Status != want.status || len(services) != 1 || services[0].Permission != want.permission {
+				t.Errorf("%s was imported %s with %+v", email, user.Status, services)
+			}
+		}
+	})
+}
+
+func TestExportUsersRoundTrip(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		createListTestUsers(t, store)
+
+		for _, format := range []string{UserFormatCSV, UserFormatJSONL} {
+			t.Run(format, func(t *testing.T) {
+				var buf bytes.Buffer
+				writer, err := NewUserExportWriter(&buf, format)
+				if err != nil {
+					t.Fatalf("failed to create writer: %v", err)
+				}
+				opts := UserListOptions{Sort: UserSortEmail, Statuses: []model.UserStatus{model.UserStatusActive, model.UserStatusLocked}}
+				err = accounts.ExportUsers(ctx, opts, func(users []model.Luna4UserWithServices) error {
+					for i := range users {
+						if err := writer.Write(&users[i]); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("ExportUsers failed: %v", err)
+				}
+				if err := writer.Flush(); err != nil {
+					t.Fatalf("failed to flush: %v", err)
+				}
+
+				// The export reads back as an import of the same users and grants
+				records, err := ReadUserRecords(&buf, format)
+				if err != nil {
+					t.Fatalf("failed to read the export: %v", err)
+				}
+				var emails []string
+				grants := make(map[string][]model.UserServicePermission)
+				for _, record := range records {
+					if record.unreadable || len(record.problems) > 0 {
+						t.Errorf("exported line %d does not read back: %v", record.Line, record.problems)
+					}
+					emails = append(emails, record.Email)
+					for _, service := range record.Services {
+						grants[record.Email] = append(grants[record.Email], service.Permission)
+					}
+				}
+				want := []string{"Bob.Admin@example.com", "carol@example.com", "daveXops@example.org", "dave_ops@example.org", "erin@example.org"}
+				if !slices.Equal(emails, want) {
+					t.Errorf("exported %v, want %v", emails, want)
+				}
+				if len(grants["Bob.Admin@example.com"]) != 2 || !slices.Equal(grants["dave_ops@example.org"], []model.UserServicePermission{model.UserServiceSuperUser}) {
+					t.Errorf("exported grants %v", grants)
+				}
+			})
+		}
+	})
+}
+
+func TestImportUsersBeyondOneTransaction(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+
+		// Each user takes several writes, so DynamoDB needs more than one transaction
+		lines := []string{"email,services"}
+		for i := range 120 {
+			lines = append(lines, fmt.Sprintf("bulk%03d@example.com,PRUNK:USER", i))
+		}
+		records, err := ReadUserRecords(strings.NewReader(strings.Join(lines, "\n")), UserFormatCSV)
+		if err != nil {
+			t.Fatalf("ReadUserRecords failed: %v", err)
+		}
+		report, err := accounts.ImportUsers(ctx, records, false)
+		if err != nil {
+			t.Fatalf("ImportUsers failed: %v", err)
+		}
+		if len(report.Errors) != 0 || report.Imported != 120 {
+			t.Fatalf("import report has %d errors and imported %d, want 120", len(report.Errors), report.Imported)
+		}
+		for _, email := range []string{"bulk000@example.com", "bulk119@example.com"} {
+			if user, err := store.GetUserByEmail(ctx, email); err != nil || user == nil {
+				t.Errorf("imported user %s is missing (%v)", email, err)
+			}
+		}
+	})
+}
+
+func TestBatchUserRecords(t *testing.T) {
+	records := make([]UserRecord, 5)
+	records[2].Services = make([]model.Luna4UserService, 4)
+
+	// Users cost 3 writes, or 6 with four grants
+	var sizes []int
+	for _, batch := range batchUserRecords(records, 9) {
+		sizes = append(sizes, len(batch))
+	}
+	if !slices.Equal(sizes, []int{2, 2, 1}) {
+		t.Errorf("batches have %v records, want [2 2 1]", sizes)
+	}
+}
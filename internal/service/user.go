@@ -3,17 +3,81 @@ package service
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/locale"
 	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/util"
 )
 
-func (s *SQLiteService) GetAllUsers(ctx context.Context) ([]*model.Luna4User, error) {
+// ErrInvalidStatusTransition is returned when a user cannot move from their current status to the requested one
+var ErrInvalidStatusTransition = errors.New("invalid user status transition")
+
+// ErrUnsupportedLanguage is returned when a preferred language has no translations
+var ErrUnsupportedLanguage = errors.New("unsupported language")
+
+// userColumns lists the luna4_users columns in the order scanUser expects them
+const userColumns = `id, email, name, organization, notes, status, status_reason, status_changed_at, preferences, created_at, updated_at`
+
+// statusAuditActions maps each target status to the audit action recorded for it
+var statusAuditActions = map[model.UserStatus]model.AuditAction{
+	model.UserStatusActive:    model.AuditActionUserActivated,
+	model.UserStatusSuspended: model.AuditActionUserSuspended,
+	model.UserStatusLocked:    model.AuditActionUserLocked,
+	model.UserStatusDeleted:   model.AuditActionUserDeleted,
+}
+
+// qualifiedUserColumns returns userColumns prefixed with a table alias
+func qualifiedUserColumns(alias string) string {
+	columns := strings.Split(userColumns, ", ")
+	for i := range columns {
+		columns[i] = alias + "." + columns[i]
+	}
+	return strings.Join(columns, ", ")
+}
+
+// scanUser scans a row selected with userColumns, followed by any extra columns
+func scanUser(row rowScanner, user *model.Luna4User, extra ...any) error {
+	var statusChangedAt sql.NullInt64
+	var preferences string
+
+	dest := []any{
+		&user.ID,
+		&user.Email,
+		&user.Name,
+		&user.Organization,
+		&user.Notes,
+		&user.Status,
+		&user.StatusReason,
+		&statusChangedAt,
+		&preferences,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	if statusChangedAt.Valid {
+		user.StatusChangedAt = &statusChangedAt.Int64
+	}
+	if err := json.Unmarshal([]byte(preferences), &user.Preferences); err != nil {
+		return fmt.Errorf("failed to decode preferences: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) GetAllUsers(ctx context.Context) ([]*model.Luna4User, error) {
 	log.Printf("GetAllUsers: Starting to fetch all users")
 	query := `
-		SELECT id, email, status, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM luna4_users
 		ORDER BY created_at DESC
 	`
@@ -31,13 +95,7 @@ func (s *SQLiteService) GetAllUsers(ctx context.Context) ([]*model.Luna4User, er
 	for rows.Next() {
 		var user model.Luna4User
 
-		err := rows.Scan(
-			&user.ID,
-			&user.Email,
-			&user.Status,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-		)
+		err := scanUser(rows, &user)
 		if err != nil {
 			log.Printf("GetAllUsers: Failed to scan user row: %v", err)
 			return nil, fmt.Errorf("failed to scan user: %w", err)
@@ -56,18 +114,46 @@ func (s *SQLiteService) GetAllUsers(ctx context.Context) ([]*model.Luna4User, er
 	return users, nil
 }
 
-func (s *SQLiteService) CreateUser(ctx context.Context, user *model.Luna4User) error {
+// CreateUserWithServices creates the user together with their service grants. If any
+// grant fails nothing is written.
+func (s *AccountService) CreateUserWithServices(ctx context.Context, user *model.Luna4User, services []model.Luna4UserService) error {
+	return s.inTx(ctx, func(tx *AccountService) error {
+		if err := tx.CreateUser(ctx, user); err != nil {
+			return err
+		}
+
+		for i := range services {
+			if err := tx.CreateUserService(ctx, &services[i]); err != nil {
+				return fmt.Errorf("failed to create user service %s: %w", services[i].Service, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqlStore) CreateUser(ctx context.Context, user *model.Luna4User) error {
 	log.Printf("CreateUser: Creating user with ID: %s, Email: %s", user.ID, user.Email)
 	query := `
-		INSERT INTO luna4_users (id, email, status, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO luna4_users (` + userColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
+	preferences, err := json.Marshal(user.Preferences)
+	if err != nil {
+		return fmt.Errorf("failed to encode preferences: %w", err)
+	}
+
 	log.Printf("CreateUser: Executing insert query")
-	_, err := s.db.ExecContext(ctx, query,
+	_, err = s.db.ExecContext(ctx, query,
 		user.ID,
 		user.Email,
+		user.Name,
+		user.Organization,
+		user.Notes,
 		user.Status,
+		user.StatusReason,
+		user.StatusChangedAt,
+		string(preferences),
 		user.CreatedAt,
 		user.UpdatedAt,
 	)
@@ -80,10 +166,10 @@ func (s *SQLiteService) CreateUser(ctx context.Context, user *model.Luna4User) e
 	return err
 }
 
-func (s *SQLiteService) GetUserByID(ctx context.Context, userID string) (*model.Luna4User, error) {
+func (s *sqlStore) GetUserByID(ctx context.Context, userID string) (*model.Luna4User, error) {
 	log.Printf("GetUserByID: Looking for user with ID: %s", userID)
 	query := `
-		SELECT id, email, status, created_at, updated_at 
+		SELECT ` + userColumns + `
 		FROM luna4_users
 		WHERE id = ?
 	`
@@ -93,13 +179,7 @@ func (s *SQLiteService) GetUserByID(ctx context.Context, userID string) (*model.
 
 	var user model.Luna4User
 
-	err := row.Scan(
-		&user.ID,
-		&user.Email,
-		&user.Status,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	err := scanUser(row, &user)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -114,10 +194,10 @@ func (s *SQLiteService) GetUserByID(ctx context.Context, userID string) (*model.
 	return &user, nil
 }
 
-func (s *SQLiteService) GetUserByEmail(ctx context.Context, email string) (*model.Luna4User, error) {
+func (s *sqlStore) GetUserByEmail(ctx context.Context, email string) (*model.Luna4User, error) {
 	log.Printf("GetUserByEmail: Looking for user with email: %s", email)
 	query := `
-		SELECT *
+		SELECT ` + userColumns + `
 		FROM luna4_users
 		WHERE luna4_users.email = ?
 		LIMIT 1
@@ -128,13 +208,7 @@ func (s *SQLiteService) GetUserByEmail(ctx context.Context, email string) (*mode
 
 	var user model.Luna4User
 
-	err := row.Scan(
-		&user.ID,
-		&user.Email,
-		&user.Status,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	err := scanUser(row, &user)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -149,17 +223,17 @@ func (s *SQLiteService) GetUserByEmail(ctx context.Context, email string) (*mode
 	return &user, nil
 }
 
-func (s *SQLiteService) UpdateUserStatus(ctx context.Context, userID string, status model.UserStatus) error {
+func (s *sqlStore) UpdateUserStatus(ctx context.Context, userID string, status model.UserStatus, reason string) error {
 	log.Printf("UpdateUserStatus: Updating status for user %s to %v", userID, status)
 	query := `
 		UPDATE luna4_users
-		SET status = ?, updated_at = ?
+		SET status = ?, status_reason = ?, status_changed_at = ?, updated_at = ?
 		WHERE id = ?
 	`
 
 	now := time.Now().UnixMilli()
 	log.Printf("UpdateUserStatus: Executing update query")
-	_, err := s.db.ExecContext(ctx, query, status, now, userID)
+	_, err := s.db.ExecContext(ctx, query, status, reason, now, now, userID)
 	if err != nil {
 		log.Printf("UpdateUserStatus: Failed to update user status: %v", err)
 		return fmt.Errorf("failed to update user status: %w", err)
@@ -169,14 +243,352 @@ func (s *SQLiteService) UpdateUserStatus(ctx context.Context, userID string, sta
 	return nil
 }
 
-func (s *SQLiteService) DeleteUser(ctx context.Context, userID string) error {
-	log.Printf("DeleteUser: Deleting user with ID: %s", userID)
-	query := `DELETE FROM luna4_users WHERE id = ?`
+// TransitionUserStatus moves a user to the target status if the lifecycle allows it,
+// and records who did it and why
+func (s *AccountService) TransitionUserStatus(ctx context.Context, userID string, target model.UserStatus, actor, reason string) error {
+	return s.inTx(ctx, func(tx *AccountService) error {
+		user, err := tx.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return fmt.Errorf("no user found with ID: %s", userID)
+		}
 
-	log.Printf("DeleteUser: Executing delete query")
-	result, err := s.db.ExecContext(ctx, query, userID)
+		if !user.Status.CanTransitionTo(target) {
+			log.Printf("TransitionUserStatus: User %s cannot move from %s to %s", userID, user.Status, target)
+			return fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, user.Status, target)
+		}
+
+		if err := tx.UpdateUserStatus(ctx, userID, target, reason); err != nil {
+			return err
+		}
+
+		action, ok := statusAuditActions[target]
+		if user.Status == model.UserStatusDeleted {
+			action, ok = model.AuditActionUserRestored, true
+		}
+		if ok {
+			if err := tx.CreateAuditLog(ctx, userID, actor, action, reason); err != nil {
+				return err
+			}
+		}
+
+		// A restored user was already told when they were suspended before deletion
+		if target == model.UserStatusSuspended && user.Status != model.UserStatusDeleted {
+			return tx.notify(ctx, user, user.Email, uuid.New().String(), SecurityNotice{Type: model.NotificationAccountSuspended})
+		}
+		return nil
+	})
+}
+
+// UpdateUserProfile replaces the user's name, organization and notes and records who did it
+func (s *AccountService) UpdateUserProfile(ctx context.Context, userID, name, organization, notes, actor string) error {
+	return s.inTx(ctx, func(tx *AccountService) error {
+		if err := tx.Store.UpdateUserProfile(ctx, userID, name, organization, notes); err != nil {
+			return err
+		}
+		return tx.CreateAuditLog(ctx, userID, actor, model.AuditActionProfileUpdated, "")
+	})
+}
+
+func (s *sqlStore) UpdateUserProfile(ctx context.Context, userID, name, organization, notes string) error {
+	log.Printf("UpdateUserProfile: Updating profile for user %s", userID)
+	query := `
+		UPDATE luna4_users
+		SET name = ?, organization = ?, notes = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	result, err := s.db.ExecContext(ctx, query, name, organization, notes, time.Now().UnixMilli(), userID)
 	if err != nil {
-		log.Printf("DeleteUser: Failed to execute delete query: %v", err)
+		log.Printf("UpdateUserProfile: Failed to update user profile: %v", err)
+		return fmt.Errorf("failed to update user profile: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no user found with ID: %s", userID)
+	}
+	return nil
+}
+
+// UpdateUserPreferences replaces the preferences of a user. The language must be empty or
+// one of the supported locales, and is stored in its canonical form. Only non-critical
+// notifications can be opted out of.
+func (s *AccountService) UpdateUserPreferences(ctx context.Context, userID string, preferences model.UserPreferences, actor string) error {
+	if preferences.Language != "" {
+		preferences.Language = locale.Match(preferences.Language)
+		if preferences.Language == "" {
+			return ErrUnsupportedLanguage
+		}
+	}
+	for _, optOut := range preferences.NotificationOptOuts {
+		if !optOut.IsValid() || optOut.IsCritical() {
+			return ErrInvalidNotificationOptOut
+		}
+	}
+	slices.Sort(preferences.NotificationOptOuts)
+	preferences.NotificationOptOuts = slices.Compact(preferences.NotificationOptOuts)
+
+	return s.inTx(ctx, func(tx *AccountService) error {
+		if err := tx.Store.UpdateUserPreferences(ctx, userID, preferences); err != nil {
+			return err
+		}
+		return tx.CreateAuditLog(ctx, userID, actor, model.AuditActionPreferencesUpdated, "")
+	})
+}
+
+func (s *sqlStore) UpdateUserPreferences(ctx context.Context, userID string, preferences model.UserPreferences) error {
+	log.Printf("UpdateUserPreferences: Updating preferences for user %s", userID)
+	encoded, err := json.Marshal(preferences)
+	if err != nil {
+		return fmt.Errorf("failed to encode preferences: %w", err)
+	}
+
+	query := `
+		UPDATE luna4_users
+		SET preferences = ?, updated_at = ?
+		WHERE id = ?
+	`
+
+	result, err := s.db.ExecContext(ctx, query, string(encoded), time.Now().UnixMilli(), userID)
+	if err != nil {
+		log.Printf("UpdateUserPreferences: Failed to update user preferences: %v", err)
+		return fmt.Errorf("failed to update user preferences: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("no user found with ID: %s", userID)
+	}
+	return nil
+}
+
+// SuspendUser moves a user to the suspended status and records who did it and why
+func (s *AccountService) SuspendUser(ctx context.Context, userID, actor, reason string) error {
+	return s.TransitionUserStatus(ctx, userID, model.UserStatusSuspended, actor, reason)
+}
+
+// ActivateUser moves a user to the active status and records who did it and why
+func (s *AccountService) ActivateUser(ctx context.Context, userID, actor, reason string) error {
+	return s.TransitionUserStatus(ctx, userID, model.UserStatusActive, actor, reason)
+}
+
+// LockUser moves a user to the locked status and records who did it and why
+func (s *AccountService) LockUser(ctx context.Context, userID, actor, reason string) error {
+	return s.TransitionUserStatus(ctx, userID, model.UserStatusLocked, actor, reason)
+}
+
+// GetDormantUsers returns active users whose last activity happened before the given time
+// in milliseconds. A user is active when they complete an email login, start a session or
+// sign in with a passkey; a user who never did is active when created.
+func (s *sqlStore) GetDormantUsers(ctx context.Context, inactiveSince int64) ([]model.Luna4UserActivity, error) {
+	log.Printf("GetDormantUsers: Looking for users inactive since %d", inactiveSince)
+	query := `
+		SELECT ` + userColumns + `, last_active_at, dormancy_warned_at
+		FROM (
+			SELECT
+				u.*,
+				COALESCE((
+					SELECT MAX(active_at)
+					FROM (
+						SELECT a.sent_at AS active_at
+						FROM luna4_email_auth a
+						WHERE a.user_id = u.id AND a.completed = TRUE
+						UNION ALL
+						SELECT se.created_at
+						FROM luna4_session se
+						WHERE se.user_id = u.id
+						UNION ALL
+						SELECT c.last_used_at
+						FROM luna4_webauthn_credential c
+						WHERE c.user_id = u.id
+					) AS activity_times
+				), u.created_at) AS last_active_at,
+				(
+					SELECT MAX(l.created_at)
+					FROM luna4_audit_log l
+					WHERE l.user_id = u.id AND l.action = ?
+				) AS dormancy_warned_at
+			FROM luna4_users u
+			WHERE u.status = ?
+		) AS activity
+		WHERE last_active_at < ?
+		ORDER BY last_active_at
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, model.AuditActionDormancyWarning, model.UserStatusActive, inactiveSince)
+	if err != nil {
+		log.Printf("GetDormantUsers: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query dormant users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []model.Luna4UserActivity
+	for rows.Next() {
+		var user model.Luna4UserActivity
+		var warnedAt sql.NullInt64
+
+		err := scanUser(rows, &user.Luna4User, &user.LastActiveAt, &warnedAt)
+		if err != nil {
+			log.Printf("GetDormantUsers: Failed to scan user row: %v", err)
+			return nil, fmt.Errorf("failed to scan dormant user: %w", err)
+		}
+
+		if warnedAt.Valid {
+			user.DormancyWarnedAt = &warnedAt.Int64
+		}
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("GetDormantUsers: Error during row iteration: %v", err)
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	log.Printf("GetDormantUsers: Found %d dormant users", len(users))
+	return users, nil
+}
+
+// WarnDormantUser queues the dormancy warning and records it in the audit log. The warning is
+// queued once per period of inactivity, so a run interrupted before the audit entry is
+// written does not send it twice.
+func (s *AccountService) WarnDormantUser(ctx context.Context, user *model.Luna4UserActivity, inactiveDays int, suspendAt time.Time, actor string) error {
+	msg, err := s.emails.DormancyWarningEmail(user.Email, inactiveDays, suspendAt, locale.Resolve(user.Preferences.Language))
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("dormancy-warning:%s:%d", user.ID, user.LastActiveAt)
+	detail := "Suspension scheduled for " + suspendAt.UTC().Format(time.RFC3339)
+	return s.inTx(ctx, func(tx *AccountService) error {
+		if _, err := tx.QueueEmail(ctx, key, msg, nil); err != nil {
+			return err
+		}
+		return tx.CreateAuditLog(ctx, user.ID, actor, model.AuditActionDormancyWarning, detail)
+	})
+}
+
+// DeleteUser soft deletes a user by moving them to the deleted status; the user can be
+// restored until PurgeUser removes them for good
+func (s *AccountService) DeleteUser(ctx context.Context, userID, actor, reason string) error {
+	return s.TransitionUserStatus(ctx, userID, model.UserStatusDeleted, actor, reason)
+}
+
+// RestoreUser brings a soft deleted user back in the suspended status
+func (s *AccountService) RestoreUser(ctx context.Context, userID, actor, reason string) error {
+	return s.TransitionUserStatus(ctx, userID, model.UserStatusSuspended, actor, reason)
+}
+
+// GetDeletedUsers returns soft deleted users whose deletion happened before the given time in milliseconds
+func (s *sqlStore) GetDeletedUsers(ctx context.Context, deletedBefore int64) ([]*model.Luna4User, error) {
+	log.Printf("GetDeletedUsers: Looking for users deleted before %d", deletedBefore)
+	query := `
+		SELECT ` + userColumns + `
+		FROM luna4_users
+		WHERE status = ? AND status_changed_at < ?
+		ORDER BY status_changed_at
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, model.UserStatusDeleted, deletedBefore)
+	if err != nil {
+		log.Printf("GetDeletedUsers: Query failed: %v", err)
+		return nil, fmt.Errorf("failed to query deleted users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*model.Luna4User
+	for rows.Next() {
+		var user model.Luna4User
+
+		err := scanUser(rows, &user)
+		if err != nil {
+			log.Printf("GetDeletedUsers: Failed to scan user row: %v", err)
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+
+		users = append(users, &user)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("GetDeletedUsers: Error during row iteration: %v", err)
+		return nil, fmt.Errorf("error iterating over rows: %w", err)
+	}
+
+	log.Printf("GetDeletedUsers: Found %d deleted users", len(users))
+	return users, nil
+}
+
+// PurgeUser permanently deletes a user and everything that belongs to them. Audit entries
+// are kept, with references to the user replaced by a pseudonym.
+func (s *AccountService) PurgeUser(ctx context.Context, userID, actor string) error {
+	return s.removeUser(ctx, userID, actor, model.AuditActionUserPurged)
+}
+
+// EraseUser removes a user's personal data immediately, whatever their status, to fulfil
+// a data subject erasure request
+func (s *AccountService) EraseUser(ctx context.Context, userID, actor string) error {
+	return s.removeUser(ctx, userID, actor, model.AuditActionUserErased)
+}
+
+func (s *AccountService) removeUser(ctx context.Context, userID, actor string, action model.AuditAction) error {
+	pseudonym := util.PseudonymizeID(userID)
+	return s.inTx(ctx, func(tx *AccountService) error {
+		if err := tx.DeleteUserData(ctx, userID, pseudonym); err != nil {
+			return err
+		}
+
+		return tx.CreateAuditLog(ctx, pseudonym, actor, action, "")
+	})
+}
+
+func (s *sqlStore) DeleteUserData(ctx context.Context, userID, pseudonym string) error {
+	log.Printf("DeleteUserData: Purging user with ID: %s", userID)
+
+	// Details are free text and may identify the user, so only the action and time survive
+	_, err := s.db.ExecContext(ctx, `UPDATE luna4_audit_log SET user_id = ?, detail = '' WHERE user_id = ?`, pseudonym, userID)
+	if err != nil {
+		log.Printf("DeleteUserData: Failed to anonymize audit logs: %v", err)
+		return fmt.Errorf("failed to anonymize audit logs: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `UPDATE luna4_audit_log SET actor = ? WHERE actor = ?`, pseudonym, userID)
+	if err != nil {
+		log.Printf("DeleteUserData: Failed to anonymize audit log actors: %v", err)
+		return fmt.Errorf("failed to anonymize audit logs: %w", err)
+	}
+
+	// Remove dependent rows explicitly rather than relying on ON DELETE CASCADE. Mail and
+	// delivery events are keyed by address; suppressions are kept so the address is never
+	// mailed again.
+	for _, query := range []string{
+		`DELETE FROM luna4_delivery_event WHERE email = (SELECT LOWER(email) FROM luna4_users WHERE id = ?)`,
+		`DELETE FROM luna4_outbox_email WHERE LOWER(recipient) = (SELECT LOWER(email) FROM luna4_users WHERE id = ?)`,
+		`DELETE FROM luna4_email_auth WHERE user_id = ?`,
+		`DELETE FROM luna4_user_service WHERE user_id = ?`,
+		`DELETE FROM luna4_session WHERE user_id = ?`,
+		`DELETE FROM luna4_email_change WHERE user_id = ?`,
+		`DELETE FROM luna4_scim_user WHERE user_id = ?`,
+		`DELETE FROM luna4_webauthn_credential WHERE user_id = ?`,
+	} {
+		if _, err := s.db.ExecContext(ctx, query, userID); err != nil {
+			log.Printf("DeleteUserData: Failed to delete user data: %v", err)
+			return fmt.Errorf("failed to delete user data: %w", err)
+		}
+	}
+
+	log.Printf("DeleteUserData: Executing delete query")
+	result, err := s.db.ExecContext(ctx, `DELETE FROM luna4_users WHERE id = ?`, userID)
+	if err != nil {
+		log.Printf("DeleteUserData: Failed to execute delete query: %v", err)
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
 
@@ -186,15 +598,15 @@ func (s *SQLiteService) DeleteUser(ctx context.Context, userID string) error {
 	}
 
 	if rowsAffected == 0 {
-		log.Printf("DeleteUser: No user found with ID: %s", userID)
+		log.Printf("DeleteUserData: No user found with ID: %s", userID)
 		return fmt.Errorf("no user found with ID: %s", userID)
 	}
 
-	log.Printf("DeleteUser: Successfully deleted user with ID: %s (rows affected: %d)", userID, rowsAffected)
+	log.Printf("DeleteUserData: Successfully purged user with ID: %s", userID)
 	return nil
 }
 
-func (s *SQLiteService) CreateUserService(ctx context.Context, userService *model.Luna4UserService) error {
+func (s *sqlStore) CreateUserService(ctx context.Context, userService *model.Luna4UserService) error {
 	log.Printf("CreateUserService: Creating service %s for user %s with permission %s", userService.Service, userService.UserID, userService.Permission)
 	query := `
 		INSERT INTO luna4_user_service (id, user_id, service, permission, expires_at)
@@ -218,7 +630,7 @@ func (s *SQLiteService) CreateUserService(ctx context.Context, userService *mode
 	return err
 }
 
-func (s *SQLiteService) GetUserServices(ctx context.Context, userID string) ([]model.Luna4UserService, error) {
+func (s *sqlStore) GetUserServices(ctx context.Context, userID string) ([]model.Luna4UserService, error) {
 	log.Printf("GetUserServices: Fetching services for user: %s", userID)
 	query := `
 		SELECT id, user_id, service, permission, expires_at
@@ -270,7 +682,41 @@ func (s *SQLiteService) GetUserServices(ctx context.Context, userID string) ([]m
 	return services, nil
 }
 
-func (s *SQLiteService) DeleteUserService(ctx context.Context, serviceID string) error {
+// ReplaceUserServices removes every service grant of the user and creates the given ones in
+// their place, and tells the user about the grants they received and lost
+func (s *AccountService) ReplaceUserServices(ctx context.Context, userID string, services []model.Luna4UserService) error {
+	return s.inTx(ctx, func(tx *AccountService) error {
+		user, err := tx.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return fmt.Errorf("no user found with ID: %s", userID)
+		}
+
+		existing, err := tx.GetUserServices(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		for _, service := range existing {
+			if err := tx.DeleteUserService(ctx, service.ID); err != nil {
+				return err
+			}
+		}
+
+		for i := range services {
+			if err := tx.CreateUserService(ctx, &services[i]); err != nil {
+				return fmt.Errorf("failed to create user service %s: %w", services[i].Service, err)
+			}
+		}
+
+		granted, removed := diffGrants(existing, services)
+		return tx.notifyServiceAccess(ctx, user, uuid.New().String(), granted, removed)
+	})
+}
+
+func (s *sqlStore) DeleteUserService(ctx context.Context, serviceID string) error {
 	log.Printf("DeleteUserService: Deleting service with ID: %s", serviceID)
 	query := `DELETE FROM luna4_user_service WHERE id = ?`
 
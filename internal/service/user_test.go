@@ -0,0 +1,427 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/util"
+)
+
+func TestGetDormantUsersCountsEveryActivity(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		now := time.Now()
+		daysAgo := func(days int) int64 { return now.AddDate(0, 0, -days).UnixMilli() }
+
+		// Every user was created 120 days ago and is dormant after 90
+		createUser := func(email string) *model.Luna4User {
+			t.Helper()
+			user := &model.Luna4User{
+				ID:        uuid.New().String(),
+				Email:     email,
+				Status:    model.UserStatusActive,
+				CreatedAt: daysAgo(120),
+				UpdatedAt: daysAgo(120),
+			}
+			if err := store.CreateUser(ctx, user); err != nil {
+				t.Fatalf("failed to create user %s: %v", email, err)
+			}
+			return user
+		}
+		emailAuth := func(user *model.Luna4User, sentAt int64, completed bool) {
+			t.Helper()
+			emailAuth := &model.Luna4EmailAuth{ID: uuid.New().String(), UserID: user.ID, Token: uuid.New().String(), SentAt: sentAt}
+			if err := store.CreateEmailAuth(ctx, emailAuth); err != nil {
+				t.Fatalf("failed to create email auth: %v", err)
+			}
+			if completed {
+				if err := store.MarkEmailAuthCompleted(ctx, emailAuth.ID); err != nil {
+					t.Fatalf("failed to complete email auth: %v", err)
+				}
+			}
+		}
+		session := func(user *model.Luna4User, createdAt int64) {
+			t.Helper()
+			session := &model.Luna4Session{ID: uuid.New().String(), UserID: user.ID, CreatedAt: createdAt, ExpiresAt: createdAt + time.Hour.Milliseconds()}
+			if err := store.CreateSession(ctx, session); err != nil {
+				t.Fatalf("failed to create session: %v", err)
+			}
+		}
+		passkey := func(user *model.Luna4User, lastUsedAt *int64) {
+			t.Helper()
+			credential := &model.Luna4WebAuthnCredential{
+				ID:        uuid.New().String(),
+				UserID:    user.ID,
+				PublicKey: []byte{1},
+				Algorithm: -7,
+				CreatedAt: daysAgo(110),
+			}
+			if err := store.CreateWebAuthnCredential(ctx, credential); err != nil {
+				t.Fatalf("failed to create passkey: %v", err)
+			}
+			if lastUsedAt != nil {
+				if err := store.MarkWebAuthnCredentialUsed(ctx, credential.ID, 1, false, *lastUsedAt); err != nil {
+					t.Fatalf("failed to mark passkey used: %v", err)
+				}
+			}
+		}
+
+		neverActive := createUser("never@example.com")
+
+		emailLogin := createUser("email@example.com")
+		emailAuth(emailLogin, daysAgo(100), true)
+		emailAuth(emailLogin, daysAgo(95), true)
+		emailAuth(emailLogin, daysAgo(1), false)
+
+		recentEmail := createUser("recent-email@example.com")
+		emailAuth(recentEmail, daysAgo(100), true)
+		emailAuth(recentEmail, daysAgo(10), true)
+
+		recentSession := createUser("recent-session@example.com")
+		emailAuth(recentSession, daysAgo(100), true)
+		session(recentSession, daysAgo(100))
+		session(recentSession, daysAgo(5))
+
+		recentPasskey := createUser("recent-passkey@example.com")
+		emailAuth(recentPasskey, daysAgo(100), true)
+		passkey(recentPasskey, nil)
+		usedAt := daysAgo(3)
+		passkey(recentPasskey, &usedAt)
+
+		oldSession := createUser("old-session@example.com")
+		emailAuth(oldSession, daysAgo(115), true)
+		session(oldSession, daysAgo(93))
+		oldUse := daysAgo(97)
+		passkey(oldSession, &oldUse)
+
+		dormant, err := store.GetDormantUsers(ctx, daysAgo(90))
+		if err != nil {
+			t.Fatalf("GetDormantUsers failed: %v", err)
+		}
+
+		// Longest inactive first
+		want := []struct {
+			user         *model.Luna4User
+			lastActiveAt int64
+		}{
+			{neverActive, neverActive.CreatedAt},
+			{emailLogin, daysAgo(95)},
+			{oldSession, daysAgo(93)},
+		}
+		if len(dormant) != len(want) {
+			var emails []string
+			for _, user := range dormant {
+				emails = append(emails, user.Email)
+			}
+			t.Fatalf("dormant users are %v, want %d", emails, len(want))
+		}
+		for i, w := range want {
+			if dormant[i].ID != w.user.ID {
+				t.Errorf("dormant user %d is %s, want %s", i, dormant[i].Email, w.user.Email)
+				continue
+			}
+			if dormant[i].LastActiveAt != w.lastActiveAt {
+				t.Errorf("%s was last active at %d, want %d", w.user.Email, dormant[i].LastActiveAt, w.lastActiveAt)
+			}
+		}
+	})
+}
+
+func TestDeleteAndRestoreUser(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		user := createTestUser(t, store, "deleted@example.com", model.UserStatusSuspended)
+		grant := &model.Luna4UserService{ID: uuid.New().String(), UserID: user.ID, Service: model.Luna4ServicePrunk, Permission: model.UserServiceUser}
+		if err := store.CreateUserService(ctx, grant); err != nil {
+			t.Fatalf("failed to create user service: %v", err)
+		}
+
+		// Users are suspended before they can be deleted
+		active := createTestUser(t, store, "active@example.com", model.UserStatusActive)
+		if err := accounts.DeleteUser(ctx, active.ID, "admin", ""); !errors.Is(err, ErrInvalidStatusTransition) {
+			t.Errorf("deleting an active user returned %v, want ErrInvalidStatusTransition", err)
+		}
+
+		if err := accounts.DeleteUser(ctx, user.ID, "admin", "Left the company"); err != nil {
+			t.Fatalf("DeleteUser failed: %v", err)
+		}
+		deleted, err := store.GetUserByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("failed to read user: %v", err)
+		}
+		if deleted == nil || deleted.Status != model.UserStatusDeleted || deleted.StatusChangedAt == nil {
+			t.Fatalf("deleted user is %+v, want kept with the deleted status", deleted)
+		}
+		if deleted.Status.CanAuthenticate() {
+			t.Errorf("a deleted user can sign in")
+		}
+
+		// Nothing else can happen to a deleted user until they are restored
+		for name, change := range map[string]func() error{
+			"activate": func() error { return accounts.ActivateUser(ctx, user.ID, "admin", "") },
+			"delete":   func() error { return accounts.DeleteUser(ctx, user.ID, "admin", "") },
+		} {
+			if err := change(); !errors.Is(err, ErrInvalidStatusTransition) {
+				t.Errorf("%s on a deleted user returned %v, want ErrInvalidStatusTransition", name, err)
+			}
+		}
+
+		if err := accounts.RestoreUser(ctx, user.ID, "admin", "Deleted by mistake"); err != nil {
+			t.Fatalf("RestoreUser failed: %v", err)
+		}
+		restored, err := store.GetUserByID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("failed to read user: %v", err)
+		}
+		if restored.Status != model.UserStatusSuspended || restored.StatusReason != "Deleted by mistake" {
+			t.Errorf("restored user is %s because %q, want suspended", restored.Status, restored.StatusReason)
+		}
+		if services, err := store.GetUserServices(ctx, user.ID); err != nil || len(services) != 1 {
+			t.Errorf("restored user has services %v (%v), want the grant kept", services, err)
+		}
+
+		logs, err := store.GetUserAuditLogs(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("failed to read audit logs: %v", err)
+		}
+		actions := make(map[model.AuditAction]bool)
+		for _, log := range logs {
+			actions[log.Action] = true
+		}
+		if len(logs) != 2 || !actions[model.AuditActionUserDeleted] || !actions[model.AuditActionUserRestored] {
+			t.Errorf("audit entries are %v, want the deletion and the restore", logs)
+		}
+
+		// Only deleted users can be restored
+		if err := accounts.RestoreUser(ctx, user.ID, "admin", ""); !errors.Is(err, ErrInvalidStatusTransition) {
+			t.Errorf("restoring a suspended user returned %v, want ErrInvalidStatusTransition", err)
+		}
+	})
+}
+
+func TestPurgeUser(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		user := createTestUser(t, store, "purged@example.com", model.UserStatusSuspended)
+		other := createTestUser(t, store, "other@example.com", model.UserStatusActive)
+
+		now := time.Now().UnixMilli()
+		for _, owner := range []*model.Luna4User{user, other} {
+			createTestEmailAuth(t, store, owner.ID)
+			session := &model.Luna4Session{ID: uuid.New().String(), UserID: owner.ID, CreatedAt: now, ExpiresAt: now + time.Hour.Milliseconds()}
+			if err := store.CreateSession(ctx, session); err != nil {
+				t.Fatalf("failed to create session: %v", err)
+			}
+			grant := &model.Luna4UserService{ID: uuid.New().String(), UserID: owner.ID, Service: model.Luna4ServicePrunk, Permission: model.UserServiceUser}
+			if err := store.CreateUserService(ctx, grant); err != nil {
+				t.Fatalf("failed to create user service: %v", err)
+			}
+		}
+		if err := accounts.DeleteUser(ctx, user.ID, "admin", "Requested by "+user.Email); err != nil {
+			t.Fatalf("DeleteUser failed: %v", err)
+		}
+		if err := store.CreateAuditLog(ctx, other.ID, user.ID, model.AuditActionProfileUpdated, ""); err != nil {
+			t.Fatalf("failed to create audit log: %v", err)
+		}
+
+		// Only deleted users are due, and only once their deletion is older than the cutoff
+		if due, err := store.GetDeletedUsers(ctx, now-time.Hour.Milliseconds()); err != nil || len(due) != 0 {
+			t.Errorf("users deleted an hour ago are %v (%v), want none", due, err)
+		}
+		due, err := store.GetDeletedUsers(ctx, time.Now().Add(time.Second).UnixMilli())
+		if err != nil {
+			t.Fatalf("GetDeletedUsers failed: %v", err)
+		}
+		if len(due) != 1 || due[0].ID != user.ID {
+			t.Fatalf("deleted users are %v, want %s", due, user.Email)
+		}
+
+		if err := accounts.PurgeUser(ctx, user.ID, "policy:user-purge"); err != nil {
+			t.Fatalf("PurgeUser failed: %v", err)
+		}
+
+		if purged, err := store.GetUserByID(ctx, user.ID); err != nil || purged != nil {
+			t.Errorf("purged user is %v (%v), want gone", purged, err)
+		}
+		if purged, err := store.GetUserByEmail(ctx, user.Email); err != nil || purged != nil {
+			t.Errorf("purged user's email still finds %v (%v)", purged, err)
+		}
+		for _, owner := range []*model.Luna4User{user, other} {
+			want := 0
+			if owner == other {
+				want = 1
+			}
+			emailAuths, _ := store.GetUserEmailAuths(ctx, owner.ID)
+			sessions, _ := store.GetUserSessions(ctx, owner.ID)
+			services, _ := store.GetUserServices(ctx, owner.ID)
+			if len(emailAuths) != want || len(sessions) != want || len(services) != want {
+				t.Errorf("%s has %d email auths, %d sessions and %d services, want %d of each",
+					owner.Email, len(emailAuths), len(sessions), len(services), want)
+			}
+		}
+
+		// Audit entries about and by the user survive under a pseudonym, without details
+		if logs, err := store.GetUserAuditLogs(ctx, user.ID); err != nil || len(logs) != 0 {
+			t.Errorf("audit entries still reference the purged user: %v (%v)", logs, err)
+		}
+		pseudonym := util.PseudonymizeID(user.ID)
+		logs, err := store.GetUserAuditLogs(ctx, pseudonym)
+		if err != nil {
+			t.Fatalf("failed to read audit logs: %v", err)
+		}
+		actions := make(map[model.AuditAction]bool)
+		for _, log := range logs {
+			actions[log.Action] = true
+			if log.Detail != "" {
+				t.Errorf("audit entry %s kept the detail %q", log.Action, log.Detail)
+			}
+		}
+		if len(logs) != 3 || !actions[model.AuditActionUserDeleted] || !actions[model.AuditActionUserPurged] || !actions[model.AuditActionProfileUpdated] {
+			t.Errorf("pseudonymous audit entries are %v, want the deletion, the purge and the profile update", logs)
+		}
+		otherLogs, err := store.GetUserAuditLogs(ctx, other.ID)
+		if err != nil {
+			t.Fatalf("failed to read audit logs: %v", err)
+		}
+		if len(otherLogs) != 1 || otherLogs[0].Actor != pseudonym {
+			t.Errorf("entries the purged user made are %v, want them attributed to %s", otherLogs, pseudonym)
+		}
+
+		if err := accounts.PurgeUser(ctx, user.ID, "policy:user-purge"); err == nil {
+			t.Errorf("purging a purged user succeeded")
+		}
+	})
+}
+
+// testGrants returns grants of the permissions for the user, the last one reusing the first
+// one's ID when conflicting is set so that writing it fails
+func testGrants(userID string, conflicting bool, permissions ...model.UserServicePermission) []model.Luna4UserService {
+	var grants []model.Luna4UserService
+	for _, permission := range permissions {
+		grants = append(grants, model.Luna4UserService{ID: uuid.New().String(), UserID: userID, Service: model.Luna4ServicePrunk, Permission: permission})
+	}
+	if conflicting {
+		grants[len(grants)-1].ID = grants[0].ID
+	}
+	return grants
+}
+
+func TestCreateUserWithServicesIsAllOrNothing(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		now := time.Now().UnixMilli()
+		newUser := func(email string) *model.Luna4User {
+			return &model.Luna4User{ID: uuid.New().String(), Email: email, Status: model.UserStatusActive, CreatedAt: now, UpdatedAt: now}
+		}
+
+		failed := newUser("failed@example.com")
+		grants := testGrants(failed.ID, true, model.UserServiceUser, model.UserServiceSuperUser)
+		if err := accounts.CreateUserWithServices(ctx, failed, grants); err == nil {
+			t.Fatalf("creating a user with a failing grant succeeded")
+		}
+		if stored, err := store.GetUserByEmail(ctx, failed.Email); err != nil || stored != nil {
+			t.Errorf("user with a failed grant is %v (%v), want nothing written", stored, err)
+		}
+		if services, err := store.GetUserServices(ctx, failed.ID); err != nil || len(services) != 0 {
+			t.Errorf("user with a failed grant has services %v (%v)", services, err)
+		}
+
+		created := newUser("created@example.com")
+		if err := accounts.CreateUserWithServices(ctx, created, testGrants(created.ID, false, model.UserServiceUser, model.UserServiceSuperUser)); err != nil {
+			t.Fatalf("CreateUserWithServices failed: %v", err)
+		}
+		if stored, err := store.GetUserByEmail(ctx, created.Email); err != nil || stored == nil {
+			t.Errorf("created user is %v (%v)", stored, err)
+		}
+		if services, err := store.GetUserServices(ctx, created.ID); err != nil || len(services) != 2 {
+			t.Errorf("created user has services %v (%v), want 2", services, err)
+		}
+	})
+}
+
+func TestReplaceUserServicesIsAllOrNothing(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		user := createTestUser(t, store, "grants@example.com", model.UserStatusActive)
+		original := testGrants(user.ID, false, model.UserServiceUser)
+		if err := store.CreateUserService(ctx, &original[0]); err != nil {
+			t.Fatalf("failed to create user service: %v", err)
+		}
+
+		if err := accounts.ReplaceUserServices(ctx, user.ID, testGrants(user.ID, true, model.UserServiceSuperUser, model.UserServiceUser)); err == nil {
+			t.Fatalf("replacing grants with a failing grant succeeded")
+		}
+		services, err := store.GetUserServices(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("failed to read services: %v", err)
+		}
+		if len(services) != 1 || services[0].ID != original[0].ID {
+			t.Errorf("services after a failed replace are %v, want the original grant", services)
+		}
+		if queued, err := store.GetOutboxEmails(ctx, model.OutboxStatusPending, 10); err != nil || len(queued) != 0 {
+			t.Errorf("failed replace queued %d notices (%v)", len(queued), err)
+		}
+
+		replacement := testGrants(user.ID, false, model.UserServiceSuperUser)
+		if err := accounts.ReplaceUserServices(ctx, user.ID, replacement); err != nil {
+			t.Fatalf("ReplaceUserServices failed: %v", err)
+		}
+		services, err = store.GetUserServices(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("failed to read services: %v", err)
+		}
+		if len(services) != 1 || services[0].ID != replacement[0].ID {
+			t.Errorf("services after the replace are %v, want the replacement", services)
+		}
+	})
+}
+
+func TestUpdateUserPreferences(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		ctx := context.Background()
+		accounts := newTestAccountServiceOn(t, store)
+		user := createTestUser(t, store, "prefs@example.com", model.UserStatusActive)
+
+		// Regional tags are stored as the supported locale they match
+		if err := accounts.UpdateUserPreferences(ctx, user.ID, model.UserPreferences{Language: "ko-KR"}, user.ID); err != nil {
+			t.Fatalf("UpdateUserPreferences failed: %v", err)
+		}
+		stored, err := store.GetUserByID(ctx, user.ID)
+		if err != nil || stored == nil {
+			t.Fatalf("failed to read user: %v", err)
+		}
+		if stored.Preferences.Language != "ko" {
+			t.Errorf("language is %q, want ko", stored.Preferences.Language)
+		}
+		if n := countAuditLogs(t, store, user.ID, model.AuditActionPreferencesUpdated); n != 1 {
+			t.Errorf("recorded %d preference updates, want 1", n)
+		}
+
+		if err := accounts.UpdateUserPreferences(ctx, user.ID, model.UserPreferences{Language: "fr"}, user.ID); !errors.Is(err, ErrUnsupportedLanguage) {
+			t.Errorf("unsupported language returned %v, want ErrUnsupportedLanguage", err)
+		}
+		if stored, _ := store.GetUserByID(ctx, user.ID); stored.Preferences.Language != "ko" {
+			t.Errorf("rejected update changed the language to %q", stored.Preferences.Language)
+		}
+
+		// An empty language hands the choice back to the browser
+		if err := accounts.UpdateUserPreferences(ctx, user.ID, model.UserPreferences{}, user.ID); err != nil {
+			t.Fatalf("clearing the language failed: %v", err)
+		}
+		if stored, _ := store.GetUserByID(ctx, user.ID); stored.Preferences.Language != "" {
+			t.Errorf("language is %q after clearing it", stored.Preferences.Language)
+		}
+
+		if err := accounts.UpdateUserPreferences(ctx, uuid.New().String(), model.UserPreferences{Language: "en"}, "admin"); err == nil {
+			t.Errorf("updating an unknown user succeeded")
+		}
+	})
+}
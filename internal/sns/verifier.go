@@ -0,0 +1,206 @@
+package sns
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fetchTimeout    = 10 * time.Second
+	maxResponseSize = 64 << 10
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid SNS message signature")
+	ErrTopicNotAllowed  = errors.New("SNS topic is not allowed")
+)
+
+// snsHost matches the regional SNS endpoints that serve signing certificates and
+// subscription confirmations
+var snsHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// Config controls which SNS messages are accepted
+type Config struct {
+	VerifySignatures bool
+	TopicArns        []string
+}
+
+// GetConfig reads the SNS settings from the environment
+func GetConfig() Config {
+	var topics []string
+	for _, topic := range strings.Split(os.Getenv("SNS_TOPIC_ARNS"), ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			topics = append(topics, topic)
+		}
+	}
+
+	return Config{
+		VerifySignatures: os.Getenv("SNS_VERIFY_SIGNATURES") != "false", // Default true
+		TopicArns:        topics,                                        // Required when verifying signatures
+	}
+}
+
+// Verifier checks that messages were signed by SNS and come from an allowed topic,
+// caching the signing certificates it downloads
+type Verifier struct {
+	config Config
+	client *http.Client
+
+	mu    sync.Mutex
+	certs map[string]*x509.Certificate
+}
+
+func NewVerifier(config Config) (*Verifier, error) {
+	return NewVerifierWithClient(config, &http.Client{Timeout: fetchTimeout})
+}
+
+// NewVerifierWithClient returns a verifier that downloads signing certificates and confirms
+// subscriptions with the client. Verifying signatures requires a topic allowlist: any AWS
+// account can create a topic whose messages carry valid SNS signatures.
+func NewVerifierWithClient(config Config, client *http.Client) (*Verifier, error) {
+	if config.VerifySignatures && len(config.TopicArns) == 0 {
+		return nil, errors.New("SNS_TOPIC_ARNS is required when SNS signatures are verified")
+	}
+	return &Verifier{
+		config: config,
+		client: client,
+		certs:  make(map[string]*x509.Certificate),
+	}, nil
+}
+
+// Verify returns nil when the message may be processed. Only unverified messages, which
+// are meant for local testing, may come from any topic when no allowlist is set.
+func (v *Verifier) Verify(ctx context.Context, m *Message) error {
+	if (v.config.VerifySignatures || len(v.config.TopicArns) > 0) && !slices.Contains(v.config.TopicArns, m.TopicArn) {
+		return ErrTopicNotAllowed
+	}
+	if !v.config.VerifySignatures {
+		return nil
+	}
+
+	var hash crypto.Hash
+	switch m.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return fmt.Errorf("%w: unsupported signature version %q", ErrInvalidSignature, m.SignatureVersion)
+	}
+
+	canonical, err := m.stringToSign()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil || len(signature) == 0 {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+
+	cert, err := v.certificate(ctx, m.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	publicKey, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: signing certificate does not hold an RSA key", ErrInvalidSignature)
+	}
+
+	digest := hash.New()
+	digest.Write([]byte(canonical))
+	if err := rsa.VerifyPKCS1v15(publicKey, hash, digest.Sum(nil), signature); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ConfirmSubscription visits the subscribe URL of a SubscriptionConfirmation message
+func (v *Verifier) ConfirmSubscription(ctx context.Context, m *Message) error {
+	if _, err := snsURL(m.SubscribeURL); err != nil {
+		return err
+	}
+
+	if _, err := v.fetch(ctx, m.SubscribeURL); err != nil {
+		return fmt.Errorf("failed to confirm SNS subscription: %w", err)
+	}
+	return nil
+}
+
+func (v *Verifier) certificate(ctx context.Context, certURL string) (*x509.Certificate, error) {
+	u, err := snsURL(certURL)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(u.Path, ".pem") {
+		return nil, fmt.Errorf("%w: signing certificate URL is not a PEM file", ErrInvalidSignature)
+	}
+
+	v.mu.Lock()
+	cert, ok := v.certs[certURL]
+	v.mu.Unlock()
+	if ok {
+		return cert, nil
+	}
+
+	body, err := v.fetch(ctx, certURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download SNS signing certificate: %w", err)
+	}
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, fmt.Errorf("%w: signing certificate is not PEM encoded", ErrInvalidSignature)
+	}
+	cert, err = x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse signing certificate: %v", ErrInvalidSignature, err)
+	}
+
+	v.mu.Lock()
+	v.certs[certURL] = cert
+	v.mu.Unlock()
+	return cert, nil
+}
+
+func (v *Verifier) fetch(ctx context.Context, target string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+}
+
+// snsURL accepts only HTTPS URLs on an SNS endpoint, so a forged message cannot make
+// airlock fetch arbitrary URLs
+func snsURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || !snsHost.MatchString(u.Hostname()) || u.Port() != "" {
+		return nil, fmt.Errorf("%w: URL %q is not an SNS endpoint", ErrInvalidSignature, raw)
+	}
+	return u, nil
+}
@@ -0,0 +1,266 @@
+package sns
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testTopicArn = "arn:aws:sns:us-east-1:123456789012:ses-notifications"
+	testCertURL  = "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-0123456789.pem"
+)
+
+// certServer answers every request with the PEM of a self-signed signing certificate and
+// records the URLs it was asked for
+type certServer struct {
+	key *rsa.PrivateKey
+	pem []byte
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func newCertServer(t *testing.T) *certServer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return &certServer{
+		key: key,
+		pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+func (s *certServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.mu.Lock()
+	s.requests = append(s.requests, req.URL.String())
+	s.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Body:       io.NopCloser(bytes.NewReader(s.pem)),
+		Request:    req,
+	}, nil
+}
+
+func (s *certServer) verifier(t *testing.T, config Config) *Verifier {
+	t.Helper()
+	v, err := NewVerifierWithClient(config, &http.Client{Transport: s})
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	return v
+}
+
+// sign signs the message as SNS does with the given signature version
+func (s *certServer) sign(t *testing.T, m *Message, version string) {
+	t.Helper()
+	hash := crypto.SHA1
+	if version == "2" {
+		hash = crypto.SHA256
+	}
+
+	canonical, err := m.stringToSign()
+	if err != nil {
+		t.Fatalf("failed to build string to sign: %v", err)
+	}
+	digest := hash.New()
+	digest.Write([]byte(canonical))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, hash, digest.Sum(nil))
+	if err != nil {
+		t.Fatalf("failed to sign message: %v", err)
+	}
+
+	m.SignatureVersion = version
+	m.Signature = base64.StdEncoding.EncodeToString(signature)
+}
+
+func testNotification() *Message {
+	return &Message{
+		Type:           TypeNotification,
+		MessageID:      "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+		TopicArn:       testTopicArn,
+		Subject:        "Amazon SES Email Event Notification",
+		Message:        `{"notificationType":"Bounce","bounce":{"bounceType":"Permanent"}}`,
+		Timestamp:      "2026-10-18T12:00:00.000Z",
+		SigningCertURL: testCertURL,
+	}
+}
+
+func TestVerifySignatureVersions(t *testing.T) {
+	server := newCertServer(t)
+	v := server.verifier(t, Config{VerifySignatures: true, TopicArns: []string{testTopicArn}})
+
+	confirmation := &Message{
+		Type:           TypeSubscriptionConfirmation,
+		MessageID:      "165545c9-2a5c-472c-8df2-7ff2be2b3b1b",
+		Token:          "2336412f37fb687f5d51e6e241d09c805a5a57b30d712f794cc5f6a988666d92768dd60a747ba6f3beb71854e285d6ad02428b09ceece29417f1f02d609c582afbacc99c583a916b9981dd2728f4ae6fdb82efd087cc3b7849e05798d2d2785c03b0879594eeac82c01f235d0e717736",
+		TopicArn:       testTopicArn,
+		Message:        "You have chosen to subscribe to the topic",
+		SubscribeURL:   "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription&TopicArn=" + testTopicArn,
+		Timestamp:      "2026-10-18T12:00:00.000Z",
+		SigningCertURL: testCertURL,
+	}
+	withoutSubject := testNotification()
+	withoutSubject.Subject = ""
+
+	for _, version := range []string{"1", "2"} {
+		for _, m := range []*Message{testNotification(), withoutSubject, confirmation} {
+			server.sign(t, m, version)
+			if err := v.Verify(context.Background(), m); err != nil {
+				t.Errorf("version %s %s (subject %q) failed to verify: %v", version, m.Type, m.Subject, err)
+			}
+		}
+	}
+
+	// The certificate is downloaded once
+	if len(server.requests) != 1 || server.requests[0] != testCertURL {
+		t.Errorf("fetched %v, want the signing certificate once", server.requests)
+	}
+}
+
+func TestVerifyRejectsTamperedMessage(t *testing.T) {
+	server := newCertServer(t)
+	// Both topics are allowed so that the signature, not the allowlist, rejects the swap
+	v := server.verifier(t, Config{VerifySignatures: true, TopicArns: []string{testTopicArn, "arn:aws:sns:us-east-1:210987654321:other"}})
+
+	for _, version := range []string{"1", "2"} {
+		for name, tamper := range map[string]func(m *Message){
+			"message":   func(m *Message) { m.Message = `{"notificationType":"Delivery"}` },
+			"topic":     func(m *Message) { m.TopicArn = "arn:aws:sns:us-east-1:210987654321:other" },
+			"subject":   func(m *Message) { m.Subject = "" },
+			"timestamp": func(m *Message) { m.Timestamp = "2026-10-18T12:00:01.000Z" },
+			"version":   func(m *Message) { m.SignatureVersion = map[string]string{"1": "2", "2": "1"}[m.SignatureVersion] },
+			"signature": func(m *Message) { m.Signature = base64.StdEncoding.EncodeToString([]byte("forged")) },
+		} {
+			m := testNotification()
+			server.sign(t, m, version)
+			tamper(m)
+			if err := v.Verify(context.Background(), m); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("version %s with tampered %s returned %v, want ErrInvalidSignature", version, name, err)
+			}
+		}
+	}
+}
+
+func TestVerifyRejectsMalformedMessage(t *testing.T) {
+	server := newCertServer(t)
+	v := server.verifier(t, Config{VerifySignatures: true, TopicArns: []string{testTopicArn}})
+
+	for name, m := range map[string]*Message{
+		"unsupported version": func() *Message { m := testNotification(); server.sign(t, m, "2"); m.SignatureVersion = "3"; return m }(),
+		"unknown type":        func() *Message { m := testNotification(); server.sign(t, m, "2"); m.Type = "Unknown"; return m }(),
+		"missing signature":   func() *Message { m := testNotification(); m.SignatureVersion = "2"; return m }(),
+		"signature not base64": func() *Message {
+			m := testNotification()
+			server.sign(t, m, "2")
+			m.Signature = "not base64!"
+			return m
+		}(),
+	} {
+		if err := v.Verify(context.Background(), m); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s returned %v, want ErrInvalidSignature", name, err)
+		}
+	}
+}
+
+func TestVerifyRejectsSigningCertURL(t *testing.T) {
+	server := newCertServer(t)
+	v := server.verifier(t, Config{VerifySignatures: true, TopicArns: []string{testTopicArn}})
+
+	for _, certURL := range []string{
+		"https://sns.evil.amazonaws.com.attacker.com/SimpleNotificationService.pem",
+		"https://sns.us-east-1.amazonaws.com:8443/SimpleNotificationService.pem",
+		"https://sns.us-east-1.amazonaws.com:443/SimpleNotificationService.pem",
+		"http://sns.us-east-1.amazonaws.com/SimpleNotificationService.pem",
+		"https://attacker.com/sns.us-east-1.amazonaws.com/SimpleNotificationService.pem",
+		"https://sns.us-east-1.amazonaws.com.attacker.com/SimpleNotificationService.pem",
+		"https://s3.amazonaws.com/SimpleNotificationService.pem",
+		"https://sns.us-east-1.amazonaws.com@attacker.com/SimpleNotificationService.pem",
+		"https://sns.us-east-1.amazonaws.com/SimpleNotificationService.txt",
+		"//sns.us-east-1.amazonaws.com/SimpleNotificationService.pem",
+	} {
+		// The message is signed by the certificate the URL would serve
+		m := testNotification()
+		m.SigningCertURL = certURL
+		server.sign(t, m, "2")
+		if err := v.Verify(context.Background(), m); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("signing certificate URL %s returned %v, want ErrInvalidSignature", certURL, err)
+		}
+	}
+
+	if len(server.requests) != 0 {
+		t.Errorf("fetched %v from URLs that are not SNS endpoints", server.requests)
+	}
+}
+
+func TestVerifyTopics(t *testing.T) {
+	server := newCertServer(t)
+	v := server.verifier(t, Config{VerifySignatures: true, TopicArns: []string{testTopicArn}})
+
+	allowed := testNotification()
+	server.sign(t, allowed, "2")
+	if err := v.Verify(context.Background(), allowed); err != nil {
+		t.Errorf("message from an allowed topic failed to verify: %v", err)
+	}
+
+	other := testNotification()
+	other.TopicArn = "arn:aws:sns:us-east-1:210987654321:other"
+	server.sign(t, other, "2")
+	if err := v.Verify(context.Background(), other); !errors.Is(err, ErrTopicNotAllowed) {
+		t.Errorf("message from another topic returned %v, want ErrTopicNotAllowed", err)
+	}
+
+	// Any account can sign messages from its own topic, so verifying needs an allowlist
+	if _, err := NewVerifier(Config{VerifySignatures: true}); err == nil {
+		t.Errorf("verifier without a topic allowlist was created")
+	}
+	unverified, err := NewVerifier(Config{})
+	if err != nil {
+		t.Fatalf("failed to create unverified verifier: %v", err)
+	}
+	if err := unverified.Verify(context.Background(), other); err != nil {
+		t.Errorf("unverified message without an allowlist returned %v", err)
+	}
+}
+
+func TestConfirmSubscription(t *testing.T) {
+	server := newCertServer(t)
+	v := server.verifier(t, Config{VerifySignatures: true, TopicArns: []string{testTopicArn}})
+
+	subscribeURL := "https://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription&Token=abc"
+	if err := v.ConfirmSubscription(context.Background(), &Message{SubscribeURL: subscribeURL}); err != nil {
+		t.Fatalf("confirming the subscription failed: %v", err)
+	}
+	if err := v.ConfirmSubscription(context.Background(), &Message{SubscribeURL: "https://sns.eu-west-1.amazonaws.com.attacker.com/?Action=ConfirmSubscription"}); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("confirming on another host returned %v, want ErrInvalidSignature", err)
+	}
+	if len(server.requests) != 1 || server.requests[0] != subscribeURL {
+		t.Errorf("fetched %v, want only the SNS subscribe URL", server.requests)
+	}
+}
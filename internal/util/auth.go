@@ -1,11 +1,14 @@
 package util
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -26,24 +29,35 @@ func GenerateEmailToken() (string, string, error) {
 }
 
 func VerifyEmailToken(providedToken, storedTokenHash string) bool {
-	// Try new method first (hash raw bytes)
-	tokenBytes, err := hex.DecodeString(providedToken)
+	providedTokenHash, err := HashEmailToken(providedToken)
 	if err != nil {
 		return false
 	}
 
-	tokenHashByte := sha256.Sum256(tokenBytes)
-	providedTokenHash := hex.EncodeToString(tokenHashByte[:])
-
 	return providedTokenHash == storedTokenHash
 }
 
+// HashEmailToken returns the hash stored for a token generated by GenerateEmailToken
+func HashEmailToken(token string) (string, error) {
+	tokenBytes, err := hex.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+
+	tokenHashByte := sha256.Sum256(tokenBytes)
+	return hex.EncodeToString(tokenHashByte[:]), nil
+}
+
 type JWTClaims struct {
 	UserID string `json:"userId"`
 	jwt.RegisteredClaims
 }
 
-func GenerateBearerToken(userID string) (string, error) {
+// BearerTokenLifetime is how long bearer tokens and their sessions stay valid
+const BearerTokenLifetime = 30 * 24 * time.Hour // 30 days
+
+// GenerateBearerToken issues a token for the user, identified by the session ID
+func GenerateBearerToken(userID, sessionID string) (string, error) {
 	issuer := os.Getenv("JWT_ISSUER")
 	if issuer == "" {
 		return "", errors.New("JWT_ISSUER is not set")
@@ -58,7 +72,8 @@ func GenerateBearerToken(userID string) (string, error) {
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    issuer,
 			Subject:   userID,
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(30 * 24 * time.Hour)), // 30 days
+			ID:        sessionID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(BearerTokenLifetime)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -66,3 +81,67 @@ func GenerateBearerToken(userID string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(secret))
 }
+
+// ParseBearerToken validates a bearer token issued by GenerateBearerToken and returns its claims
+func ParseBearerToken(tokenString string) (*JWTClaims, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, errors.New("JWT_SECRET is not set")
+	}
+
+	claims := &JWTClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
+		jwt.WithIssuer(os.Getenv("JWT_ISSUER")),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// GenerateUnsubscribeToken signs the user and notification type an unsubscribe link stops.
+// The token does not expire, so a link in an old email keeps working.
+func GenerateUnsubscribeToken(userID, notification string) (string, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return "", errors.New("JWT_SECRET is not set")
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(userID + ":" + notification))
+	return payload + "." + unsubscribeSignature(secret, payload), nil
+}
+
+// ParseUnsubscribeToken validates a token issued by GenerateUnsubscribeToken and returns the
+// user and notification type it names
+func ParseUnsubscribeToken(token string) (string, string, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return "", "", errors.New("JWT_SECRET is not set")
+	}
+
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(unsubscribeSignature(secret, payload))) {
+		return "", "", errors.New("invalid unsubscribe token")
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", "", errors.New("invalid unsubscribe token")
+	}
+	userID, notification, ok := strings.Cut(string(decoded), ":")
+	if !ok || userID == "" || notification == "" {
+		return "", "", errors.New("invalid unsubscribe token")
+	}
+	return userID, notification, nil
+}
+
+// unsubscribeSignature is keyed apart from bearer tokens, which share the secret
+func unsubscribeSignature(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("unsubscribe\n" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,10 @@
+package util
+
+import "regexp"
+
+var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+// IsValidEmail validates if the provided email string is in a valid format
+func IsValidEmail(email string) bool {
+	return emailRegex.MatchString(email)
+}
@@ -0,0 +1,13 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// PseudonymizeID returns a stable, non-reversible stand-in for a user ID so records
+// that must outlive the user can still be grouped without identifying them
+func PseudonymizeID(userID string) string {
+	hash := sha256.Sum256([]byte("airlock-pseudonym:" + userID))
+	return "anon-" + hex.EncodeToString(hash[:16])
+}
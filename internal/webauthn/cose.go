@@ -0,0 +1,121 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithms accepted for credentials, in order of preference (RFC 9053)
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// SupportedAlgorithms are offered to authenticators when a credential is created
+var SupportedAlgorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters (RFC 9052, RFC 9053)
+const (
+	coseKeyType  = 1
+	coseKeyAlg   = 3
+	coseCurve    = -1 // n for RSA keys
+	coseX        = -2 // e for RSA keys
+	coseY        = -3
+	coseKeyOKP   = 1
+	coseKeyEC2   = 2
+	coseKeyRSA   = 3
+	coseP256     = 1
+	coseEd25519  = 6
+	minRSAKeyLen = 2048
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// publicKey is a credential public key decoded from its COSE form
+type publicKey struct {
+	algorithm int
+	key       crypto.PublicKey
+}
+
+// parseCOSEKey decodes a COSE_Key and returns it with the bytes that follow it
+func parseCOSEKey(data []byte) (*publicKey, []byte, error) {
+	item, rest, err := decodeCBOR(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode credential public key: %w", err)
+	}
+	fields, ok := item.(map[any]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("credential public key is not a COSE key")
+	}
+
+	keyType, _ := fields[int64(coseKeyType)].(int64)
+	alg, _ := fields[int64(coseKeyAlg)].(int64)
+
+	switch {
+	case keyType == coseKeyEC2 && alg == AlgES256:
+		curve, _ := fields[int64(coseCurve)].(int64)
+		x, _ := fields[int64(coseX)].([]byte)
+		y, _ := fields[int64(coseY)].([]byte)
+		if curve != coseP256 || len(x) != 32 || len(y) != 32 {
+			return nil, nil, fmt.Errorf("ES256 key is not an uncompressed P-256 point")
+		}
+		// ecdh rejects points that are not on the curve
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, nil, fmt.Errorf("invalid ES256 key: %w", err)
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return &publicKey{algorithm: AlgES256, key: key}, rest, nil
+
+	case keyType == coseKeyOKP && alg == AlgEdDSA:
+		curve, _ := fields[int64(coseCurve)].(int64)
+		x, _ := fields[int64(coseX)].([]byte)
+		if curve != coseEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, nil, fmt.Errorf("EdDSA key is not an Ed25519 key")
+		}
+		return &publicKey{algorithm: AlgEdDSA, key: ed25519.PublicKey(x)}, rest, nil
+
+	case keyType == coseKeyRSA && alg == AlgRS256:
+		n, _ := fields[int64(coseCurve)].([]byte)
+		e, _ := fields[int64(coseX)].([]byte)
+		exponent := new(big.Int).SetBytes(e)
+		if len(e) == 0 || len(e) > 4 || exponent.Int64() < 3 {
+			return nil, nil, fmt.Errorf("invalid RS256 key exponent")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+		if key.N.BitLen() < minRSAKeyLen {
+			return nil, nil, fmt.Errorf("RS256 key is shorter than %d bits", minRSAKeyLen)
+		}
+		return &publicKey{algorithm: AlgRS256, key: key}, rest, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported credential key type %d with algorithm %d", keyType, alg)
+	}
+}
+
+// verify checks the signature over data
+func (k *publicKey) verify(data, signature []byte) error {
+	var valid bool
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		valid = ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, data, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	}
+	if !valid {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,481 @@
+// Package webauthn implements the relying party side of WebAuthn registration and
+// authentication ceremonies (W3C Web Authentication Level 2) for passkeys. Attestation is
+// not requested, so any authenticator the browser offers can register.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ceremony types, as the browser names them in the client data
+const (
+	CeremonyCreate = "webauthn.create"
+	CeremonyGet    = "webauthn.get"
+)
+
+// User verification requirements
+const (
+	UserVerificationRequired  = "required"
+	UserVerificationPreferred = "preferred"
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagBackedUp       = 0x10
+	flagAttested       = 0x40
+)
+
+// maxCredentialIDLength is the longest credential ID the specification allows
+const maxCredentialIDLength = 1023
+
+var ErrVerification = errors.New("webauthn verification failed")
+
+// Config identifies the relying party to authenticators
+type Config struct {
+	RPID             string
+	RPName           string
+	Origins          []string
+	Timeout          time.Duration
+	UserVerification string
+}
+
+// GetConfig reads the WebAuthn settings from the environment
+func GetConfig() Config {
+	serviceURL := os.Getenv("SERVICE_URL")
+	if serviceURL == "" {
+		serviceURL = "localhost:8080"
+	}
+
+	config := Config{
+		RPID:             os.Getenv("WEBAUTHN_RP_ID"),
+		RPName:           os.Getenv("WEBAUTHN_RP_NAME"),
+		Timeout:          time.Duration(getEnvInt("WEBAUTHN_TIMEOUT", 300)) * time.Second, // Default 5 minutes
+		UserVerification: strings.ToLower(os.Getenv("WEBAUTHN_USER_VERIFICATION")),
+	}
+	if config.RPID == "" {
+		config.RPID = serviceURL // Default the host the emailed links point at
+		if host, _, err := net.SplitHostPort(serviceURL); err == nil {
+			config.RPID = host
+		}
+	}
+	if config.RPName == "" {
+		config.RPName = "Luna4"
+	}
+	for _, origin := range strings.Split(os.Getenv("WEBAUTHN_ORIGINS"), ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			config.Origins = append(config.Origins, origin)
+		}
+	}
+	if len(config.Origins) == 0 {
+		config.Origins = []string{"https://" + serviceURL}
+	}
+	if config.UserVerification != UserVerificationRequired {
+		config.UserVerification = UserVerificationPreferred
+	}
+	return config
+}
+
+// User is the account a credential is created for
+type User struct {
+	ID          string
+	Name        string
+	DisplayName string
+}
+
+// CredentialDescriptor names an existing credential. IDs are base64url encoded.
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// NewCredentialDescriptor describes a public key credential by its base64url ID
+func NewCredentialDescriptor(id string, transports []string) CredentialDescriptor {
+	return CredentialDescriptor{Type: "public-key", ID: id, Transports: transports}
+}
+
+// CreationOptions are the options for navigator.credentials.create, in the JSON form of
+// PublicKeyCredentialCreationOptions with binary values base64url encoded
+type CreationOptions struct {
+	RP struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	Challenge              string                 `json:"challenge"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey        string `json:"residentKey"`
+		RequireResidentKey bool   `json:"requireResidentKey"`
+		UserVerification   string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+// CredentialParameter is a key type the relying party accepts
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// RequestOptions are the options for navigator.credentials.get, in the JSON form of
+// PublicKeyCredentialRequestOptions
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// NewChallenge returns a random base64url challenge
+func NewChallenge() (string, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return "", fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(challenge), nil
+}
+
+// CreationOptions asks for a discoverable credential, a passkey, for the user. The user's
+// existing credentials are excluded so an authenticator is not registered twice.
+func (c Config) CreationOptions(challenge string, user User, exclude []CredentialDescriptor) *CreationOptions {
+	options := &CreationOptions{
+		Challenge:          challenge,
+		Timeout:            c.Timeout.Milliseconds(),
+		ExcludeCredentials: append([]CredentialDescriptor{}, exclude...),
+		Attestation:        "none",
+	}
+	options.RP.ID = c.RPID
+	options.RP.Name = c.RPName
+	options.User.ID = base64.RawURLEncoding.EncodeToString([]byte(user.ID))
+	options.User.Name = user.Name
+	options.User.DisplayName = user.DisplayName
+	for _, alg := range SupportedAlgorithms {
+		options.PubKeyCredParams = append(options.PubKeyCredParams, CredentialParameter{Type: "public-key", Alg: alg})
+	}
+	options.AuthenticatorSelection.ResidentKey = "required"
+	options.AuthenticatorSelection.RequireResidentKey = true
+	options.AuthenticatorSelection.UserVerification = c.UserVerification
+	return options
+}
+
+// RequestOptions asks for any passkey of this relying party; the authenticator lets the
+// user pick one and tells us whose it is
+func (c Config) RequestOptions(challenge string) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          c.Timeout.Milliseconds(),
+		RPID:             c.RPID,
+		AllowCredentials: []CredentialDescriptor{},
+		UserVerification: c.UserVerification,
+	}
+}
+
+// RegistrationResponse is the credential navigator.credentials.create returned, in the JSON
+// form of PublicKeyCredential with binary values base64url encoded
+type RegistrationResponse struct {
+	ID       string `json:"id" binding:"required"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type" binding:"required"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+		AttestationObject string   `json:"attestationObject" binding:"required"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the credential navigator.credentials.get returned
+type AssertionResponse struct {
+	ID       string `json:"id" binding:"required"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type" binding:"required"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+		AuthenticatorData string `json:"authenticatorData" binding:"required"`
+		Signature         string `json:"signature" binding:"required"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// Challenge returns the challenge the browser signed, to look up the ceremony it answers
+func (r *RegistrationResponse) Challenge() (string, error) {
+	clientData, _, err := parseClientData(r.Response.ClientDataJSON)
+	if err != nil {
+		return "", err
+	}
+	return clientData.Challenge, nil
+}
+
+// Challenge returns the challenge the browser signed
+func (r *AssertionResponse) Challenge() (string, error) {
+	clientData, _, err := parseClientData(r.Response.ClientDataJSON)
+	if err != nil {
+		return "", err
+	}
+	return clientData.Challenge, nil
+}
+
+// UserID returns the user ID the authenticator stored with the passkey, if it returned one
+func (r *AssertionResponse) UserID() (string, error) {
+	userHandle, err := decodeBase64URL(r.Response.UserHandle)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid user handle", ErrVerification)
+	}
+	return string(userHandle), nil
+}
+
+// Credential is a verified new credential
+type Credential struct {
+	ID             string
+	PublicKey      []byte
+	Algorithm      int
+	SignCount      uint32
+	AAGUID         string
+	Transports     []string
+	BackupEligible bool
+	BackedUp       bool
+}
+
+// Assertion is a verified authentication
+type Assertion struct {
+	SignCount    uint32
+	UserVerified bool
+	BackedUp     bool
+}
+
+// VerifyRegistration checks that the response answers the challenge for this relying party
+// and returns the new credential. The attestation statement is not checked, as none was
+// requested.
+func (c Config) VerifyRegistration(response *RegistrationResponse, challenge string) (*Credential, error) {
+	if response.Type != "public-key" {
+		return nil, fmt.Errorf("%w: unexpected credential type %q", ErrVerification, response.Type)
+	}
+	if _, err := c.verifyClientData(response.Response.ClientDataJSON, CeremonyCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	attestationObject, err := decodeBase64URL(response.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid attestation object encoding", ErrVerification)
+	}
+	item, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid attestation object: %v", ErrVerification, err)
+	}
+	attestation, ok := item.(map[any]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation object is not a map", ErrVerification)
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation object has no authenticator data", ErrVerification)
+	}
+
+	authData, err := c.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.flags&flagAttested == 0 {
+		return nil, fmt.Errorf("%w: authenticator data has no credential", ErrVerification)
+	}
+
+	rest := authData.rest
+	if len(rest) < 18 {
+		return nil, fmt.Errorf("%w: truncated attested credential data", ErrVerification)
+	}
+	aaguid, _ := uuid.FromBytes(rest[:16])
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLength == 0 || idLength > maxCredentialIDLength || len(rest) < idLength {
+		return nil, fmt.Errorf("%w: invalid credential ID length", ErrVerification)
+	}
+	credentialID, rest := rest[:idLength], rest[idLength:]
+
+	key, extensions, err := parseCOSEKey(rest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+	publicKey := rest[:len(rest)-len(extensions)]
+
+	id := base64.RawURLEncoding.EncodeToString(credentialID)
+	if rawID, err := decodeBase64URL(response.ID); err != nil || !bytes.Equal(rawID, credentialID) {
+		return nil, fmt.Errorf("%w: credential ID does not match the authenticator data", ErrVerification)
+	}
+
+	return &Credential{
+		ID:             id,
+		PublicKey:      append([]byte{}, publicKey...),
+		Algorithm:      key.algorithm,
+		SignCount:      authData.signCount,
+		AAGUID:         aaguid.String(),
+		Transports:     response.Response.Transports,
+		BackupEligible: authData.flags&flagBackupEligible != 0,
+		BackedUp:       authData.flags&flagBackedUp != 0,
+	}, nil
+}
+
+// VerifyAssertion checks that the response answers the challenge and is signed by the
+// stored credential. A signature counter that did not increase means the authenticator may
+// have been cloned, unless the authenticator does not count at all.
+func (c Config) VerifyAssertion(response *AssertionResponse, challenge string, publicKey []byte, signCount uint32) (*Assertion, error) {
+	if response.Type != "public-key" {
+		return nil, fmt.Errorf("%w: unexpected credential type %q", ErrVerification, response.Type)
+	}
+	clientDataJSON, err := c.verifyClientData(response.Response.ClientDataJSON, CeremonyGet, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	rawAuthData, err := decodeBase64URL(response.Response.AuthenticatorData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid authenticator data encoding", ErrVerification)
+	}
+	authData, err := c.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := decodeBase64URL(response.Response.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid signature encoding", ErrVerification)
+	}
+	key, _, err := parseCOSEKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stored credential key: %w", err)
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if err := key.verify(append(append([]byte{}, rawAuthData...), clientDataHash[:]...), signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrVerification, err)
+	}
+
+	if (authData.signCount != 0 || signCount != 0) && authData.signCount <= signCount {
+		return nil, fmt.Errorf("%w: signature counter went from %d to %d, the authenticator may be cloned",
+			ErrVerification, signCount, authData.signCount)
+	}
+
+	return &Assertion{
+		SignCount:    authData.signCount,
+		UserVerified: authData.flags&flagUserVerified != 0,
+		BackedUp:     authData.flags&flagBackedUp != 0,
+	}, nil
+}
+
+// clientData is the part of CollectedClientData the relying party checks
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func parseClientData(encoded string) (*clientData, []byte, error) {
+	raw, err := decodeBase64URL(encoded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid client data encoding", ErrVerification)
+	}
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid client data: %v", ErrVerification, err)
+	}
+	return &data, raw, nil
+}
+
+// verifyClientData checks the ceremony, challenge and origin, and returns the raw client
+// data the signature covers
+func (c Config) verifyClientData(encoded, ceremony, challenge string) ([]byte, error) {
+	data, raw, err := parseClientData(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if data.Type != ceremony {
+		return nil, fmt.Errorf("%w: expected a %s ceremony, got %q", ErrVerification, ceremony, data.Type)
+	}
+	if data.Challenge != challenge {
+		return nil, fmt.Errorf("%w: challenge does not match", ErrVerification)
+	}
+	if !slices.Contains(c.Origins, data.Origin) {
+		return nil, fmt.Errorf("%w: origin %q is not allowed", ErrVerification, data.Origin)
+	}
+	if data.CrossOrigin {
+		return nil, fmt.Errorf("%w: cross-origin ceremonies are not allowed", ErrVerification)
+	}
+	return raw, nil
+}
+
+// authenticatorData is the fixed part of the authenticator data, with the attested
+// credential data and extensions that follow it left in rest
+type authenticatorData struct {
+	flags     byte
+	signCount uint32
+	rest      []byte
+}
+
+// parseAuthenticatorData checks the relying party ID hash and that the user was present,
+// and verified when that is required
+func (c Config) parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: truncated authenticator data", ErrVerification)
+	}
+	rpIDHash := sha256.Sum256([]byte(c.RPID))
+	if !bytes.Equal(data[:32], rpIDHash[:]) {
+		return nil, fmt.Errorf("%w: credential is for another relying party", ErrVerification)
+	}
+
+	authData := &authenticatorData{
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+		rest:      data[37:],
+	}
+	if authData.flags&flagUserPresent == 0 {
+		return nil, fmt.Errorf("%w: user was not present", ErrVerification)
+	}
+	if c.UserVerification == UserVerificationRequired && authData.flags&flagUserVerified == 0 {
+		return nil, fmt.Errorf("%w: user was not verified", ErrVerification)
+	}
+	return authData, nil
+}
+
+// decodeBase64URL decodes base64url with or without padding, as browsers and libraries
+// differ on it
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
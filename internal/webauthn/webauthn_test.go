@@ -0,0 +1,275 @@
+package webauthn
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/webauthn/webauthntest"
+)
+
+var testConfig = Config{
+	RPID:             "localhost",
+	RPName:           "Airlock",
+	Origins:          []string{"http://localhost:8080"},
+	Timeout:          time.Minute,
+	UserVerification: UserVerificationPreferred,
+}
+
+var testAlgorithms = map[string]int{"ES256": AlgES256, "RS256": AlgRS256, "EdDSA": AlgEdDSA}
+
+func newTestAuthenticator(t *testing.T, algorithm int) *webauthntest.Authenticator {
+	t.Helper()
+	authenticator, err := webauthntest.New(testConfig.RPID, testConfig.Origins[0], algorithm)
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+	return authenticator
+}
+
+func newTestChallenge(t *testing.T) string {
+	t.Helper()
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatalf("failed to create challenge: %v", err)
+	}
+	return challenge
+}
+
+func register(t *testing.T, authenticator *webauthntest.Authenticator, challenge string) *RegistrationResponse {
+	t.Helper()
+	body, err := authenticator.Register(challenge)
+	if err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	var response RegistrationResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatalf("failed to decode registration: %v", err)
+	}
+	return &response
+}
+
+func assert(t *testing.T, authenticator *webauthntest.Authenticator, challenge string) *AssertionResponse {
+	t.Helper()
+	body, err := authenticator.Assert(challenge)
+	if err != nil {
+		t.Fatalf("failed to assert: %v", err)
+	}
+	var response AssertionResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		t.Fatalf("failed to decode assertion: %v", err)
+	}
+	return &response
+}
+
+// registerCredential registers the authenticator and returns the verified credential
+func registerCredential(t *testing.T, authenticator *webauthntest.Authenticator) *Credential {
+	t.Helper()
+	challenge := newTestChallenge(t)
+	credential, err := testConfig.VerifyRegistration(register(t, authenticator, challenge), challenge)
+	if err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+	return credential
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	for name, algorithm := range testAlgorithms {
+		t.Run(name, func(t *testing.T) {
+			authenticator := newTestAuthenticator(t, algorithm)
+			authenticator.Flags |= webauthntest.FlagBackupEligible
+
+			credential := registerCredential(t, authenticator)
+			if credential.ID != authenticator.ID() || credential.Algorithm != algorithm {
+				t.Errorf("registered %s with algorithm %d, want %s with %d", credential.ID, credential.Algorithm, authenticator.ID(), algorithm)
+			}
+			if credential.AAGUID != "adce0002-35bc-c60a-648b-0b25f1f05503" || !credential.BackupEligible || credential.BackedUp {
+				t.Errorf("registered AAGUID %s, backup eligible %v, backed up %v", credential.AAGUID, credential.BackupEligible, credential.BackedUp)
+			}
+			if len(credential.Transports) != 2 {
+				t.Errorf("registered transports %v, want the authenticator's", credential.Transports)
+			}
+
+			// Every sign-in raises the counter and may report a backup
+			signCount := credential.SignCount
+			for i := range 3 {
+				if i == 2 {
+					authenticator.Flags |= webauthntest.FlagBackedUp
+				}
+				challenge := newTestChallenge(t)
+				response := assert(t, authenticator, challenge)
+				assertion, err := testConfig.VerifyAssertion(response, challenge, credential.PublicKey, signCount)
+				if err != nil {
+					t.Fatalf("assertion %d failed: %v", i, err)
+				}
+				if assertion.SignCount != signCount+1 || !assertion.UserVerified || assertion.BackedUp != (i == 2) {
+					t.Errorf("assertion %d counted %d, verified %v, backed up %v", i, assertion.SignCount, assertion.UserVerified, assertion.BackedUp)
+				}
+				signCount = assertion.SignCount
+			}
+		})
+	}
+}
+
+func TestVerifyAssertionRejects(t *testing.T) {
+	for name, algorithm := range testAlgorithms {
+		t.Run(name, func(t *testing.T) {
+			for _, tc := range []struct {
+				name   string
+				config Config
+				change func(a *webauthntest.Authenticator, r *AssertionResponse)
+				// signCount is the counter stored for the credential
+				signCount uint32
+				challenge string
+			}{
+				{name: "sign count regression", signCount: 10},
+				{name: "repeated sign count", signCount: 1},
+				{name: "wrong origin", change: func(a *webauthntest.Authenticator, r *AssertionResponse) { a.Origin = "https://evil.example" }},
+				{name: "cross origin", change: func(a *webauthntest.Authenticator, r *AssertionResponse) { a.CrossOrigin = true }},
+				{name: "wrong rpIdHash", change: func(a *webauthntest.Authenticator, r *AssertionResponse) { a.RPID = "evil.example" }},
+				{name: "user not present", change: func(a *webauthntest.Authenticator, r *AssertionResponse) { a.Flags = webauthntest.FlagUserVerified }},
+				{
+					name:   "user not verified",
+					config: Config{UserVerification: UserVerificationRequired},
+					change: func(a *webauthntest.Authenticator, r *AssertionResponse) { a.Flags = webauthntest.FlagUserPresent },
+				},
+				{name: "wrong challenge", challenge: "another-challenge"},
+				{
+					name: "tampered signature",
+					change: func(a *webauthntest.Authenticator, r *AssertionResponse) {
+						if r != nil {
+							r.Response.Signature = r.Response.AuthenticatorData
+						}
+					},
+				},
+				{
+					name: "registration client data",
+					change: func(a *webauthntest.Authenticator, r *AssertionResponse) {
+						if r != nil {
+							r.Response.ClientDataJSON = register(t, a, "").Response.ClientDataJSON
+						}
+					},
+				},
+				{
+					name: "not a public key credential",
+					change: func(a *webauthntest.Authenticator, r *AssertionResponse) {
+						if r != nil {
+							r.Type = "password"
+						}
+					},
+				},
+			} {
+				authenticator := newTestAuthenticator(t, algorithm)
+				credential := registerCredential(t, authenticator)
+
+				config := testConfig
+				if tc.config.UserVerification != "" {
+					config.UserVerification = tc.config.UserVerification
+				}
+				if tc.change != nil {
+					tc.change(authenticator, nil)
+				}
+				challenge := newTestChallenge(t)
+				response := assert(t, authenticator, challenge)
+				if tc.change != nil {
+					tc.change(authenticator, response)
+				}
+				if tc.challenge != "" {
+					challenge = tc.challenge
+				}
+
+				if _, err := config.VerifyAssertion(response, challenge, credential.PublicKey, tc.signCount); !errors.Is(err, ErrVerification) {
+					t.Errorf("%s returned %v, want ErrVerification", tc.name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyAssertionWithAnotherKey(t *testing.T) {
+	authenticator := newTestAuthenticator(t, AlgES256)
+	registerCredential(t, authenticator)
+	other := registerCredential(t, newTestAuthenticator(t, AlgES256))
+
+	challenge := newTestChallenge(t)
+	if _, err := testConfig.VerifyAssertion(assert(t, authenticator, challenge), challenge, other.PublicKey, 0); !errors.Is(err, ErrVerification) {
+		t.Errorf("assertion checked against another passkey's key returned %v, want ErrVerification", err)
+	}
+}
+
+func TestVerifyAssertionWithoutCounter(t *testing.T) {
+	authenticator := newTestAuthenticator(t, AlgEdDSA)
+	credential := registerCredential(t, authenticator)
+
+	// Authenticators that do not count always report zero, which the counter wraps to
+	for range 2 {
+		authenticator.SignCount = ^uint32(0)
+		challenge := newTestChallenge(t)
+		assertion, err := testConfig.VerifyAssertion(assert(t, authenticator, challenge), challenge, credential.PublicKey, 0)
+		if err != nil {
+			t.Fatalf("assertion without a counter failed: %v", err)
+		}
+		if assertion.SignCount != 0 {
+			t.Errorf("assertion without a counter counted %d", assertion.SignCount)
+		}
+	}
+}
+
+func TestVerifyRegistrationRejects(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config Config
+		change func(a *webauthntest.Authenticator, r *RegistrationResponse)
+	}{
+		{name: "wrong origin", change: func(a *webauthntest.Authenticator, r *RegistrationResponse) { a.Origin = "https://evil.example" }},
+		{name: "wrong rpIdHash", change: func(a *webauthntest.Authenticator, r *RegistrationResponse) { a.RPID = "evil.example" }},
+		{name: "user not present", change: func(a *webauthntest.Authenticator, r *RegistrationResponse) { a.Flags = 0 }},
+		{
+			name:   "user not verified",
+			config: Config{UserVerification: UserVerificationRequired},
+			change: func(a *webauthntest.Authenticator, r *RegistrationResponse) { a.Flags = webauthntest.FlagUserPresent },
+		},
+		{
+			name: "credential ID mismatch",
+			change: func(a *webauthntest.Authenticator, r *RegistrationResponse) {
+				if r != nil {
+					r.ID = "AAAA"
+				}
+			},
+		},
+		{
+			name: "assertion client data",
+			change: func(a *webauthntest.Authenticator, r *RegistrationResponse) {
+				if r != nil {
+					var response AssertionResponse
+					body, _ := a.Assert("")
+					json.Unmarshal(body, &response)
+					r.Response.ClientDataJSON = response.Response.ClientDataJSON
+				}
+			},
+		},
+		{
+			name: "truncated attestation",
+			change: func(a *webauthntest.Authenticator, r *RegistrationResponse) {
+				if r != nil {
+					r.Response.AttestationObject = r.Response.AttestationObject[:40]
+				}
+			},
+		},
+	} {
+		authenticator := newTestAuthenticator(t, AlgES256)
+		config := testConfig
+		if tc.config.UserVerification != "" {
+			config.UserVerification = tc.config.UserVerification
+		}
+		tc.change(authenticator, nil)
+		challenge := newTestChallenge(t)
+		response := register(t, authenticator, challenge)
+		tc.change(authenticator, response)
+
+		if _, err := config.VerifyRegistration(response, challenge); !errors.Is(err, ErrVerification) {
+			t.Errorf("%s returned %v, want ErrVerification", tc.name, err)
+		}
+	}
+}
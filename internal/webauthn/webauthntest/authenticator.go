@@ -0,0 +1,246 @@
+// Package webauthntest provides a software authenticator for testing WebAuthn relying
+// parties. It answers ceremonies with the JSON a browser posts for a PublicKeyCredential,
+// and its fields can be changed between ceremonies to produce responses a real
+// authenticator would not.
+package webauthntest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// COSE algorithms the authenticator can create keys for
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// Authenticator data flags
+const (
+	FlagUserPresent    = 0x01
+	FlagUserVerified   = 0x04
+	FlagBackupEligible = 0x08
+	FlagBackedUp       = 0x10
+	flagAttested       = 0x40
+)
+
+// Authenticator holds one credential for a relying party
+type Authenticator struct {
+	// RPID is hashed into the authenticator data, Origin and CrossOrigin go in the client data
+	RPID        string
+	Origin      string
+	CrossOrigin bool
+
+	// Flags are set in the authenticator data, FlagUserPresent and FlagUserVerified by default
+	Flags byte
+
+	// SignCount is the signature counter; every assertion increments it first
+	SignCount uint32
+
+	// UserHandle is returned with assertions when set
+	UserHandle string
+
+	CredentialID []byte
+	Algorithm    int
+	AAGUID       [16]byte
+	signer       crypto.Signer
+}
+
+// New returns an authenticator with a new credential using the COSE algorithm
+func New(rpID, origin string, algorithm int) (*Authenticator, error) {
+	var signer crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	case AlgRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %d", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate credential ID: %w", err)
+	}
+
+	return &Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		Flags:        FlagUserPresent | FlagUserVerified,
+		CredentialID: id,
+		Algorithm:    algorithm,
+		AAGUID:       [16]byte{0xad, 0xce, 0x00, 0x02, 0x35, 0xbc, 0xc6, 0x0a, 0x64, 0x8b, 0x0b, 0x25, 0xf1, 0xf0, 0x55, 0x03},
+		signer:       signer,
+	}, nil
+}
+
+// ID returns the credential ID base64url encoded
+func (a *Authenticator) ID() string {
+	return base64.RawURLEncoding.EncodeToString(a.CredentialID)
+}
+
+// Register answers a navigator.credentials.create challenge with the credential and no
+// attestation statement
+func (a *Authenticator) Register(challenge string) ([]byte, error) {
+	clientDataJSON, err := a.clientData("webauthn.create", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	authData := a.authenticatorData(a.Flags | flagAttested)
+	authData = append(authData, a.AAGUID[:]...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.CredentialID)))
+	authData = append(authData, a.CredentialID...)
+	authData = appendCBOR(authData, a.coseKey())
+
+	attestationObject := appendCBOR(nil, cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", authData},
+	})
+
+	return json.Marshal(map[string]any{
+		"id":    a.ID(),
+		"rawId": a.ID(),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    encode(clientDataJSON),
+			"attestationObject": encode(attestationObject),
+			"transports":        []string{"internal", "hybrid"},
+		},
+	})
+}
+
+// Assert answers a navigator.credentials.get challenge, signing with the credential
+func (a *Authenticator) Assert(challenge string) ([]byte, error) {
+	clientDataJSON, err := a.clientData("webauthn.get", challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	a.SignCount++
+	authData := a.authenticatorData(a.Flags)
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signature, err := a.sign(append(append([]byte{}, authData...), clientDataHash[:]...))
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]any{
+		"id":    a.ID(),
+		"rawId": a.ID(),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    encode(clientDataJSON),
+			"authenticatorData": encode(authData),
+			"signature":         encode(signature),
+			"userHandle":        encode([]byte(a.UserHandle)),
+		},
+	})
+}
+
+func (a *Authenticator) clientData(ceremony, challenge string) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      a.Origin,
+		"crossOrigin": a.CrossOrigin,
+	})
+}
+
+func (a *Authenticator) authenticatorData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.SignCount)
+}
+
+// coseKey returns the credential public key as a COSE_Key (RFC 9053)
+func (a *Authenticator) coseKey() cborMap {
+	switch public := a.signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		point, _ := public.ECDH()
+		raw := point.Bytes()
+		return cborMap{{1, 2}, {3, AlgES256}, {-1, 1}, {-2, raw[1:33]}, {-3, raw[33:]}}
+	case ed25519.PublicKey:
+		return cborMap{{1, 1}, {3, AlgEdDSA}, {-1, 6}, {-2, []byte(public)}}
+	case *rsa.PublicKey:
+		exponent := binary.BigEndian.AppendUint32(nil, uint32(public.E))
+		for len(exponent) > 1 && exponent[0] == 0 {
+			exponent = exponent[1:]
+		}
+		return cborMap{{1, 3}, {3, AlgRS256}, {-1, public.N.Bytes()}, {-2, exponent}}
+	}
+	return nil
+}
+
+func (a *Authenticator) sign(data []byte) ([]byte, error) {
+	switch key := a.signer.(type) {
+	case ed25519.PrivateKey:
+		return ed25519.Sign(key, data), nil
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(data)
+		return ecdsa.SignASN1(rand.Reader, key, digest[:])
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	}
+	return nil, fmt.Errorf("unsupported key %T", a.signer)
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// cborMap is a CBOR map that keeps its keys in order
+type cborMap []struct{ key, value any }
+
+// appendCBOR encodes integers, byte strings, text and maps with definite lengths (RFC 8949)
+func appendCBOR(b []byte, value any) []byte {
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return appendCBORHead(b, 1, uint64(-1-v))
+		}
+		return appendCBORHead(b, 0, uint64(v))
+	case []byte:
+		return append(appendCBORHead(b, 2, uint64(len(v))), v...)
+	case string:
+		return append(appendCBORHead(b, 3, uint64(len(v))), v...)
+	case cborMap:
+		b = appendCBORHead(b, 5, uint64(len(v)))
+		for _, pair := range v {
+			b = appendCBOR(appendCBOR(b, pair.key), pair.value)
+		}
+		return b
+	}
+	panic(fmt.Sprintf("webauthntest: cannot encode %T as CBOR", value))
+}
+
+func appendCBORHead(b []byte, major byte, argument uint64) []byte {
+	switch {
+	case argument < 24:
+		return append(b, major<<5|byte(argument))
+	case argument <= 0xff:
+		return append(b, major<<5|24, byte(argument))
+	case argument <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major<<5|25), uint16(argument))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, major<<5|26), uint32(argument))
+	}
+}
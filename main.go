@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
@@ -9,9 +11,18 @@ import (
 
 	"github.com/luna4dev/airlock-client/alcgin"
 
+	"github.com/luna4dev/airlock/internal/backup"
+	"github.com/luna4dev/airlock/internal/brand"
 	"github.com/luna4dev/airlock/internal/handler"
 	"github.com/luna4dev/airlock/internal/handler/maintenance"
+	"github.com/luna4dev/airlock/internal/handler/scim"
+	"github.com/luna4dev/airlock/internal/mailer"
+	"github.com/luna4dev/airlock/internal/outbox"
+	"github.com/luna4dev/airlock/internal/policy"
+	"github.com/luna4dev/airlock/internal/replication"
 	"github.com/luna4dev/airlock/internal/service"
+	"github.com/luna4dev/airlock/internal/sns"
+	"github.com/luna4dev/airlock/internal/webauthn"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -23,12 +34,12 @@ var webFS embed.FS
 //go:embed assets/templates/*
 var templateFS embed.FS
 
-//go:embed configs/sqlite-schema/*
-var sqliteSchemaFS embed.FS
-
 //go:embed configs/sqlite-migration/*
 var sqliteMigrationFS embed.FS
 
+//go:embed configs/postgres-migration/*
+var postgresMigrationFS embed.FS
+
 func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using environment variables")
@@ -37,17 +48,119 @@ func main() {
 	// inject embed to services package
 	service.TemplateFS = templateFS
 
-	// Initialize SQLite service once
-	sqliteService, err := service.NewSQLiteService("data/airlock.db", &sqliteSchemaFS, &sqliteMigrationFS)
+	// Restoring swaps the database file, so it runs before the store is opened
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		if err := runRestoreCommand(os.Args[2:]); err != nil {
+			log.Fatal("Restore failed:", err)
+		}
+		return
+	}
+
+	// Initialize the storage backend once
+	store, err := newStore()
 	if err != nil {
-		log.Fatal("Failed to initialize SQLite service:", err)
+		log.Fatal("Failed to initialize storage backend:", err)
+	}
+	defer store.Close()
+
+	// Schema maintenance runs instead of the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(store, os.Args[2:]); err != nil {
+			log.Fatal("Migration failed:", err)
+		}
+		return
 	}
-	defer sqliteService.Close()
 
+	if err := service.Migrate(context.Background(), store); err != nil {
+		log.Fatal("Failed to migrate database schema:", err)
+	}
+	brands, err := brand.NewRegistry(brand.GetConfig())
+	if err != nil {
+		log.Fatal("Failed to load brand profiles:", err)
+	}
+	emailService, err := service.NewEmailService(brands)
+	if err != nil {
+		log.Fatal("Failed to load email templates:", err)
+	}
+	accountService := service.NewAccountService(store, emailService, webauthn.GetConfig())
+
+	// Start the account policy engine
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	emailService.WatchTemplates(ctx)
+	policyEngine := policy.NewEngine(
+		policy.GetEngineInterval(),
+		policy.NewDormantAccountPolicy(accountService, policy.GetDormantAccountThreshold(), policy.GetDormantAccountWarning()),
+		policy.NewUserPurgePolicy(accountService, policy.GetUserDeletionRetention()),
+	)
+	policyEngine.Start(ctx)
+
+	// Deliver queued email in the background
+	mailConfig := mailer.GetConfig()
+	mail, err := mailer.New(ctx, mailConfig)
+	if err != nil {
+		log.Fatal("Invalid mail configuration:", err)
+	}
+	outbox.NewWorker(accountService, mail, outbox.GetConfig()).Start(ctx)
+
+	// Schedule backups when the database is a local SQLite file
+	var backupManager *backup.Manager
+	if sqliteService, ok := store.(*service.SQLiteService); ok {
+		backupConfig, err := backup.GetConfig()
+		if err != nil {
+			log.Fatal("Invalid backup configuration:", err)
+		}
+		backupManager = backup.NewManager(sqliteService, backupConfig)
+		backupManager.Start(ctx)
+
+		// Continuously replicate the WAL when a replica is configured
+		if replicationConfig := replication.GetConfig(); replicationConfig.URL != "" {
+			replica, err := replication.NewReplica(ctx, replicationConfig.URL, replicationConfig.S3)
+			if err != nil {
+				log.Fatal("Invalid replication configuration:", err)
+			}
+			if err := replication.NewReplicator(sqliteService, replica, replicationConfig).Start(ctx); err != nil {
+				log.Fatal("Failed to start replication:", err)
+			}
+		}
+	}
+
+	router, err := newRouter(accountService, brands, policyEngine, backupManager, mail)
+	if err != nil {
+		log.Fatal("Failed to set up routes:", err)
+	}
+	if _, ok := mail.(*mailer.FileMailer); ok {
+		log.Printf("Mail is written to %s instead of being sent, see /dev/mailbox", mailConfig.OutboxDir)
+	}
+
+	port := os.Getenv("PORT")
+	router.Run(":" + port)
+}
+
+// newRouter builds the handlers on the running services and routes requests to them.
+// backupManager is nil when the database has no file to back up.
+func newRouter(accountService *service.AccountService, brands *brand.Registry, policyEngine *policy.Engine, backupManager *backup.Manager, mail mailer.Mailer) (*gin.Engine, error) {
 	// Initialize handlers with dependencies
-	userHandler := maintenance.NewUserHandler(sqliteService)
-	userServiceHandler := maintenance.NewUserServiceHandler(sqliteService)
-	authHandler := handler.NewAuthHandler(sqliteService)
+	userHandler := maintenance.NewUserHandler(accountService)
+	userServiceHandler := maintenance.NewUserServiceHandler(accountService)
+	userDataHandler := maintenance.NewUserDataHandler(accountService)
+	userTransferHandler := maintenance.NewUserTransferHandler(accountService)
+	userPasskeyHandler := maintenance.NewUserPasskeyHandler(accountService)
+	authHandler := handler.NewAuthHandler(accountService, brands)
+	brandHandler := handler.NewBrandHandler(brands)
+	accountHandler := handler.NewAccountHandler(accountService)
+	policyHandler := maintenance.NewPolicyHandler(policyEngine)
+	backupHandler := maintenance.NewBackupHandler(backupManager)
+	scimTokenHandler := maintenance.NewScimTokenHandler(accountService)
+	emailOutboxHandler := maintenance.NewEmailOutboxHandler(accountService)
+	emailPreviewHandler := maintenance.NewEmailPreviewHandler(accountService, brands)
+	snsVerifier, err := sns.NewVerifier(sns.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+	sesNotificationHandler := handler.NewSESNotificationHandler(accountService, snsVerifier)
+	scimUserHandler := scim.NewUserHandler(accountService)
+	scimGroupHandler := scim.NewGroupHandler(accountService)
 
 	router := gin.Default()
 
@@ -57,7 +170,7 @@ func main() {
 	// Serve embedded static files
 	staticFS, err := fs.Sub(webFS, "web")
 	if err != nil {
-		log.Fatal("Failed to create sub filesystem:", err)
+		return nil, fmt.Errorf("failed to create sub filesystem: %w", err)
 	}
 	router.StaticFS("/app", http.FS(staticFS))
 
@@ -69,31 +182,137 @@ func main() {
 		{
 			auth.POST("/email", authHandler.AuthEmailHandler)
 			auth.GET("/email/verify", authHandler.AuthEmailVerifyHandler)
+			auth.GET("/session", authHandler.RequireSession, authHandler.GetSessionHandler)
+			auth.POST("/passkey/options", authHandler.PasskeyLoginOptionsHandler)
+			auth.POST("/passkey", authHandler.PasskeyLoginHandler)
+		}
+
+		// Self-service account endpoints
+		account := api.Group("/account")
+		{
+			account.POST("/email", authHandler.RequireSession, accountHandler.ChangeEmailHandler)
+			account.POST("/email/confirm", accountHandler.ConfirmEmailChangeHandler)
+			account.POST("/email/revert", accountHandler.RevertEmailChangeHandler)
+			account.GET("/preferences", authHandler.RequireSession, accountHandler.GetPreferencesHandler)
+			account.PUT("/preferences", authHandler.RequireSession, accountHandler.UpdatePreferencesHandler)
+			account.POST("/notifications/unsubscribe", accountHandler.UnsubscribeHandler)
+			account.POST("/passkey/options", authHandler.RequireSession, accountHandler.PasskeyRegistrationOptionsHandler)
+			account.POST("/passkey", authHandler.RequireSession, accountHandler.RegisterPasskeyHandler)
+			account.GET("/passkey", authHandler.RequireSession, accountHandler.GetPasskeysHandler)
+			account.DELETE("/passkey/:id", authHandler.RequireSession, accountHandler.RemovePasskeyHandler)
 		}
 
-		// Maintenance endpoints
-		maintenance := api.Group("/maintenance")
+		// Brand profiles for the login pages
+		api.GET("/brand/:app", brandHandler.GetBrandHandler)
+
+		// SES bounce and complaint notifications delivered by SNS, verified by signature
+		api.POST("/email/notification", sesNotificationHandler.ReceiveNotification)
+
+		// Maintenance endpoints, protected by the auth middleware. It must be part of the
+		// group before any route is added, since routes only get the middleware already in place.
+		maintenance := api.Group("/maintenance", alcgin.NewAuthMiddleware())
 		{
 			// User management
 			maintenance.GET("/user", userHandler.GetUsers)
+			maintenance.GET("/user/search", userHandler.SearchUsers)
 			maintenance.GET("/user/:id", userHandler.GetUser)
 			maintenance.POST("/user", userHandler.CreateUser)
 			maintenance.PUT("/user/:id/suspend", userHandler.SuspendUser)
 			maintenance.PUT("/user/:id/activate", userHandler.ActivateUser)
+			maintenance.PUT("/user/:id/lock", userHandler.LockUser)
 			maintenance.DELETE("/user/:id", userHandler.DeleteUser)
+			maintenance.POST("/user/:id/restore", userHandler.RestoreUser)
+			maintenance.POST("/user/:id/email", userHandler.ChangeUserEmail)
+			maintenance.PUT("/user/:id/profile", userHandler.UpdateUserProfile)
+			maintenance.PUT("/user/:id/preferences", userHandler.UpdateUserPreferences)
+			maintenance.DELETE("/user/:id/session", userHandler.RevokeUserSessions)
+
+			// Bulk import and export
+			maintenance.POST("/user/import", userTransferHandler.ImportUsers)
+			maintenance.GET("/user/export", userTransferHandler.ExportUsers)
 
 			// User service management
 			maintenance.GET("/user/:id/service", userServiceHandler.GetUserServices)
 			maintenance.POST("/user/:id/service", userServiceHandler.AddUserService)
+			maintenance.PUT("/user/:id/service", userServiceHandler.ReplaceUserServices)
 			maintenance.DELETE("/user/:id/service/:serviceId", userServiceHandler.RemoveUserService)
+
+			// User passkeys
+			maintenance.GET("/user/:id/passkey", userPasskeyHandler.GetUserPasskeys)
+			maintenance.DELETE("/user/:id/passkey/:passkeyId", userPasskeyHandler.RemoveUserPasskey)
+
+			// Data subject requests
+			maintenance.GET("/user/:id/export", userDataHandler.ExportUserData)
+			maintenance.POST("/user/:id/erase", userDataHandler.EraseUserData)
+
+			// Account policies
+			maintenance.GET("/policy/:name/report", policyHandler.GetPolicyReport)
+
+			// Database backups
+			maintenance.GET("/backup", backupHandler.GetBackups)
+			maintenance.POST("/backup", backupHandler.CreateBackup)
+
+			// SCIM tenant tokens
+			maintenance.GET("/scim/token", scimTokenHandler.GetScimTokens)
+			maintenance.POST("/scim/token", scimTokenHandler.CreateScimToken)
+			maintenance.DELETE("/scim/token/:id", scimTokenHandler.RevokeScimToken)
+
+			// Email outbox and templates
+			maintenance.GET("/email/outbox", emailOutboxHandler.GetOutboxEmails)
+			maintenance.POST("/email/outbox/:id/retry", emailOutboxHandler.RetryOutboxEmail)
+			maintenance.DELETE("/email/suppression/:email", emailOutboxHandler.LiftEmailSuppression)
+			maintenance.GET("/email/preview/:template", emailPreviewHandler.PreviewEmail)
 		}
+	}
 
-		// Protect maintenance with auth middleware
-		maintenance.Use(alcgin.NewAuthMiddleware())
+	// SCIM 2.0 provisioning, authenticated with per-tenant bearer tokens
+	scimV2 := router.Group("/scim/v2", scim.NewAuthMiddleware(accountService))
+	{
+		scimV2.GET("/ServiceProviderConfig", scim.GetServiceProviderConfig)
+		scimV2.GET("/ResourceTypes", scim.GetResourceTypes)
+		scimV2.GET("/Schemas", scim.GetSchemas)
+
+		scimV2.GET("/Users", scimUserHandler.GetUsers)
+		scimV2.POST("/Users", scimUserHandler.CreateUser)
+		scimV2.GET("/Users/:id", scimUserHandler.GetUser)
+		scimV2.PUT("/Users/:id", scimUserHandler.ReplaceUser)
+		scimV2.PATCH("/Users/:id", scimUserHandler.PatchUser)
+		scimV2.DELETE("/Users/:id", scimUserHandler.DeleteUser)
+
+		scimV2.GET("/Groups", scimGroupHandler.GetGroups)
+		scimV2.POST("/Groups", scimGroupHandler.CreateGroup)
+		scimV2.GET("/Groups/:id", scimGroupHandler.GetGroup)
+		scimV2.PUT("/Groups/:id", scimGroupHandler.ReplaceGroup)
+		scimV2.PATCH("/Groups/:id", scimGroupHandler.PatchGroup)
+		scimV2.DELETE("/Groups/:id", scimGroupHandler.DeleteGroup)
 	}
 
-	port := os.Getenv("PORT")
-	router.Run(":" + port)
+	// Development mailbox showing the messages the file mail backend wrote
+	if fileMailer, ok := mail.(*mailer.FileMailer); ok {
+		mailboxHandler := handler.NewMailboxHandler(fileMailer)
+		router.GET("/dev/mailbox", mailboxHandler.GetMessages)
+		router.GET("/dev/mailbox/:id", mailboxHandler.GetMessage)
+	}
+
+	return router, nil
+}
+
+// newStore opens the storage backend selected by STORAGE_BACKEND
+func newStore() (service.Store, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "sqlite":
+		return service.NewSQLiteService(service.GetSQLiteConfig(), &sqliteMigrationFS)
+	case "postgres":
+		return service.NewPostgresService(os.Getenv("POSTGRES_DSN"), &postgresMigrationFS)
+	case "dynamodb":
+		tablePrefix := os.Getenv("DYNAMODB_TABLE_PREFIX")
+		if tablePrefix == "" {
+			tablePrefix = "Luna4" // Default table prefix
+		}
+		return service.NewDynamoDBService(tablePrefix)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
 }
 
 func redirectToApp(c *gin.Context) {
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/luna4dev/airlock/internal/brand"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/policy"
+	"github.com/luna4dev/airlock/internal/service"
+	"github.com/luna4dev/airlock/internal/webauthn"
+)
+
+// newTestRouter routes requests to services on a migrated SQLite database in a temporary
+// directory, without backups or a mail backend
+func newTestRouter(t *testing.T) (*gin.Engine, *service.AccountService) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	service.TemplateFS = templateFS
+	t.Setenv("SNS_TOPIC_ARNS", "arn:aws:sns:us-east-1:123456789012:ses-notifications")
+
+	config := service.GetSQLiteConfig()
+	config.Path = filepath.Join(t.TempDir(), "airlock.db")
+	store, err := service.NewSQLiteService(config, &sqliteMigrationFS)
+	if err != nil {
+		t.Fatalf("failed to open SQLite store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := service.Migrate(context.Background(), store); err != nil {
+		t.Fatalf("failed to migrate SQLite store: %v", err)
+	}
+
+	brands, err := brand.NewRegistry(brand.GetConfig())
+	if err != nil {
+		t.Fatalf("failed to create brand registry: %v", err)
+	}
+	emails, err := service.NewEmailService(brands)
+	if err != nil {
+		t.Fatalf("failed to load email templates: %v", err)
+	}
+	accountService := service.NewAccountService(store, emails, webauthn.GetConfig())
+
+	router, err := newRouter(accountService, brands, policy.NewEngine(0), nil, nil)
+	if err != nil {
+		t.Fatalf("failed to set up routes: %v", err)
+	}
+	return router, accountService
+}
+
+func TestMaintenanceRequiresAuthentication(t *testing.T) {
+	router, accountService := newTestRouter(t)
+
+	ctx := context.Background()
+	now := time.Now().UnixMilli()
+	user := &model.Luna4User{
+		ID:        uuid.New().String(),
+		Email:     "subject@example.com",
+		Status:    model.UserStatusActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := accountService.CreateUser(ctx, user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	routes := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/api/maintenance/user", ""},
+		{http.MethodGet, "/api/maintenance/user/" + user.ID, ""},
+		{http.MethodGet, "/api/maintenance/user/" + user.ID + "/export", ""},
+		{http.MethodPost, "/api/maintenance/user/" + user.ID + "/erase", ""},
+		{http.MethodDelete, "/api/maintenance/user/" + user.ID, ""},
+		{http.MethodGet, "/api/maintenance/user/export", ""},
+		{http.MethodPost, "/api/maintenance/user/import", `{"email":"imported@example.com"}`},
+		{http.MethodGet, "/api/maintenance/backup", ""},
+		{http.MethodPost, "/api/maintenance/backup", ""},
+		{http.MethodGet, "/api/maintenance/scim/token", ""},
+		{http.MethodPost, "/api/maintenance/scim/token", `{"tenant":"acme"}`},
+		{http.MethodDelete, "/api/maintenance/scim/token/" + uuid.New().String(), ""},
+	}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			req := httptest.NewRequest(route.method, route.path, strings.NewReader(route.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want %d; body: %s", rec.Code, http.StatusUnauthorized, rec.Body.String())
+			}
+		})
+	}
+
+	// The rejected erase and delete requests must not have touched the user
+	stored, err := accountService.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("failed to read user: %v", err)
+	}
+	if stored == nil || stored.Status != model.UserStatusActive || stored.Email != user.Email {
+		t.Errorf("user changed by unauthenticated requests: %+v", stored)
+	}
+	imported, err := accountService.GetUserByEmail(ctx, "imported@example.com")
+	if err != nil {
+		t.Fatalf("failed to read imported user: %v", err)
+	}
+	if imported != nil {
+		t.Error("unauthenticated import created a user")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/service"
+)
+
+// runMigrateCommand handles `airlock migrate [status|up|down] [--dry-run] [--to version]`
+func runMigrateCommand(store service.Store, args []string) error {
+	migratable, ok := store.(service.Migratable)
+	if !ok {
+		return fmt.Errorf("the storage backend has no versioned schema")
+	}
+
+	migrator, err := migratable.Migrator()
+	if err != nil {
+		return err
+	}
+
+	command := "status"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
+	}
+
+	flags := flag.NewFlagSet("migrate "+command, flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "list the migrations without running them")
+	to := flags.Int("to", -1, "version to roll back to (default: undo the latest migration)")
+	flags.Parse(args)
+
+	ctx := context.Background()
+	switch command {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printMigrationStatus(statuses)
+		return nil
+
+	case "up":
+		migrations, err := migrator.Up(ctx, *dryRun)
+		printMigrations(migrations, *dryRun, "apply", "Applied")
+		return err
+
+	case "down":
+		target := *to
+		if target < 0 {
+			statuses, err := migrator.Status(ctx)
+			if err != nil {
+				return err
+			}
+			target = previousVersion(statuses)
+		}
+
+		migrations, err := migrator.Down(ctx, target, *dryRun)
+		printMigrations(migrations, *dryRun, "roll back", "Rolled back")
+		return err
+
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected status, up or down", command)
+	}
+}
+
+// previousVersion returns the version below the latest applied migration
+func previousVersion(statuses []service.MigrationStatus) int {
+	var applied []int
+	for _, status := range statuses {
+		if status.Applied {
+			applied = append(applied, status.Version)
+		}
+	}
+
+	if len(applied) < 2 {
+		return 0
+	}
+	return applied[len(applied)-2]
+}
+
+func printMigrationStatus(statuses []service.MigrationStatus) {
+	fmt.Printf("%-8s %-30s %-26s %s\n", "VERSION", "NAME", "APPLIED AT", "NOTE")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = time.UnixMilli(*status.AppliedAt).Format(time.RFC3339)
+		}
+
+		note := ""
+		if status.ChecksumMismatch {
+			note = "checksum mismatch"
+		} else if status.Unknown {
+			note = "not in this binary"
+		}
+
+		fmt.Printf("%-8d %-30s %-26s %s\n", status.Version, status.Name, appliedAt, note)
+	}
+}
+
+func printMigrations(migrations []service.Migration, dryRun bool, verb, pastVerb string) {
+	if len(migrations) == 0 {
+		fmt.Printf("Nothing to %s\n", verb)
+		return
+	}
+
+	for _, migration := range migrations {
+		if dryRun {
+			fmt.Printf("Would %s %d (%s)\n", verb, migration.Version, migration.Name)
+		} else {
+			fmt.Printf("%s %d (%s)\n", pastVerb, migration.Version, migration.Name)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/luna4dev/airlock/internal/backup"
+	"github.com/luna4dev/airlock/internal/replication"
+	"github.com/luna4dev/airlock/internal/service"
+)
+
+// runRestoreCommand handles `airlock restore [--replica] [--at time] [backup file]`. The
+// service must be stopped while the database file is swapped.
+func runRestoreCommand(args []string) error {
+	if backend := os.Getenv("STORAGE_BACKEND"); backend != "" && backend != "sqlite" {
+		return fmt.Errorf("restore is only available with the SQLite storage backend")
+	}
+
+	backupConfig, err := backup.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	at := flags.String("at", "", "restore the database as it was at this RFC 3339 time")
+	fromReplica := flags.Bool("replica", false, "restore from REPLICATION_URL instead of a backup")
+	flags.Parse(args)
+
+	restoreTime := time.Now()
+	if *at != "" {
+		restoreTime, err = time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return fmt.Errorf("invalid --at time: %w", err)
+		}
+	}
+
+	// The data directory may not exist yet when restoring onto a new host
+	sqliteConfig := service.GetSQLiteConfig()
+	if err := os.MkdirAll(filepath.Dir(sqliteConfig.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
+	}
+	staged := sqliteConfig.Path + ".restore"
+	defer func() {
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			os.Remove(staged + suffix)
+		}
+	}()
+
+	var restored string
+	if *fromReplica {
+		restored, err = restoreFromReplica(staged, restoreTime)
+	} else {
+		restored, err = restoreFromBackup(flags.Arg(0), backupConfig, staged, restoreTime)
+	}
+	if err != nil {
+		return err
+	}
+
+	version, err := service.ValidateSQLiteBackup(context.Background(), staged, &sqliteMigrationFS)
+	if err != nil {
+		return fmt.Errorf("restored database failed validation: %w", err)
+	}
+	fmt.Printf("Restored database is valid at schema version %d\n", version)
+
+	// Keep the current database, with its WAL, next to the restored one
+	if _, err := os.Stat(sqliteConfig.Path); err == nil {
+		previous := sqliteConfig.Path + ".pre-restore-" + time.Now().UTC().Format("20060102T150405Z")
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			if err := os.Rename(sqliteConfig.Path+suffix, previous+suffix); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to move current database aside: %w", err)
+			}
+		}
+		fmt.Printf("Previous database kept at %s\n", previous)
+	}
+
+	if err := os.Rename(staged, sqliteConfig.Path); err != nil {
+		return fmt.Errorf("failed to swap in restored database: %w", err)
+	}
+
+	fmt.Printf("Restored %s to %s\n", restored, sqliteConfig.Path)
+	return nil
+}
+
+// restoreFromBackup decodes the given backup file, or the newest backup taken at or before
+// the restore time, to staged
+func restoreFromBackup(file string, backupConfig backup.Config, staged string, restoreTime time.Time) (string, error) {
+	var selected *backup.Backup
+	var err error
+	if file != "" {
+		selected, err = backup.Inspect(file)
+	} else {
+		selected, err = backup.FindAt(backupConfig.Dir, restoreTime)
+	}
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Restoring %s (taken %s)\n", selected.Name, time.UnixMilli(selected.CreatedAt).UTC().Format(time.RFC3339))
+
+	if err := backup.Decode(selected, backupConfig.EncryptionKey, staged); err != nil {
+		return "", err
+	}
+	return selected.Name, nil
+}
+
+// restoreFromReplica rebuilds the database as of the restore time from the replica
+func restoreFromReplica(staged string, restoreTime time.Time) (string, error) {
+	replicationConfig := replication.GetConfig()
+	if replicationConfig.URL == "" {
+		return "", fmt.Errorf("REPLICATION_URL is not set")
+	}
+
+	ctx := context.Background()
+	replica, err := replication.NewReplica(ctx, replicationConfig.URL, replicationConfig.S3)
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Replaying %s up to %s\n", replica, restoreTime.UTC().Format(time.RFC3339))
+
+	restoredAt, err := replication.Restore(ctx, replica, staged, restoreTime)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s as of %s", replica, restoredAt.Format(time.RFC3339Nano)), nil
+}
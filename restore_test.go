@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/luna4dev/airlock/internal/backup"
+	"github.com/luna4dev/airlock/internal/model"
+	"github.com/luna4dev/airlock/internal/service"
+)
+
+// setupRestoreEnv points the SQLite database and the encrypted backups at a temporary directory
+func setupRestoreEnv(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	t.Setenv("STORAGE_BACKEND", "sqlite")
+	t.Setenv("SQLITE_PATH", filepath.Join(dir, "airlock.db"))
+	t.Setenv("BACKUP_DIR", filepath.Join(dir, "backups"))
+	t.Setenv("BACKUP_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(key))
+	return dir
+}
+
+func openRestoreStore(t *testing.T) *service.SQLiteService {
+	t.Helper()
+	store, err := service.NewSQLiteService(service.GetSQLiteConfig(), &sqliteMigrationFS)
+	if err != nil {
+		t.Fatalf("failed to open SQLite store: %v", err)
+	}
+	if err := service.Migrate(context.Background(), store); err != nil {
+		t.Fatalf("failed to migrate SQLite store: %v", err)
+	}
+	return store
+}
+
+func createRestoreUser(t *testing.T, store service.Store, email string) {
+	t.Helper()
+	now := time.Now().UnixMilli()
+	user := &model.Luna4User{ID: uuid.New().String(), Email: email, Status: model.UserStatusActive, CreatedAt: now, UpdatedAt: now}
+	if err := store.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("failed to create user %s: %v", email, err)
+	}
+}
+
+func assertRestoreUser(t *testing.T, store service.Store, email string, want bool) {
+	t.Helper()
+	user, err := store.GetUserByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatalf("failed to read user %s: %v", email, err)
+	}
+	if (user != nil) != want {
+		t.Errorf("user %s exists: %v, want %v", email, user != nil, want)
+	}
+}
+
+func TestRestoreRoundTrip(t *testing.T) {
+	dir := setupRestoreEnv(t)
+	backupConfig, err := backup.GetConfig()
+	if err != nil {
+		t.Fatalf("invalid backup configuration: %v", err)
+	}
+
+	store := openRestoreStore(t)
+	createRestoreUser(t, store, "before@example.com")
+	taken, err := backup.NewManager(store, backupConfig).Run(context.Background())
+	if err != nil {
+		t.Fatalf("backup failed: %v", err)
+	}
+	if !taken.Compressed || !taken.Encrypted {
+		t.Errorf("backup %s is not compressed and encrypted", taken.Name)
+	}
+	createRestoreUser(t, store, "after@example.com")
+	store.Close()
+
+	if err := runRestoreCommand(nil); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+
+	restored := openRestoreStore(t)
+	defer restored.Close()
+	assertRestoreUser(t, restored, "before@example.com", true)
+	assertRestoreUser(t, restored, "after@example.com", false)
+
+	// The replaced database is kept next to the restored one
+	previous, err := filepath.Glob(filepath.Join(dir, "airlock.db.pre-restore-*"))
+	if err != nil || len(previous) == 0 {
+		t.Fatalf("previous database was not kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "airlock.db.restore")); !os.IsNotExist(err) {
+		t.Errorf("staged database was left behind: %v", err)
+	}
+}
+
+func TestRestoreRejectsInvalidBackup(t *testing.T) {
+	dir := setupRestoreEnv(t)
+
+	store := openRestoreStore(t)
+	createRestoreUser(t, store, "live@example.com")
+	store.Close()
+
+	invalid := filepath.Join(dir, "airlock-20260101T000000.000Z.db")
+	if err := os.WriteFile(invalid, []byte("not a database"), 0600); err != nil {
+		t.Fatalf("failed to write backup: %v", err)
+	}
+	if err := runRestoreCommand([]string{invalid}); err == nil {
+		t.Fatal("restoring an invalid backup succeeded")
+	}
+
+	// The live database is untouched
+	live := openRestoreStore(t)
+	defer live.Close()
+	assertRestoreUser(t, live, "live@example.com", true)
+	if previous, _ := filepath.Glob(filepath.Join(dir, "airlock.db.pre-restore-*")); len(previous) != 0 {
+		t.Errorf("live database was moved aside: %v", previous)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/luna4dev/airlock/internal/service"
+)
+
+// scimClient sends SCIM requests with a tenant's bearer token
+type scimClient struct {
+	t      *testing.T
+	router *gin.Engine
+	token  string
+}
+
+func newScimClient(t *testing.T, router *gin.Engine, accountService *service.AccountService, tenant string) *scimClient {
+	t.Helper()
+	token, _, err := accountService.IssueScimToken(context.Background(), tenant)
+	if err != nil {
+		t.Fatalf("failed to issue SCIM token for %s: %v", tenant, err)
+	}
+	return &scimClient{t: t, router: router, token: token}
+}
+
+func (c *scimClient) do(method, path, body string) (int, map[string]any) {
+	c.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/scim+json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	rec := httptest.NewRecorder()
+	c.router.ServeHTTP(rec, req)
+
+	var response map[string]any
+	if rec.Body.Len() > 0 {
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			c.t.Fatalf("%s %s returned invalid JSON: %s", method, path, rec.Body.String())
+		}
+	}
+	return rec.Code, response
+}
+
+// createUser provisions a user and returns its ID
+func (c *scimClient) createUser(email string) string {
+	c.t.Helper()
+	status, user := c.do(http.MethodPost, "/scim/v2/Users", fmt.Sprintf(`{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":%q,"displayName":"Test User"}`, email))
+	if status != http.StatusCreated {
+		c.t.Fatalf("creating %s returned %d: %v", email, status, user)
+	}
+	return user["id"].(string)
+}
+
+func TestScimRequiresToken(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	for _, token := range []string{"", "not-a-token"} {
+		client := &scimClient{t: t, router: router, token: token}
+		status, body := client.do(http.MethodGet, "/scim/v2/Users", "")
+		if status != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, want 401", token, status)
+		}
+		if body["status"] != "401" {
+			t.Errorf("token %q: body %v is not a SCIM error", token, body)
+		}
+	}
+}
+
+func TestScimListPagination(t *testing.T) {
+	router, accountService := newTestRouter(t)
+	client := newScimClient(t, router, accountService, "acme")
+
+	for i := range 5 {
+		client.createUser(fmt.Sprintf("user%d@acme.example", i))
+	}
+
+	// Pages follow the order of the full list, oldest first
+	_, all := client.do(http.MethodGet, "/scim/v2/Users", "")
+	var ids []string
+	for _, resource := range all["Resources"].([]any) {
+		ids = append(ids, resource.(map[string]any)["id"].(string))
+	}
+
+	tests := []struct {
+		query        string
+		startIndex   int
+		itemsPerPage int
+		first        string
+	}{
+		{"", 1, 5, ids[0]},
+		{"?startIndex=2&count=2", 2, 2, ids[1]},
+		{"?startIndex=5&count=2", 5, 1, ids[4]},
+		{"?startIndex=6", 6, 0, ""},
+		{"?startIndex=0&count=1", 1, 1, ids[0]},
+		{"?count=0", 1, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			status, list := client.do(http.MethodGet, "/scim/v2/Users"+tt.query, "")
+			if status != http.StatusOK {
+				t.Fatalf("status = %d: %v", status, list)
+			}
+			if list["totalResults"] != float64(5) || list["startIndex"] != float64(tt.startIndex) || list["itemsPerPage"] != float64(tt.itemsPerPage) {
+				t.Errorf("page = total %v, start %v, items %v", list["totalResults"], list["startIndex"], list["itemsPerPage"])
+			}
+			resources := list["Resources"].([]any)
+			if len(resources) != tt.itemsPerPage {
+				t.Fatalf("%d resources, want %d", len(resources), tt.itemsPerPage)
+			}
+			if tt.first != "" && resources[0].(map[string]any)["id"] != tt.first {
+				t.Errorf("first resource is %v, want %s", resources[0].(map[string]any)["id"], tt.first)
+			}
+		})
+	}
+
+	status, list := client.do(http.MethodGet, `/scim/v2/Users?filter=userName+eq+%22USER3@acme.example%22`, "")
+	if status != http.StatusOK || list["totalResults"] != float64(1) {
+		t.Errorf("filtered list returned %d: %v", status, list)
+	}
+	status, body := client.do(http.MethodGet, `/scim/v2/Users?filter=userName+eq`, "")
+	if status != http.StatusBadRequest || body["scimType"] != "invalidFilter" {
+		t.Errorf("invalid filter returned %d: %v", status, body)
+	}
+	status, body = client.do(http.MethodGet, `/scim/v2/Users?count=many`, "")
+	if status != http.StatusBadRequest || body["scimType"] != "invalidValue" {
+		t.Errorf("invalid count returned %d: %v", status, body)
+	}
+}
+
+func TestScimPatchUser(t *testing.T) {
+	router, accountService := newTestRouter(t)
+	client := newScimClient(t, router, accountService, "acme")
+	id := client.createUser("patch@acme.example")
+
+	status, user := client.do(http.MethodPatch, "/scim/v2/Users/"+id, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "replace", "path": "active", "value": "False"},
+			{"op": "replace", "path": "displayName", "value": "Patched Name"},
+			{"op": "add", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:organization", "value": "Acme"}
+		]
+	}`)
+	if status != http.StatusOK {
+		t.Fatalf("patch returned %d: %v", status, user)
+	}
+	if user["active"] != false || user["displayName"] != "Patched Name" {
+		t.Errorf("patched user = %v", user)
+	}
+
+	stored, err := accountService.GetUserByID(context.Background(), id)
+	if err != nil || stored == nil {
+		t.Fatalf("failed to read user: %v", err)
+	}
+	if stored.Name != "Patched Name" || stored.Organization != "Acme" || stored.Status.CanAuthenticate() {
+		t.Errorf("stored user = %+v", stored)
+	}
+
+	status, body := client.do(http.MethodPatch, "/scim/v2/Users/"+id, `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"remove","path":"userName"}]}`)
+	if status != http.StatusBadRequest || body["scimType"] != "mutability" {
+		t.Errorf("removing userName returned %d: %v", status, body)
+	}
+}
+
+func TestScimTenantIsolation(t *testing.T) {
+	router, accountService := newTestRouter(t)
+	acme := newScimClient(t, router, accountService, "acme")
+	globex := newScimClient(t, router, accountService, "globex")
+
+	acmeID := acme.createUser("alice@acme.example")
+	globexID := globex.createUser("bob@globex.example")
+
+	status, list := globex.do(http.MethodGet, "/scim/v2/Users", "")
+	if status != http.StatusOK || list["totalResults"] != float64(1) || list["Resources"].([]any)[0].(map[string]any)["id"] != globexID {
+		t.Errorf("globex lists %v", list)
+	}
+
+	// Another tenant's user does not exist for globex
+	for _, request := range []struct{ method, body string }{
+		{http.MethodGet, ""},
+		{http.MethodPut, `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"taken@globex.example"}`},
+		{http.MethodPatch, `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"active","value":false}]}`},
+		{http.MethodDelete, ""},
+	} {
+		if status, body := globex.do(request.method, "/scim/v2/Users/"+acmeID, request.body); status != http.StatusNotFound {
+			t.Errorf("%s of another tenant's user returned %d: %v", request.method, status, body)
+		}
+	}
+
+	// Nor can it be made a member of a group
+	status, body := globex.do(http.MethodPatch, "/scim/v2/Groups/PRUNK:USER", fmt.Sprintf(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"add","path":"members","value":[{"value":%q}]}]}`, acmeID))
+	if status != http.StatusBadRequest || body["scimType"] != "invalidValue" {
+		t.Errorf("adding another tenant's user to a group returned %d: %v", status, body)
+	}
+
+	// Group membership only shows the tenant's own users
+	if status, body := acme.do(http.MethodPatch, "/scim/v2/Groups/PRUNK:USER", fmt.Sprintf(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"add","path":"members","value":[{"value":%q}]}]}`, acmeID)); status != http.StatusOK {
+		t.Fatalf("adding a member returned %d: %v", status, body)
+	}
+	status, group := globex.do(http.MethodGet, "/scim/v2/Groups/PRUNK:USER", "")
+	if status != http.StatusOK {
+		t.Fatalf("reading the group returned %d: %v", status, group)
+	}
+	for _, member := range group["members"].([]any) {
+		if member.(map[string]any)["value"] != globexID {
+			t.Errorf("globex sees member %v", member)
+		}
+	}
+
+	stored, err := accountService.GetUserByID(context.Background(), acmeID)
+	if err != nil || stored == nil || stored.Email != "alice@acme.example" || !stored.Status.CanAuthenticate() {
+		t.Errorf("acme user changed by globex: %+v (%v)", stored, err)
+	}
+	services, err := accountService.GetUserServices(context.Background(), acmeID)
+	if err != nil || len(services) != 1 {
+		t.Errorf("acme user has services %v (%v), want the one acme granted", services, err)
+	}
+}
@@ -7,7 +7,9 @@ class AuthForm {
         this.btnLoader = this.submitBtn.querySelector('.btn-loader');
         this.messageEl = document.getElementById('message');
         this.countdownEl = document.getElementById('countdown');
-        this.countdownTimer = document.getElementById('countdown-timer');
+        this.countdownText = document.getElementById('countdown-text');
+        this.passkeySignInEl = document.getElementById('passkey-signin');
+        this.passkeyBtn = document.getElementById('passkey-btn');
 
         this.countdownInterval = null;
 
@@ -17,6 +19,41 @@ class AuthForm {
     init() {
         this.form.addEventListener('submit', (e) => this.handleSubmit(e));
         this.emailInput.addEventListener('input', () => this.clearMessage());
+
+        if (passkeySupported()) {
+            this.passkeySignInEl.style.display = 'flex';
+            this.passkeyBtn.addEventListener('click', () => this.handlePasskey());
+        }
+    }
+
+    async handlePasskey() {
+        this.clearMessage();
+        this.passkeyBtn.disabled = true;
+
+        try {
+            const data = await signInWithPasskey();
+            storeSession(data);
+            this.redirectWithToken(data.access_token);
+        } catch (error) {
+            this.passkeyBtn.disabled = false;
+            if (error.name === 'NotAllowedError' || error.name === 'AbortError') {
+                return; // The user closed the passkey prompt
+            }
+            console.error('Passkey sign in error:', error);
+            if (error.status === 403) {
+                this.handleError(error.status, error.data);
+            } else {
+                this.showMessage(error.status ? t('passkey.failed') : t('network.error'), 'error');
+            }
+        }
+    }
+
+    // redirectWithToken continues to the page that asked for sign in, as email verification does
+    redirectWithToken(token) {
+        const redirect = new URLSearchParams(window.location.search).get('redirect');
+        const url = new URL(redirect || '/app/dashboard', window.location.origin);
+        url.searchParams.set('accesstoken', token);
+        window.location.href = url.toString();
     }
 
     async handleSubmit(e) {
@@ -27,7 +64,7 @@ class AuthForm {
         const redirect = urlParams.get("redirect");
 
         if (!email || !this.isValidEmail(email)) {
-            this.showMessage('Please enter a valid email address.', 'error');
+            this.showMessage(t('auth.invalidEmail'), 'error');
             return;
         }
 
@@ -40,20 +77,20 @@ class AuthForm {
                 headers: {
                     'Content-Type': 'application/json',
                 },
-                body: JSON.stringify({ email, redirect })
+                body: JSON.stringify({ email, redirect, language: I18N_LOCALE, app: BRAND_APP })
             });
 
             const data = await response.json();
 
             if (response.ok) {
-                this.showMessage('Authentication email sent! Please check your inbox and click the link to sign in.', 'success');
+                this.showMessage(t('auth.sent'), 'success');
                 this.hideFormPermanently();
             } else {
                 this.handleError(response.status, data);
             }
         } catch (error) {
             console.error('Network error:', error);
-            this.showMessage('Network error. Please check your connection and try again.', 'error');
+            this.showMessage(t('network.error'), 'error');
             this.setLoading(false);
         }
     }
@@ -62,14 +99,16 @@ class AuthForm {
         if (status === 429) {
             // Too many requests - show countdown
             const retryAfter = data.retry_after_seconds || 180;
-            this.showMessage(`Too many requests. Please wait before trying again.`, 'warning');
+            this.showMessage(t('auth.tooMany'), 'warning');
             this.startCountdown(retryAfter);
+        } else if (status === 403) {
+            this.showMessage(t('auth.inactive', { status: t('status.' + (data.status || 'inactive').toLowerCase()) }), 'error');
         } else if (status === 404) {
-            this.showMessage('Email address not found. Please contact your administrator for access.', 'error');
+            this.showMessage(t('auth.notFound'), 'error');
         } else if (status === 400) {
-            this.showMessage(data.error || 'Invalid email address.', 'error');
+            this.showMessage(data.error || t('auth.badRequest'), 'error');
         } else {
-            this.showMessage(data.error || 'An error occurred. Please try again.', 'error');
+            this.showMessage(data.error || t('auth.error'), 'error');
         }
         this.setLoading(false);
     }
@@ -114,11 +153,11 @@ class AuthForm {
         this.submitBtn.disabled = true;
 
         let remaining = seconds;
-        this.countdownTimer.textContent = remaining;
+        this.countdownText.textContent = t('auth.countdown', { seconds: remaining });
 
         this.countdownInterval = setInterval(() => {
             remaining--;
-            this.countdownTimer.textContent = remaining;
+            this.countdownText.textContent = t('auth.countdown', { seconds: remaining });
 
             if (remaining <= 0) {
                 this.stopCountdown();
@@ -0,0 +1,40 @@
+// Styles the page for the app named by the app query parameter, which links in branded
+// emails carry. Without one the page keeps the Luna4 profile.
+const BRAND_APP = new URLSearchParams(window.location.search).get('app');
+
+async function applyBrand() {
+    try {
+        const response = await fetch(`/api/brand/${encodeURIComponent(BRAND_APP || 'luna4')}`);
+        if (!response.ok) {
+            return;
+        }
+        const { brand } = await response.json();
+
+        const root = document.documentElement.style;
+        root.setProperty('--brand-primary', brand.primaryColor);
+        root.setProperty('--brand-secondary', brand.secondaryColor);
+
+        document.querySelectorAll('.logo').forEach((el) => {
+            if (brand.logoUrl) {
+                const logo = document.createElement('img');
+                logo.src = brand.logoUrl;
+                logo.alt = brand.name;
+                el.replaceChildren(logo);
+            } else {
+                el.textContent = brand.name;
+            }
+        });
+
+        if (brand.supportUrl) {
+            document.getElementById('support-link').href = brand.supportUrl;
+            document.getElementById('support').style.display = 'block';
+        }
+
+        I18N_VARS.brand = brand.name;
+        applyTranslations();
+    } catch (error) {
+        console.error('Failed to load brand:', error);
+    }
+}
+
+document.addEventListener('DOMContentLoaded', applyBrand);
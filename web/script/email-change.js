@@ -0,0 +1,74 @@
+class EmailChange {
+    constructor() {
+        this.titleEl = document.getElementById('title');
+        this.verificationStatusEl = document.getElementById('verification-status');
+        this.successContentEl = document.getElementById('success-content');
+        this.successTitleEl = document.getElementById('success-title');
+        this.successMessageEl = document.getElementById('success-message');
+        this.errorContentEl = document.getElementById('error-content');
+        this.errorMessageEl = document.getElementById('error-message');
+
+        this.init();
+    }
+
+    init() {
+        const urlParams = new URLSearchParams(window.location.search);
+        const action = urlParams.get('action');
+        const token = urlParams.get('token');
+
+        if (!token || (action !== 'confirm' && action !== 'revert')) {
+            this.showError(t('change.invalidLink'));
+            return;
+        }
+
+        this.titleEl.textContent = action === 'confirm' ? t('change.confirmHeading') : t('change.revertHeading');
+        this.submit(action, token);
+    }
+
+    async submit(action, token) {
+        try {
+            const response = await fetch(`/api/account/email/${action}`, {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify({ token })
+            });
+
+            const data = await response.json();
+
+            if (response.ok) {
+                // Existing sessions were revoked, so drop the stored token
+                localStorage.removeItem('luna4_access_token');
+                localStorage.removeItem('luna4_user');
+
+                this.showSuccess(action === 'confirm' ? t('change.confirmed') : t('change.reverted'), data.message);
+            } else {
+                this.showError(data.error || t('change.error'));
+            }
+        } catch (error) {
+            console.error('Email change error:', error);
+            this.showError(t('network.error'));
+        }
+    }
+
+    showSuccess(title, message) {
+        this.verificationStatusEl.style.display = 'none';
+        this.errorContentEl.style.display = 'none';
+        this.successContentEl.style.display = 'block';
+        this.successTitleEl.textContent = title;
+        this.successMessageEl.textContent = message;
+    }
+
+    showError(errorMessage) {
+        this.verificationStatusEl.style.display = 'none';
+        this.successContentEl.style.display = 'none';
+        this.errorContentEl.style.display = 'block';
+        this.errorMessageEl.textContent = errorMessage;
+    }
+}
+
+// Initialize when DOM is loaded
+document.addEventListener('DOMContentLoaded', () => {
+    new EmailChange();
+});
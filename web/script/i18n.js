@@ -0,0 +1,228 @@
+// Translations for the web pages. Elements carry a data-i18n key for their text and
+// data-i18n-placeholder for input placeholders; scripts use t() for messages they show.
+const I18N_MESSAGES = {
+    en: {
+        'brand.footer': 'This is a secure authentication system for {brand} platform members.',
+        'brand.support': 'Need help? Contact support',
+
+        'auth.title': '{brand} - Authentication',
+        'auth.heading': 'Access {brand} Services',
+        'auth.subtitle': 'Enter your email to receive an authentication link',
+        'auth.email': 'Email Address',
+        'auth.placeholder': 'your.email@example.com',
+        'auth.submit': 'Send Authentication Link',
+        'auth.sending': 'Sending...',
+        'auth.countdown': 'Please wait {seconds} seconds before requesting another email.',
+        'auth.noAccess': "If you don't have access, please contact your administrator.",
+        'auth.invalidEmail': 'Please enter a valid email address.',
+        'auth.sent': 'Authentication email sent! Please check your inbox and click the link to sign in.',
+        'auth.tooMany': 'Too many requests. Please wait before trying again.',
+        'auth.inactive': 'Your account is {status}. Please contact your administrator.',
+        'auth.notFound': 'Email address not found. Please contact your administrator for access.',
+        'auth.badRequest': 'Invalid email address.',
+        'auth.error': 'An error occurred. Please try again.',
+
+        'status.active': 'active',
+        'status.inactive': 'inactive',
+        'status.suspended': 'suspended',
+        'status.deleted': 'deleted',
+
+        'verify.title': '{brand} - Email Verification',
+        'verify.heading': 'Email Verification',
+        'verify.subtitle': 'Verifying your authentication token...',
+        'verify.loading': 'Verifying your email token...',
+        'verify.success': 'Verification Successful!',
+        'verify.welcome': 'Welcome back! You have been successfully authenticated.',
+        'verify.token': 'Your access token:',
+        'verify.copy': 'Copy to clipboard',
+        'verify.tokenNote': 'Token expires in 30 days. Keep it secure!',
+        'verify.continue': 'Continue to Dashboard',
+        'verify.failed': 'Verification Failed',
+        'verify.retry': 'Try Again',
+        'verify.newLink': 'Request New Link',
+        'verify.invalidLink': 'Invalid verification link. Missing token or email parameters.',
+        'verify.error': 'Verification failed',
+        'verify.email': 'Email:',
+        'verify.userId': 'User ID:',
+        'verify.status': 'Status:',
+        'verify.copyManually': 'Token selected. Please copy manually (Ctrl+C).',
+
+        'change.title': '{brand} - Email Change',
+        'change.heading': 'Email Change',
+        'change.subtitle': 'Updating your email address...',
+        'change.loading': 'Processing your request...',
+        'change.done': 'Done!',
+        'change.signIn': 'Sign In',
+        'change.failed': 'Request Failed',
+        'change.invalidLink': 'Invalid email change link.',
+        'change.confirmHeading': 'Confirm New Email',
+        'change.revertHeading': 'Cancel Email Change',
+        'change.confirmed': 'Email Changed!',
+        'change.reverted': 'Email Change Cancelled',
+        'change.error': 'Request failed',
+
+        'unsubscribe.title': '{brand} - Unsubscribe',
+        'unsubscribe.heading': 'Security Notices',
+        'unsubscribe.subtitle': 'Stop receiving this kind of security notice by email?',
+        'unsubscribe.submit': 'Unsubscribe',
+        'unsubscribe.sending': 'Unsubscribing...',
+        'unsubscribe.done': 'Unsubscribed',
+        'unsubscribe.doneMessage': 'You will no longer receive emails about {notification}. Notices about email changes and suspension are always sent.',
+        'unsubscribe.failed': 'Request Failed',
+        'unsubscribe.invalidLink': 'Invalid unsubscribe link.',
+        'unsubscribe.error': 'Request failed',
+        'notification.NEW_DEVICE_LOGIN': 'sign-ins from new devices',
+        'notification.SESSIONS_REVOKED': 'being signed out of all devices',
+        'notification.SERVICE_ACCESS_CHANGED': 'changes to your service access',
+
+        'passkey.signIn': 'Sign in with a passkey',
+        'passkey.failed': 'Passkey sign in failed. Use your email instead, or try another passkey.',
+        'passkey.add': 'Add a passkey for faster sign in',
+        'passkey.added': 'Passkey added! Next time, sign in with it instead of waiting for an email.',
+        'passkey.exists': 'This device already has a passkey for your account.',
+        'passkey.addFailed': 'Could not add the passkey. Please try again.',
+        'passkey.error': 'Passkey request failed',
+
+        'network.error': 'Network error. Please check your connection and try again.'
+    },
+    ko: {
+        'brand.footer': '{brand} 플랫폼 회원을 위한 보안 인증 시스템입니다.',
+        'brand.support': '도움이 필요하신가요? 고객 지원에 문의하세요',
+
+        'auth.title': '{brand} - 인증',
+        'auth.heading': '{brand} 서비스 이용하기',
+        'auth.subtitle': '이메일을 입력하면 인증 링크를 보내드립니다',
+        'auth.email': '이메일 주소',
+        'auth.placeholder': 'your.email@example.com',
+        'auth.submit': '인증 링크 보내기',
+        'auth.sending': '보내는 중...',
+        'auth.countdown': '{seconds}초 후에 이메일을 다시 요청할 수 있습니다.',
+        'auth.noAccess': '접근 권한이 없다면 관리자에게 문의하세요.',
+        'auth.invalidEmail': '올바른 이메일 주소를 입력하세요.',
+        'auth.sent': '인증 이메일을 보냈습니다! 받은편지함에서 링크를 눌러 로그인하세요.',
+        'auth.tooMany': '요청이 너무 많습니다. 잠시 후 다시 시도하세요.',
+        'auth.inactive': '계정이 {status} 상태입니다. 관리자에게 문의하세요.',
+        'auth.notFound': '등록되지 않은 이메일 주소입니다. 접근 권한은 관리자에게 문의하세요.',
+        'auth.badRequest': '이메일 주소가 올바르지 않습니다.',
+        'auth.error': '오류가 발생했습니다. 다시 시도하세요.',
+
+        'status.active': '활성',
+        'status.inactive': '비활성',
+        'status.suspended': '정지',
+        'status.deleted': '삭제',
+
+        'verify.title': '{brand} - 이메일 인증',
+        'verify.heading': '이메일 인증',
+        'verify.subtitle': '인증 토큰을 확인하는 중...',
+        'verify.loading': '이메일 토큰을 확인하는 중...',
+        'verify.success': '인증되었습니다!',
+        'verify.welcome': '다시 오신 것을 환영합니다! 인증이 완료되었습니다.',
+        'verify.token': '액세스 토큰:',
+        'verify.copy': '클립보드에 복사',
+        'verify.tokenNote': '토큰은 30일 후 만료됩니다. 안전하게 보관하세요!',
+        'verify.continue': '대시보드로 이동',
+        'verify.failed': '인증 실패',
+        'verify.retry': '다시 시도',
+        'verify.newLink': '새 링크 요청',
+        'verify.invalidLink': '잘못된 인증 링크입니다. 토큰 또는 이메일이 없습니다.',
+        'verify.error': '인증에 실패했습니다',
+        'verify.email': '이메일:',
+        'verify.userId': '사용자 ID:',
+        'verify.status': '상태:',
+        'verify.copyManually': '토큰을 선택했습니다. 직접 복사하세요 (Ctrl+C).',
+
+        'change.title': '{brand} - 이메일 변경',
+        'change.heading': '이메일 변경',
+        'change.subtitle': '이메일 주소를 변경하는 중...',
+        'change.loading': '요청을 처리하는 중...',
+        'change.done': '완료!',
+        'change.signIn': '로그인',
+        'change.failed': '요청 실패',
+        'change.invalidLink': '잘못된 이메일 변경 링크입니다.',
+        'change.confirmHeading': '새 이메일 확인',
+        'change.revertHeading': '이메일 변경 취소',
+        'change.confirmed': '이메일이 변경되었습니다!',
+        'change.reverted': '이메일 변경이 취소되었습니다',
+        'change.error': '요청에 실패했습니다',
+
+        'unsubscribe.title': '{brand} - 수신 거부',
+        'unsubscribe.heading': '보안 알림',
+        'unsubscribe.subtitle': '이 종류의 보안 알림 이메일을 더 이상 받지 않으시겠습니까?',
+        'unsubscribe.submit': '수신 거부',
+        'unsubscribe.sending': '처리하는 중...',
+        'unsubscribe.done': '수신 거부 완료',
+        'unsubscribe.doneMessage': '더 이상 {notification}에 대한 이메일을 받지 않습니다. 이메일 변경과 계정 정지 알림은 항상 발송됩니다.',
+        'unsubscribe.failed': '요청 실패',
+        'unsubscribe.invalidLink': '잘못된 수신 거부 링크입니다.',
+        'unsubscribe.error': '요청에 실패했습니다',
+        'notification.NEW_DEVICE_LOGIN': '새 기기 로그인',
+        'notification.SESSIONS_REVOKED': '전체 기기 로그아웃',
+        'notification.SERVICE_ACCESS_CHANGED': '서비스 권한 변경',
+
+        'passkey.signIn': '패스키로 로그인',
+        'passkey.failed': '패스키 로그인에 실패했습니다. 이메일로 로그인하거나 다른 패스키를 사용하세요.',
+        'passkey.add': '빠른 로그인을 위해 패스키 추가',
+        'passkey.added': '패스키를 추가했습니다! 다음부터는 이메일을 기다리지 않고 패스키로 로그인하세요.',
+        'passkey.exists': '이 기기에는 이미 계정의 패스키가 있습니다.',
+        'passkey.addFailed': '패스키를 추가하지 못했습니다. 다시 시도하세요.',
+        'passkey.error': '패스키 요청에 실패했습니다',
+
+        'network.error': '네트워크 오류입니다. 연결을 확인한 후 다시 시도하세요.'
+    }
+};
+
+const I18N_DEFAULT = 'en';
+
+// Values available to every message, updated by brand.js once the app's profile is loaded
+const I18N_VARS = { brand: 'Luna4' };
+
+// detectLocale picks the first supported language from the lang query parameter (set on
+// links in emails), the last language used on this browser and the browser languages
+function detectLocale() {
+    const candidates = [
+        new URLSearchParams(window.location.search).get('lang'),
+        localStorage.getItem('luna4_lang'),
+        ...(navigator.languages || [navigator.language])
+    ];
+
+    for (const candidate of candidates) {
+        if (!candidate) {
+            continue;
+        }
+        const tag = candidate.toLowerCase();
+        if (I18N_MESSAGES[tag]) {
+            return tag;
+        }
+        const base = tag.split('-')[0];
+        if (I18N_MESSAGES[base]) {
+            return base;
+        }
+    }
+    return I18N_DEFAULT;
+}
+
+const I18N_LOCALE = detectLocale();
+localStorage.setItem('luna4_lang', I18N_LOCALE);
+document.documentElement.lang = I18N_LOCALE;
+
+// t translates a key, replacing {name} placeholders with vars and I18N_VARS. Keys missing
+// from the locale fall back to English.
+function t(key, vars = {}) {
+    vars = { ...I18N_VARS, ...vars };
+    const text = I18N_MESSAGES[I18N_LOCALE][key] ?? I18N_MESSAGES[I18N_DEFAULT][key] ?? key;
+    return text.replace(/\{(\w+)\}/g, (match, name) => (name in vars ? vars[name] : match));
+}
+
+function applyTranslations() {
+    document.querySelectorAll('[data-i18n]').forEach((el) => {
+        el.textContent = t(el.dataset.i18n);
+    });
+    document.querySelectorAll('[data-i18n-placeholder]').forEach((el) => {
+        el.placeholder = t(el.dataset.i18nPlaceholder);
+    });
+    document.querySelectorAll('[data-i18n-title]').forEach((el) => {
+        el.title = t(el.dataset.i18nTitle);
+    });
+}
+
+document.addEventListener('DOMContentLoaded', applyTranslations);
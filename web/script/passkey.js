@@ -0,0 +1,99 @@
+// Passkey registration and sign in. The server sends WebAuthn options and expects
+// credentials in their JSON form, with binary values base64url encoded.
+
+function passkeySupported() {
+    return !!(window.PublicKeyCredential && navigator.credentials);
+}
+
+function base64urlToBuffer(value) {
+    const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+    const binary = atob(base64 + '='.repeat((4 - base64.length % 4) % 4));
+    return Uint8Array.from(binary, (c) => c.charCodeAt(0)).buffer;
+}
+
+function bufferToBase64url(buffer) {
+    const binary = String.fromCharCode(...new Uint8Array(buffer));
+    return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+function decodeDescriptors(descriptors) {
+    return (descriptors || []).map((descriptor) => ({ ...descriptor, id: base64urlToBuffer(descriptor.id) }));
+}
+
+// credentialToJSON encodes a PublicKeyCredential, for browsers without toJSON()
+function credentialToJSON(credential) {
+    if (typeof credential.toJSON === 'function') {
+        return credential.toJSON();
+    }
+
+    const response = credential.response;
+    const json = {
+        id: credential.id,
+        rawId: bufferToBase64url(credential.rawId),
+        type: credential.type,
+        response: { clientDataJSON: bufferToBase64url(response.clientDataJSON) }
+    };
+    if (response.attestationObject) {
+        json.response.attestationObject = bufferToBase64url(response.attestationObject);
+        json.response.transports = response.getTransports ? response.getTransports() : [];
+    } else {
+        json.response.authenticatorData = bufferToBase64url(response.authenticatorData);
+        json.response.signature = bufferToBase64url(response.signature);
+        if (response.userHandle) {
+            json.response.userHandle = bufferToBase64url(response.userHandle);
+        }
+    }
+    return json;
+}
+
+async function postJSON(url, body, token) {
+    const headers = { 'Content-Type': 'application/json' };
+    if (token) {
+        headers['Authorization'] = `Bearer ${token}`;
+    }
+    const response = await fetch(url, { method: 'POST', headers, body: body ? JSON.stringify(body) : undefined });
+    const data = await response.json();
+    if (!response.ok) {
+        const error = new Error(data.error || t('passkey.error'));
+        error.status = response.status;
+        error.data = data;
+        throw error;
+    }
+    return data;
+}
+
+// registerPasskey creates a passkey on this device for the user signed in with token
+async function registerPasskey(token, name) {
+    const { publicKey } = await postJSON('/api/account/passkey/options', null, token);
+    publicKey.challenge = base64urlToBuffer(publicKey.challenge);
+    publicKey.user.id = base64urlToBuffer(publicKey.user.id);
+    publicKey.excludeCredentials = decodeDescriptors(publicKey.excludeCredentials);
+
+    const credential = await navigator.credentials.create({ publicKey });
+    return postJSON('/api/account/passkey', { name, credential: credentialToJSON(credential) }, token);
+}
+
+// signInWithPasskey lets the user pick one of their passkeys and returns the new session,
+// shaped like the email verification response
+async function signInWithPasskey() {
+    const { publicKey } = await postJSON('/api/auth/passkey/options');
+    publicKey.challenge = base64urlToBuffer(publicKey.challenge);
+    publicKey.allowCredentials = decodeDescriptors(publicKey.allowCredentials);
+
+    const credential = await navigator.credentials.get({ publicKey });
+    return postJSON('/api/auth/passkey', credentialToJSON(credential));
+}
+
+// storeSession keeps the access token for the application, as email verification does
+function storeSession(data) {
+    localStorage.setItem('luna4_access_token', data.access_token);
+    localStorage.setItem('luna4_token_type', data.token_type || 'Bearer');
+    localStorage.setItem('luna4_expires_in', data.expires_in || 2592000);
+    localStorage.setItem('luna4_user', JSON.stringify(data.user));
+}
+
+// defaultPasskeyName names a passkey after the platform it was created on
+function defaultPasskeyName() {
+    const platform = navigator.userAgentData?.platform || navigator.platform || '';
+    return platform ? `${platform} passkey` : 'Passkey';
+}
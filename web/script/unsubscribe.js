@@ -0,0 +1,74 @@
+class Unsubscribe {
+    constructor() {
+        this.form = document.getElementById('unsubscribe-form');
+        this.submitBtn = document.getElementById('submit-btn');
+        this.btnText = this.submitBtn.querySelector('.btn-text');
+        this.btnLoader = this.submitBtn.querySelector('.btn-loader');
+        this.successContentEl = document.getElementById('success-content');
+        this.successMessageEl = document.getElementById('success-message');
+        this.errorContentEl = document.getElementById('error-content');
+        this.errorMessageEl = document.getElementById('error-message');
+
+        this.init();
+    }
+
+    init() {
+        const urlParams = new URLSearchParams(window.location.search);
+        this.token = urlParams.get('token');
+
+        if (!this.token) {
+            this.showError(t('unsubscribe.invalidLink'));
+            return;
+        }
+
+        // Unsubscribing waits for a click, so link scanners opening the page change nothing
+        this.form.addEventListener('submit', (e) => this.handleSubmit(e));
+    }
+
+    async handleSubmit(e) {
+        e.preventDefault();
+        this.setLoading(true);
+
+        try {
+            const response = await fetch(`/api/account/notifications/unsubscribe?token=${encodeURIComponent(this.token)}`, {
+                method: 'POST'
+            });
+
+            const data = await response.json();
+
+            if (response.ok) {
+                this.showSuccess(t('unsubscribe.doneMessage', { notification: t('notification.' + data.notification) }));
+            } else {
+                this.showError(data.error || t('unsubscribe.error'));
+            }
+        } catch (error) {
+            console.error('Unsubscribe error:', error);
+            this.showError(t('network.error'));
+        }
+    }
+
+    setLoading(loading) {
+        this.submitBtn.disabled = loading;
+        this.btnText.style.display = loading ? 'none' : 'block';
+        this.btnLoader.style.display = loading ? 'flex' : 'none';
+    }
+
+    showSuccess(message) {
+        this.form.style.display = 'none';
+        this.errorContentEl.style.display = 'none';
+        this.successContentEl.style.display = 'block';
+        this.successMessageEl.textContent = message;
+    }
+
+    showError(errorMessage) {
+        this.form.style.display = 'none';
+        this.successContentEl.style.display = 'none';
+        this.errorContentEl.style.display = 'block';
+        this.errorMessageEl.textContent = errorMessage;
+    }
+}
+
+// Initialize when DOM is loaded
+document.addEventListener('DOMContentLoaded', () => {
+    new Unsubscribe();
+});
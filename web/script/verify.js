@@ -9,6 +9,8 @@ class EmailVerification {
         this.copyTokenBtn = document.getElementById('copy-token');
         this.continueBtn = document.getElementById('continue-btn');
         this.retryBtn = document.getElementById('retry-btn');
+        this.passkeyBtn = document.getElementById('passkey-btn');
+        this.passkeyMessageEl = document.getElementById('passkey-message');
         
         this.init();
     }
@@ -20,7 +22,7 @@ class EmailVerification {
         const email = urlParams.get('email');
         
         if (!token || !email) {
-            this.showError('Invalid verification link. Missing token or email parameters.');
+            this.showError(t('verify.invalidLink'));
             return;
         }
         
@@ -35,6 +37,7 @@ class EmailVerification {
         this.copyTokenBtn?.addEventListener('click', () => this.copyTokenToClipboard());
         this.continueBtn?.addEventListener('click', () => this.redirectToDashboard());
         this.retryBtn?.addEventListener('click', () => this.retryVerification());
+        this.passkeyBtn?.addEventListener('click', () => this.addPasskey());
     }
     
     async verifyToken(token, email) {
@@ -51,11 +54,11 @@ class EmailVerification {
             if (response.ok) {
                 this.showSuccess(data);
             } else {
-                this.showError(data.error || 'Verification failed');
+                this.showError(data.error || t('verify.error'));
             }
         } catch (error) {
             console.error('Verification error:', error);
-            this.showError('Network error. Please check your connection and try again.');
+            this.showError(t('network.error'));
         }
     }
     
@@ -68,9 +71,9 @@ class EmailVerification {
         if (data.user) {
             this.userInfoEl.innerHTML = `
                 <div class="user-details">
-                    <p><strong>Email:</strong> ${data.user.email}</p>
-                    <p><strong>User ID:</strong> ${data.user.id}</p>
-                    <p><strong>Status:</strong> <span class="status ${data.user.status.toLowerCase()}">${data.user.status}</span></p>
+                    <p><strong>${t('verify.email')}</strong> ${data.user.email}</p>
+                    <p><strong>${t('verify.userId')}</strong> ${data.user.id}</p>
+                    <p><strong>${t('verify.status')}</strong> <span class="status ${data.user.status.toLowerCase()}">${data.user.status}</span></p>
                 </div>
             `;
         }
@@ -80,16 +83,46 @@ class EmailVerification {
             this.accessTokenEl.textContent = data.access_token;
             
             // Store token in localStorage for the application
-            localStorage.setItem('luna4_access_token', data.access_token);
-            localStorage.setItem('luna4_token_type', data.token_type || 'Bearer');
-            localStorage.setItem('luna4_expires_in', data.expires_in || 2592000);
-            localStorage.setItem('luna4_user', JSON.stringify(data.user));
+            storeSession(data);
+
+            // Offer a passkey so the next sign in needs no email
+            if (passkeySupported()) {
+                this.passkeyBtn.style.display = 'block';
+            }
         }
 
         // Auto-redirect
         // this.redirectToDashboard();
     }
     
+    async addPasskey() {
+        this.passkeyBtn.disabled = true;
+
+        try {
+            await registerPasskey(localStorage.getItem('luna4_access_token'), defaultPasskeyName());
+            this.passkeyBtn.style.display = 'none';
+            this.showPasskeyMessage(t('passkey.added'), 'success');
+        } catch (error) {
+            this.passkeyBtn.disabled = false;
+            if (error.name === 'NotAllowedError' || error.name === 'AbortError') {
+                return; // The user closed the passkey prompt
+            }
+            console.error('Passkey registration error:', error);
+            if (error.name === 'InvalidStateError' || error.status === 409) {
+                this.passkeyBtn.style.display = 'none';
+                this.showPasskeyMessage(t('passkey.exists'), 'warning');
+            } else {
+                this.showPasskeyMessage(error.status ? t('passkey.addFailed') : t('network.error'), 'error');
+            }
+        }
+    }
+
+    showPasskeyMessage(text, type) {
+        this.passkeyMessageEl.textContent = text;
+        this.passkeyMessageEl.className = `message ${type}`;
+        this.passkeyMessageEl.style.display = 'block';
+    }
+
     showError(errorMessage) {
         this.verificationStatusEl.style.display = 'none';
         this.successContentEl.style.display = 'none';
@@ -121,7 +154,7 @@ class EmailVerification {
             window.getSelection().removeAllRanges();
             window.getSelection().addRange(range);
             
-            alert('Token selected. Please copy manually (Ctrl+C).');
+            alert(t('verify.copyManually'));
         }
     }
     